@@ -0,0 +1,246 @@
+package exec
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/dop251/goja"
+	"github.com/sirupsen/logrus"
+	k6execution "go.k6.io/k6/execution"
+	"go.k6.io/k6/js/common"
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/lib/types"
+)
+
+// defaultAbortHandlerTimeout is how long abort handlers are allowed to run by default.
+const defaultAbortHandlerTimeout = 30 * time.Second
+
+// abortHandlers holds the commands run once the test is aborted, registered by all
+// the VUs of the test. As each VU registers the same handlers, they are deduplicated.
+type abortHandlers struct {
+	mu       sync.Mutex
+	keys     map[string]bool
+	handlers []*detachedCommand
+
+	// ctx is the context watched for the test run being aborted.
+	ctx context.Context
+
+	runOnce   sync.Once
+	watchOnce sync.Once
+}
+
+// detachedCommand is a command run once the VU it was built by is done, such as an abort
+// handler, or a cleanup command. It is built beforehand, so that running it doesn't involve
+// the JS runtime of the VU, and it isn't bound to the VU context.
+type detachedCommand struct {
+	name    string
+	cmd     *exec.Cmd
+	cancel  context.CancelFunc
+	timeout time.Duration
+	logger  logrus.FieldLogger
+}
+
+// detach builds the command, with the default options, into a detached command
+// allowed to run for timeout.
+func (c *Command) detach(timeout time.Duration) (*detachedCommand, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	opts, _ := parseExecOptions(c.vu.Runtime(), nil)
+	cmd, err := c.build(ctx, opts)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	closeFiles(cmd.ExtraFiles...)
+	cmd.ExtraFiles = nil
+
+	return &detachedCommand{
+		name:    c.Name,
+		cmd:     cmd,
+		cancel:  cancel,
+		timeout: timeout,
+		logger:  c.logger(),
+	}, nil
+}
+
+// add registers handler, unless a handler with the same key already was.
+func (ah *abortHandlers) add(key string, handler *detachedCommand) {
+	ah.mu.Lock()
+	defer ah.mu.Unlock()
+
+	if ah.keys[key] {
+		handler.cancel()
+		return
+	}
+
+	if ah.keys == nil {
+		ah.keys = make(map[string]bool)
+	}
+	ah.keys[key] = true
+	ah.handlers = append(ah.handlers, handler)
+}
+
+// run runs the registered handlers, in the order they were registered, once.
+func (ah *abortHandlers) run(reason error) {
+	ah.runOnce.Do(func() {
+		ah.mu.Lock()
+		handlers := ah.handlers
+		ah.mu.Unlock()
+
+		for _, handler := range handlers {
+			logger := handler.logger.WithField("reason", reason.Error())
+			logger.Infof("the test was aborted, running the %s abort handler", handler.name)
+
+			if err := handler.run(); err != nil {
+				logger.WithError(err).Warnf("the %s abort handler failed", handler.name)
+			}
+		}
+	})
+}
+
+// run runs the command, killing it if it runs for longer than its timeout. The returned
+// error includes the end of the command's output, if it failed.
+func (d *detachedCommand) run() error {
+	defer d.cancel()
+
+	var output bytes.Buffer
+	d.cmd.Stdout, d.cmd.Stderr = &output, &output
+
+	timer := time.AfterFunc(d.timeout, d.cancel)
+	err := d.cmd.Run()
+	timer.Stop()
+
+	if err != nil && output.Len() > 0 {
+		return fmt.Errorf("%w\noutput:\n%s", err, stderrSnippet(output.Bytes()))
+	}
+
+	return err
+}
+
+// OnAbort registers a command, either a Cmd or an array holding the name of the command
+// followed by its arguments, run once the test is aborted or interrupted, for instance to
+// tear down infrastructure the script created. It is run once per test, even when every
+// VU registers it, and even when iterations are cancelled. It is run, at the latest, before
+// teardown() or, if it isn't exported, handleSummary() runs, k6 waiting for it to complete;
+// otherwise, as when k6 is run with --no-summary, its execution is best-effort.
+func (mi *ModuleInstance) OnAbort(spec goja.Value, options goja.Value) {
+	rt := mi.vu.Runtime()
+
+	command, ok := mi.commandFromSpec(spec)
+	if !ok {
+		common.Throw(rt, errors.New("onAbort expects a Cmd, or an array holding a command and its arguments"))
+	}
+
+	timeout, err := parseAbortHandlerOptions(rt, options)
+	if err != nil {
+		common.Throw(rt, err)
+	}
+
+	if reason := prohibitedReason(command.config.resolve(mi.vu)); reason != "" {
+		if _, err := command.prohibited(reason); err != nil {
+			command.throw(err)
+		}
+		return
+	}
+
+	handler, err := command.detach(timeout)
+	if err != nil {
+		common.Throw(rt, err)
+	}
+	mi.aborts.add(command.key(), handler)
+	mi.aborts.watch(mi.vu.Context(), mi.testRun)
+}
+
+// watch runs the registered handlers once the test run ctx is derived from is aborted. It only
+// watches the first context it is called with which can be done, that is neither the one the
+// options are evaluated with, nor one outside of a test run.
+//
+// The contexts of the VUs are derived from the one of the test run, which holds why it was
+// aborted. They are done before it though: the one of the init context once the VUs were
+// initialized, and the ones of iterations once they ended. The reason is thus polled once
+// ctx is done, until the test is aborted, or until tr, the test run, ended, which checks it
+// a last time, synchronously.
+func (ah *abortHandlers) watch(ctx context.Context, tr *testRun) {
+	if ctx == nil || ctx.Done() == nil {
+		return
+	}
+
+	ah.watchOnce.Do(func() {
+		ah.mu.Lock()
+		ah.ctx = ctx
+		ah.mu.Unlock()
+		tr.watch(lib.GetExecutionState(ctx))
+
+		go func() {
+			<-ctx.Done()
+
+			ticker := time.NewTicker(testEndPollInterval)
+			defer ticker.Stop()
+
+			for !tr.hasEnded() && !ah.runIfAborted() {
+				<-ticker.C
+			}
+		}()
+	})
+}
+
+// runIfAborted runs the registered handlers if the test run the watched context is derived
+// from was aborted, and returns true then.
+func (ah *abortHandlers) runIfAborted() bool {
+	ah.mu.Lock()
+	ctx := ah.ctx
+	ah.mu.Unlock()
+
+	if ctx == nil {
+		return false
+	}
+
+	reason := k6execution.GetCancelReasonIfTestAborted(ctx)
+	if reason == nil {
+		return false
+	}
+	ah.run(reason)
+
+	return true
+}
+
+// parseAbortHandlerOptions parses the options object optionally passed to
+// OnAbort, and returns how long the handler is allowed to run.
+func parseAbortHandlerOptions(rt *goja.Runtime, v goja.Value) (time.Duration, error) {
+	timeout := defaultAbortHandlerTimeout
+	if common.IsNullish(v) {
+		return timeout, nil
+	}
+
+	obj := v.ToObject(rt)
+	for _, key := range obj.Keys() {
+		switch key {
+		case "timeout":
+			d, err := types.GetDurationValue(obj.Get(key).Export())
+			if err != nil {
+				return 0, fmt.Errorf("invalid timeout: %w", err)
+			}
+			timeout = d
+		default:
+			return 0, fmt.Errorf("unknown onAbort option %q", key)
+		}
+	}
+
+	return timeout, nil
+}
+
+// key returns a string identifying the command line and environment of the command.
+func (c *Command) key() string {
+	env := make([]string, 0, len(c.env))
+	for k, v := range c.env {
+		env = append(env, k+"="+v)
+	}
+	sort.Strings(env)
+
+	return quoteArgs(append(env, append([]string{c.Name}, c.args...)...))
+}
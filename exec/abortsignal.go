@@ -0,0 +1,109 @@
+package exec
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/dop251/goja"
+	"go.k6.io/k6/js/common"
+)
+
+// errAborted is wrapped by the errors of the executions which weren't started,
+// as the AbortSignal passed as their signal option was already aborted.
+var errAborted = errors.New("the operation was aborted")
+
+// abortSignal is an AbortSignal, or an object implementing its interface, such as the ones of
+// polyfills, cancelling the executions it is passed to, as their signal option, once aborted.
+type abortSignal struct {
+	obj *goja.Object
+}
+
+// parseAbortSignal parses the signal option, which must implement the interface of AbortSignal.
+func parseAbortSignal(rt *goja.Runtime, v goja.Value) (*abortSignal, error) {
+	if common.IsNullish(v) {
+		return nil, nil
+	}
+
+	obj, ok := v.(*goja.Object)
+	if ok {
+		_, ok = goja.AssertFunction(obj.Get("addEventListener"))
+	}
+	if !ok || obj.Get("aborted") == nil {
+		return nil, errors.New("invalid signal option; expected an AbortSignal")
+	}
+
+	return &abortSignal{obj: obj}, nil
+}
+
+// aborted returns true if the signal was aborted.
+func (s *abortSignal) aborted() bool {
+	return s.obj.Get("aborted").ToBoolean()
+}
+
+// reason returns the reason the signal was aborted for, if any.
+func (s *abortSignal) reason() string {
+	reason := s.obj.Get("reason")
+	if common.IsNullish(reason) {
+		return ""
+	}
+
+	return reason.String()
+}
+
+// onAbort registers fn to be called, on the event loop, once the signal is aborted.
+func (s *abortSignal) onAbort(rt *goja.Runtime, fn func()) error {
+	addEventListener, _ := goja.AssertFunction(s.obj.Get("addEventListener"))
+
+	listener := func(goja.FunctionCall) goja.Value {
+		fn()
+		return goja.Undefined()
+	}
+
+	options := rt.NewObject()
+	if err := options.Set("once", true); err != nil {
+		return err
+	}
+
+	_, err := addEventListener(s.obj, rt.ToValue("abort"), rt.ToValue(listener), options)
+	return err
+}
+
+// watchAbortSignal makes the started execution be killed once the AbortSignal passed as its
+// signal option is aborted, if any, unless it exited by then.
+func (e *execution) watchAbortSignal() {
+	signal := e.opts.signal
+	if signal == nil {
+		return
+	}
+
+	err := signal.onAbort(e.command.vu.Runtime(), func() {
+		select {
+		case <-e.exited:
+		default:
+			e.killMu.Lock()
+			if e.killReason == "" {
+				e.abortReason = signal.reason()
+			}
+			e.killMu.Unlock()
+
+			e.kill(killReasonAborted)
+		}
+	})
+	if err != nil {
+		e.logger().WithError(err).Warn("unable to watch the signal option of " + e.command.Name)
+	}
+}
+
+// abortedBeforeStart returns the error executions fail with when the AbortSignal passed
+// as their signal option was already aborted before they were started, if it was.
+func (c *Command) abortedBeforeStart(opts *execOptions) error {
+	if opts.signal == nil || !opts.signal.aborted() {
+		return nil
+	}
+
+	if reason := opts.signal.reason(); reason != "" {
+		return fmt.Errorf("executing %q: %w: %s", c.Name, errAborted, reason)
+	}
+
+	return fmt.Errorf("executing %q: %w", c.Name, errAborted)
+}
@@ -0,0 +1,94 @@
+package exec
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"go.k6.io/k6/js/common"
+)
+
+// azureExitCodeMarker prefixes the exit code of commands run on Azure VMs, written
+// at the end of their standard error, as Run Command doesn't report it.
+const azureExitCodeMarker = "k6-exec-exit-code="
+
+// azureExitCodePattern matches the exit code written at the end of the standard error.
+var azureExitCodePattern = regexp.MustCompile(`\n?` + azureExitCodeMarker + `(\d+)\n?$`) //nolint:gochecknoglobals
+
+// AzureVMOptions configures the Azure virtual machine a command is run on, using Run Command.
+type AzureVMOptions struct {
+	// ResourceGroup is the resource group of the virtual machine.
+	ResourceGroup string `js:"resourceGroup"`
+
+	// Name is the name of the virtual machine.
+	Name string `js:"name"`
+
+	// Subscription is the subscription of the virtual machine,
+	// if not the default one of the Azure CLI.
+	Subscription string `js:"subscription"`
+}
+
+// AzureVM returns a copy of the command run on an Azure Linux virtual machine,
+// through Run Command, using the Azure CLI, rather than locally.
+func (c Command) AzureVM(opts AzureVMOptions) Command {
+	if opts.ResourceGroup == "" || opts.Name == "" {
+		common.Throw(c.vu.Runtime(), errors.New("azureVM expects a resourceGroup and a name"))
+	}
+
+	c.azure = &opts
+	return c
+}
+
+// wrap returns the executable and arguments running the named command, along with
+// the provided environment variables, on the virtual machine.
+func (o *AzureVMOptions) wrap(name string, args []string, env map[string]string) (string, []string) {
+	script := remoteCommandLine(name, args, env) + "; echo " + azureExitCodeMarker + "$? >&2"
+
+	wrapped := []string{
+		"vm", "run-command", "invoke",
+		"--resource-group", o.ResourceGroup,
+		"--name", o.Name,
+		"--command-id", "RunShellScript",
+		"--scripts", script,
+		"--output", "json",
+	}
+	if o.Subscription != "" {
+		wrapped = append(wrapped, "--subscription", o.Subscription)
+	}
+
+	return "az", wrapped
+}
+
+// unwrap extracts the output and exit code of the command from the Run Command
+// result written by the Azure CLI to its standard output.
+func (o *AzureVMOptions) unwrap(result []byte) (stdout, stderr []byte, exitCode int, err error) {
+	var invocation struct {
+		Value []struct {
+			Code    string `json:"code"`
+			Message string `json:"message"`
+		} `json:"value"`
+	}
+	if err := json.Unmarshal(result, &invocation); err != nil || len(invocation.Value) == 0 {
+		return nil, nil, 0, fmt.Errorf("unable to parse the Run Command result of %s: %w", o.Name, err)
+	}
+
+	message := invocation.Value[0].Message
+	if i := strings.Index(message, "[stdout]\n"); i >= 0 {
+		message = message[i+len("[stdout]\n"):]
+	}
+
+	out, errOut, _ := strings.Cut(message, "[stderr]\n")
+	out = strings.TrimSuffix(out, "\n")
+
+	m := azureExitCodePattern.FindStringSubmatchIndex(errOut)
+	if m == nil {
+		return nil, nil, 0, fmt.Errorf("unable to determine the exit code of the command run on %s: %s",
+			o.Name, invocation.Value[0].Code)
+	}
+	exitCode, _ = strconv.Atoi(errOut[m[2]:m[3]])
+
+	return []byte(out), []byte(errOut[:m[0]]), exitCode, nil
+}
@@ -0,0 +1,88 @@
+package exec
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/dop251/goja"
+)
+
+// capabilityNames holds the names of the Linux capabilities, without
+// their CAP_ prefix, indexed by their number.
+var capabilityNames = []string{ //nolint:gochecknoglobals
+	"CHOWN", "DAC_OVERRIDE", "DAC_READ_SEARCH", "FOWNER", "FSETID", "KILL", "SETGID", "SETUID",
+	"SETPCAP", "LINUX_IMMUTABLE", "NET_BIND_SERVICE", "NET_BROADCAST", "NET_ADMIN", "NET_RAW",
+	"IPC_LOCK", "IPC_OWNER", "SYS_MODULE", "SYS_RAWIO", "SYS_CHROOT", "SYS_PTRACE", "SYS_PACCT",
+	"SYS_ADMIN", "SYS_BOOT", "SYS_NICE", "SYS_RESOURCE", "SYS_TIME", "SYS_TTY_CONFIG", "MKNOD",
+	"LEASE", "AUDIT_WRITE", "AUDIT_CONTROL", "SETFCAP", "MAC_OVERRIDE", "MAC_ADMIN", "SYSLOG",
+	"WAKE_ALARM", "BLOCK_SUSPEND", "AUDIT_READ", "PERFMON", "BPF", "CHECKPOINT_RESTORE",
+}
+
+// parseCapabilities parses the dropCapabilities and keepCapabilities options, arrays of
+// capability names, with or without their CAP_ prefix, or ALL, and returns their numbers.
+func parseCapabilities(rt *goja.Runtime, option string, v goja.Value) ([]int, error) {
+	var names []string
+	if err := rt.ExportTo(v, &names); err != nil {
+		return nil, fmt.Errorf("%s must be an array of capability names: %w", option, err)
+	}
+
+	caps := make([]int, 0, len(names))
+	for _, name := range names {
+		normalized := strings.TrimPrefix(strings.ToUpper(name), "CAP_")
+		if normalized == "ALL" {
+			for c := range capabilityNames {
+				caps = append(caps, c)
+			}
+			continue
+		}
+
+		c := indexOf(capabilityNames, normalized)
+		if c < 0 {
+			return nil, fmt.Errorf("unknown capability %q in %s", name, option)
+		}
+		caps = append(caps, c)
+	}
+
+	return caps, nil
+}
+
+// droppedCapabilities returns the numbers of the capabilities the options drop: those listed in
+// dropCapabilities, or if only keepCapabilities is set, all but those, and never those kept.
+func (opts *execOptions) droppedCapabilities() []int {
+	if opts.dropCapabilities == nil && opts.keepCapabilities == nil {
+		return nil
+	}
+
+	dropped := opts.dropCapabilities
+	if dropped == nil {
+		dropped = make([]int, len(capabilityNames))
+		for c := range capabilityNames {
+			dropped[c] = c
+		}
+	}
+
+	kept := make(map[int]bool, len(opts.keepCapabilities))
+	for _, c := range opts.keepCapabilities {
+		kept[c] = true
+	}
+
+	caps := make([]int, 0, len(dropped))
+	for _, c := range dropped {
+		if !kept[c] {
+			caps = append(caps, c)
+		}
+	}
+
+	return caps
+}
+
+// indexOf returns the index of value in values, or -1 if it's not found.
+func indexOf(values []string, value string) int {
+	for i, v := range values {
+		if v == value {
+			return i
+		}
+	}
+
+	return -1
+}
@@ -0,0 +1,27 @@
+//go:build linux
+
+package exec
+
+import (
+	"errors"
+	"fmt"
+
+	"golang.org/x/sys/unix"
+)
+
+// dropCapabilities drops the provided capabilities from the bounding set of the calling
+// thread, so that the commands it starts, root-owned ones included, can't hold them.
+// Capabilities unknown to the running kernel are ignored.
+func dropCapabilities(caps []int) error {
+	for _, c := range caps {
+		err := unix.Prctl(unix.PR_CAPBSET_DROP, uintptr(c), 0, 0, 0)
+		if errors.Is(err, unix.EINVAL) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("unable to drop the CAP_%s capability: %w", capabilityNames[c], err)
+		}
+	}
+
+	return nil
+}
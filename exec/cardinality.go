@@ -0,0 +1,87 @@
+package exec
+
+import (
+	"strconv"
+	"sync"
+
+	"go.k6.io/k6/metrics"
+)
+
+// defaultMaxTagValues is the default cap on the number of distinct values of the tags guarded
+// against cardinality explosions.
+const defaultMaxTagValues = 100
+
+// overflowTagValue is the value guarded tags are set to once their cap was reached.
+const overflowTagValue = "other"
+
+// tagCardinality caps the number of distinct values of the high-cardinality tags the module sets
+// on metrics, such as executable and exit_code, so that long or pathological tests don't flood
+// the time series databases metrics are output to. It is shared by all the VUs of the test.
+type tagCardinality struct {
+	mu     sync.Mutex
+	values map[string]map[string]bool
+}
+
+// bucket returns value if it was already seen for tag, or if fewer than limit distinct
+// values were, and overflowTagValue otherwise. A limit of zero disables the cap.
+func (tc *tagCardinality) bucket(tag, value string, limit int) string {
+	if tc == nil || limit <= 0 {
+		return value
+	}
+
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+
+	seen := tc.values[tag]
+	if seen[value] {
+		return value
+	}
+
+	if len(seen) >= limit {
+		return overflowTagValue
+	}
+
+	if seen == nil {
+		if tc.values == nil {
+			tc.values = make(map[string]map[string]bool)
+		}
+		seen = make(map[string]bool)
+		tc.values[tag] = seen
+	}
+	seen[value] = true
+
+	return value
+}
+
+// withGuardedTag returns tags with the guarded tag key set to value, or to overflowTagValue
+// if the configured cap on the number of distinct values of the tag was reached.
+func (c *Command) withGuardedTag(tags *metrics.TagSet, key, value string) *metrics.TagSet {
+	return tags.With(key, c.cardinality.bucket(key, value, c.config.resolve(c.vu).maxTagValues))
+}
+
+// withCommandTags returns tags with the user-defined tags of the command set, along with the tags
+// identifying it: its executable, the shell it is run by, for scripts, the remote host it runs on,
+// over SSH, if it does, and the number of the attempt, if it is retried.
+func (c *Command) withCommandTags(tags *metrics.TagSet) *metrics.TagSet {
+	for key, value := range c.tags {
+		tags = tags.With(key, c.redact(value))
+	}
+
+	executable := c.Name
+	if c.shell {
+		tags = tags.With("shell", c.Name)
+		if name := scriptExecutable(c.script); name != "" {
+			executable = name
+		}
+	}
+
+	tags = c.withGuardedTag(tags, "executable", executable)
+	if c.ssh != nil {
+		tags = c.withGuardedTag(tags, "host", c.ssh.Host)
+	}
+	if c.attempt > 0 {
+		tags = tags.With("attempt", strconv.Itoa(c.attempt))
+	}
+
+	return tags
+}
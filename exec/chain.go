@@ -0,0 +1,149 @@
+package exec
+
+import (
+	"errors"
+
+	"github.com/dop251/goja"
+	"go.k6.io/k6/js/common"
+)
+
+// The operators chaining commands.
+const (
+	// chainAnd runs the next command only if the previous one succeeded, like the shell's &&.
+	chainAnd = "and"
+
+	// chainOr runs the next command only if the previous one failed, like the shell's ||.
+	chainOr = "or"
+)
+
+// chainLink is a command chained to the previous ones, and the operator chaining it.
+type chainLink struct {
+	op      string
+	command Command
+}
+
+// AndThen returns a copy of the command chained with next, which is only executed if
+// the command exits with a zero exit code, like the shell's && operator.
+func (c Command) AndThen(next Command) Command {
+	return c.chainWith(chainAnd, next)
+}
+
+// OrElse returns a copy of the command chained with next, which is only executed if
+// the command exits with a non-zero exit code, like the shell's || operator.
+func (c Command) OrElse(next Command) Command {
+	return c.chainWith(chainOr, next)
+}
+
+// chainWith returns a copy of the command with next chained using op. Chains are evaluated from
+// left to right, with the same short-circuit behavior as shell lists: a.andThen(b).orElse(c)
+// behaves like a && b || c.
+func (c Command) chainWith(op string, next Command) Command {
+	if len(next.chain) > 0 {
+		common.Throw(c.vu.Runtime(), errors.New("chains can't be nested; chain the commands one after the other instead"))
+	}
+
+	if len(c.pipeline) > 0 || len(next.pipeline) > 0 {
+		common.Throw(c.vu.Runtime(), errors.New("pipelines can't be chained"))
+	}
+
+	// The full slice expression makes appending copy the links,
+	// leaving the ones of the original command untouched.
+	c.chain = append(c.chain[:len(c.chain):len(c.chain)], chainLink{op: op, command: next})
+	return c
+}
+
+// execChain executes the chained commands one after the other, each one started from the event
+// loop once the previous one completed, and settles the promise with the result of the last one
+// which was executed, holding the results of all of them in its steps property. The options apply
+// to each command, but for throwOnError, which applies to the chain as a whole.
+func (c *Command) execChain(opts *execOptions, resolve, reject func(interface{})) {
+	first := *c
+	first.chain = nil
+	links := append([]chainLink{{command: first}}, c.chain...)
+
+	stepOpts := *opts
+	stepOpts.throwOnError = false
+
+	var (
+		results []*CommandResult
+		last    string
+		runFrom func(i int, succeeded bool)
+	)
+
+	finish := func() {
+		final := *results[len(results)-1]
+		final.steps = results
+
+		if opts.throwOnError && final.ExitCode != 0 {
+			reject(c.rejection(final.execError(last)))
+			return
+		}
+
+		resolve(&final)
+	}
+
+	runFrom = func(i int, succeeded bool) {
+		for ; i < len(links); i++ {
+			op := links[i].op
+			if op == "" || (op == chainAnd && succeeded) || (op == chainOr && !succeeded) {
+				break
+			}
+		}
+
+		if i == len(links) {
+			finish()
+			return
+		}
+
+		command := links[i].command
+		complete, err := command.run(&stepOpts)
+		if err != nil {
+			reject(c.rejection(err))
+			return
+		}
+
+		callback := c.vu.RegisterCallback()
+		go func() {
+			result, err := complete()
+			callback(func() error {
+				if err != nil {
+					reject(c.rejection(err))
+					return nil
+				}
+
+				results, last = append(results, result), command.Name
+				runFrom(i+1, result.ExitCode == 0)
+				return nil
+			})
+		}()
+	}
+
+	runFrom(0, true)
+}
+
+// execError returns the error a chain whose last executed command produced the
+// result fails with, when throwOnError is set.
+func (r *CommandResult) execError(name string) *ExecError {
+	err := &ExecError{Command: name, ExitCode: r.ExitCode}
+
+	if r.stderr != nil {
+		if b, berr := r.stderr.bytes(); berr == nil {
+			err.Stderr = stderrSnippet(b)
+		}
+	} else {
+		err.Stderr = stderrSnippet([]byte(r.Stderr))
+	}
+	err.Message = err.Error()
+
+	return err
+}
+
+// stepsJSValue returns the results of the chained commands which were executed, as a JS array.
+func (r *CommandResult) stepsJSValue(rt *goja.Runtime) goja.Value {
+	steps := make([]interface{}, len(r.steps))
+	for i, step := range r.steps {
+		steps[i] = step.toJSValue(rt)
+	}
+
+	return rt.NewArray(steps...)
+}
@@ -0,0 +1,47 @@
+package exec
+
+import (
+	"crypto/md5"  //nolint:gosec
+	"crypto/sha1" //nolint:gosec
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+)
+
+// checksumAlgorithms holds the hash constructors of the supported checksum algorithms.
+var checksumAlgorithms = map[string]func() hash.Hash{ //nolint:gochecknoglobals
+	"md5":    md5.New,
+	"sha1":   sha1.New,
+	"sha256": sha256.New,
+	"sha512": sha512.New,
+}
+
+// newChecksum returns a new hash for the named algorithm, or nil if
+// no algorithm is provided.
+func newChecksum(algorithm string) hash.Hash {
+	if algorithm == "" {
+		return nil
+	}
+
+	return checksumAlgorithms[algorithm]()
+}
+
+// validateChecksumAlgorithm returns an error if the named algorithm is not supported.
+func validateChecksumAlgorithm(algorithm string) error {
+	if _, ok := checksumAlgorithms[algorithm]; !ok {
+		return fmt.Errorf("unsupported checksum algorithm %q; expected one of md5, sha1, sha256 or sha512", algorithm)
+	}
+
+	return nil
+}
+
+// hexSum returns the hex encoded sum of h, or an empty string if h is nil.
+func hexSum(h hash.Hash) string {
+	if h == nil {
+		return ""
+	}
+
+	return hex.EncodeToString(h.Sum(nil))
+}
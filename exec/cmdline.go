@@ -0,0 +1,136 @@
+package exec
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/dop251/goja"
+	"go.k6.io/k6/js/common"
+)
+
+// shellOperators are the characters which, unquoted, would make a shell pipe, redirect, chain
+// or substitute commands, which command lines parsed without running a shell don't support.
+const shellOperators = "|&;<>()`$"
+
+// ParseCommand returns the command run by line, a command line split into the executable and its
+// arguments following the quoting rules of POSIX shells, without running a shell: words are
+// separated by blanks, single quotes preserve the characters they enclose, double quotes those
+// they enclose but backslashes escaping $, `, ", \ or a newline, and backslashes outside quotes
+// the character following them. As nothing is expanded, unquoted shell operators, such as pipes,
+// redirections or substitutions, throw. The options are the ones of the Cmd constructor, whose
+// args are appended to the ones of the command line.
+func (mi *ModuleInstance) ParseCommand(line string, options goja.Value) *Command {
+	rt := mi.vu.Runtime()
+
+	words, err := splitCommandLine(line)
+	if err != nil {
+		common.Throw(rt, fmt.Errorf("invalid command line: %w", err))
+	}
+	if len(words) == 0 {
+		common.Throw(rt, errors.New("parseCommand expects a non-empty command line"))
+	}
+
+	command := mi.newCommand(words[0])
+	command.args = append(command.args, words[1:]...)
+	if !common.IsNullish(options) {
+		*command = command.describe(options.ToObject(rt))
+	}
+
+	return command
+}
+
+// splitCommandLine splits line into words following the quoting rules of POSIX shells.
+func splitCommandLine(line string) ([]string, error) {
+	var (
+		words []string
+		word  strings.Builder
+
+		// inWord is true once the current word started, so that quoted empty strings are words.
+		inWord bool
+	)
+
+	runes := []rune(line)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+
+		switch {
+		case r == ' ' || r == '\t' || r == '\n':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		case r == '\\':
+			i++
+			if i == len(runes) {
+				return nil, errors.New("trailing backslash")
+			}
+			if runes[i] != '\n' {
+				word.WriteRune(runes[i])
+				inWord = true
+			}
+		case r == '\'':
+			end := indexRune(runes, i+1, '\'')
+			if end < 0 {
+				return nil, errors.New("unterminated single quote")
+			}
+			word.WriteString(string(runes[i+1 : end]))
+			inWord = true
+			i = end
+		case r == '"':
+			end, err := unquoteDouble(runes, i+1, &word)
+			if err != nil {
+				return nil, err
+			}
+			inWord = true
+			i = end
+		case strings.ContainsRune(shellOperators, r):
+			return nil, fmt.Errorf("unquoted %q at offset %d; shell operators aren't supported, "+
+				"as no shell is run, and must be quoted to be passed literally", r, i)
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+
+	if inWord {
+		words = append(words, word.String())
+	}
+
+	return words, nil
+}
+
+// unquoteDouble writes the content of the double-quoted string starting at runes[start] to word,
+// and returns the index of its closing quote.
+func unquoteDouble(runes []rune, start int, word *strings.Builder) (int, error) {
+	for i := start; i < len(runes); i++ {
+		switch r := runes[i]; {
+		case r == '"':
+			return i, nil
+		case r == '\\' && i+1 < len(runes) && strings.ContainsRune("$`\"\\\n", runes[i+1]):
+			i++
+			if runes[i] != '\n' {
+				word.WriteRune(runes[i])
+			}
+		case r == '$' || r == '`':
+			return 0, fmt.Errorf("unescaped %q at offset %d in double quotes; substitutions aren't supported, "+
+				"as no shell is run, and must be escaped or single-quoted to be passed literally", r, i)
+		default:
+			word.WriteRune(r)
+		}
+	}
+
+	return 0, errors.New("unterminated double quote")
+}
+
+// indexRune returns the index of the first r in runes from start on, or -1 if there is none.
+func indexRune(runes []rune, start int, r rune) int {
+	for i := start; i < len(runes); i++ {
+		if runes[i] == r {
+			return i
+		}
+	}
+
+	return -1
+}
@@ -0,0 +1,109 @@
+package exec
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSplitCommandLine(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		line    string
+		want    []string
+		wantErr string
+	}{
+		{name: "words", line: "ls -la /tmp", want: []string{"ls", "-la", "/tmp"}},
+		{name: "blanks", line: " \tls  \n -la\t", want: []string{"ls", "-la"}},
+		{name: "empty", line: "", want: nil},
+		{name: "only blanks", line: " \t\n", want: nil},
+		{name: "single quotes", line: `echo 'a  b' 'c\d' '"e"'`, want: []string{"echo", "a  b", `c\d`, `"e"`}},
+		{name: "double quotes", line: `echo "a  b" "'c'"`, want: []string{"echo", "a  b", "'c'"}},
+		{name: "quoted empty words", line: `a '' ""`, want: []string{"a", "", ""}},
+		{name: "adjacent quotes", line: `a'b'"c"d`, want: []string{"abcd"}},
+		{name: "escaped blank", line: `touch my\ file`, want: []string{"touch", "my file"}},
+		{name: "escaped quote", line: `echo \'a\"`, want: []string{"echo", `'a"`}},
+		{name: "line continuation", line: "echo a\\\nb", want: []string{"echo", "ab"}},
+		{name: "quoted operators", line: `sh -c 'a | b && c > d'`, want: []string{"sh", "-c", "a | b && c > d"}},
+		{name: "escaped operator", line: `echo \|`, want: []string{"echo", "|"}},
+		{name: "unicode", line: `écho "wörld"`, want: []string{"écho", "wörld"}},
+		{name: "trailing backslash", line: `echo a\`, wantErr: "trailing backslash"},
+		{name: "unterminated single quote", line: `echo 'a`, wantErr: "unterminated single quote"},
+		{name: "unterminated double quote", line: `echo "a`, wantErr: "unterminated double quote"},
+		{name: "pipe", line: "ls | wc", wantErr: `unquoted '|' at offset 3`},
+		{name: "redirection", line: "ls >out", wantErr: `unquoted '>' at offset 3`},
+		{name: "chaining", line: "a;b", wantErr: `unquoted ';' at offset 1`},
+		{name: "substitution", line: "echo $HOME", wantErr: `unquoted '$' at offset 5`},
+		{name: "substitution in double quotes", line: `echo "$HOME"`, wantErr: `unescaped '$' at offset 6 in double quotes`},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := splitCommandLine(tt.line)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("splitCommandLine(%q) error = %v, want it to contain %q", tt.line, err, tt.wantErr)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("splitCommandLine(%q) error = %v", tt.line, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("splitCommandLine(%q) = %q, want %q", tt.line, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnquoteDouble(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		quoted  string
+		want    string
+		wantEnd int
+		wantErr string
+	}{
+		{name: "plain", quoted: `ab"cd`, want: "ab", wantEnd: 2},
+		{name: "empty", quoted: `"`, want: "", wantEnd: 0},
+		{name: "escaped specials", quoted: `\$\` + "`" + `\"\\"`, want: "$`\"\\", wantEnd: 8},
+		{name: "escaped newline", quoted: "a\\\nb\"", want: "ab", wantEnd: 4},
+		{name: "other escapes kept", quoted: `\n\t"`, want: `\n\t`, wantEnd: 4},
+		{name: "single quotes kept", quoted: `'a'"`, want: "'a'", wantEnd: 3},
+		{name: "unterminated", quoted: `abc`, wantErr: "unterminated double quote"},
+		{name: "escaped closing quote", quoted: `abc\"`, wantErr: "unterminated double quote"},
+		{name: "substitution", quoted: `a$(b)"`, wantErr: `unescaped '$' at offset 1`},
+		{name: "backquote", quoted: "`b`\"", wantErr: "unescaped '`' at offset 0"},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var word strings.Builder
+			end, err := unquoteDouble([]rune(tt.quoted), 0, &word)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("unquoteDouble(%q) error = %v, want it to contain %q", tt.quoted, err, tt.wantErr)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unquoteDouble(%q) error = %v", tt.quoted, err)
+			}
+			if end != tt.wantEnd || word.String() != tt.want {
+				t.Errorf("unquoteDouble(%q) = %d, %q, want %d, %q", tt.quoted, end, word.String(), tt.wantEnd, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,38 @@
+package exec
+
+import (
+	"fmt"
+
+	"github.com/dop251/goja"
+)
+
+// stringify returns the string v, a command argument or the value of an environment variable,
+// stands for: strings are used as is, while numbers and booleans are stringified the way String
+// does in JS, so that scripts don't need to convert them. Other values, null and undefined
+// included, are rejected rather than silently turning into "null" or "[object Object]".
+func stringify(v goja.Value) (string, error) {
+	if v != nil {
+		switch v.Export().(type) {
+		case string, int64, float64, bool:
+			return v.String(), nil
+		}
+	}
+
+	return "", fmt.Errorf("expected a string, a number or a boolean, got %s", describeValue(v))
+}
+
+// describeValue returns a short description of the kind of value v is, for error messages.
+func describeValue(v goja.Value) string {
+	switch {
+	case v == nil || goja.IsUndefined(v):
+		return "undefined"
+	case goja.IsNull(v):
+		return "null"
+	}
+
+	if obj, ok := v.(*goja.Object); ok {
+		return "an instance of " + obj.ClassName()
+	}
+
+	return v.String()
+}
@@ -0,0 +1,913 @@
+package exec
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/dop251/goja"
+	"github.com/sirupsen/logrus"
+	"go.k6.io/k6/js/common"
+	"go.k6.io/k6/js/modules"
+	"go.k6.io/k6/lib"
+)
+
+// Command represents a command to be executed.
+//
+// Commands are immutable: the builder methods return a modified copy of the command
+// they are called on, which is left untouched. A base command can thus be safely
+// derived into variants.
+type Command struct {
+	Name string
+
+	args []string
+	env  map[string]string
+
+	stdoutFile *outputFile
+	stderrFile *outputFile
+
+	checksum string
+
+	onLine goja.Callable
+
+	// onExit, if set, is called once an execution of the command completed.
+	onExit goja.Callable
+
+	// clearEnv makes the command not inherit the environment of k6, and inheritEnv,
+	// if not empty, makes it only inherit the variables matching its patterns.
+	clearEnv   bool
+	inheritEnv []string
+
+	// sensitive holds the names of the environment variables whose values are redacted.
+	sensitive map[string]bool
+
+	// tags holds the user-defined tags set on the metrics of the command.
+	tags map[string]string
+
+	// dir, if set, is the working directory of the command.
+	dir string
+
+	// stdin, if set, is the source of the command's standard input.
+	stdin *stdinSource
+
+	// timeout, if set, bounds how long the command is allowed to run.
+	timeout *commandTimeout
+
+	// sudo, if set, configures how the command is run with elevated privileges.
+	sudo *SudoOptions
+
+	// azure, if set, configures the Azure virtual machine the command is run on.
+	azure *AzureVMOptions
+
+	// ssh, if set, configures the remote host the command is run on, over SSH.
+	ssh *SSHOptions
+
+	// retry, if set, configures how the command is retried when its execution fails.
+	retry *retryPolicy
+
+	// attempt is the number of the attempt of a retried command being executed, zero if it isn't retried.
+	attempt int
+
+	// limiter caps the number of commands executing concurrently, shared by all VUs.
+	limiter *concurrencyLimiter
+
+	// shell is set if the command is a shell running script, as started by Sh or ShSync.
+	shell  bool
+	script string
+
+	// powerShell, if set, makes the command be run as a PowerShell pipeline.
+	powerShell *PowerShellOptions
+
+	// emitted are the user-defined metrics fed with values parsed from the standard output.
+	emitted []*emittedMetric
+
+	// chain holds the commands chained to the command using AndThen and OrElse.
+	chain []chainLink
+
+	// pipeline holds the commands the output of the command is piped to using Pipe.
+	pipeline []Command
+
+	// stage, if set, wires the command to the adjacent stages of the pipeline it is executed in.
+	stage *pipeStage
+
+	vu      modules.VU
+	metrics *CustomMetrics
+	config  *moduleConfig
+	warmups *warmups
+	hooks   *testHooks
+	testRun *testRun
+
+	// cardinality caps the number of distinct values of the tags set on the metrics.
+	cardinality *tagCardinality
+
+	// processes holds the commands the VU started which didn't exit yet.
+	processes *processRegistry
+
+	// policy is the execution policy set by the operator of k6.
+	policy *operatorPolicy
+
+	// typedErrors makes promises be rejected with JS Error objects.
+	typedErrors bool
+}
+
+// Arg returns a copy of the command with an additional argument, either a
+// string, or a number or a boolean, which is stringified.
+func (c Command) Arg(arg goja.Value) Command {
+	s, err := stringify(arg)
+	if err != nil {
+		common.Throw(c.vu.Runtime(), fmt.Errorf("invalid argument: %w", err))
+	}
+
+	c = c.Clone()
+	c.args = append(c.args, s)
+	return c
+}
+
+// Args returns a copy of the command with additional arguments, either passed as an array, or as
+// several arguments, which are stringified the same way as by Arg.
+func (c Command) Args(args ...goja.Value) Command {
+	rt := c.vu.Runtime()
+
+	for _, arg := range args {
+		obj, ok := arg.(*goja.Object)
+		if !ok || obj.ClassName() != "Array" {
+			c = c.Arg(arg)
+			continue
+		}
+
+		var values []goja.Value
+		if err := rt.ExportTo(obj, &values); err != nil {
+			common.Throw(rt, fmt.Errorf("invalid args; expected an array: %w", err))
+		}
+		for _, value := range values {
+			c = c.Arg(value)
+		}
+	}
+
+	return c
+}
+
+// Env returns a copy of the command with an environment variable set, to a string, or to a number
+// or a boolean, which is stringified. It can also be called with an object or a Map, to set all the
+// variables it holds at once.
+func (c Command) Env(key, value goja.Value) Command {
+	rt := c.vu.Runtime()
+	c = c.Clone()
+
+	if value != nil && !goja.IsUndefined(value) {
+		s, err := stringify(value)
+		if err != nil {
+			common.Throw(rt, fmt.Errorf("invalid value of the %s environment variable: %w", key, err))
+		}
+
+		c.env[key.String()] = s
+		return c
+	}
+
+	var vars map[string]goja.Value
+	if err := rt.ExportTo(key, &vars); err != nil {
+		common.Throw(rt, fmt.Errorf("env expects a name and a value, or an object: %w", err))
+	}
+
+	for k, v := range vars {
+		s, err := stringify(v)
+		if err != nil {
+			common.Throw(rt, fmt.Errorf("invalid value of the %s environment variable: %w", k, err))
+		}
+
+		c.env[k] = s
+	}
+
+	return c
+}
+
+// Cwd returns a copy of the command executed in the directory at path. Relative
+// paths of the executable, such as ./tool, are resolved relative to it.
+func (c Command) Cwd(path string) Command {
+	if path == "" {
+		common.Throw(c.vu.Runtime(), errors.New("cwd expects the path of a directory"))
+	}
+
+	c.dir = path
+	return c
+}
+
+// Tag returns a copy of the command whose metrics are tagged with key, set to a string, or to a
+// number or a boolean, which is stringified. It can also be called with an object, to set all
+// the tags it holds at once. The tags set by the module, such as executable, take precedence.
+func (c Command) Tag(key, value goja.Value) Command {
+	rt := c.vu.Runtime()
+
+	tags := make(map[string]string, len(c.tags)+1)
+	for k, v := range c.tags {
+		tags[k] = v
+	}
+	c.tags = tags
+
+	if value != nil && !goja.IsUndefined(value) {
+		s, err := stringify(value)
+		if err != nil {
+			common.Throw(rt, fmt.Errorf("invalid value of the %s tag: %w", key, err))
+		}
+		if key.String() == "" {
+			common.Throw(rt, errors.New("tag expects a non-empty name"))
+		}
+
+		c.tags[key.String()] = s
+		return c
+	}
+
+	var values map[string]goja.Value
+	if err := rt.ExportTo(key, &values); err != nil {
+		common.Throw(rt, fmt.Errorf("tag expects a name and a value, or an object: %w", err))
+	}
+
+	for k, v := range values {
+		s, err := stringify(v)
+		if err != nil {
+			common.Throw(rt, fmt.Errorf("invalid value of the %s tag: %w", k, err))
+		}
+		if k == "" {
+			common.Throw(rt, errors.New("tag expects a non-empty name"))
+		}
+
+		c.tags[k] = s
+	}
+
+	return c
+}
+
+// describe returns a copy of the command described by the options object passed to the Cmd constructor.
+func (c Command) describe(obj *goja.Object) Command {
+	rt := c.vu.Runtime()
+
+	for _, key := range obj.Keys() {
+		value := obj.Get(key)
+
+		switch key {
+		case "args":
+			var args []goja.Value
+			if err := rt.ExportTo(value, &args); err != nil {
+				common.Throw(rt, fmt.Errorf("invalid args; expected an array: %w", err))
+			}
+			for _, arg := range args {
+				c = c.Arg(arg)
+			}
+		case "env":
+			c = c.Env(value, goja.Undefined())
+		case "cwd":
+			c = c.Cwd(value.String())
+		case "timeout":
+			c = c.Timeout(value, goja.Undefined())
+		case "tags":
+			c = c.Tag(value, goja.Undefined())
+		case "clearEnv":
+			if value.ToBoolean() {
+				c = c.ClearEnv()
+			}
+		case "inheritEnv":
+			var patterns []string
+			if err := rt.ExportTo(value, &patterns); err != nil {
+				common.Throw(rt, fmt.Errorf("invalid inheritEnv; expected an array of names or prefixes: %w", err))
+			}
+			c = c.InheritEnv(patterns...)
+		case "sensitive":
+			var names []string
+			if err := rt.ExportTo(value, &names); err != nil {
+				common.Throw(rt, fmt.Errorf("invalid sensitive; expected an array of environment variable names: %w", err))
+			}
+			c = c.Sensitive(names...)
+		default:
+			common.Throw(rt, fmt.Errorf("unknown Cmd option %q; expected args, env, cwd, timeout, tags, "+
+				"clearEnv, inheritEnv or sensitive", key))
+		}
+	}
+
+	return c
+}
+
+// Clone returns a copy of the command sharing no state with it.
+func (c Command) Clone() Command {
+	c.args = append(make([]string, 0, len(c.args)+1), c.args...)
+
+	env := make(map[string]string, len(c.env))
+	for k, v := range c.env {
+		env[k] = v
+	}
+	c.env = env
+
+	return c
+}
+
+// StdoutToFile redirects the command's standard output to the file at path
+// instead of capturing it in the result. The path can contain a {vu} placeholder,
+// which is replaced by the ID of the VU executing the command.
+func (c Command) StdoutToFile(path string, opts OutputFileOptions) Command {
+	c.stdoutFile = &outputFile{path: path, append: opts.Append}
+	return c
+}
+
+// StderrToFile redirects the command's standard error to the file at path
+// instead of capturing it in the result. The path can contain a {vu} placeholder,
+// which is replaced by the ID of the VU executing the command.
+func (c Command) StderrToFile(path string, opts OutputFileOptions) Command {
+	c.stderrFile = &outputFile{path: path, append: opts.Append}
+	return c
+}
+
+// Checksum enables computing a checksum of the command's output streams using the
+// named algorithm (md5, sha1, sha256 or sha512). The hex encoded checksums are
+// included in the result, whether the output was captured or redirected to a file.
+func (c Command) Checksum(algorithm string) Command {
+	if err := validateChecksumAlgorithm(algorithm); err != nil {
+		common.Throw(c.vu.Runtime(), err)
+	}
+
+	c.checksum = algorithm
+	return c
+}
+
+// OnLine registers a callback called with each line the command writes to its output
+// streams, and the name of the stream it was written to ("stdout" or "stderr").
+// The output is still captured in the result, unless it is discarded.
+func (c Command) OnLine(fn goja.Value) Command {
+	callback, ok := goja.AssertFunction(fn)
+	if !ok {
+		common.Throw(c.vu.Runtime(), errors.New("onLine expects a function"))
+	}
+
+	c.onLine = callback
+	return c
+}
+
+// Exec runs the command and returns a promise that will be resolved when the command finishes.
+// FIXME: this is probably very unsafe.
+func (c *Command) Exec(options goja.Value) *goja.Promise {
+	promise, resolve, reject := makeHandledPromise(c.vu)
+	resolve, reject = c.notifyExit(resolve, reject)
+
+	opts, err := parseExecOptions(c.vu.Runtime(), options)
+	if err != nil {
+		reject(c.rejection(err))
+		return promise
+	}
+
+	c.execute(opts, resolve, reject)
+
+	return promise
+}
+
+// execute executes the command, its chained commands, or its pipeline, retrying it according to
+// its retry policy, if any, and settles the outcome with resolve or reject. It is called on the
+// event loop.
+func (c *Command) execute(opts *execOptions, resolve, reject func(interface{})) {
+	switch {
+	case len(c.chain) > 0:
+		c.execChain(opts, resolve, reject)
+		return
+	case len(c.pipeline) > 0:
+		c.execPipeline(opts, resolve, reject)
+		return
+	case c.retry != nil:
+		c.execWithRetries(opts, resolve, reject)
+		return
+	}
+
+	complete, err := c.run(opts)
+	if err != nil {
+		reject(c.rejection(err))
+		return
+	}
+
+	go func() {
+		result, err := complete()
+		if err != nil {
+			reject(c.rejection(err))
+			return
+		}
+
+		resolve(result)
+	}()
+}
+
+// ExecSync runs the command, blocking the VU until it exited, and returns its result, so that
+// steps such as provisioning and cleanup in setup and teardown don't need to await promises. The
+// options are the ones of Exec, the VU context and timeouts still being respected. From the init
+// context, it requires executing commands from the init context to be allowed, as runInit does.
+func (c *Command) ExecSync(options goja.Value) goja.Value {
+	rt := c.vu.Runtime()
+
+	if err := c.checkInitAllowed(); err != nil {
+		common.Throw(rt, err)
+	}
+
+	if len(c.chain) > 0 || len(c.pipeline) > 0 {
+		common.Throw(rt, errors.New("chained commands and pipelines aren't supported by execSync; use exec instead"))
+	}
+
+	opts, err := parseExecOptions(rt, options)
+	if err != nil {
+		common.Throw(rt, err)
+	}
+
+	return c.runSync(opts, "execSync")
+}
+
+// run starts the command, and returns a function blocking until it completed, and
+// returning its result, or the error its execution failed with.
+func (c *Command) run(opts *execOptions) (func() (*CommandResult, error), error) {
+	if err := c.checkInitAllowed(); err != nil {
+		return nil, err
+	}
+
+	opts = c.withTimeout(opts)
+
+	config := c.config.resolve(c.vu)
+	if reason := prohibitedReason(config); reason != "" {
+		return c.prohibited(reason)
+	}
+
+	c.testRun.watch(lib.GetExecutionState(c.vu.Context()))
+	if err := c.hooks.start(c); err != nil {
+		return nil, err
+	}
+
+	if err := c.warmUp(config); err != nil {
+		return nil, err
+	}
+
+	if err := config.loadGuard.wait(c.vu.Context()); err != nil {
+		return nil, fmt.Errorf("executing %q: %w", c.Name, err)
+	}
+
+	if err := c.checkOutputCapture(opts); err != nil {
+		return nil, err
+	}
+
+	cmd, err := c.build(c.vu.Context(), opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.discardsOutput(opts) {
+		return c.runDiscarded(cmd, opts)
+	}
+
+	r := &runningCommand{
+		command:   c,
+		cmd:       cmd,
+		opts:      opts,
+		vuContext: c.vu.Context(),
+		vuState:   c.vu.State(),
+		logger:    c.logger(),
+	}
+	if err := r.start(); err != nil {
+		return nil, err
+	}
+
+	return r.wait, nil
+}
+
+// checkOutputCapture returns an error if the features requiring the output
+// to be captured are used along with options keeping it from being captured.
+func (c *Command) checkOutputCapture(opts *execOptions) error {
+	if len(c.emitted) > 0 && (c.stdoutFile != nil || opts.discardOutput) {
+		return errors.New("emitMetric requires the standard output to be captured; " +
+			"it can't be redirected to a file, nor discarded")
+	}
+
+	if (opts.expect.inspects("stdout") && (c.stdoutFile != nil || opts.discardOutput)) ||
+		(opts.expect.inspects("stderr") && (c.stderrFile != nil || opts.discardOutput)) {
+		return errors.New("expectations on the output require it to be captured; " +
+			"it can't be redirected to a file, nor discarded")
+	}
+
+	if c.powerShell != nil && (c.stdoutFile != nil || opts.discardOutput ||
+		opts.filter != nil || opts.keepOutput != nil) {
+		return errPowerShellOutput
+	}
+
+	return nil
+}
+
+// runningCommand is a command whose output is captured, started by run, in the context,
+// and with the state, of the VU which started it.
+type runningCommand struct {
+	command *Command
+	cmd     *exec.Cmd
+	opts    *execOptions
+
+	vuContext context.Context
+	vuState   *lib.State
+	logger    logrus.FieldLogger
+
+	stdout, stderr         *outputPipe
+	stdoutFile, stderrFile *os.File
+	execution              *execution
+}
+
+// start wires the output streams of the command, and starts it.
+func (r *runningCommand) start() error {
+	c, cmd, opts := r.command, r.cmd, r.opts
+	defer closeFiles(cmd.ExtraFiles...)
+
+	// The output streams are written to pipes we own, rather than obtained through StdoutPipe
+	// and StderrPipe, so that waiting for the command is bounded by its WaitDelay even if the
+	// pipes are kept open by a child. Commands run in a pseudo-terminal write both to it, their
+	// merged output being exposed as their standard output, and their standard error being empty.
+	var err error
+	if opts.pty != nil {
+		r.stdout, err = c.openTerminal(cmd, opts)
+	} else {
+		r.stdout, err = newOutputPipe(opts.pipeSize)
+	}
+	if err != nil {
+		return err
+	}
+	if r.stderr, err = newOutputPipe(opts.pipeSize); err != nil {
+		r.stdout.close()
+		return err
+	}
+	cmd.Stdout, cmd.Stderr = r.stdout.w, r.stderr.w
+	if opts.pty != nil {
+		cmd.Stderr = r.stdout.w
+	}
+	if c.stage != nil {
+		c.stage.wire(cmd)
+	}
+
+	if r.stdoutFile, r.stderrFile, err = c.openOutputFiles(r.vuState); err != nil {
+		r.stdout.close()
+		r.stderr.close()
+		return err
+	}
+
+	if r.execution, err = c.startExecution(cmd, opts); err != nil {
+		r.stdout.close()
+		r.stderr.close()
+		closeFiles(r.stdoutFile, r.stderrFile)
+		return err
+	}
+	if c.stage != nil {
+		c.stage.execution = r.execution
+	}
+	r.stdout.started()
+	r.stderr.started()
+
+	return nil
+}
+
+// wait blocks until the command completed, and returns its result, or the error its execution failed with.
+func (r *runningCommand) wait() (*CommandResult, error) {
+	defer closeFiles(r.stdoutFile, r.stderrFile)
+	defer r.stdout.close()
+	defer r.stderr.close()
+
+	c, execution, opts := r.command, r.execution, r.opts
+
+	stdoutCapture := c.newStreamCapture("stdout", r.stdoutFile, opts)
+	stderrCapture := c.newStreamCapture("stderr", r.stderrFile, opts)
+
+	// Both streams are drained concurrently, so that a command filling
+	// one of the pipes can't block for the other one to be read.
+	stdoutDone := stdoutCapture.drainAsync(execution.observe("stdout", r.stdout.r))
+	stderrDone := stderrCapture.drainAsync(execution.observe("stderr", r.stderr.r))
+
+	execution.wait()
+	r.stdout.exited(r.cmd.WaitDelay)
+	r.stderr.exited(r.cmd.WaitDelay)
+	stdoutResult, stderrResult := <-stdoutDone, <-stderrDone
+	execution.closeTranscript()
+
+	for _, err := range []error{stdoutResult.err, stderrResult.err} {
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if c.azure != nil {
+		if err := c.unwrapAzure(execution, &stdoutResult, &stderrResult); err != nil {
+			return nil, err
+		}
+	}
+
+	execution.debugDrained(stdoutResult.totals(), stderrResult.totals())
+
+	if err := execution.checkExpectations(stdoutResult.output, stderrResult.output); err != nil {
+		return nil, err
+	}
+
+	r.pushMetrics(stdoutResult, stderrResult)
+
+	result := newCommandResult(
+		execution.exitCode,
+		stdoutResult.output, stderrResult.output,
+		stdoutCapture, stderrCapture,
+		opts,
+	)
+	execution.annotate(result)
+
+	if err := execution.failure(stderrResult.output); err != nil {
+		return nil, err
+	}
+
+	if err := execution.unmetExpectations(stderrResult.output); err != nil {
+		return nil, err
+	}
+
+	if c.powerShell != nil && execution.exitCode == 0 {
+		if err := c.parsePowerShell(result, stdoutResult.output); err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
+
+// pushMetrics pushes the metrics of the completed command, and the ones it emitted, if it succeeded.
+func (r *runningCommand) pushMetrics(stdoutResult, stderrResult drainResult) {
+	c, execution := r.command, r.execution
+
+	metricsContext, cancel := execution.metricsContext(r.vuContext)
+	defer cancel()
+
+	c.pushMetrics(metricsContext, r.vuState, execution.stats(stdoutResult.totals(), stderrResult.totals()))
+	if execution.exitCode == 0 {
+		c.pushEmittedMetrics(metricsContext, r.vuState, r.logger, stdoutResult.output, execution.end)
+	}
+}
+
+// parsePowerShell sets the data of result to the deserialized output of the PowerShell pipeline.
+func (c *Command) parsePowerShell(result *CommandResult, stdout capturedOutput) error {
+	b, err := stdout.bytes()
+	if err != nil {
+		return err
+	}
+
+	result.Data, err = c.powerShell.parse(b)
+	return err
+}
+
+// unwrapAzure replaces the output and exit code of the Azure CLI with the ones
+// of the command it ran on the virtual machine.
+func (c *Command) unwrapAzure(execution *execution, stdout, stderr *drainResult) error {
+	if execution.exitCode != 0 {
+		return nil
+	}
+
+	b, err := stdout.output.bytes()
+	if err != nil {
+		return err
+	}
+
+	out, errOut, exitCode, err := c.azure.unwrap(b)
+	if err != nil {
+		return err
+	}
+
+	stdout.output, stdout.n, stdout.lines = capturedOutput{data: out}, int64(len(out)), countLines(out)
+	stderr.output, stderr.n, stderr.lines = capturedOutput{data: errOut}, int64(len(errOut)), countLines(errOut)
+	execution.exitCode = exitCode
+	execution.outcome = execution.opts.outcomes.resolve(exitCode)
+
+	return nil
+}
+
+// rejection returns the value promises should be rejected with on err.
+func (c *Command) rejection(err error) error {
+	if !c.typedErrors {
+		return err
+	}
+
+	return jsError{err}
+}
+
+// throw throws err as a JS exception, converted the same way as promise rejections.
+func (c *Command) throw(err error) {
+	rt := c.vu.Runtime()
+	if v, ok := c.rejection(err).(jsValuer); ok {
+		panic(v.toJSValue(rt))
+	}
+
+	common.Throw(rt, err)
+}
+
+// build resolves the command's executable and returns an *exec.Cmd ready to be started,
+// bound to the provided context.
+//
+// The files in the returned command's ExtraFiles are opened by build, and should be
+// closed by the caller once the command was started.
+func (c *Command) build(ctx context.Context, opts *execOptions) (*exec.Cmd, error) {
+	config := c.config.resolve(c.vu)
+
+	// The command itself is checked before its wrappers, such as sudo, rewrite it, so that
+	// allowing a wrapper doesn't allow every command run through it, nor bypass deny rules.
+	if err := c.checkPolicy(config, c.Name, c.policyPath(), c.args); err != nil {
+		return nil, err
+	}
+
+	name, args, err := c.wrap(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	// Relative paths of the executable are relative to the working directory of the command.
+	if c.dir != "" && strings.ContainsRune(name, filepath.Separator) && !filepath.IsAbs(name) {
+		name = filepath.Join(c.dir, name)
+	}
+
+	operator, err := c.policy.get()
+	if err != nil {
+		return nil, err
+	}
+
+	cmdPath, cwdRelative, err := lookExecutable(name, operator, config)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := c.checkPolicy(config, name, cmdPath, args); err != nil {
+		return nil, err
+	}
+
+	cmd := exec.CommandContext(ctx, cmdPath, args...)
+	// CommandContext looks the executable up again, and rejects it the same way, though it was allowed.
+	if cwdRelative && errors.Is(cmd.Err, exec.ErrDot) {
+		cmd.Err = nil
+	}
+	cmd.Env = append(c.environ(ctx, cmd.Environ()), localeEnviron(opts.locale)...)
+	// The working directory of commands run over SSH is the one they run in on the remote host.
+	if c.ssh == nil {
+		cmd.Dir = c.dir
+	}
+
+	// The password is fed to sudo through its standard input, and kept out of the command's environment.
+	if c.sudo != nil && c.sudo.PasswordEnv != "" {
+		password, err := c.sudo.password()
+		if err != nil {
+			return nil, err
+		}
+
+		cmd.Stdin = strings.NewReader(password + "\n")
+		cmd.Env = withoutEnvVar(cmd.Env, c.sudo.PasswordEnv)
+	}
+
+	c.prepareCancellation(cmd, opts)
+
+	cmd.ExtraFiles, err = openExtraFiles(opts.extraFiles)
+	if err != nil {
+		return nil, err
+	}
+
+	return cmd, nil
+}
+
+// wrap returns the executable actually executed to run the command, and its arguments,
+// once rewritten by the wrappers of the command, such as sudo or ssh, if any.
+func (c *Command) wrap(opts *execOptions) (string, []string, error) {
+	name, args := c.Name, c.args
+	if c.powerShell != nil {
+		name, args = c.powerShell.wrap(name, args)
+	}
+	if c.sudo != nil {
+		name, args = c.sudo.wrap(name, args)
+	}
+	if c.ssh != nil {
+		if c.azure != nil {
+			return "", nil, errors.New("commands can't be run both over SSH and on Azure virtual machines")
+		}
+		name, args = c.ssh.wrap(name, args, c.env, c.dir, opts.pty != nil)
+	}
+	if c.azure != nil {
+		name, args = c.azure.wrap(name, args, c.env)
+	}
+	if opts.isolation != nil {
+		var err error
+		if name, args, err = opts.isolation.wrap(name, args); err != nil {
+			return "", nil, err
+		}
+	}
+	if opts.systemd != nil {
+		name, args = opts.systemd.wrap(name, args)
+	}
+
+	return name, args, nil
+}
+
+// prepareCancellation sets how cmd is terminated once the VU context is done,
+// and how long its output is waited for once it exited.
+func (c *Command) prepareCancellation(cmd *exec.Cmd, opts *execOptions) {
+	cmd.WaitDelay = opts.waitDelay
+	prepareSignals(cmd)
+
+	// Commands given a grace period are asked to terminate once the VU context is
+	// done, and only killed if they didn't exit by the end of the grace period.
+	if grace := c.gracePeriod(opts); grace > 0 {
+		cmd.Cancel = func() error { return terminateProcess(cmd.Process) }
+		if cmd.WaitDelay < grace {
+			cmd.WaitDelay = grace
+		}
+	}
+
+	if sequence := killSequenceDuration(opts.killSequence); cmd.WaitDelay < sequence {
+		cmd.WaitDelay = sequence
+	}
+
+	// Commands run as systemd units are signaled as a whole, processes they started included.
+	if unit := opts.systemd; unit != nil {
+		signal := "SIGKILL"
+		if c.gracePeriod(opts) > 0 {
+			signal = "SIGTERM"
+		}
+		cmd.Cancel = func() error { return unit.signal(signal) }
+	}
+}
+
+// logger returns the logger of the VU executing the command, or the logger of its init
+// environment if the command is executed outside of a VU state.
+func (c *Command) logger() logrus.FieldLogger {
+	if state := c.vu.State(); state != nil {
+		return state.Logger
+	}
+
+	if env := c.vu.InitEnv(); env != nil {
+		return env.Logger
+	}
+
+	return logrus.StandardLogger()
+}
+
+// exitCodeOf returns the exit code corresponding to the error returned
+// when waiting for a command to finish.
+func exitCodeOf(err error) int {
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode()
+	}
+
+	return 0
+}
+
+// newStreamCapture returns the streamCapture describing how the named output
+// stream is consumed, according to the command's configuration and options.
+func (c *Command) newStreamCapture(stream string, file *os.File, opts *execOptions) streamCapture {
+	return streamCapture{
+		file:              file,
+		hash:              newChecksum(c.checksum),
+		compress:          opts.compressOutput,
+		keep:              opts.keepOutput,
+		filter:            opts.filter,
+		normalizeNewlines: opts.normalizeNewlines,
+		onLine:            c.lineHandler(stream, c.lineCallback(stream, opts), opts.maxPendingLines),
+		readBufferSize:    opts.readBufferSize,
+		maxBytes:          opts.maxOutputBytes,
+		discard:           opts.discardOutput,
+	}
+}
+
+// lineCallback returns the callback called with each line written to the named stream: the
+// onStdout or onStderr option, or the command's OnLine callback, or nil if there is none.
+func (c *Command) lineCallback(stream string, opts *execOptions) goja.Callable {
+	switch {
+	case stream == "stdout" && opts.onStdout != nil:
+		return opts.onStdout
+	case stream == "stderr" && opts.onStderr != nil:
+		return opts.onStderr
+	default:
+		return c.onLine
+	}
+}
+
+// lineHandler returns a function calling onLine, on the event loop, with each line
+// written to the named stream. It returns nil if onLine is nil.
+//
+// The returned function blocks while maxPending lines are waiting for the callback
+// to be called, so that the stream stops being read, and the command is paused on
+// its next write, until the event loop catches up.
+func (c *Command) lineHandler(stream string, onLine goja.Callable, maxPending int) func(line string) {
+	if onLine == nil {
+		return nil
+	}
+
+	rt, ctx := c.vu.Runtime(), c.vu.Context()
+	pending := make(chan struct{}, maxPending)
+	return func(line string) {
+		select {
+		case pending <- struct{}{}:
+		case <-ctx.Done():
+			return
+		}
+
+		callback := c.vu.RegisterCallback()
+		callback(func() error {
+			defer func() { <-pending }()
+
+			_, err := onLine(goja.Undefined(), rt.ToValue(line), rt.ToValue(stream))
+			return err
+		})
+	}
+}
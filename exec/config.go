@@ -0,0 +1,351 @@
+package exec
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/dop251/goja"
+	"go.k6.io/k6/js/common"
+	"go.k6.io/k6/js/modules"
+	"go.k6.io/k6/lib"
+)
+
+// moduleConfig holds the configuration of a module instance, shared by all the commands it built.
+type moduleConfig struct {
+	// structuredConcurrency makes iterations not end until all the
+	// processes spawned during them exited.
+	structuredConcurrency bool
+
+	// sanitizeEnv makes the well-known environment variables holding
+	// credentials not be inherited by commands.
+	sanitizeEnv bool
+
+	// executionContextEnv makes the variables describing the k6 execution
+	// context be set in the environment of commands.
+	executionContextEnv bool
+
+	// maxTagValues caps the number of distinct values of the high-cardinality tags,
+	// the values past the cap being bucketed into "other". Zero disables the cap.
+	maxTagValues int
+
+	// allowCwdExecutables allows executables to be resolved relative to the current
+	// directory, through the relative entries of PATH, such as ".".
+	allowCwdExecutables bool
+
+	// locale, if set, is the locale commands are executed with, so that their output is
+	// formatted the same way whatever the locale of the load generator.
+	locale string
+
+	// runIDTag makes the metrics of executions be tagged with their run ID.
+	runIDTag bool
+
+	// loadGuard, if set, delays or fails executions while the host is saturated.
+	loadGuard *loadGuard
+
+	// lifecycleLogSampling is the rate, between 0 and 1, of the executions whose
+	// lifecycle events are logged. Zero disables the lifecycle logs.
+	lifecycleLogSampling float64
+
+	// policy, if set, decides which commands are allowed to be executed, along with
+	// the policy set by the operator of k6, which it can't loosen.
+	policy *execPolicy
+
+	// whenProhibited is how executions behave when executing
+	// commands is prohibited in the environment.
+	whenProhibited string
+
+	// disabled prohibits executing commands.
+	disabled bool
+
+	// instanceUnknown prohibits executing commands as well, as the configuration specific
+	// to the instance couldn't be applied, the instance not being known.
+	instanceUnknown bool
+
+	// disabledMetrics holds the names of the metrics of the module which aren't emitted.
+	disabledMetrics map[string]bool
+
+	// warmup is the command executed once per VU before the first command it executes,
+	// and scenarioWarmups the ones executed once per VU in the scenario they're keyed by.
+	warmup          *Command
+	scenarioWarmups map[string]*Command
+
+	// instances holds the configuration specific to some of the instances of a
+	// distributed test run, applied on top of the rest of the configuration.
+	instances []instanceConfig
+}
+
+// instanceConfig holds the configuration specific to the instances of a distributed
+// test run selected either by the index of their execution segment, or by the segment.
+type instanceConfig struct {
+	index   int
+	segment *lib.ExecutionSegment
+
+	settings []func(*moduleConfig)
+}
+
+// newModuleConfig returns the default configuration of a module instance.
+func newModuleConfig() *moduleConfig {
+	return &moduleConfig{
+		sanitizeEnv:         true,
+		executionContextEnv: true,
+		maxTagValues:        defaultMaxTagValues,
+		allowCwdExecutables: true,
+	}
+}
+
+// Configure sets the configuration of the module for the current VU, from a
+// configuration object. Only the keys present in the object are changed.
+func (mi *ModuleInstance) Configure(config goja.Value) {
+	rt := mi.vu.Runtime()
+	if common.IsNullish(config) {
+		return
+	}
+
+	settings, err := mi.parseConfig(config.ToObject(rt), true)
+	if err != nil {
+		common.Throw(rt, err)
+	}
+
+	for _, set := range settings {
+		set(mi.config)
+	}
+}
+
+// parseConfig parses a configuration object into the settings it holds. The instances key,
+// holding the configuration specific to some instances, is only allowed at the top level.
+func (mi *ModuleInstance) parseConfig(obj *goja.Object, topLevel bool) ([]func(*moduleConfig), error) {
+	var settings []func(*moduleConfig)
+
+	for _, key := range obj.Keys() {
+		set, err := mi.parseSetting(key, obj.Get(key), topLevel)
+		if err != nil {
+			return nil, err
+		}
+		settings = append(settings, set)
+	}
+
+	return settings, nil
+}
+
+// configFlags holds the boolean configuration options, by key.
+var configFlags = map[string]func(cfg *moduleConfig) *bool{ //nolint:gochecknoglobals
+	"structuredConcurrency": func(cfg *moduleConfig) *bool { return &cfg.structuredConcurrency },
+	"sanitizeEnv":           func(cfg *moduleConfig) *bool { return &cfg.sanitizeEnv },
+	"executionContextEnv":   func(cfg *moduleConfig) *bool { return &cfg.executionContextEnv },
+	"allowCwdExecutables":   func(cfg *moduleConfig) *bool { return &cfg.allowCwdExecutables },
+	"runIDTag":              func(cfg *moduleConfig) *bool { return &cfg.runIDTag },
+	"disabled":              func(cfg *moduleConfig) *bool { return &cfg.disabled },
+}
+
+// parseSetting parses the named configuration option into the setting it holds.
+func (mi *ModuleInstance) parseSetting(key string, value goja.Value, topLevel bool) (func(*moduleConfig), error) {
+	if flag, ok := configFlags[key]; ok {
+		enabled := value.ToBoolean()
+		return func(cfg *moduleConfig) { *flag(cfg) = enabled }, nil
+	}
+
+	switch key {
+	case "maxTagValues":
+		limit := value.ToInteger()
+		if limit < 0 {
+			return nil, fmt.Errorf("invalid maxTagValues %d; expected a positive number, or zero", limit)
+		}
+		return func(cfg *moduleConfig) { cfg.maxTagValues = int(limit) }, nil
+	case "lifecycleLogSampling":
+		rate := value.ToFloat()
+		if rate < 0 || rate > 1 {
+			return nil, fmt.Errorf("invalid lifecycleLogSampling %v; expected a rate between 0 and 1", rate)
+		}
+		return func(cfg *moduleConfig) { cfg.lifecycleLogSampling = rate }, nil
+	case "whenProhibited":
+		behavior := value.String()
+		if behavior != prohibitedFail && behavior != prohibitedWarn && behavior != prohibitedSkip {
+			return nil, fmt.Errorf("invalid whenProhibited %q; expected %s, %s or %s",
+				behavior, prohibitedFail, prohibitedWarn, prohibitedSkip)
+		}
+		return func(cfg *moduleConfig) { cfg.whenProhibited = behavior }, nil
+	case "instances":
+		if !topLevel {
+			return nil, fmt.Errorf("the configuration of an instance can't hold an %q key", key)
+		}
+
+		instances, err := mi.parseInstanceConfigs(value)
+		if err != nil {
+			return nil, err
+		}
+		return func(cfg *moduleConfig) { cfg.instances = instances }, nil
+	default:
+		return mi.parseObjectSetting(key, value)
+	}
+}
+
+// parseObjectSetting parses the named configuration option, whose value is an object or an array,
+// into the setting it holds. The options holding a single object are unset when it is nullish.
+func (mi *ModuleInstance) parseObjectSetting(key string, value goja.Value) (func(*moduleConfig), error) {
+	var err error
+
+	switch key {
+	case "disabledMetrics":
+		var disabled map[string]bool
+		disabled, err = mi.parseDisabledMetrics(value)
+		return func(cfg *moduleConfig) { cfg.disabledMetrics = disabled }, err
+	case "warmup":
+		var warmup *Command
+		var scenarioWarmups map[string]*Command
+		warmup, scenarioWarmups, err = mi.parseWarmup(value)
+		return func(cfg *moduleConfig) { cfg.warmup, cfg.scenarioWarmups = warmup, scenarioWarmups }, err
+	case "locale":
+		var locale string
+		if !common.IsNullish(value) {
+			locale, err = parseLocale(value)
+		}
+		return func(cfg *moduleConfig) { cfg.locale = locale }, err
+	case "loadGuard":
+		var guard *loadGuard
+		if !common.IsNullish(value) {
+			guard, err = parseLoadGuard(mi.vu.Runtime(), value)
+		}
+		return func(cfg *moduleConfig) { cfg.loadGuard = guard }, err
+	case "policy":
+		var policy *execPolicy
+		if !common.IsNullish(value) {
+			policy, err = parsePolicy(value)
+		}
+		return func(cfg *moduleConfig) { cfg.policy = policy }, err
+	default:
+		return nil, fmt.Errorf("unknown configuration option %q", key)
+	}
+}
+
+// parseDisabledMetrics parses the disabledMetrics configuration option: an array of the names
+// of the metrics of the module which aren't emitted, so that the ones a test doesn't need don't
+// add to the number of time series it outputs.
+func (mi *ModuleInstance) parseDisabledMetrics(v goja.Value) (map[string]bool, error) {
+	if common.IsNullish(v) {
+		return nil, nil
+	}
+
+	var names []string
+	if err := mi.vu.Runtime().ExportTo(v, &names); err != nil {
+		return nil, fmt.Errorf("invalid disabledMetrics; expected an array of metric names: %w", err)
+	}
+
+	disabled := make(map[string]bool, len(names))
+	for _, name := range names {
+		if !mi.Metrics.builtin(name) {
+			return nil, fmt.Errorf("invalid disabledMetrics; %q isn't a metric of the module", name)
+		}
+		disabled[name] = true
+	}
+
+	return disabled, nil
+}
+
+// parseInstanceConfigs parses the instances configuration option: an object whose keys select
+// instances, either by the index of their execution segment in the execution segment sequence,
+// or by their execution segment, such as "1/2:1", and whose values are configuration objects.
+func (mi *ModuleInstance) parseInstanceConfigs(v goja.Value) ([]instanceConfig, error) {
+	if common.IsNullish(v) {
+		return nil, nil
+	}
+
+	rt := mi.vu.Runtime()
+	obj := v.ToObject(rt)
+	instances := make([]instanceConfig, 0, len(obj.Keys()))
+	for _, key := range obj.Keys() {
+		instance := instanceConfig{index: -1}
+		if index, err := strconv.Atoi(key); err == nil && index >= 0 {
+			instance.index = index
+		} else {
+			segment, err := lib.NewExecutionSegmentFromString(key)
+			if err != nil {
+				return nil, fmt.Errorf("invalid instance %q; expected a segment index, or an execution segment: %w",
+					key, err)
+			}
+			instance.segment = segment
+		}
+
+		value := obj.Get(key)
+		if common.IsNullish(value) {
+			continue
+		}
+
+		settings, err := mi.parseConfig(value.ToObject(rt), false)
+		if err != nil {
+			return nil, fmt.Errorf("invalid configuration of instance %q: %w", key, err)
+		}
+		instance.settings = settings
+
+		instances = append(instances, instance)
+	}
+
+	return instances, nil
+}
+
+// matches returns true if the instance configuration applies to the instance
+// running the provided execution segment of the test.
+func (ic instanceConfig) matches(et *lib.ExecutionTuple) bool {
+	if ic.segment != nil {
+		return ic.segment.Equal(et.Segment)
+	}
+
+	return ic.index == et.SegmentIndex
+}
+
+// resolve returns the configuration applying to the instance the VU runs on, that is
+// the configuration with the settings specific to the instance applied, if any. The
+// instance is known out of the execution state of the test, or, in setup() and teardown(),
+// out of the options of the VU. As it isn't known in the init context, where the settings
+// specific to the instance could thus be skipped, executing commands is disabled there if
+// any instance has a specific configuration.
+func (cfg *moduleConfig) resolve(vu modules.VU) *moduleConfig {
+	if cfg == nil {
+		return newModuleConfig()
+	}
+
+	if len(cfg.instances) == 0 {
+		return cfg
+	}
+
+	resolved := *cfg
+
+	tuple := executionTuple(vu)
+	if tuple == nil {
+		resolved.instanceUnknown = true
+		return &resolved
+	}
+
+	for _, instance := range cfg.instances {
+		if !instance.matches(tuple) {
+			continue
+		}
+
+		for _, set := range instance.settings {
+			set(&resolved)
+		}
+	}
+
+	return &resolved
+}
+
+// executionTuple returns the execution segment the instance the VU runs on executes, within
+// the execution segment sequence of the test, or nil if it isn't known.
+func executionTuple(vu modules.VU) *lib.ExecutionTuple {
+	if ctx := vu.Context(); ctx != nil {
+		if es := lib.GetExecutionState(ctx); es != nil && es.ExecutionTuple != nil {
+			return es.ExecutionTuple
+		}
+	}
+
+	state := vu.State()
+	if state == nil {
+		return nil
+	}
+
+	tuple, err := lib.NewExecutionTuple(state.Options.ExecutionSegment, state.Options.ExecutionSegmentSequence)
+	if err != nil {
+		return nil
+	}
+
+	return tuple
+}
@@ -0,0 +1,12 @@
+package exec
+
+import "os/exec"
+
+// startCommand starts cmd, confined the way the options require.
+func startCommand(cmd *exec.Cmd, opts *execOptions) error {
+	if opts.network == networkHost && !opts.readOnlyCwd && opts.droppedCapabilities() == nil && opts.ioPriority == 0 {
+		return cmd.Start()
+	}
+
+	return startConfined(cmd, opts)
+}
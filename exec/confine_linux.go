@@ -0,0 +1,80 @@
+//go:build linux
+
+package exec
+
+import (
+	"fmt"
+	"os/exec"
+	"runtime"
+
+	"golang.org/x/sys/unix"
+)
+
+// startConfined starts cmd confined the way the options require.
+//
+// Namespaces which can be set up before the command starts are created when cloning
+// it. The others, which must be populated from within before the command starts, are
+// instead created by a dedicated thread, which the command is started from, and
+// inherits them from. This requires k6 to run as root. Capabilities are dropped
+// from the bounding set of that thread, and its I/O priority is set, the same way.
+func startConfined(cmd *exec.Cmd, opts *execOptions) error {
+	var prepare []func() error
+
+	switch opts.network {
+	case networkNone:
+		isolateNetwork(cmd)
+	case networkLoopback:
+		prepare = append(prepare, unshareNetwork)
+	}
+
+	if opts.readOnlyCwd {
+		dir := cmd.Dir
+		prepare = append(prepare, func() error { return mountReadOnly(dir) })
+	}
+
+	if caps := opts.droppedCapabilities(); caps != nil {
+		prepare = append(prepare, func() error { return dropCapabilities(caps) })
+	}
+
+	if opts.ioPriority != 0 {
+		prepare = append(prepare, func() error { return setIOPriority(opts.ioPriority) })
+	}
+
+	if len(prepare) == 0 {
+		return cmd.Start()
+	}
+
+	return startFromThread(cmd, prepare)
+}
+
+// startFromThread starts cmd from a dedicated thread, once prepared by the provided functions.
+func startFromThread(cmd *exec.Cmd, prepare []func() error) error {
+	errc := make(chan error, 1)
+	go func() {
+		// The thread is never unlocked, so that it is terminated rather than
+		// reused once the goroutine returns, as it was altered.
+		runtime.LockOSThread()
+
+		for _, fn := range prepare {
+			if err := fn(); err != nil {
+				errc <- err
+				return
+			}
+		}
+
+		errc <- cmd.Start()
+	}()
+
+	return <-errc
+}
+
+// setIOPriority sets the I/O priority of the calling thread, which the commands it starts inherit.
+func setIOPriority(prio int) error {
+	const ioprioWhoProcess = 1
+
+	if _, _, errno := unix.Syscall(unix.SYS_IOPRIO_SET, ioprioWhoProcess, 0, uintptr(prio)); errno != 0 {
+		return fmt.Errorf("unable to set the I/O priority: %w", errno)
+	}
+
+	return nil
+}
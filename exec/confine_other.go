@@ -0,0 +1,25 @@
+//go:build !linux
+
+package exec
+
+import (
+	"errors"
+	"os/exec"
+)
+
+// startConfined fails on platforms other than Linux, which lack namespaces and capabilities.
+func startConfined(_ *exec.Cmd, opts *execOptions) error {
+	if opts.readOnlyCwd {
+		return errors.New("readOnlyCwd is only supported on Linux")
+	}
+
+	if opts.ioPriority != 0 {
+		return errors.New("ioPriority is only supported on Linux")
+	}
+
+	if opts.droppedCapabilities() != nil {
+		return errors.New("dropping capabilities is only supported on Linux")
+	}
+
+	return errors.New("network isolation is only supported on Linux")
+}
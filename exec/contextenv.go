@@ -0,0 +1,85 @@
+package exec
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+
+	"go.k6.io/k6/lib"
+)
+
+// The environment variables describing the k6 execution context, set in the environment of commands.
+const (
+	vuEnvVar        = "K6_VU"
+	iterationEnvVar = "K6_ITERATION"
+	scenarioEnvVar  = "K6_SCENARIO"
+	testRunIDEnvVar = "K6_TEST_RUN_ID"
+)
+
+// runIDEnvVar is the environment variable holding the run ID of an execution,
+// set in the environment of every command.
+const runIDEnvVar = "K6_EXEC_RUN_ID"
+
+// cloudTestRunIDEnvVar is an environment variable set on k6 Cloud
+// load generators, holding the ID of the test run.
+const cloudTestRunIDEnvVar = "K6_CLOUDRUN_TEST_RUN_ID"
+
+var (
+	testRunID     string    //nolint:gochecknoglobals
+	testRunIDOnce sync.Once //nolint:gochecknoglobals
+)
+
+// currentTestRunID returns the ID of the test run: the one assigned by k6 Cloud, if the
+// test runs on it, or else a random UUID generated once for the k6 process.
+func currentTestRunID() string {
+	testRunIDOnce.Do(func() {
+		if id, ok := os.LookupEnv(cloudTestRunIDEnvVar); ok && id != "" {
+			testRunID = id
+			return
+		}
+
+		testRunID = newUUID()
+	})
+
+	return testRunID
+}
+
+// newUUID returns a random (version 4) UUID.
+func newUUID() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// executionContextEnviron returns the environment variables describing the k6 execution
+// context the command is executed in, so that the tools it runs can tag their own logs
+// and outputs for correlation with the test. The VU, iteration and scenario are only
+// known when the command is executed by a VU, during a scenario for the latter.
+func executionContextEnviron(ctx context.Context, state *lib.State) []string {
+	environ := []string{testRunIDEnvVar + "=" + currentTestRunID()}
+	if state == nil {
+		return environ
+	}
+
+	environ = append(environ,
+		vuEnvVar+"="+strconv.FormatUint(state.VUIDGlobal, 10),
+		iterationEnvVar+"="+strconv.FormatInt(state.Iteration, 10),
+	)
+
+	if ctx == nil {
+		return environ
+	}
+
+	if scenario := lib.GetScenarioState(ctx); scenario != nil {
+		environ = append(environ, scenarioEnvVar+"="+scenario.Name)
+	}
+
+	return environ
+}
@@ -0,0 +1,189 @@
+package exec
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/dop251/goja"
+	"go.k6.io/k6/js/modules"
+)
+
+// controlSocketEnvVar is the name of the environment variable holding
+// the path of the control channel's socket in the child's environment.
+const controlSocketEnvVar = "K6_EXEC_CONTROL_SOCKET"
+
+// errControlChannelClosed is returned when using a closed control channel.
+var errControlChannelClosed = errors.New("the control channel is closed")
+
+// ControlChannel is a line-oriented communication channel between the script and a
+// spawned process. It is backed by a Unix domain socket created for each execution,
+// whose path is passed to the process through the K6_EXEC_CONTROL_SOCKET environment
+// variable. The first connection the process makes to it is used as the channel.
+type ControlChannel struct {
+	// Path is the path of the channel's socket.
+	Path string `js:"path"`
+
+	vu  modules.VU
+	dir string
+	ln  net.Listener
+
+	// connected is closed once the process connected to the channel,
+	// after which conn and reader are set.
+	connected chan struct{}
+	conn      net.Conn
+	reader    *bufio.Reader
+
+	// closed is closed once the channel was closed.
+	closed    chan struct{}
+	closeOnce sync.Once
+
+	sends    *serialQueue
+	receives *serialQueue
+}
+
+// newControlChannel creates a new control channel, and starts listening for the
+// connection of the process. The channel is closed once ctx is done.
+func newControlChannel(ctx context.Context, vu modules.VU) (*ControlChannel, error) {
+	dir, err := os.MkdirTemp("", "xk6-exec-")
+	if err != nil {
+		return nil, err
+	}
+
+	path := filepath.Join(dir, "control.sock")
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		_ = os.RemoveAll(dir)
+		return nil, err
+	}
+
+	cc := &ControlChannel{
+		Path:      path,
+		vu:        vu,
+		dir:       dir,
+		ln:        ln,
+		connected: make(chan struct{}),
+		closed:    make(chan struct{}),
+		sends:     newSerialQueue(),
+		receives:  newSerialQueue(),
+	}
+
+	go cc.accept()
+	go func() {
+		select {
+		case <-ctx.Done():
+			cc.close()
+		case <-cc.closed:
+		}
+	}()
+
+	return cc, nil
+}
+
+// accept waits for the process to connect to the channel.
+func (cc *ControlChannel) accept() {
+	conn, err := cc.ln.Accept()
+	_ = cc.ln.Close()
+	if err != nil {
+		cc.close()
+		return
+	}
+
+	cc.conn, cc.reader = conn, bufio.NewReader(conn)
+	close(cc.connected)
+}
+
+// Send sends msg, followed by a newline, to the process. The returned promise
+// is resolved once the message was sent. If the process didn't connect to the
+// channel yet, the message is sent once it does.
+func (cc *ControlChannel) Send(msg string) *goja.Promise {
+	promise, resolve, reject := makeHandledPromise(cc.vu)
+
+	cc.sends.run(func() {
+		if err := cc.waitConnected(); err != nil {
+			reject(err)
+			return
+		}
+
+		if _, err := io.WriteString(cc.conn, msg+"\n"); err != nil {
+			reject(err)
+			return
+		}
+
+		resolve(goja.Undefined())
+	})
+
+	return promise
+}
+
+// Receive returns a promise resolved with the next line sent by the process,
+// without its trailing newline, or with null once the process closed the channel.
+func (cc *ControlChannel) Receive() *goja.Promise {
+	promise, resolve, reject := makeHandledPromise(cc.vu)
+
+	cc.receives.run(func() {
+		if err := cc.waitConnected(); err != nil {
+			resolve(goja.Null())
+			return
+		}
+
+		line, err := cc.reader.ReadString('\n')
+		switch {
+		case line != "":
+			resolve(strings.TrimSuffix(line, "\n"))
+		case errors.Is(err, io.EOF), errors.Is(err, net.ErrClosed):
+			resolve(goja.Null())
+		default:
+			reject(err)
+		}
+	})
+
+	return promise
+}
+
+// Close closes the channel.
+func (cc *ControlChannel) Close() {
+	cc.close()
+}
+
+// waitConnected waits for the process to connect to the channel, and
+// returns an error if the channel was closed before it did.
+func (cc *ControlChannel) waitConnected() error {
+	select {
+	case <-cc.connected:
+		return nil
+	case <-cc.closed:
+		return errControlChannelClosed
+	}
+}
+
+// closeIfUnused closes the channel if the process never connected to it.
+func (cc *ControlChannel) closeIfUnused() {
+	select {
+	case <-cc.connected:
+	default:
+		cc.close()
+	}
+}
+
+// close closes the channel, and removes its socket.
+func (cc *ControlChannel) close() {
+	cc.closeOnce.Do(func() {
+		close(cc.closed)
+		_ = cc.ln.Close()
+
+		select {
+		case <-cc.connected:
+			_ = cc.conn.Close()
+		default:
+		}
+
+		_ = os.RemoveAll(cc.dir)
+	})
+}
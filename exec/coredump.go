@@ -0,0 +1,55 @@
+package exec
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// Core dump policies, controlling whether spawned commands are allowed to dump core.
+const (
+	// coreDumpsInherit leaves the core dump limit inherited from k6 untouched.
+	coreDumpsInherit = ""
+
+	// coreDumpsDisable prevents commands from dumping core.
+	coreDumpsDisable = "disable"
+
+	// coreDumpsCollect allows commands to dump core, and reports
+	// the path of the core dump in the result when they do.
+	coreDumpsCollect = "collect"
+)
+
+// validateCoreDumpPolicy returns an error if policy is not a supported core dump policy.
+func validateCoreDumpPolicy(policy string) error {
+	if policy != coreDumpsDisable && policy != coreDumpsCollect {
+		return fmt.Errorf("unsupported core dump policy %q; expected one of %s or %s",
+			policy, coreDumpsDisable, coreDumpsCollect)
+	}
+
+	return nil
+}
+
+// coreDumpInfo returns whether the command exited dumping core and, when the
+// collect policy is used, the path of the core dump if it could be determined.
+func coreDumpInfo(cmd *exec.Cmd, policy string) (bool, string) {
+	if cmd.ProcessState == nil {
+		return false, ""
+	}
+
+	ws, ok := cmd.ProcessState.Sys().(syscall.WaitStatus)
+	if !ok || !ws.CoreDump() {
+		return false, ""
+	}
+
+	if policy != coreDumpsCollect {
+		return true, ""
+	}
+
+	dir := cmd.Dir
+	if dir == "" {
+		dir, _ = os.Getwd()
+	}
+
+	return true, corePath(cmd.Process.Pid, cmd.Path, dir)
+}
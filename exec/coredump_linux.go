@@ -0,0 +1,97 @@
+//go:build linux
+
+package exec
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// applyCoreDumpPolicy sets the core dump limit of the process with the provided pid
+// according to policy. As it is applied once the process was started, a process
+// crashing right away might not be affected.
+func applyCoreDumpPolicy(pid int, policy string) error {
+	var limit unix.Rlimit
+
+	switch policy {
+	case coreDumpsDisable:
+		limit = unix.Rlimit{Cur: 0, Max: 0}
+	case coreDumpsCollect:
+		if err := unix.Prlimit(pid, unix.RLIMIT_CORE, nil, &limit); err != nil {
+			return err
+		}
+		limit.Cur = limit.Max
+	default:
+		return nil
+	}
+
+	return unix.Prlimit(pid, unix.RLIMIT_CORE, &limit, nil)
+}
+
+// corePath returns the path of the core dumped by the process with the provided pid,
+// running the executable at path from dir, according to the kernel's core pattern.
+// It returns an empty string when cores are piped to a helper, such as systemd-coredump.
+func corePath(pid int, path, dir string) string {
+	b, err := os.ReadFile("/proc/sys/kernel/core_pattern")
+	if err != nil {
+		return ""
+	}
+
+	pattern := strings.TrimSpace(string(b))
+	if pattern == "" || strings.HasPrefix(pattern, "|") {
+		return ""
+	}
+
+	hostname, _ := os.Hostname()
+	exe := filepath.Base(path)
+	if len(exe) > 15 {
+		exe = exe[:15] // the kernel truncates the executable name to TASK_COMM_LEN.
+	}
+
+	var (
+		out    strings.Builder
+		hasPid bool
+	)
+	for i := 0; i < len(pattern); i++ {
+		if pattern[i] != '%' || i+1 == len(pattern) {
+			out.WriteByte(pattern[i])
+			continue
+		}
+
+		i++
+		switch pattern[i] {
+		case 'p', 'P':
+			out.WriteString(strconv.Itoa(pid))
+			hasPid = true
+		case 'e':
+			out.WriteString(exe)
+		case 'h':
+			out.WriteString(hostname)
+		case 't':
+			out.WriteString(strconv.FormatInt(time.Now().Unix(), 10))
+		case 'u':
+			out.WriteString(strconv.Itoa(os.Getuid()))
+		case 'g':
+			out.WriteString(strconv.Itoa(os.Getgid()))
+		case '%':
+			out.WriteByte('%')
+		}
+	}
+
+	result := out.String()
+	if usesPid, err := os.ReadFile("/proc/sys/kernel/core_uses_pid"); err == nil &&
+		strings.TrimSpace(string(usesPid)) == "1" && !hasPid {
+		result += "." + strconv.Itoa(pid)
+	}
+
+	if !filepath.IsAbs(result) {
+		result = filepath.Join(dir, result)
+	}
+
+	return result
+}
@@ -0,0 +1,14 @@
+//go:build !linux
+
+package exec
+
+// applyCoreDumpPolicy is a no-op on platforms other than Linux.
+func applyCoreDumpPolicy(_ int, _ string) error {
+	return nil
+}
+
+// corePath returns an empty string on platforms other than Linux, as
+// the location of core dumps can't be determined.
+func corePath(_ int, _, _ string) string {
+	return ""
+}
@@ -0,0 +1,102 @@
+package exec
+
+import (
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// debugCommand logs, at debug level, the command about to be started as it was resolved: its
+// executable, arguments and working directory, and how its environment differs from the one of
+// k6, by name only, as values often hold secrets. The values of sensitive variables are redacted
+// from the arguments, which hold the environment of the commands run on remote hosts.
+func (e *execution) debugCommand() {
+	added, changed, removed := compareEnviron(os.Environ(), e.cmd.Env)
+
+	e.logger().WithFields(logrus.Fields{
+		"path":        e.cmd.Path,
+		"args":        e.command.redactArgs(e.cmd.Args[1:]),
+		"dir":         e.cmd.Dir,
+		"env_added":   added,
+		"env_changed": changed,
+		"env_removed": removed,
+	}).Debugf("starting %s", e.command.Name)
+}
+
+// debugStarted logs, at debug level, that the command started, and how long starting it took.
+func (e *execution) debugStarted(startLatency time.Duration) {
+	e.logger().WithFields(logrus.Fields{
+		"pid":           e.cmd.Process.Pid,
+		"start_latency": startLatency.String(),
+	}).Debugf("started %s", e.command.Name)
+}
+
+// debugExited logs, at debug level, how the command exited, and how long it ran for.
+func (e *execution) debugExited() {
+	fields := logrus.Fields{
+		"pid":       e.cmd.Process.Pid,
+		"exit_code": e.exitCode,
+		"duration":  e.end.Sub(e.start).String(),
+	}
+	if e.signal != "" {
+		fields["signal"] = e.signal
+	}
+	if e.killedBy != "" {
+		fields["kill_reason"] = e.killedBy
+	}
+	if e.oomKilled {
+		fields["oom_killed"] = true
+	}
+
+	e.logger().WithFields(fields).Debugf("%s exited", e.command.Name)
+}
+
+// debugDrained logs, at debug level, how much output the command produced, and how long reading
+// the end of its output took once it exited.
+func (e *execution) debugDrained(stdout, stderr outputTotals) {
+	if !e.opts.debug {
+		return
+	}
+
+	e.logger().WithFields(logrus.Fields{
+		"stdout_bytes": stdout.bytes,
+		"stderr_bytes": stderr.bytes,
+		"drain":        time.Since(e.end).String(),
+	}).Debugf("drained the output of %s", e.command.Name)
+}
+
+// compareEnviron returns the sorted names of the variables of environ which aren't in base,
+// the ones whose values differ, and the ones of base which aren't in environ.
+func compareEnviron(base, environ []string) (added, changed, removed []string) {
+	toMap := func(environ []string) map[string]string {
+		m := make(map[string]string, len(environ))
+		for _, kv := range environ {
+			k, v, _ := strings.Cut(kv, "=")
+			m[k] = v
+		}
+		return m
+	}
+
+	before, after := toMap(base), toMap(environ)
+	for k, v := range after {
+		if prev, ok := before[k]; !ok {
+			added = append(added, k)
+		} else if prev != v {
+			changed = append(changed, k)
+		}
+	}
+	for k := range before {
+		if _, ok := after[k]; !ok {
+			removed = append(removed, k)
+		}
+	}
+
+	sort.Strings(added)
+	sort.Strings(changed)
+	sort.Strings(removed)
+
+	return added, changed, removed
+}
@@ -0,0 +1,276 @@
+package exec
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/dop251/goja"
+	"go.k6.io/k6/js/common"
+)
+
+// diffContextLines is the number of unchanged lines surrounding the changes of a unified diff.
+const diffContextLines = 3
+
+// DiffResult holds the outcome of comparing a command's output to the expected one.
+type DiffResult struct {
+	// Match is true if the output is identical to the expected one.
+	Match bool `js:"match"`
+
+	// Diff is the unified diff from the expected output to the actual one, empty if they match.
+	Diff string `js:"diff"`
+}
+
+// Diff compares the command's standard output to the expected one, either a string, or an object
+// whose file key holds the path of a golden file holding it, and returns whether they match, along
+// with the unified diff from the expected output to the actual one.
+func (r *CommandResult) Diff(expected goja.Value) (*DiffResult, error) {
+	want, label, err := expectedOutput(expected)
+	if err != nil {
+		return nil, err
+	}
+
+	stdout, err := r.stdoutString()
+	if err != nil {
+		return nil, err
+	}
+
+	if stdout == want {
+		return &DiffResult{Match: true}, nil
+	}
+
+	return &DiffResult{Diff: unifiedDiff(label, "stdout", splitLines(want), splitLines(stdout))}, nil
+}
+
+// expectedOutput returns the output expected by Diff, and the label of the expected
+// side of the diff: either the expected output itself, or the content of a golden file.
+func expectedOutput(v goja.Value) (string, string, error) {
+	if obj, ok := v.(*goja.Object); ok {
+		path := obj.Get("file")
+		if common.IsNullish(path) {
+			return "", "", errors.New("diff expects the expected output, or an object holding the path of a file")
+		}
+
+		b, err := os.ReadFile(path.String())
+		if err != nil {
+			return "", "", fmt.Errorf("unable to read the expected output: %w", err)
+		}
+
+		return string(b), path.String(), nil
+	}
+
+	if common.IsNullish(v) {
+		return "", "", errors.New("diff expects the expected output, or an object holding the path of a file")
+	}
+
+	return v.String(), "expected", nil
+}
+
+// splitLines splits s into lines, each holding its line ending, if any.
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	return lines
+}
+
+// diffOp is an operation of the edit script turning a sequence of lines into another one.
+type diffOp struct {
+	kind byte // ' ' for unchanged lines, '-' for removed ones, '+' for added ones
+	line string
+}
+
+// editScript returns the shortest edit script turning a into b, using Myers' algorithm.
+func editScript(a, b []string) []diffOp {
+	// The lines which are identical at both ends are set aside, as they're usually most of them.
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	ops := make([]diffOp, 0, len(a)+len(b))
+	for _, line := range a[:prefix] {
+		ops = append(ops, diffOp{' ', line})
+	}
+	ops = append(ops, myers(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])...)
+	for _, line := range a[len(a)-suffix:] {
+		ops = append(ops, diffOp{' ', line})
+	}
+
+	return ops
+}
+
+// myers returns the shortest edit script turning a into b.
+func myers(a, b []string) []diffOp {
+	n, m := len(a), len(b)
+	offset := n + m
+	v := make([]int, 2*offset+2)
+
+	// trace holds the furthest reaching paths of each diagonal, for each number of edits.
+	var trace [][]int
+	for d := 0; d <= n+m; d++ {
+		trace = append(trace, append([]int(nil), v...))
+
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x, y = x+1, y+1
+			}
+			v[offset+k] = x
+
+			if x >= n && y >= m {
+				return backtrack(a, b, trace, offset)
+			}
+		}
+	}
+
+	return nil
+}
+
+// backtrack walks the furthest reaching paths of trace back from the end of both
+// sequences, and returns the edit script they describe.
+func backtrack(a, b []string, trace [][]int, offset int) []diffOp {
+	x, y := len(a), len(b)
+	ops := make([]diffOp, 0, x+y)
+
+	for d := len(trace) - 1; d >= 0; d-- {
+		v := trace[d]
+		k := x - y
+
+		var prevK int
+		if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := v[offset+prevK]
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			x, y = x-1, y-1
+			ops = append(ops, diffOp{' ', a[x]})
+		}
+
+		if d > 0 {
+			if x == prevX {
+				ops = append(ops, diffOp{'+', b[prevY]})
+			} else {
+				ops = append(ops, diffOp{'-', a[prevX]})
+			}
+		}
+		x, y = prevX, prevY
+	}
+
+	for i, j := 0, len(ops)-1; i < j; i, j = i+1, j-1 {
+		ops[i], ops[j] = ops[j], ops[i]
+	}
+
+	return ops
+}
+
+// unifiedDiff returns the unified diff from a to b, labelled from and to.
+func unifiedDiff(from, to string, a, b []string) string {
+	ops := editScript(a, b)
+
+	var diff strings.Builder
+	diff.WriteString("--- " + from + "\n+++ " + to + "\n")
+
+	for start := 0; start < len(ops); {
+		// A hunk starts with the context preceding the next change, and ends once the
+		// changes are followed by more unchanged lines than twice the context.
+		change := start
+		for change < len(ops) && ops[change].kind == ' ' {
+			change++
+		}
+		if change == len(ops) {
+			break
+		}
+
+		first := change - diffContextLines
+		if first < start {
+			first = start
+		}
+
+		last, unchanged := change, 0
+		for end := change; end < len(ops) && unchanged <= 2*diffContextLines; end++ {
+			if ops[end].kind == ' ' {
+				unchanged++
+			} else {
+				last, unchanged = end, 0
+			}
+		}
+		end := last + 1 + diffContextLines
+		if end > len(ops) {
+			end = len(ops)
+		}
+
+		writeHunk(&diff, ops, first, end)
+		start = end
+	}
+
+	return diff.String()
+}
+
+// writeHunk writes the hunk made of ops[first:end] to diff.
+func writeHunk(diff *strings.Builder, ops []diffOp, first, end int) {
+	// The hunk's ranges start at the lines of each side preceding it.
+	aStart, bStart := 1, 1
+	for _, op := range ops[:first] {
+		if op.kind != '+' {
+			aStart++
+		}
+		if op.kind != '-' {
+			bStart++
+		}
+	}
+
+	aLen, bLen := 0, 0
+	for _, op := range ops[first:end] {
+		if op.kind != '+' {
+			aLen++
+		}
+		if op.kind != '-' {
+			bLen++
+		}
+	}
+
+	fmt.Fprintf(diff, "@@ -%s +%s @@\n", hunkRange(aStart, aLen), hunkRange(bStart, bLen))
+	for _, op := range ops[first:end] {
+		diff.WriteByte(op.kind)
+		diff.WriteString(op.line)
+		if !strings.HasSuffix(op.line, "\n") {
+			diff.WriteString("\n\\ No newline at end of file\n")
+		}
+	}
+}
+
+// hunkRange formats the range of lines of one side of a hunk. Empty ranges
+// start at the line preceding them, following the unified diff format.
+func hunkRange(start, length int) string {
+	switch length {
+	case 0:
+		return fmt.Sprintf("%d,0", start-1)
+	case 1:
+		return fmt.Sprintf("%d", start)
+	default:
+		return fmt.Sprintf("%d,%d", start, length)
+	}
+}
@@ -0,0 +1,151 @@
+package exec
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestSplitLines(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		input string
+		want  []string
+	}{
+		{input: "", want: nil},
+		{input: "a", want: []string{"a"}},
+		{input: "a\n", want: []string{"a\n"}},
+		{input: "a\nb", want: []string{"a\n", "b"}},
+		{input: "a\r\n\nb\n", want: []string{"a\r\n", "\n", "b\n"}},
+	}
+
+	for _, tt := range tests {
+		if got := splitLines(tt.input); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("splitLines(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestEditScript(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		a, b  string
+		edits int
+	}{
+		{name: "identical", a: "a\nb\nc\n", b: "a\nb\nc\n", edits: 0},
+		{name: "both empty", a: "", b: "", edits: 0},
+		{name: "added", a: "", b: "a\nb\n", edits: 2},
+		{name: "removed", a: "a\nb\n", b: "", edits: 2},
+		{name: "replaced", a: "a\nb\nc\n", b: "a\nB\nc\n", edits: 2},
+		{name: "inserted in the middle", a: "a\nc\n", b: "a\nb\nc\n", edits: 1},
+		{name: "moved", a: "a\nb\nc\nd\n", b: "b\nc\nd\na\n", edits: 2},
+		{name: "interleaved", a: "a\nb\nc\na\nb\nb\na\n", b: "c\nb\na\nb\na\nc\n", edits: 5},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			a, b := splitLines(tt.a), splitLines(tt.b)
+			ops := editScript(a, b)
+
+			var from, to []string
+			edits := 0
+			for _, op := range ops {
+				if op.kind != '+' {
+					from = append(from, op.line)
+				}
+				if op.kind != '-' {
+					to = append(to, op.line)
+				}
+				if op.kind != ' ' {
+					edits++
+				}
+			}
+
+			if !reflect.DeepEqual(from, a) || !reflect.DeepEqual(to, b) {
+				t.Fatalf("editScript(%q, %q) = %v, which turns %q into %q", tt.a, tt.b, ops, from, to)
+			}
+			if edits != tt.edits {
+				t.Errorf("editScript(%q, %q) made %d edits, want %d", tt.a, tt.b, edits, tt.edits)
+			}
+		})
+	}
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	t.Parallel()
+
+	numbered := func(replaced map[int]string) string {
+		var lines strings.Builder
+		for i := 1; i <= 20; i++ {
+			line, ok := replaced[i]
+			if !ok {
+				line = strconv.Itoa(i)
+			}
+			lines.WriteString(line + "\n")
+		}
+		return lines.String()
+	}
+
+	tests := []struct {
+		name string
+		a, b string
+		want string
+	}{
+		{
+			name: "replaced line",
+			a:    "a\nb\nc\n",
+			b:    "a\nB\nc\n",
+			want: "@@ -1,3 +1,3 @@\n a\n-b\n+B\n c\n",
+		},
+		{
+			name: "added to empty",
+			a:    "",
+			b:    "x\n",
+			want: "@@ -0,0 +1 @@\n+x\n",
+		},
+		{
+			name: "removed everything",
+			a:    "x\ny\n",
+			b:    "",
+			want: "@@ -1,2 +0,0 @@\n-x\n-y\n",
+		},
+		{
+			name: "missing newline at end of file",
+			a:    "a\n",
+			b:    "a\nb",
+			want: "@@ -1 +1,2 @@\n a\n+b\n\\ No newline at end of file\n",
+		},
+		{
+			name: "distant changes in separate hunks",
+			a:    numbered(nil),
+			b:    numbered(map[int]string{2: "two", 19: "nineteen"}),
+			want: "@@ -1,5 +1,5 @@\n 1\n-2\n+two\n 3\n 4\n 5\n" +
+				"@@ -16,5 +16,5 @@\n 16\n 17\n 18\n-19\n+nineteen\n 20\n",
+		},
+		{
+			name: "close changes in a single hunk",
+			a:    numbered(nil),
+			b:    numbered(map[int]string{5: "five", 10: "ten"}),
+			want: "@@ -2,12 +2,12 @@\n 2\n 3\n 4\n-5\n+five\n 6\n 7\n 8\n 9\n-10\n+ten\n 11\n 12\n 13\n",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			want := "--- expected\n+++ stdout\n" + tt.want
+			if got := unifiedDiff("expected", "stdout", splitLines(tt.a), splitLines(tt.b)); got != want {
+				t.Errorf("unifiedDiff(%q, %q) =\n%s\nwant\n%s", tt.a, tt.b, got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package exec
+
+import "os/exec"
+
+// discardsOutput returns true if the output of the command is discarded, and nothing else
+// consumes it: it isn't redirected to files, passed to a line callback, hashed, filtered,
+// recorded in a transcript, nor watched, and the command isn't a stage of a pipeline. Its
+// output streams can then be wired to the null device, sparing the pipes and the goroutines
+// draining them.
+func (c *Command) discardsOutput(opts *execOptions) bool {
+	return opts.discardOutput && c.stage == nil && opts.pty == nil &&
+		c.stdoutFile == nil && c.stderrFile == nil &&
+		c.onLine == nil && opts.onStdout == nil && opts.onStderr == nil &&
+		c.checksum == "" && c.azure == nil && c.powerShell == nil && len(c.emitted) == 0 &&
+		opts.filter == nil && opts.until == nil && opts.idleTimeout == 0 && opts.transcriptDir == ""
+}
+
+// runDiscarded starts cmd, whose output streams are wired to the null device, and returns
+// a function waiting for it to exit, returning its result. As its output isn't read, the
+// output metrics aren't emitted.
+func (c *Command) runDiscarded(cmd *exec.Cmd, opts *execOptions) (func() (*CommandResult, error), error) {
+	vuContext := c.vu.Context()
+	vuState := c.vu.State()
+
+	cmd.Stdout, cmd.Stderr = nil, nil
+
+	execution, err := c.startExecution(cmd, opts)
+	closeFiles(cmd.ExtraFiles...)
+	if err != nil {
+		return nil, err
+	}
+
+	return func() (*CommandResult, error) {
+		execution.wait()
+
+		if err := execution.checkExpectations(capturedOutput{}, capturedOutput{}); err != nil {
+			return nil, err
+		}
+
+		metricsContext, cancel := execution.metricsContext(vuContext)
+		defer cancel()
+
+		stats := execution.stats(outputTotals{}, outputTotals{})
+		stats.outputDiscarded = true
+		c.pushMetrics(metricsContext, vuState, stats)
+
+		result := newCommandResult(execution.exitCode, capturedOutput{}, capturedOutput{}, streamCapture{}, streamCapture{}, opts)
+		execution.annotate(result)
+
+		if err := execution.failure(capturedOutput{}); err != nil {
+			return nil, err
+		}
+
+		if err := execution.unmetExpectations(capturedOutput{}); err != nil {
+			return nil, err
+		}
+
+		return result, nil
+	}, nil
+}
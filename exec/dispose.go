@@ -0,0 +1,72 @@
+package exec
+
+import (
+	"time"
+
+	"github.com/dop251/goja"
+)
+
+// disposeTimeout is how long disposed processes are given to exit once asked to terminate,
+// before being killed.
+const disposeTimeout = 5 * time.Second
+
+// asyncDisposeSymbol returns the Symbol.asyncDispose well-known symbol of rt, defining it
+// if the runtime lacks it, as polyfills of explicit resource management do, so that the
+// module, scripts and transpiled code all use the same symbol.
+func asyncDisposeSymbol(rt *goja.Runtime) *goja.Symbol {
+	ctor := rt.Get("Symbol").ToObject(rt)
+	if sym, ok := ctor.Get("asyncDispose").(*goja.Symbol); ok {
+		return sym
+	}
+
+	sym := goja.NewSymbol("Symbol.asyncDispose")
+	_ = ctor.DefineDataProperty("asyncDispose", sym, goja.FLAG_FALSE, goja.FLAG_FALSE, goja.FLAG_FALSE)
+
+	return sym
+}
+
+// object returns the JS object exposing the process. It is only created once, so that the
+// Symbol.asyncDispose method defined on it is preserved by the methods returning the process.
+func (p *Process) object() *goja.Object {
+	if p.obj == nil {
+		rt := p.vu.Runtime()
+		p.obj = rt.ToValue(p).ToObject(rt)
+		_ = p.obj.SetSymbol(asyncDisposeSymbol(rt), p.dispose)
+	}
+
+	return p.obj
+}
+
+// dispose closes the standard input of the process and asks it to terminate, unless it already
+// exited, killing it if it didn't exit within disposeTimeout. It returns a promise resolved once
+// the process exited, and its standard output was released. It is the Symbol.asyncDispose method
+// of the process, so that it is cleaned up when leaving the scope it was declared in with
+// `await using`, exceptions included.
+func (p *Process) dispose() *goja.Promise {
+	promise, resolve, _ := makeHandledPromise(p.vu)
+
+	select {
+	case <-p.exited:
+	default:
+		p.execution.signalled.Store(true)
+		_ = p.Stdin.w.Close()
+		_ = terminateProcess(p.process)
+	}
+
+	go func() {
+		timer := time.NewTimer(disposeTimeout)
+		defer timer.Stop()
+
+		select {
+		case <-p.exited:
+		case <-timer.C:
+			_ = p.process.Kill()
+			<-p.exited
+		}
+
+		p.Stdout.finish()
+		resolve(goja.Undefined())
+	}()
+
+	return promise
+}
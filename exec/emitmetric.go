@@ -0,0 +1,163 @@
+package exec
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dop251/goja"
+	"github.com/sirupsen/logrus"
+	"go.k6.io/k6/js/common"
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/metrics"
+)
+
+// emittedMetric is a user-defined metric fed with the value parsed from the standard
+// output of the command.
+type emittedMetric struct {
+	metric *metrics.Metric
+
+	// parse, if set, is the regular expression extracting the value from the output: the
+	// value is its first capture group, if it has one, or else the whole match. Without it,
+	// the value is the whole output.
+	parse *regexp.Regexp
+}
+
+// EmitMetric returns a copy of the command whose standard output is parsed into a number,
+// emitted as a sample of the user-defined metric described by options, once it exited
+// successfully. The metric is registered, or reused if it already exists.
+func (c Command) EmitMetric(options goja.Value) Command {
+	rt := c.vu.Runtime()
+
+	m, err := c.parseEmittedMetric(rt, options)
+	if err != nil {
+		common.Throw(rt, err)
+	}
+
+	// The full slice expression makes appending copy the metrics,
+	// leaving the ones of the original command untouched.
+	c.emitted = append(c.emitted[:len(c.emitted):len(c.emitted)], m)
+	return c
+}
+
+// parseEmittedMetric parses the options object passed to EmitMetric, and registers the metric.
+func (c *Command) parseEmittedMetric(rt *goja.Runtime, v goja.Value) (*emittedMetric, error) {
+	if common.IsNullish(v) {
+		return nil, errors.New("emitMetric expects an object with at least a name")
+	}
+
+	var (
+		name       string
+		metricType = metrics.Gauge
+		valueType  = metrics.Default
+		m          = &emittedMetric{}
+	)
+
+	obj := v.ToObject(rt)
+	for _, key := range obj.Keys() {
+		value := obj.Get(key)
+
+		switch key {
+		case "name":
+			name = value.String()
+		case "type":
+			if err := metricType.UnmarshalText([]byte(value.String())); err != nil {
+				return nil, fmt.Errorf("invalid metric type %q; expected counter, gauge, rate or trend", value.String())
+			}
+		case "isTime":
+			if value.ToBoolean() {
+				valueType = metrics.Time
+			}
+		case "parse":
+			re, err := toRegexp(value)
+			if err != nil {
+				return nil, err
+			}
+			m.parse = re
+		default:
+			return nil, fmt.Errorf("unknown emitMetric option %q", key)
+		}
+	}
+
+	if name == "" {
+		return nil, errors.New("emitMetric expects the name of the metric")
+	}
+
+	metric, err := c.metrics.registry.NewMetric(name, metricType, valueType)
+	if err != nil {
+		return nil, fmt.Errorf("unable to register the %s metric: %w", name, err)
+	}
+	m.metric = metric
+
+	return m, nil
+}
+
+// value parses the value of the metric from the command's output.
+func (m *emittedMetric) value(output []byte) (float64, error) {
+	text := output
+	if m.parse != nil {
+		match := m.parse.FindSubmatch(output)
+		switch {
+		case match == nil:
+			return 0, fmt.Errorf("the output doesn't match %s", m.parse)
+		case len(match) > 1:
+			text = match[1]
+		default:
+			text = match[0]
+		}
+	}
+
+	trimmed := strings.TrimSpace(string(text))
+	value, err := strconv.ParseFloat(trimmed, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%q isn't a number", trimmed)
+	}
+
+	if m.metric.Type == metrics.Rate && value != 0 {
+		value = 1
+	}
+
+	return value, nil
+}
+
+// pushEmittedMetrics emits the samples of the user-defined metrics parsed from the output
+// of a command which exited successfully. As the output of a measuring command can't
+// always be relied upon, values failing to be parsed are logged rather than failing the
+// execution. No samples are emitted for commands executed outside of a VU state.
+func (c *Command) pushEmittedMetrics(
+	ctx context.Context, state *lib.State, logger logrus.FieldLogger, output capturedOutput, end time.Time,
+) {
+	if state == nil || len(c.emitted) == 0 {
+		return
+	}
+
+	b, err := output.bytes()
+	if err != nil {
+		logger.WithError(err).Warnf("unable to read the output of %s", c.Name)
+		return
+	}
+
+	tags := state.Tags.GetCurrentValues().Tags
+	tags = c.withCommandTags(tags)
+
+	samples := make([]metrics.Sample, 0, len(c.emitted))
+	for _, m := range c.emitted {
+		value, err := m.value(b)
+		if err != nil {
+			logger.WithError(err).Warnf("unable to parse the %s metric from the output of %s", m.metric.Name, c.Name)
+			continue
+		}
+
+		samples = append(samples, metrics.Sample{
+			TimeSeries: metrics.TimeSeries{Metric: m.metric, Tags: tags},
+			Value:      value,
+			Time:       end,
+		})
+	}
+
+	metrics.PushIfNotDone(ctx, state.Samples, metrics.Samples(samples))
+}
@@ -0,0 +1,179 @@
+package exec
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/dop251/goja"
+	"go.k6.io/k6/js/common"
+)
+
+// environ returns the environment of the command, out of the inherited environment: the
+// inherited variables, unless cleared or only some of them are inherited, sanitized unless
+// disabled by the configuration, the variables describing the execution context, unless
+// disabled too, the variables setting the configured locale, if any, and the variables set on
+// the command.
+func (c *Command) environ(ctx context.Context, inherited []string) []string {
+	config := c.config.resolve(c.vu)
+	inherited = c.inherits(inherited)
+	// The variables explicitly inherited by name are kept, even if they hold credentials.
+	if config.sanitizeEnv {
+		inherited = sanitizeEnviron(inherited, c.inheritEnv...)
+	}
+	// The variables explicitly set on the command take precedence over the execution context ones.
+	if config.executionContextEnv {
+		inherited = append(inherited, executionContextEnviron(ctx, c.vu.State())...)
+	}
+	inherited = append(inherited, localeEnviron(config.locale)...)
+
+	environ := make([]string, 0, len(c.env))
+	for k, v := range c.env {
+		environ = append(environ, k+"="+v)
+	}
+
+	return append(inherited, environ...)
+}
+
+// Environ returns the environment commands are executed with, or the provided command, either a
+// Cmd or an array holding the name of a command followed by its arguments, is, as an object, so
+// that what commands actually see can be verified. The variables set for each execution only,
+// such as K6_EXEC_RUN_ID, aren't included. As it exposes the environment of k6, it is prohibited
+// whenever executing commands is.
+func (mi *ModuleInstance) Environ(spec goja.Value) map[string]string {
+	rt := mi.vu.Runtime()
+
+	command := mi.newCommand("")
+	if !common.IsNullish(spec) {
+		var ok bool
+		if command, ok = mi.commandFromSpec(spec); !ok {
+			common.Throw(rt, errors.New("environ expects a Cmd, or an array holding a command and its arguments"))
+		}
+	}
+
+	if reason := prohibitedReason(command.config.resolve(mi.vu)); reason != "" {
+		common.Throw(rt, fmt.Errorf("inspecting the environment of commands is prohibited in this environment (%s)", reason))
+	}
+
+	// Later occurrences of a variable take precedence, as they do for the executed commands.
+	ctx := mi.vu.Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	environ := make(map[string]string)
+	for _, kv := range command.environ(ctx, os.Environ()) {
+		if key, value, ok := strings.Cut(kv, "="); ok {
+			if command.sensitive[key] {
+				value = redactedValue
+			}
+			environ[key] = value
+		}
+	}
+
+	return environ
+}
+
+// redactedValue is what the values of sensitive environment variables are replaced with.
+const redactedValue = "***"
+
+// ClearEnv returns a copy of the command started from an empty environment, rather than
+// inheriting the one of k6, only the variables set on it, and the ones the module sets,
+// such as the ones describing the execution context, being passed to it.
+func (c Command) ClearEnv() Command {
+	c.clearEnv = true
+	c.inheritEnv = nil
+	return c
+}
+
+// InheritEnv returns a copy of the command only inheriting the variables of the environment
+// of k6 named by patterns, either names, or prefixes followed by an asterisk, such as AWS_*.
+func (c Command) InheritEnv(patterns ...string) Command {
+	for _, pattern := range patterns {
+		if pattern == "" || pattern == "*" {
+			common.Throw(c.vu.Runtime(), fmt.Errorf("invalid inheritEnv pattern %q; expected a name, or a prefix followed by *", pattern))
+		}
+	}
+
+	c.clearEnv = false
+	c.inheritEnv = append([]string(nil), patterns...)
+	return c
+}
+
+// EnvFrom returns a copy of the command with all the environment variables held by an
+// object, or a Map, set, the same way as Env does when called with one.
+func (c Command) EnvFrom(vars goja.Value) Command {
+	return c.Env(vars, goja.Undefined())
+}
+
+// Sensitive returns a copy of the command whose named environment variables, whether set on it
+// or inherited, are sensitive: their values are redacted from the errors its executions fail
+// with, from its transcripts, from the environment inspected using Environ, and from its tags.
+func (c Command) Sensitive(names ...string) Command {
+	sensitive := make(map[string]bool, len(c.sensitive)+len(names))
+	for name := range c.sensitive {
+		sensitive[name] = true
+	}
+	for _, name := range names {
+		sensitive[name] = true
+	}
+
+	c.sensitive = sensitive
+	return c
+}
+
+// inherits returns the variables of inherited, a list of KEY=value pairs, the command inherits.
+func (c *Command) inherits(inherited []string) []string {
+	if c.clearEnv {
+		return nil
+	}
+	if len(c.inheritEnv) == 0 {
+		return inherited
+	}
+
+	kept := make([]string, 0, len(c.inheritEnv))
+	for _, kv := range inherited {
+		key, _, _ := strings.Cut(kv, "=")
+		for _, pattern := range c.inheritEnv {
+			if prefix, ok := strings.CutSuffix(pattern, "*"); (ok && strings.HasPrefix(key, prefix)) || key == pattern {
+				kept = append(kept, kv)
+				break
+			}
+		}
+	}
+
+	return kept
+}
+
+// redact returns s with the values of the sensitive environment variables of the command replaced.
+func (c *Command) redact(s string) string {
+	for name := range c.sensitive {
+		value, ok := c.env[name]
+		if !ok {
+			value = os.Getenv(name)
+		}
+
+		if value != "" {
+			// Values are quoted on the command lines of commands run on remote hosts.
+			s = strings.ReplaceAll(s, quoteArgs([]string{value}), redactedValue)
+			s = strings.ReplaceAll(s, value, redactedValue)
+		}
+	}
+
+	return s
+}
+
+// redactArgs returns a copy of args with the values of the sensitive environment variables of the command replaced.
+func (c *Command) redactArgs(args []string) []string {
+	if len(c.sensitive) == 0 {
+		return args
+	}
+
+	redacted := make([]string, len(args))
+	for i, arg := range args {
+		redacted[i] = c.redact(arg)
+	}
+
+	return redacted
+}
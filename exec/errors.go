@@ -0,0 +1,178 @@
+package exec
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/dop251/goja"
+	"go.k6.io/k6/js/common"
+)
+
+// stderrSnippetSize is the maximum amount of bytes of the standard
+// error included in the message of the errors of failed commands.
+const stderrSnippetSize = 1024
+
+// ExecError is the error the promise returned by Exec is rejected with when the command timed
+// out, exceeded its scratch directory quota, was aborted, or failed while throwOnError is set.
+type ExecError struct {
+	// Message is the error message, as returned by Error.
+	Message string `js:"message"`
+
+	// Command is the name of the command.
+	Command string `js:"command"`
+
+	// ExitCode is the exit code of the command, or -1 if it was killed by a signal.
+	ExitCode int `js:"exitCode"`
+
+	// Signal is the name of the signal which killed the command, if any.
+	Signal string `js:"signal"`
+
+	// TimedOut is true if the command was killed for exceeding its timeout,
+	// or its idle timeout.
+	TimedOut bool `js:"timedOut"`
+
+	// Reason is the reason the command was killed for
+	// (timeout, idle_timeout, disk_quota or aborted), if any.
+	Reason string `js:"reason"`
+
+	// Stderr holds the last bytes the command wrote to its standard error.
+	Stderr string `js:"stderr"`
+
+	// timeout is the timeout the command exceeded, if it timed out.
+	timeout time.Duration
+	// quota is the scratch directory quota the command exceeded, if it did.
+	quota int64
+	// abortReason is the reason the signal the command was aborted by was aborted for, if any.
+	abortReason string
+}
+
+// Error implements the error interface.
+func (e *ExecError) Error() string {
+	var msg strings.Builder
+
+	switch e.Reason {
+	case killReasonTimeout:
+		fmt.Fprintf(&msg, "command %q timed out after %s", e.Command, e.timeout)
+	case killReasonIdleTimeout:
+		fmt.Fprintf(&msg, "command %q timed out after producing no output for %s", e.Command, e.timeout)
+	case killReasonDiskQuota:
+		fmt.Fprintf(&msg, "command %q exceeded its scratch directory quota of %d bytes", e.Command, e.quota)
+	case killReasonAborted:
+		fmt.Fprintf(&msg, "command %q was aborted", e.Command)
+		if e.abortReason != "" {
+			fmt.Fprintf(&msg, ": %s", e.abortReason)
+		}
+	default:
+		fmt.Fprintf(&msg, "command %q failed", e.Command)
+	}
+
+	if e.Signal != "" {
+		fmt.Fprintf(&msg, " (signal: %s)", e.Signal)
+	} else {
+		fmt.Fprintf(&msg, " (exit code %d)", e.ExitCode)
+	}
+
+	if e.Stderr != "" {
+		fmt.Fprintf(&msg, "\nstderr:\n%s", e.Stderr)
+	}
+
+	return msg.String()
+}
+
+// stderrSnippet returns the last stderrSnippetSize bytes of stderr, trimmed,
+// and prefixed by an ellipsis if the beginning of the output was dropped.
+func stderrSnippet(stderr []byte) string {
+	var truncated bool
+	if len(stderr) > stderrSnippetSize {
+		stderr, truncated = stderr[len(stderr)-stderrSnippetSize:], true
+
+		// Don't start the snippet in the middle of a multi-byte character.
+		for len(stderr) > 0 && !utf8.RuneStart(stderr[0]) {
+			stderr = stderr[1:]
+		}
+	}
+
+	snippet := strings.TrimSpace(string(stderr))
+	if truncated && snippet != "" {
+		snippet = "..." + snippet
+	}
+
+	return snippet
+}
+
+// jsError wraps errors promises are rejected with, so that they are
+// converted to JS Error objects rather than exposed as Go values.
+type jsError struct {
+	error
+}
+
+// Ensure the interfaces are implemented correctly
+var _ jsValuer = jsError{}
+
+// toJSValue implements the jsValuer interface. Exec errors are converted to
+// errors named ExecError, holding the details of how the command failed,
+// expectation errors to errors named ExpectationError, holding the violated
+// expectations, the errors of executions failing as the host is overloaded
+// to errors named HostOverloadedError, and the errors of executions cancelled
+// by their signal option to errors named AbortError.
+func (e jsError) toJSValue(rt *goja.Runtime) goja.Value {
+	obj := rt.NewGoError(e.error)
+
+	if errors.Is(e.error, errHostOverloaded) {
+		if err := obj.Set("name", "HostOverloadedError"); err != nil {
+			common.Throw(rt, err)
+		}
+		return obj
+	}
+
+	if errors.Is(e.error, errAborted) {
+		if err := obj.Set("name", "AbortError"); err != nil {
+			common.Throw(rt, err)
+		}
+		return obj
+	}
+
+	var expectErr *ExpectationError
+	if errors.As(e.error, &expectErr) {
+		for key, value := range map[string]interface{}{
+			"name":       "ExpectationError",
+			"command":    expectErr.Command,
+			"violations": expectErr.Violations,
+			"stderr":     expectErr.Stderr,
+		} {
+			if err := obj.Set(key, value); err != nil {
+				common.Throw(rt, err)
+			}
+		}
+		return obj
+	}
+
+	var execErr *ExecError
+	if !errors.As(e.error, &execErr) {
+		return obj
+	}
+
+	name := "ExecError"
+	if execErr.Reason == killReasonAborted {
+		name = "AbortError"
+	}
+
+	for key, value := range map[string]interface{}{
+		"name":     name,
+		"command":  execErr.Command,
+		"exitCode": execErr.ExitCode,
+		"signal":   execErr.Signal,
+		"timedOut": execErr.TimedOut,
+		"reason":   execErr.Reason,
+		"stderr":   execErr.Stderr,
+	} {
+		if err := obj.Set(key, value); err != nil {
+			common.Throw(rt, err)
+		}
+	}
+
+	return obj
+}
@@ -0,0 +1,177 @@
+package exec
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/dop251/goja"
+	"go.k6.io/k6/js/common"
+	"go.k6.io/k6/js/modules"
+)
+
+// The events emitted by processes.
+const (
+	processEventExit   = "exit"
+	processEventStdout = "stdout"
+	processEventStderr = "stderr"
+	processEventError  = "error"
+)
+
+// processEvents holds the names of the events emitted by processes.
+var processEvents = []string{processEventExit, processEventStdout, processEventStderr, processEventError} //nolint:gochecknoglobals
+
+// emitter calls the listeners registered for an event, on the event loop, when it is emitted.
+// While listeners are registered, and sources may still emit events, it keeps the event loop
+// running, so that promises settled by listeners, e.g. once the process exited, can be awaited.
+type emitter struct {
+	vu modules.VU
+
+	mu        sync.Mutex
+	listeners map[string][]goja.Callable
+
+	// sources is the number of goroutines which may still emit events.
+	sources int
+	// held, if set, is the event loop callback reserved while sources may emit events to listeners.
+	held func(func() error)
+}
+
+// newEmitter returns a new emitter, with no listeners.
+func newEmitter(vu modules.VU) *emitter {
+	return &emitter{vu: vu, listeners: make(map[string][]goja.Callable)}
+}
+
+// on registers fn as a listener of event. It must be called on the event loop.
+func (em *emitter) on(event string, fn goja.Callable) {
+	em.mu.Lock()
+	defer em.mu.Unlock()
+
+	em.listeners[event] = append(em.listeners[event], fn)
+	if em.sources > 0 && em.held == nil {
+		em.held = em.vu.RegisterCallback()
+	}
+}
+
+// open registers a source of events, which must call done once it emitted its last event.
+// It must be called on the event loop, or before the emitter is returned to the script.
+func (em *emitter) open() {
+	em.mu.Lock()
+	defer em.mu.Unlock()
+
+	em.sources++
+	if len(em.listeners) > 0 && em.held == nil {
+		em.held = em.vu.RegisterCallback()
+	}
+}
+
+// done unregisters a source of events, releasing the event loop once no source is left.
+func (em *emitter) done() {
+	em.mu.Lock()
+	defer em.mu.Unlock()
+
+	em.sources--
+	if em.sources == 0 && em.held != nil {
+		em.held(func() error { return nil })
+		em.held = nil
+	}
+}
+
+// emit calls the listeners of event, on the event loop, with the arguments returned by args.
+// Events emitted while no listener is registered are dropped.
+func (em *emitter) emit(event string, args func(rt *goja.Runtime) []goja.Value) {
+	em.mu.Lock()
+	listeners := em.listeners[event]
+	em.mu.Unlock()
+
+	if len(listeners) == 0 {
+		return
+	}
+
+	callback := em.vu.RegisterCallback()
+	callback(func() error {
+		rt := em.vu.Runtime()
+		values := args(rt)
+		for _, fn := range listeners {
+			if _, err := fn(goja.Undefined(), values...); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// emitWriter is an io.Writer emitting each chunk written to it as event.
+type emitWriter struct {
+	em    *emitter
+	event string
+}
+
+// Write implements the io.Writer interface.
+func (w emitWriter) Write(p []byte) (int, error) {
+	chunk := append([]byte(nil), p...)
+	w.em.emit(w.event, func(rt *goja.Runtime) []goja.Value {
+		return []goja.Value{toUint8Array(rt, chunk)}
+	})
+
+	return len(p), nil
+}
+
+// On registers fn as a listener of the named event, and returns the process, so that
+// calls can be chained. The supported events are:
+//   - exit: emitted with the result of the process once it exited.
+//   - stdout and stderr: emitted with each chunk, as a Uint8Array, the process writes to
+//     the corresponding stream. Listening to stdout locks the process's stdout stream.
+//   - error: emitted with the error the process failed with, if it timed out or failed
+//     while throwOnError is set.
+//
+// As long as listeners are registered, the iteration doesn't end before the process exited,
+// and its stdout stream was read up to its end, if stdout is listened to.
+func (p *Process) On(event string, fn goja.Value) *goja.Object {
+	rt := p.vu.Runtime()
+
+	listener, ok := goja.AssertFunction(fn)
+	if !ok {
+		common.Throw(rt, errors.New("on expects a function"))
+	}
+
+	if !contains(processEvents, event) {
+		common.Throw(rt, fmt.Errorf("unsupported process event %q", event))
+	}
+
+	p.events.on(event, listener)
+
+	if event == processEventStdout && !p.Stdout.locked {
+		p.Stdout.locked = true
+		p.Stdout.consumed.Store(true)
+		p.events.open()
+		go p.pumpStdout()
+	}
+
+	return p.object()
+}
+
+// pumpStdout reads the process's standard output until EOF, emitting each chunk read.
+func (p *Process) pumpStdout() {
+	defer p.events.done()
+
+	w := emitWriter{em: p.events, event: processEventStdout}
+	for {
+		chunk, err := p.Stdout.readChunk()
+		if len(chunk) > 0 {
+			_, _ = w.Write(chunk)
+		}
+
+		if errors.Is(err, io.EOF) {
+			return
+		}
+
+		if err != nil {
+			p.events.emit(processEventError, func(rt *goja.Runtime) []goja.Value {
+				return []goja.Value{rt.ToValue(err)}
+			})
+			return
+		}
+	}
+}
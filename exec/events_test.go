@@ -0,0 +1,70 @@
+//go:build !windows
+
+package exec
+
+import (
+	"testing"
+
+	"go.k6.io/k6/lib"
+)
+
+func TestProcessEvents(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		script string
+		want   string
+	}{
+		{
+			name: "exit",
+			script: `new Promise((resolve) => {
+				new exec.Cmd("sh").args(["-c", "sleep 0.1; exit 3"]).spawn().on("exit", (result) => resolve(result.exitCode));
+			})`,
+			want: "3",
+		},
+		{
+			name: "error",
+			script: `new Promise((resolve) => {
+				new exec.Cmd("false").spawn({ throwOnError: true }).on("error", () => resolve("failed"));
+			})`,
+			want: "failed",
+		},
+		{
+			name: "stdout",
+			script: `new Promise((resolve) => {
+				let stdout = "";
+				new exec.Cmd("sh").args(["-c", "echo a; sleep 0.1; echo b"]).spawn()
+					.on("stdout", (chunk) => {
+						stdout += String.fromCharCode(...chunk);
+						if (stdout.endsWith("b\n")) resolve(stdout);
+					});
+			})`,
+			want: "a\nb\n",
+		},
+		{
+			name: "stderr",
+			script: `new Promise((resolve) => {
+				let stderr = "";
+				new exec.Cmd("sh").args(["-c", "sleep 0.1; echo oops >&2"]).spawn()
+					.on("stderr", (chunk) => { stderr += String.fromCharCode(...chunk); })
+					.on("exit", () => resolve(stderr));
+			})`,
+			want: "oops\n",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			vu := newTestVU(t, nil)
+			vu.moveToVUContext(lib.Options{})
+
+			if got := vu.mustRun(tt.script).String(); got != tt.want {
+				t.Errorf("the listeners resolved the promise with %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,626 @@
+package exec
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"go.k6.io/k6/lib"
+)
+
+// The reasons the module kills commands for.
+const (
+	killReasonTimeout     = "timeout"
+	killReasonIdleTimeout = "idle_timeout"
+	killReasonDiskQuota   = "disk_quota"
+	killReasonAborted     = "aborted"
+
+	// The commands the module didn't kill for any of the reasons above are
+	// reported as killed when the VU context was done before they exited,
+	// or when they were killed by a signal sent by the script.
+	killReasonVUCancelled = "vu_cancelled"
+	killReasonManual      = "manual"
+)
+
+// execution tracks a single execution of a command, from its start to its exit.
+type execution struct {
+	command *Command
+	cmd     *exec.Cmd
+	opts    *execOptions
+
+	// ctx is the VU context the command is bound to.
+	ctx context.Context
+
+	// runID uniquely identifies the execution, so that its artifacts can be correlated.
+	runID string
+
+	// release releases the slot the execution was granted by the concurrency limiter.
+	release func()
+
+	start    time.Time
+	end      time.Time
+	exitCode int
+	// signal is the name of the signal which killed the command, if any.
+	signal string
+
+	// timer kills the command once its timeout elapsed, if it has one.
+	timer *time.Timer
+	// slow warns about the command once its warnAfter threshold elapsed, if it has one.
+	slow *time.Timer
+	// idle kills the command once it produced no output for its idle timeout, if it has one.
+	idle *idleWatchdog
+
+	// grace is how long the command is given to exit once the VU context is done.
+	grace time.Duration
+
+	// exited is closed once the command exited.
+	exited chan struct{}
+
+	// killReason is the reason the command was killed by the module for, if any, and abortReason
+	// the reason the AbortSignal it was killed once aborted was aborted for, if any.
+	killReason  string
+	abortReason string
+	killMu      sync.Mutex
+
+	// killedBy is the reason the command was killed for, by the module or by the script,
+	// if it was: the kill reason, or the one inferred once it exited.
+	killedBy string
+
+	// signalled is set once the script sent a signal to the command.
+	signalled atomic.Bool
+
+	// lifecycleFields are the fields of the lifecycle events logged for the execution,
+	// if it was sampled for them to be.
+	lifecycleFields logrus.Fields
+
+	// tree is the process tree of the command, if it is killed along with the processes it started.
+	tree *processTree
+	// treeAttached is set once the process tree was attached to the started command, and
+	// treeSignalled once a signal was sent to it.
+	treeAttached  atomic.Bool
+	treeSignalled atomic.Bool
+
+	// escalateOnce ensures the kill sequence is only run through once.
+	escalateOnce sync.Once
+
+	// matched is set once the command's output matched the until option, and
+	// matchOnce ensures the command is only stopped once.
+	matched   atomic.Bool
+	matchOnce sync.Once
+
+	// oomKills is the value of the OOM kill counter when the command started.
+	oomKills oomKillCount
+	// oomKilled is set if the command was killed by the OOM killer.
+	oomKilled bool
+
+	// usage holds the resources the command consumed, once it exited.
+	usage resourceUsage
+
+	// outcome is the named outcome the exit code maps to, if any.
+	outcome string
+
+	// violations describe the expectations the execution didn't meet, if any.
+	violations []string
+
+	// scratch is the path of the scratch directory created for the execution, if any.
+	scratch string
+
+	// transcript records the execution, if the transcript option is set.
+	transcript *transcript
+	// transcriptStreams record the output streams in the transcript.
+	transcriptStreams []*transcriptStream
+
+	// metricsRead is closed once the metrics written by the command were read, if it has a metrics channel.
+	metricsRead <-chan struct{}
+
+	// stdinFed receives the error feeding the command's standard input failed with, if any,
+	// once it was fed, when it is fed from an iterator, and stdinErr holds it.
+	stdinFed <-chan error
+	stdinErr error
+}
+
+// startExecution starts cmd, and applies the execution options which need the process to exist.
+func (c *Command) startExecution(cmd *exec.Cmd, opts *execOptions) (*execution, error) {
+	if err := c.abortedBeforeStart(opts); err != nil {
+		return nil, err
+	}
+
+	release, err := c.acquireSlot()
+	if err != nil {
+		return nil, err
+	}
+
+	e := &execution{
+		command:  c,
+		cmd:      cmd,
+		opts:     opts,
+		ctx:      c.vu.Context(),
+		runID:    newUUID(),
+		grace:    c.gracePeriod(opts),
+		oomKills: readOOMKillCount(),
+		exited:   make(chan struct{}),
+		release:  release,
+	}
+	e.prepareTermination()
+
+	channels, err := e.openChannels()
+	if err != nil {
+		release()
+		return nil, err
+	}
+
+	cmd.Env = append(cmd.Env, runIDEnvVar+"="+e.runID)
+	if opts.debug {
+		e.debugCommand()
+	}
+
+	e.start = time.Now()
+	retried, err := startWithRetries(e.ctx, cmd, opts)
+	startLatency := time.Since(e.start)
+	c.pushStartRetries(e.ctx, c.vu.State(), retried, time.Now())
+	closeFiles(channels.metricsWriter, channels.stdinReader)
+	if err != nil {
+		channels.close(e)
+		release()
+		return nil, err
+	}
+
+	e.attachProcessTree()
+	c.processes.add(e)
+	if opts.debug {
+		e.debugStarted(startLatency)
+	}
+	e.sampleLifecycleLogs(c.config.resolve(c.vu).lifecycleLogSampling)
+	e.logLifecycle(lifecycleEventStart, nil)
+
+	if channels.stdin != nil {
+		e.stdinFed = channels.stdin.feed(channels.stdinWriter, e.exited)
+	}
+
+	if channels.metricsReader != nil {
+		e.metricsRead = c.readMetrics(c.vu.Context(), c.vu.State(), c.logger(), channels.metricsReader)
+	}
+
+	e.applyCoreDumpPolicy()
+	e.applyOOMScoreAdj()
+	e.watchAbortSignal()
+
+	if opts.transcriptDir != "" {
+		e.openTranscript()
+	}
+
+	e.startWatchdogs()
+
+	return e, nil
+}
+
+// prepareTermination sets how the command is terminated once the VU context is done.
+func (e *execution) prepareTermination() {
+	cmd, opts := e.cmd, e.opts
+
+	// Commands with a kill sequence run through it once the VU context is done, and are given
+	// the time it takes to exit, on top of their grace period, rather than being killed.
+	if len(opts.killSequence) > 0 {
+		cmd.Cancel = func() error {
+			e.escalateOnce.Do(func() { go e.escalate(e.opts.killSequence) })
+			return nil
+		}
+		e.grace += killSequenceDuration(opts.killSequence)
+	}
+
+	// Commands killed along with the processes they started are started in a process tree of
+	// their own, signalled as a whole once the VU context is done. The processes left in it once
+	// the command exited, or was killed at the end of its grace period, are killed.
+	if opts.killTree && opts.systemd == nil {
+		e.tree = prepareProcessTree(cmd)
+		if len(opts.killSequence) == 0 {
+			signal := "SIGKILL"
+			if e.grace > 0 {
+				signal = "SIGTERM"
+			}
+			cmd.Cancel = func() error { return e.deliver(signal) }
+		}
+	}
+}
+
+// attachProcessTree attaches the process tree, if any, to the started command.
+func (e *execution) attachProcessTree() {
+	if e.tree == nil {
+		return
+	}
+
+	// The command can be cancelled as soon as it started, the process itself being signalled
+	// until the tree is attached.
+	if err := e.tree.attach(e.cmd.Process); err != nil {
+		e.logger().WithError(err).Debug("unable to kill the processes started by " + e.command.Name + " along with it")
+	} else {
+		e.treeAttached.Store(true)
+	}
+}
+
+// executionChannels holds the scratch directory, metrics channel, and standard input pipe,
+// set up for an execution before the command is started, the ones it doesn't use being nil.
+type executionChannels struct {
+	metricsReader, metricsWriter *os.File
+
+	stdin       *stdinIterator
+	stdinReader *os.File
+	stdinWriter io.WriteCloser
+}
+
+// openChannels creates the scratch directory of the execution, and opens its metrics
+// channel, and the pipe its standard input is fed through, if they're enabled.
+func (e *execution) openChannels() (*executionChannels, error) {
+	if e.opts.scratchDir != nil {
+		if err := e.createScratchDir(); err != nil {
+			return nil, err
+		}
+	}
+
+	channels := &executionChannels{}
+	if e.opts.metricsChannel {
+		var err error
+		if channels.metricsReader, channels.metricsWriter, err = openMetricsChannel(e.cmd); err != nil {
+			e.removeScratchDir()
+			return nil, err
+		}
+	}
+
+	if source := e.command.stdinSource(e.opts); source != nil {
+		var err error
+		channels.stdin, channels.stdinReader, channels.stdinWriter, err = source.stdinPipe(e.command.vu, e.cmd)
+		if err != nil {
+			closeFiles(channels.metricsReader, channels.metricsWriter)
+			e.removeScratchDir()
+			return nil, err
+		}
+	}
+
+	return channels, nil
+}
+
+// close releases the channels of an execution whose command failed to start, the ends
+// passed to the command being closed already.
+func (channels *executionChannels) close(e *execution) {
+	if channels.stdinWriter != nil {
+		_ = channels.stdinWriter.Close()
+	}
+	closeFiles(channels.metricsReader)
+	e.removeScratchDir()
+}
+
+// startWatchdogs starts the timers and goroutines enforcing the timeouts, quota,
+// and pauses of the started command, and reporting how long it runs for.
+func (e *execution) startWatchdogs() {
+	c, opts := e.command, e.opts
+
+	if opts.timeout > 0 {
+		e.timer = time.AfterFunc(opts.timeout, func() { e.kill(killReasonTimeout) })
+	}
+
+	if opts.idleTimeout > 0 {
+		e.idle = newIdleWatchdog(opts.idleTimeout, func() { e.kill(killReasonIdleTimeout) })
+	}
+
+	if opts.scratchDir != nil && opts.scratchDir.quota > 0 {
+		go e.enforceQuota()
+	}
+
+	if opts.warnAfter > 0 {
+		ctx, state := c.vu.Context(), c.vu.State()
+		e.slow = time.AfterFunc(opts.warnAfter, func() { e.warnSlow(ctx, state) })
+	}
+
+	if opts.heartbeatInterval > 0 {
+		go e.heartbeat(c.vu.Context(), c.vu.State())
+	}
+
+	if es := lib.GetExecutionState(c.vu.Context()); opts.suspendOnPause && es != nil {
+		logger := c.logger()
+		go e.suspendWhilePaused(es, func(err error, msg string) { logger.WithError(err).Warn(msg) })
+	}
+}
+
+// logger returns the logger of the command, with the run ID of the execution as a field,
+// so that the records it logs can be correlated with its other artifacts.
+func (e *execution) logger() logrus.FieldLogger {
+	return e.command.logger().WithField("run_id", e.runID)
+}
+
+// applyCoreDumpPolicy applies the core dump policy to the started command. As it
+// is best-effort, failures are logged rather than failing the execution.
+func (e *execution) applyCoreDumpPolicy() {
+	if e.opts.coreDumps == coreDumpsInherit {
+		return
+	}
+
+	if err := applyCoreDumpPolicy(e.cmd.Process.Pid, e.opts.coreDumps); err != nil {
+		e.logger().WithError(err).
+			Warnf("unable to apply the %s core dump policy to %s", e.opts.coreDumps, e.command.Name)
+	}
+}
+
+// applyOOMScoreAdj applies the OOM score adjustment to the started command. As it
+// is best-effort, failures are logged rather than failing the execution.
+func (e *execution) applyOOMScoreAdj() {
+	if e.opts.oomScoreAdj == nil {
+		return
+	}
+
+	if err := adjustOOMScore(e.cmd.Process.Pid, *e.opts.oomScoreAdj); err != nil {
+		e.logger().WithError(err).
+			Warnf("unable to adjust the OOM score of %s to %d", e.command.Name, *e.opts.oomScoreAdj)
+	}
+}
+
+// heartbeat periodically emits how long the command has been running
+// for, until it exits.
+func (e *execution) heartbeat(ctx context.Context, state *lib.State) {
+	ticker := time.NewTicker(e.opts.heartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case now := <-ticker.C:
+			e.command.pushHeartbeat(ctx, state, e.start, now)
+		case <-e.exited:
+			return
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// warnSlow logs a warning, and emits a sample, about the command
+// exceeding its warnAfter threshold.
+func (e *execution) warnSlow(ctx context.Context, state *lib.State) {
+	e.logger().WithFields(logrus.Fields{
+		"executable": e.command.Name,
+		"pid":        e.cmd.Process.Pid,
+		"threshold":  e.opts.warnAfter.String(),
+	}).Warnf("%s is still running after %s", e.command.Name, e.opts.warnAfter)
+
+	e.command.pushSlow(ctx, state, time.Now())
+}
+
+// kill kills the command for the provided reason, running through its kill sequence
+// if it has one. Only the first reason is retained.
+func (e *execution) kill(reason string) {
+	e.killMu.Lock()
+	if e.killReason == "" {
+		e.killReason = reason
+	}
+	e.killMu.Unlock()
+
+	if len(e.opts.killSequence) > 0 {
+		e.escalateOnce.Do(func() { go e.escalate(e.opts.killSequence) })
+		return
+	}
+
+	_ = e.deliver("SIGKILL")
+}
+
+// deliver sends the named signal to the command, or to its whole unit if it runs as a systemd
+// unit, or to its whole process tree if it is killed along with the processes it started.
+func (e *execution) deliver(name string) error {
+	if e.opts.systemd != nil && e.opts.systemd.signal(name) == nil {
+		return nil
+	}
+
+	if e.signalTree(name) {
+		return nil
+	}
+
+	if name == "SIGKILL" {
+		return e.cmd.Process.Kill()
+	}
+
+	return sendSignal(e.cmd.Process, name)
+}
+
+// reason returns the reason the command was killed by the module for, if any.
+func (e *execution) reason() string {
+	e.killMu.Lock()
+	defer e.killMu.Unlock()
+
+	return e.killReason
+}
+
+// timedOut returns true if the command was killed for exceeding its timeout, or idle timeout.
+func (e *execution) timedOut() bool {
+	reason := e.reason()
+	return reason == killReasonTimeout || reason == killReasonIdleTimeout
+}
+
+// observe returns r, the named output stream, wrapped so that reading output from it is reported
+// to the idle watchdog, if the command has an idle timeout, that the command is stopped once a
+// line read from it matches the until option, if set, and that it is recorded in the transcript.
+func (e *execution) observe(stream string, r io.Reader) io.Reader {
+	if e.transcript != nil {
+		ts := e.transcript.stream(stream)
+		e.transcriptStreams = append(e.transcriptStreams, ts)
+		r = io.TeeReader(r, ts)
+	}
+
+	if e.opts.until != nil {
+		r = io.TeeReader(r, &lineWatcher{fn: func(line []byte) {
+			if e.opts.until.Match(line) {
+				e.stop()
+			}
+		}})
+	}
+
+	if e.idle == nil {
+		return r
+	}
+
+	return activityReader{r: r, touch: e.idle.touch}
+}
+
+// wait waits for the command to exit, and records how it did.
+func (e *execution) wait() {
+	e.exitCode = exitCodeOf(e.cmd.Wait())
+	e.end = time.Now()
+	e.reapTree()
+	e.release()
+	e.command.processes.remove(e)
+	close(e.exited)
+	if e.stdinFed != nil {
+		e.stdinErr = <-e.stdinFed
+	}
+	if e.timer != nil {
+		e.timer.Stop()
+	}
+	if e.slow != nil {
+		e.slow.Stop()
+	}
+	if e.idle != nil {
+		e.idle.stop()
+	}
+	e.removeScratchDir()
+	if e.opts.systemd != nil {
+		if err := e.opts.systemd.stop(); err != nil {
+			e.logger().WithError(err).Debug("unable to stop the unit of " + e.command.Name)
+		}
+	}
+
+	if ws, ok := e.cmd.ProcessState.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+		e.signal = ws.Signal().String()
+	}
+	e.killedBy = e.inferKillReason()
+	if e.opts.debug {
+		e.debugExited()
+	}
+	e.logExit()
+	e.oomKilled = wasOOMKilled(e.cmd.ProcessState, e.oomKills)
+	e.usage = readResourceUsage(e.cmd.ProcessState)
+	e.outcome = e.opts.outcomes.resolve(e.exitCode)
+
+	if e.metricsRead != nil {
+		<-e.metricsRead
+	}
+}
+
+// signalProcess sends the named signal to the command, on behalf of the script.
+func (e *execution) signalProcess(name string) error {
+	e.signalled.Store(true)
+	return sendSignal(e.cmd.Process, name)
+}
+
+// inferKillReason returns the reason the exited command was killed for, if it was: the reason
+// the module killed it for, if any, vu_cancelled if the VU context was done before it exited,
+// or manual if it was killed by a signal after the script sent it one.
+func (e *execution) inferKillReason() string {
+	if reason := e.reason(); reason != "" {
+		return reason
+	}
+
+	switch {
+	case e.matched.Load():
+		return ""
+	case e.ctx != nil && e.ctx.Err() != nil:
+		return killReasonVUCancelled
+	case e.signal != "" && e.signalled.Load():
+		return killReasonManual
+	default:
+		return ""
+	}
+}
+
+// stats returns the measurements of the execution.
+func (e *execution) stats(stdout, stderr outputTotals) executionStats {
+	return executionStats{
+		runID:       e.runID,
+		exitCode:    e.exitCode,
+		start:       e.start,
+		end:         e.end,
+		stdoutBytes: stdout.bytes,
+		stderrBytes: stderr.bytes,
+		stdoutLines: stdout.lines,
+		stderrLines: stderr.lines,
+		oomKilled:   e.oomKilled,
+		outcome:     e.outcome,
+		killReason:  e.reason(),
+		killedBy:    e.killedBy,
+		usage:       e.usage,
+
+		expectations: e.expectationsOutcome(),
+	}
+}
+
+// annotate sets the details of how the command exited on result.
+func (e *execution) annotate(result *CommandResult) {
+	result.RunID = e.runID
+	result.Pid = e.cmd.Process.Pid
+	result.StartedAt, result.FinishedAt = e.start.UnixMilli(), e.end.UnixMilli()
+	result.Duration = milliseconds(e.end.Sub(e.start))
+	result.Signaled, result.Signal = e.signal != "", e.signal
+	result.TimedOut = e.timedOut()
+	result.CoreDumped, result.CorePath = coreDumpInfo(e.cmd, e.opts.coreDumps)
+	result.OOMKilled = e.oomKilled
+	result.Outcome = e.outcome
+	if e.usage.known {
+		result.CPUUserTime = milliseconds(e.usage.userTime)
+		result.CPUSystemTime = milliseconds(e.usage.systemTime)
+		result.MaxRSS = e.usage.maxRSS
+	}
+}
+
+// failure returns the error the execution fails with, if the command timed out, exceeded its
+// scratch directory quota, couldn't be run with elevated privileges, or when throwOnError is
+// set, exited with a non-zero exit code. Commands followed until a line of their output matches
+// fail if they exit before, and aren't subject to throwOnError. Unless the command couldn't be
+// run with elevated privileges, the error includes the end of the captured standard error, if any.
+func (e *execution) failure(stderr capturedOutput) error {
+	if e.stdinErr != nil {
+		return fmt.Errorf("unable to feed the standard input of %q: %w", e.command.Name, e.stdinErr)
+	}
+
+	if err := e.command.sudo.failure(e.command.Name, e.exitCode, stderr); err != nil {
+		return err
+	}
+
+	reason := e.reason()
+	if reason == "" && e.opts.until != nil {
+		return e.unmatched()
+	}
+
+	if reason == "" && (!e.opts.throwOnError || e.exitCode == 0) {
+		return nil
+	}
+
+	err := &ExecError{
+		Command:  e.command.Name,
+		ExitCode: e.exitCode,
+		Signal:   e.signal,
+		TimedOut: e.timedOut(),
+		Reason:   reason,
+	}
+
+	switch reason {
+	case killReasonTimeout:
+		err.timeout = e.opts.timeout
+	case killReasonIdleTimeout:
+		err.timeout = e.opts.idleTimeout
+	case killReasonDiskQuota:
+		err.quota = e.opts.scratchDir.quota
+	case killReasonAborted:
+		e.killMu.Lock()
+		err.abortReason = e.abortReason
+		e.killMu.Unlock()
+	}
+
+	if b, berr := stderr.bytes(); berr == nil {
+		err.Stderr = e.command.redact(stderrSnippet(b))
+	}
+	err.Message = err.Error()
+
+	return err
+}
@@ -0,0 +1,200 @@
+package exec
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/dop251/goja"
+	"go.k6.io/k6/lib/types"
+)
+
+// The values of the expectations tag of the metrics of executions with expectations.
+const (
+	expectationsPassed = "passed"
+	expectationsFailed = "failed"
+)
+
+// expectations are the declarative expectations an execution is checked against once the
+// command exited, the execution failing if any of them isn't met.
+type expectations struct {
+	// exitCode, if set, is the exit code the command is expected to exit with.
+	exitCode *int
+
+	// stdoutMatches and stderrMatches, if set, are expected to match the captured output.
+	stdoutMatches *regexp.Regexp
+	stderrMatches *regexp.Regexp
+
+	// stdoutEmpty and stderrEmpty expect the captured output to be empty.
+	stdoutEmpty bool
+	stderrEmpty bool
+
+	// maxDuration, if not zero, is how long the command is expected to run for at most.
+	maxDuration time.Duration
+}
+
+// parseExpectations parses the expect execution option.
+func parseExpectations(rt *goja.Runtime, v goja.Value) (*expectations, error) {
+	x := &expectations{}
+
+	obj := v.ToObject(rt)
+	for _, key := range obj.Keys() {
+		value := obj.Get(key)
+
+		switch key {
+		case "exitCode":
+			code := int(value.ToInteger())
+			x.exitCode = &code
+		case "stdoutMatches", "stderrMatches":
+			re, err := toRegexp(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid expectation %s: %w", key, err)
+			}
+			if key == "stdoutMatches" {
+				x.stdoutMatches = re
+			} else {
+				x.stderrMatches = re
+			}
+		case "stdoutEmpty":
+			x.stdoutEmpty = value.ToBoolean()
+		case "stderrEmpty":
+			x.stderrEmpty = value.ToBoolean()
+		case "maxDuration":
+			d, err := types.GetDurationValue(value.Export())
+			if err != nil {
+				return nil, fmt.Errorf("invalid expectation maxDuration: %w", err)
+			}
+			x.maxDuration = d
+		default:
+			return nil, fmt.Errorf("unknown expectation %q", key)
+		}
+	}
+
+	return x, nil
+}
+
+// inspects returns true if the expectations inspect the output of the named stream.
+func (x *expectations) inspects(stream string) bool {
+	if x == nil {
+		return false
+	}
+
+	if stream == "stdout" {
+		return x.stdoutMatches != nil || x.stdoutEmpty
+	}
+
+	return x.stderrMatches != nil || x.stderrEmpty
+}
+
+// violations returns the descriptions of the expectations the execution didn't meet.
+func (x *expectations) violations(exitCode int, duration time.Duration, stdout, stderr capturedOutput) ([]string, error) {
+	if x == nil {
+		return nil, nil
+	}
+
+	var violations []string
+	if x.exitCode != nil && exitCode != *x.exitCode {
+		violations = append(violations, fmt.Sprintf("exited with exit code %d, expected %d", exitCode, *x.exitCode))
+	}
+
+	for _, stream := range []struct {
+		name    string
+		output  capturedOutput
+		matches *regexp.Regexp
+		empty   bool
+	}{
+		{"stdout", stdout, x.stdoutMatches, x.stdoutEmpty},
+		{"stderr", stderr, x.stderrMatches, x.stderrEmpty},
+	} {
+		if stream.matches == nil && !stream.empty {
+			continue
+		}
+
+		b, err := stream.output.bytes()
+		if err != nil {
+			return nil, err
+		}
+
+		if stream.matches != nil && !stream.matches.Match(b) {
+			violations = append(violations, fmt.Sprintf("%s doesn't match %s", stream.name, stream.matches))
+		}
+		if stream.empty && len(b) > 0 {
+			violations = append(violations, fmt.Sprintf("%s isn't empty (%d bytes)", stream.name, len(b)))
+		}
+	}
+
+	if x.maxDuration > 0 && duration > x.maxDuration {
+		violations = append(violations, fmt.Sprintf("ran for %s, expected at most %s", duration.Round(time.Millisecond), x.maxDuration))
+	}
+
+	return violations, nil
+}
+
+// ExpectationError is the error the promise returned by Exec is rejected with when the
+// execution didn't meet the expectations set using the expect option.
+type ExpectationError struct {
+	// Message is the error message, as returned by Error.
+	Message string `js:"message"`
+
+	// Command is the name of the command.
+	Command string `js:"command"`
+
+	// Violations describe the expectations which weren't met.
+	Violations []string `js:"violations"`
+
+	// Stderr holds the last bytes the command wrote to its standard error.
+	Stderr string `js:"stderr"`
+}
+
+// Error implements the error interface.
+func (e *ExpectationError) Error() string {
+	var msg strings.Builder
+
+	fmt.Fprintf(&msg, "command %q didn't meet its expectations:", e.Command)
+	for _, violation := range e.Violations {
+		msg.WriteString("\n- " + violation)
+	}
+
+	if e.Stderr != "" {
+		fmt.Fprintf(&msg, "\nstderr:\n%s", e.Stderr)
+	}
+
+	return msg.String()
+}
+
+// checkExpectations checks the execution against its expectations, if any.
+func (e *execution) checkExpectations(stdout, stderr capturedOutput) error {
+	violations, err := e.opts.expect.violations(e.exitCode, e.end.Sub(e.start), stdout, stderr)
+	e.violations = violations
+
+	return err
+}
+
+// expectationsOutcome returns the value of the expectations tag of the
+// metrics of the execution, or an empty string if it has no expectations.
+func (e *execution) expectationsOutcome() string {
+	switch {
+	case e.opts.expect == nil:
+		return ""
+	case len(e.violations) > 0:
+		return expectationsFailed
+	default:
+		return expectationsPassed
+	}
+}
+
+// unmetExpectations returns the error the execution fails with if it didn't meet its expectations.
+func (e *execution) unmetExpectations(stderr capturedOutput) error {
+	if len(e.violations) == 0 {
+		return nil
+	}
+
+	err := &ExpectationError{Command: e.command.Name, Violations: e.violations}
+	if b, berr := stderr.bytes(); berr == nil {
+		err.Stderr = e.command.redact(stderrSnippet(b))
+	}
+	err.Message = err.Error()
+
+	return err
+}
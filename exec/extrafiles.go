@@ -0,0 +1,70 @@
+package exec
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/dop251/goja"
+)
+
+// extraFileModes maps the supported extra file modes to their open flags.
+var extraFileModes = map[string]int{ //nolint:gochecknoglobals
+	"r":  os.O_RDONLY,
+	"w":  os.O_WRONLY | os.O_CREATE | os.O_TRUNC,
+	"a":  os.O_WRONLY | os.O_CREATE | os.O_APPEND,
+	"rw": os.O_RDWR | os.O_CREATE,
+}
+
+// extraFile describes an additional file passed to a command, as file
+// descriptor 3 and onwards, in the order they are declared.
+type extraFile struct {
+	path string
+	mode string
+}
+
+// parseExtraFiles parses the extraFiles option, an array whose entries are
+// either a path, opened read-write, or an object holding a path and a mode.
+func parseExtraFiles(rt *goja.Runtime, v goja.Value) ([]extraFile, error) {
+	var entries []goja.Value
+	if err := rt.ExportTo(v, &entries); err != nil {
+		return nil, fmt.Errorf("extraFiles must be an array: %w", err)
+	}
+
+	files := make([]extraFile, 0, len(entries))
+	for _, entry := range entries {
+		file := extraFile{mode: "rw"}
+
+		if obj, ok := entry.(*goja.Object); ok {
+			file.path = obj.Get("path").String()
+			if mode := obj.Get("mode"); mode != nil && !goja.IsUndefined(mode) {
+				file.mode = mode.String()
+			}
+		} else {
+			file.path = entry.String()
+		}
+
+		if _, ok := extraFileModes[file.mode]; !ok {
+			return nil, fmt.Errorf("invalid mode %q for extra file %q; expected one of r, w, a or rw", file.mode, file.path)
+		}
+
+		files = append(files, file)
+	}
+
+	return files, nil
+}
+
+// openExtraFiles opens the provided extra files.
+func openExtraFiles(files []extraFile) ([]*os.File, error) {
+	opened := make([]*os.File, 0, len(files))
+	for _, file := range files {
+		f, err := os.OpenFile(file.path, extraFileModes[file.mode], 0o644) //nolint:gosec
+		if err != nil {
+			closeFiles(opened...)
+			return nil, err
+		}
+
+		opened = append(opened, f)
+	}
+
+	return opened, nil
+}
@@ -0,0 +1,197 @@
+package exec
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/dop251/goja"
+	"go.k6.io/k6/js/common"
+)
+
+// The scopes of fixtures, whose end their down command is run at.
+const (
+	fixtureScopeIteration = "iteration"
+	fixtureScopeVU        = "vu"
+)
+
+// Fixture is a pair of commands: its up command, which was executed, and its down command,
+// run once, either explicitly, or at the end of the fixture's scope.
+type Fixture struct {
+	// result is the result of the up command.
+	result *CommandResult
+
+	mi       *ModuleInstance
+	teardown *detachedCommand
+	once     sync.Once
+	err      error
+}
+
+// Ensure the interfaces are implemented correctly
+var _ jsValuer = &Fixture{}
+
+// Fixture executes the up command of the fixture described by the provided object, and returns
+// a promise resolved with a fixture handle once it succeeded. The down command is then run at the
+// end of the fixture's scope, the iteration by default, or the VU, even if the iteration threw,
+// unless it was run explicitly before. The commands are either Cmds or arrays holding the name of
+// a command followed by its arguments. As the down commands of fixtures scoped to the VU are run
+// once the scenarios ended, such fixtures can't be set up from teardown() nor handleSummary().
+func (mi *ModuleInstance) Fixture(spec goja.Value) *goja.Promise {
+	rt := mi.vu.Runtime()
+	promise, resolve, reject := makeHandledPromise(mi.vu)
+
+	up, down, scope, options, err := mi.parseFixture(rt, spec)
+	if err != nil {
+		common.Throw(rt, err)
+	}
+
+	// VUs run until the end of the test, once which the down commands can't be run.
+	ctx := mi.vu.Context()
+	if scope == fixtureScopeVU && !mi.testRun.observable(ctx) {
+		common.Throw(rt, fmt.Errorf("fixtures scoped to the %s can only be set up from the init context, "+
+			"setup() or the iterations of a test run; use the %s scope instead", fixtureScopeVU, fixtureScopeIteration))
+	}
+
+	opts, err := parseExecOptions(rt, options)
+	if err != nil {
+		reject(up.rejection(err))
+		return promise
+	}
+
+	teardown, err := down.detach(defaultCleanupTimeout)
+	if err != nil {
+		reject(up.rejection(err))
+		return promise
+	}
+
+	complete, err := up.run(opts)
+	if err != nil {
+		teardown.cancel()
+		reject(up.rejection(err))
+		return promise
+	}
+
+	go func() {
+		result, err := complete()
+		if err == nil && result.ExitCode != 0 {
+			err = result.execError(up.Name)
+		}
+
+		if err != nil {
+			teardown.cancel()
+			reject(up.rejection(err))
+			return
+		}
+
+		f := &Fixture{result: result, mi: mi, teardown: teardown}
+		switch {
+		case scope == fixtureScopeVU:
+			// k6 waits for the down commands run at the end of the test to complete.
+			if !mi.testRun.onEnd(ctx, f.scopeEnded) {
+				f.teardown.logger.Warnf("the test ended while the %s fixture was set up, tearing it down", f.teardown.name)
+				f.scopeEnded()
+			}
+		case ctx != nil:
+			go func() {
+				<-ctx.Done()
+				f.scopeEnded()
+			}()
+		}
+
+		resolve(f)
+	}()
+
+	return promise
+}
+
+// parseFixture parses the object describing a fixture.
+func (mi *ModuleInstance) parseFixture(
+	rt *goja.Runtime, v goja.Value,
+) (up, down *Command, scope string, options goja.Value, err error) {
+	if common.IsNullish(v) {
+		return nil, nil, "", nil, errors.New("fixture expects an object holding up and down commands")
+	}
+
+	scope = fixtureScopeIteration
+
+	obj := v.ToObject(rt)
+	for _, key := range obj.Keys() {
+		value := obj.Get(key)
+
+		switch key {
+		case "up", "down":
+			command, ok := mi.commandFromSpec(value)
+			if !ok {
+				return nil, nil, "", nil, fmt.Errorf("the %s command of a fixture must be a Cmd, "+
+					"or an array holding a command and its arguments", key)
+			}
+
+			if key == "up" {
+				up = command
+			} else {
+				down = command
+			}
+		case "scope":
+			scope = value.String()
+			if scope != fixtureScopeIteration && scope != fixtureScopeVU {
+				return nil, nil, "", nil, fmt.Errorf("invalid fixture scope %q; expected %s or %s",
+					scope, fixtureScopeIteration, fixtureScopeVU)
+			}
+		case "options":
+			options = value
+		default:
+			return nil, nil, "", nil, fmt.Errorf("unknown fixture option %q", key)
+		}
+	}
+
+	if up == nil || down == nil {
+		return nil, nil, "", nil, errors.New("fixture expects an object holding up and down commands")
+	}
+
+	return up, down, scope, options, nil
+}
+
+// Down runs the down command of the fixture, unless it was already run, and returns
+// a promise resolved once it completed, or rejected if it failed.
+func (f *Fixture) Down() *goja.Promise {
+	promise, resolve, reject := makeHandledPromise(f.mi.vu)
+
+	go func() {
+		if err := f.down(); err != nil {
+			reject(fmt.Errorf("the %s fixture teardown failed: %w", f.teardown.name, err))
+			return
+		}
+
+		resolve(goja.Undefined())
+	}()
+
+	return promise
+}
+
+// scopeEnded runs the down command once the scope of the fixture ended, logging its failure.
+func (f *Fixture) scopeEnded() {
+	if err := f.down(); err != nil {
+		f.teardown.logger.WithError(err).Warnf("the %s fixture teardown failed", f.teardown.name)
+	}
+}
+
+// down runs the down command once, and returns the error it failed with, if any.
+func (f *Fixture) down() error {
+	f.once.Do(func() { f.err = f.teardown.run() })
+
+	return f.err
+}
+
+// toJSValue implements the jsValuer interface, exposing the result of the up command
+// as the result property of the handle, and the down method.
+func (f *Fixture) toJSValue(rt *goja.Runtime) goja.Value {
+	obj := rt.NewObject()
+	if err := obj.Set("result", f.result.toJSValue(rt)); err != nil {
+		common.Throw(rt, err)
+	}
+	if err := obj.Set("down", f.Down); err != nil {
+		common.Throw(rt, err)
+	}
+
+	return obj
+}
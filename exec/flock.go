@@ -0,0 +1,66 @@
+package exec
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/dop251/goja"
+	"go.k6.io/k6/js/common"
+)
+
+// fileLockPollInterval is how often acquiring a file lock held by someone else is retried.
+const fileLockPollInterval = 50 * time.Millisecond
+
+// Flock acquires an advisory exclusive lock on the file at path, created if it doesn't exist,
+// calls fn once it is acquired, and releases it once fn returned, or once the promise it returned
+// settled, so that the commands fn executes don't modify the file concurrently with the ones
+// executed under the same lock by other VUs, other k6 instances, or any other process locking
+// the file. It returns a promise settled like the one returned by fn. Waiting for the lock is
+// abandoned, and the lock released, once the VU context is done.
+func (mi *ModuleInstance) Flock(path string, fn goja.Value) *goja.Promise {
+	callable, ok := goja.AssertFunction(fn)
+	if !ok {
+		common.Throw(mi.vu.Runtime(), errors.New("flock expects a path and a function"))
+	}
+
+	return mi.callLocked(callable, func(ctx context.Context) (func(), error) {
+		return acquireFileLock(ctx, path)
+	})
+}
+
+// acquireFileLock blocks until the exclusive lock of the file at path is acquired, or until
+// ctx is done, and returns the function releasing the lock.
+func acquireFileLock(ctx context.Context, path string) (func(), error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0o644) //nolint:gosec
+	if err != nil {
+		return nil, fmt.Errorf("unable to open the %s lock file: %w", path, err)
+	}
+
+	ticker := time.NewTicker(fileLockPollInterval)
+	defer ticker.Stop()
+
+	for {
+		locked, err := tryLockFile(f)
+		if err != nil {
+			_ = f.Close()
+			return nil, fmt.Errorf("unable to lock %s: %w", path, err)
+		}
+
+		if locked {
+			return func() {
+				_ = unlockFile(f)
+				_ = f.Close()
+			}, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			_ = f.Close()
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
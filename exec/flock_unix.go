@@ -0,0 +1,25 @@
+//go:build !windows
+
+package exec
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// tryLockFile tries to acquire the exclusive lock of f without blocking, and
+// returns false if it is held by another open file description.
+func tryLockFile(f *os.File) (bool, error) {
+	err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+	if errors.Is(err, syscall.EWOULDBLOCK) {
+		return false, nil
+	}
+
+	return err == nil, err
+}
+
+// unlockFile releases the lock of f.
+func unlockFile(f *os.File) error {
+	return syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+}
@@ -0,0 +1,86 @@
+package exec
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/dop251/goja"
+	"go.k6.io/k6/js/common"
+)
+
+// defaultStopSequence is the sequence of signals commands are stopped with once their
+// output matched the until option, unless they have a kill sequence of their own.
+var defaultStopSequence = []killStep{ //nolint:gochecknoglobals
+	{signal: "SIGTERM", wait: 5 * time.Second},
+	{signal: "SIGKILL"},
+}
+
+// Follow runs the command, streaming its output until a line matches the until option,
+// then stops it and returns a promise resolved with the output collected so far. It is
+// meant for commands such as kubectl logs -f, which don't exit on their own.
+func (c *Command) Follow(options goja.Value) *goja.Promise {
+	if common.IsNullish(options) || common.IsNullish(options.ToObject(c.vu.Runtime()).Get("until")) {
+		common.Throw(c.vu.Runtime(), errors.New("follow expects an until option"))
+	}
+
+	return c.Exec(options)
+}
+
+// stop stops the command, once its output matched the until option, running through its kill
+// sequence, or the default stop sequence. As it was expected, the command's exit isn't a failure.
+func (e *execution) stop() {
+	e.matchOnce.Do(func() {
+		e.matched.Store(true)
+
+		steps := e.opts.killSequence
+		if len(steps) == 0 {
+			steps = defaultStopSequence
+		}
+		go e.escalate(steps)
+	})
+}
+
+// unmatched returns the error the execution fails with if the command exited
+// before its output matched the until option, or nil.
+func (e *execution) unmatched() error {
+	if e.opts.until == nil || e.matched.Load() {
+		return nil
+	}
+
+	return fmt.Errorf("command %q exited before its output matched %s", e.command.Name, e.opts.until)
+}
+
+// lineWatcher is an io.Writer calling fn with each complete line written to it,
+// without its line ending.
+type lineWatcher struct {
+	fn func(line []byte)
+
+	// partial holds the last, incomplete, line written.
+	partial []byte
+}
+
+// Write implements the io.Writer interface.
+func (w *lineWatcher) Write(p []byte) (int, error) {
+	n := len(p)
+
+	for len(p) > 0 {
+		i := bytes.IndexByte(p, '\n')
+		if i < 0 {
+			w.partial = append(w.partial, p...)
+			break
+		}
+
+		line := p[:i]
+		if len(w.partial) > 0 {
+			line = append(w.partial, line...) //nolint:gocritic
+			w.partial = nil
+		}
+
+		w.fn(bytes.TrimSuffix(line, []byte("\r")))
+		p = p[i+1:]
+	}
+
+	return n, nil
+}
@@ -0,0 +1,81 @@
+//go:build !windows
+
+package exec
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"go.k6.io/k6/lib"
+)
+
+func TestCommandFollow(t *testing.T) {
+	t.Parallel()
+
+	// The command writes its lines, then never exits on its own.
+	const following = `new exec.Cmd("sh").args(["-c", "echo a; echo ready; echo error >&2; sleep 5 >/dev/null 2>&1 & wait"])`
+
+	tests := []struct {
+		name    string
+		script  string
+		want    string
+		wantErr string
+	}{
+		{
+			name:   "standard output",
+			script: following + `.follow({ until: /ready/ }).then((r) => JSON.stringify(r.stdout.split("\n")[0]))`,
+			want:   `"a"`,
+		},
+		{
+			name:   "standard error",
+			script: following + `.follow({ until: "^err" }).then((r) => JSON.stringify(r.stderr))`,
+			want:   `"error\n"`,
+		},
+		{
+			name:    "exited before matching",
+			script:  `new exec.Cmd("sh").args(["-c", "echo a"]).follow({ until: /ready/ })`,
+			wantErr: "exited before its output matched ready",
+		},
+		{
+			name:    "timed out before matching",
+			script:  following + `.follow({ until: /never/, timeout: "100ms" })`,
+			wantErr: "timed out after 100ms",
+		},
+		{
+			name:    "until missing",
+			script:  following + `.follow({})`,
+			wantErr: "follow expects an until option",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			vu := newTestVU(t, nil)
+			vu.moveToVUContext(lib.Options{})
+
+			start := time.Now()
+			got, err := vu.run(tt.script)
+			if elapsed := time.Since(start); elapsed > 4*time.Second {
+				t.Errorf("the command was stopped after %s, rather than once its output matched", elapsed)
+			}
+
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("the script failed with %v, want an error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.String() != tt.want {
+				t.Errorf("the script returned %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,40 @@
+package exec
+
+import (
+	"context"
+	"time"
+
+	"go.k6.io/k6/lib"
+)
+
+// gracePeriod returns how long the command is given to exit, once the VU context is
+// done, before being killed. It is either the duration set by the gracefulStop option,
+// or the gracefulStop of the current scenario, if the option is set to true.
+func (c *Command) gracePeriod(opts *execOptions) time.Duration {
+	if !opts.scenarioGracefulStop {
+		return opts.gracefulStop
+	}
+
+	scenario, state := lib.GetScenarioState(c.vu.Context()), c.vu.State()
+	if scenario == nil || state == nil {
+		return 0
+	}
+
+	config, ok := state.Options.Scenarios[scenario.Name]
+	if !ok {
+		return 0
+	}
+
+	return config.GetGracefulStop()
+}
+
+// metricsContext returns the context the metrics of the execution should be emitted
+// with. If the command was given a grace period and ctx is done, the metrics are
+// still emitted, as long as the grace period didn't elapse.
+func (e *execution) metricsContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if e.grace == 0 || ctx.Err() == nil {
+		return ctx, func() {}
+	}
+
+	return context.WithTimeout(context.Background(), e.grace)
+}
@@ -0,0 +1,172 @@
+package exec
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/dop251/goja"
+	"go.k6.io/k6/js/common"
+)
+
+// defaultCleanupTimeout is how long cleanup commands are allowed to run by default.
+const defaultCleanupTimeout = 30 * time.Second
+
+// CmdGroup groups the steps of a stateful workflow, such as create, test and delete, each of
+// which can register a cleanup command compensating it. If a step fails, or the VU context is
+// done before the group was committed or rolled back, the registered cleanups are run in the
+// reverse order they were registered in.
+type CmdGroup struct {
+	mi *ModuleInstance
+
+	mu       sync.Mutex
+	cleanups []*detachedCommand
+	// settled is closed once the group was committed or rolled back.
+	settled    chan struct{}
+	settleOnce sync.Once
+	watchOnce  sync.Once
+}
+
+// NewCmdGroup is the JS constructor for the CmdGroup object.
+func (mi *ModuleInstance) NewCmdGroup(call goja.ConstructorCall) *goja.Object {
+	rt := mi.vu.Runtime()
+	return rt.ToValue(&CmdGroup{mi: mi, settled: make(chan struct{})}).ToObject(rt)
+}
+
+// Step executes command with the provided options, and returns a promise resolved with its
+// result. If the command succeeds, cleanup, either a Cmd or an array holding the name of a
+// command followed by its arguments, is registered to compensate it. If the command fails,
+// whether its execution failed or it exited with a non-zero exit code, the cleanups registered
+// by the previous steps are run, and the promise is rejected once they completed.
+func (g *CmdGroup) Step(command Command, cleanup goja.Value, options goja.Value) *goja.Promise {
+	rt := g.mi.vu.Runtime()
+	promise, resolve, reject := makeHandledPromise(g.mi.vu)
+
+	opts, err := parseExecOptions(rt, options)
+	if err != nil {
+		reject(command.rejection(err))
+		return promise
+	}
+
+	var compensation *detachedCommand
+	if !common.IsNullish(cleanup) {
+		cleanupCommand, ok := g.mi.commandFromSpec(cleanup)
+		if !ok {
+			common.Throw(rt, errors.New("the cleanup of a step must be a Cmd, or an array holding a command and its arguments"))
+		}
+
+		if compensation, err = cleanupCommand.detach(defaultCleanupTimeout); err != nil {
+			reject(command.rejection(err))
+			return promise
+		}
+	}
+
+	g.watch()
+
+	complete, err := command.run(opts)
+	if err != nil {
+		g.cancel(compensation)
+		go func() {
+			g.rollback()
+			reject(command.rejection(err))
+		}()
+		return promise
+	}
+
+	go func() {
+		result, err := complete()
+		if err == nil && result.ExitCode != 0 {
+			err = result.execError(command.Name)
+		}
+
+		if err != nil {
+			g.cancel(compensation)
+			g.rollback()
+			reject(command.rejection(err))
+			return
+		}
+
+		if compensation != nil {
+			g.mu.Lock()
+			g.cleanups = append(g.cleanups, compensation)
+			g.mu.Unlock()
+		}
+		resolve(result)
+	}()
+
+	return promise
+}
+
+// Rollback runs the registered cleanups, in the reverse order they were registered in,
+// and returns a promise resolved once they completed. Failing cleanups are logged.
+func (g *CmdGroup) Rollback() *goja.Promise {
+	promise, resolve, _ := makeHandledPromise(g.mi.vu)
+
+	go func() {
+		g.rollback()
+		g.settle()
+		resolve(goja.Undefined())
+	}()
+
+	return promise
+}
+
+// Commit drops the registered cleanups, once the workflow completed and
+// the state it created should be kept.
+func (g *CmdGroup) Commit() {
+	g.mu.Lock()
+	cleanups := g.cleanups
+	g.cleanups = nil
+	g.mu.Unlock()
+
+	g.cancel(cleanups...)
+	g.settle()
+}
+
+// rollback runs the registered cleanups, in reverse order, and unregisters them.
+func (g *CmdGroup) rollback() {
+	g.mu.Lock()
+	cleanups := g.cleanups
+	g.cleanups = nil
+	g.mu.Unlock()
+
+	for i := len(cleanups) - 1; i >= 0; i-- {
+		cleanup := cleanups[i]
+		if err := cleanup.run(); err != nil {
+			cleanup.logger.WithError(err).Warnf("the %s cleanup failed", cleanup.name)
+		}
+	}
+}
+
+// watch makes the registered cleanups run once the VU context is done,
+// unless the group was settled before.
+func (g *CmdGroup) watch() {
+	g.watchOnce.Do(func() {
+		ctx := g.mi.vu.Context()
+		if ctx == nil {
+			return
+		}
+
+		go func() {
+			select {
+			case <-ctx.Done():
+				g.rollback()
+			case <-g.settled:
+			}
+		}()
+	})
+}
+
+// settle marks the group as committed or rolled back.
+func (g *CmdGroup) settle() {
+	g.settleOnce.Do(func() { close(g.settled) })
+}
+
+// cancel releases the provided cleanups, which won't be run.
+func (g *CmdGroup) cancel(cleanups ...*detachedCommand) {
+	for _, cleanup := range cleanups {
+		if cleanup != nil {
+			cleanup.cancel()
+		}
+	}
+}
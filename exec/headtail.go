@@ -0,0 +1,122 @@
+package exec
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/dop251/goja"
+)
+
+// keepOutput describes how much of a command's output to retain, when
+// only its first and last lines are of interest.
+type keepOutput struct {
+	head int
+	tail int
+}
+
+// parseKeepOutput parses the keepOutput option, an object holding the
+// amount of lines to retain at the start (head) and end (tail) of the output.
+func parseKeepOutput(rt *goja.Runtime, v goja.Value) (*keepOutput, error) {
+	obj := v.ToObject(rt)
+	keep := &keepOutput{}
+
+	for _, key := range obj.Keys() {
+		n := obj.Get(key).ToInteger()
+		if n < 0 {
+			return nil, fmt.Errorf("keepOutput.%s must be a positive number of lines", key)
+		}
+
+		switch key {
+		case "head":
+			keep.head = int(n)
+		case "tail":
+			keep.tail = int(n)
+		default:
+			return nil, fmt.Errorf("unknown keepOutput option %q", key)
+		}
+	}
+
+	return keep, nil
+}
+
+// headTailWriter is an io.Writer retaining only the first and last lines written to it.
+type headTailWriter struct {
+	keep keepOutput
+
+	headLines [][]byte
+
+	// tailLines is used as a ring buffer, next being the position of its oldest line.
+	tailLines [][]byte
+	next      int
+
+	// omitted is the number of lines which weren't retained.
+	omitted int
+
+	// partial holds the last, incomplete, line written.
+	partial []byte
+}
+
+// newHeadTailWriter returns a new headTailWriter retaining the lines described by keep.
+func newHeadTailWriter(keep keepOutput) *headTailWriter {
+	return &headTailWriter{keep: keep}
+}
+
+// Write implements the io.Writer interface.
+func (w *headTailWriter) Write(p []byte) (int, error) {
+	n := len(p)
+
+	for len(p) > 0 {
+		i := bytes.IndexByte(p, '\n')
+		if i < 0 {
+			w.partial = append(w.partial, p...)
+			break
+		}
+
+		line := append(w.partial, p[:i+1]...) //nolint:gocritic
+		w.partial = nil
+		w.add(line)
+		p = p[i+1:]
+	}
+
+	return n, nil
+}
+
+// add retains line if it belongs to the head or tail of the output.
+func (w *headTailWriter) add(line []byte) {
+	switch {
+	case len(w.headLines) < w.keep.head:
+		w.headLines = append(w.headLines, line)
+	case w.keep.tail == 0:
+		w.omitted++
+	case len(w.tailLines) < w.keep.tail:
+		w.tailLines = append(w.tailLines, line)
+	default:
+		w.tailLines[w.next] = line
+		w.next = (w.next + 1) % w.keep.tail
+		w.omitted++
+	}
+}
+
+// bytes returns the retained output, the head and tail lines being separated
+// by a marker indicating how many lines were omitted, if any.
+func (w *headTailWriter) bytes() []byte {
+	if len(w.partial) > 0 {
+		w.add(w.partial)
+		w.partial = nil
+	}
+
+	var buf bytes.Buffer
+	for _, line := range w.headLines {
+		buf.Write(line)
+	}
+
+	if w.omitted > 0 {
+		fmt.Fprintf(&buf, "[... %d lines omitted ...]\n", w.omitted)
+	}
+
+	for i := range w.tailLines {
+		buf.Write(w.tailLines[(w.next+i)%len(w.tailLines)])
+	}
+
+	return buf.Bytes()
+}
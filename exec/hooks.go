@@ -0,0 +1,403 @@
+package exec
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"go.k6.io/k6/errext"
+	k6execution "go.k6.io/k6/execution"
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/lib/types"
+)
+
+// optionsKey is the key of the options of the module in the ext section of the test options.
+const optionsKey = "exec"
+
+// defaultHookTimeout is how long hook commands are allowed to run by default.
+const defaultHookTimeout = 5 * time.Minute
+
+// extOptions holds the options of the module, declared under options.ext.exec in the test options.
+type extOptions struct {
+	// Preflight is the command checking the test can run, the test being aborted if it fails.
+	Preflight *hookCommand `json:"preflight"`
+
+	Hooks struct {
+		TestStart *hookCommand `json:"testStart"`
+		TestEnd   *hookCommand `json:"testEnd"`
+
+		// Scenarios holds the hooks run at the boundaries of scenarios, keyed by scenario name.
+		Scenarios map[string]*scenarioHookCommands `json:"scenarios"`
+	} `json:"hooks"`
+}
+
+// applyPolicy makes the hook commands subject to the execution policy set by the operator of k6,
+// and to config, the configuration of the VU the test is started out of: to the policy it sets,
+// and to whether it allows executables to be resolved relative to the current directory.
+func (o *extOptions) applyPolicy(policy *execPolicy, config *moduleConfig) {
+	hooks := []*hookCommand{o.Preflight, o.Hooks.TestStart, o.Hooks.TestEnd}
+	for _, commands := range o.Hooks.Scenarios {
+		if commands != nil {
+			hooks = append(hooks, commands.Start, commands.End)
+		}
+	}
+
+	for _, hook := range hooks {
+		if hook != nil {
+			hook.policy, hook.config = policy, config
+		}
+	}
+}
+
+// scenarioHookCommands holds the hook commands run when a scenario starts, and once it ended.
+type scenarioHookCommands struct {
+	Start *hookCommand `json:"start"`
+	End   *hookCommand `json:"end"`
+}
+
+// hookCommand is a command declared in the test options, and run by the root module
+// outside of any VU: either an array holding the name of a command followed by its
+// arguments, or an object holding such an array, and optionally its environment and
+// how long it is allowed to run.
+type hookCommand struct {
+	Command []string           `json:"command"`
+	Env     map[string]string  `json:"env"`
+	Timeout types.NullDuration `json:"timeout"`
+
+	// policy is the execution policy set by the operator of k6, and config the configuration
+	// of the module, which hooks are subject to as well.
+	policy *execPolicy
+	config *moduleConfig
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+func (h *hookCommand) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, &h.Command); err != nil {
+		type plain hookCommand
+		if err := json.Unmarshal(data, (*plain)(h)); err != nil {
+			return errors.New("invalid hook; expected an array holding a command and its arguments, or an object")
+		}
+	}
+
+	if len(h.Command) == 0 {
+		return errors.New("invalid hook; expected a command")
+	}
+
+	return nil
+}
+
+// run runs the hook command, and returns the error it failed with, if any, including
+// the end of its output. It inherits the environment of k6, sanitized, and is given
+// the ID of the test run.
+func (h *hookCommand) run() error {
+	timeout := defaultHookTimeout
+	if h.Timeout.Valid {
+		timeout = time.Duration(h.Timeout.Duration)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	config := h.config
+	if config == nil {
+		config = newModuleConfig()
+	}
+
+	path, cwdRelative, err := lookExecutable(h.Command[0], h.policy, config)
+	if err != nil {
+		return err
+	}
+
+	if err := checkPolicies(h.policy, config.policy, h.Command[0], path, h.Command[1:]); err != nil {
+		return err
+	}
+
+	cmd := exec.CommandContext(ctx, path, h.Command[1:]...)
+	if cwdRelative && errors.Is(cmd.Err, exec.ErrDot) {
+		cmd.Err = nil
+	}
+	cmd.Env = append(sanitizeEnviron(os.Environ()), testRunIDEnvVar+"="+currentTestRunID())
+	for k, v := range h.Env {
+		cmd.Env = append(cmd.Env, k+"="+v)
+	}
+	prepareSignals(cmd)
+
+	var output bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &output, &output
+
+	if err := cmd.Run(); err != nil {
+		if output.Len() > 0 {
+			return fmt.Errorf("%w\noutput:\n%s", err, stderrSnippet(output.Bytes()))
+		}
+		return err
+	}
+
+	return nil
+}
+
+// testHooks runs the hook commands declared in the test options, once per test instance.
+type testHooks struct {
+	mu sync.Mutex
+
+	// started, if the start of the test is observed, is closed once the start hooks completed.
+	started chan struct{}
+
+	startOnce sync.Once
+	startErr  error
+
+	// scenarios holds the hooks of the scenarios declaring some, keyed by scenario name.
+	// It is only written to by the first call of startTest.
+	scenarios map[string]*scenarioHooks
+
+	// testEnd is the testEnd hook, once the test started, run with logger.
+	testEnd *hookCommand
+	logger  logrus.FieldLogger
+}
+
+// scenarioHooks runs the hook commands declared for a scenario, once per test instance.
+type scenarioHooks struct {
+	commands  *scenarioHookCommands
+	startOnce sync.Once
+	startErr  error
+	endOnce   sync.Once
+}
+
+// schedule makes the start hooks of the test run once the VUs running the scenarios were
+// initialized, by startEagerly, the VU running setup() waiting for them to complete.
+func (th *testHooks) schedule() {
+	th.mu.Lock()
+	defer th.mu.Unlock()
+
+	th.started = make(chan struct{})
+}
+
+// startEagerly runs the preflight check and the testStart hook, state being the one of the
+// first VU running the scenarios, or nil if it failed to be initialized, as the test doesn't run
+// then, and ctx the context it was initialized with, derived from the one of the test run. If the
+// preflight check fails, the test run is aborted, before setup() and the scenarios start, as the
+// VU running setup(), and the VUs executing commands, wait for the start hooks to complete.
+func (th *testHooks) startEagerly(ctx context.Context, state *lib.State, policy *operatorPolicy, config *moduleConfig) {
+	th.mu.Lock()
+	started := th.started
+	th.mu.Unlock()
+	defer close(started)
+
+	if state == nil {
+		return
+	}
+
+	th.startOnce.Do(func() { th.startErr = th.startTest(state, policy, config) })
+
+	var interrupt *errext.InterruptError
+	if errors.As(th.startErr, &interrupt) {
+		k6execution.AbortTestRun(ctx, interrupt)
+	}
+}
+
+// waitStarted blocks until the start hooks of the test completed, if they were scheduled.
+func (th *testHooks) waitStarted() {
+	th.mu.Lock()
+	started := th.started
+	th.mu.Unlock()
+
+	if started != nil {
+		<-started
+	}
+}
+
+// start runs the start hooks of the test, unless they already ran, then runs the start hook
+// of the scenario the VU runs, and schedules its end hook to run once the scenario ended. It is
+// called before each command executed by a VU, the VUs calling it concurrently waiting for the
+// start hooks to complete. The start hooks of the test run once the VUs were initialized, or,
+// if that can't be observed, the first time start is called, and the ones of scenarios the first
+// time start is called in the scenario, as the scenario VUs run isn't known before. The error a
+// start hook failed with is returned for all the commands executed afterwards, in the test or in
+// the scenario respectively.
+func (th *testHooks) start(c *Command) error {
+	state := c.vu.State()
+	if th == nil || state == nil {
+		return nil
+	}
+
+	th.startOnce.Do(func() { th.startErr = th.startTest(state, c.policy, c.config.resolve(c.vu)) })
+	if th.startErr != nil {
+		// Unless the test run was aborted already, the test is aborted by interrupting the runtime
+		// of each VU executing commands, as test.abort() does, rather than the commands failing.
+		var interrupt *errext.InterruptError
+		if errors.As(th.startErr, &interrupt) {
+			c.vu.Runtime().Interrupt(interrupt)
+		}
+		return th.startErr
+	}
+
+	scenario := lib.GetScenarioState(c.vu.Context())
+	if scenario == nil {
+		return nil
+	}
+
+	hooks, ok := th.scenarios[scenario.Name]
+	if !ok {
+		return nil
+	}
+
+	hooks.startOnce.Do(func() { hooks.startErr = hooks.start(c, scenario) })
+
+	return hooks.startErr
+}
+
+// startTest parses the module options, out of state, the one of a VU running the scenarios,
+// configured by config, runs the preflight check and the testStart hook, and keeps the testEnd
+// one for end to run.
+func (th *testHooks) startTest(state *lib.State, operator *operatorPolicy, config *moduleConfig) error {
+	var opts extOptions
+	if raw, ok := state.Options.External[optionsKey]; ok {
+		if err := json.Unmarshal(raw, &opts); err != nil {
+			return fmt.Errorf("invalid options.ext.%s: %w", optionsKey, err)
+		}
+	}
+
+	policy, err := operator.get()
+	if err != nil {
+		return err
+	}
+	opts.applyPolicy(policy, config)
+
+	if len(opts.Hooks.Scenarios) > 0 {
+		th.scenarios = make(map[string]*scenarioHooks, len(opts.Hooks.Scenarios))
+	}
+	for name, commands := range opts.Hooks.Scenarios {
+		if _, ok := state.Options.Scenarios[name]; !ok && len(state.Options.Scenarios) > 0 {
+			return fmt.Errorf("invalid options.ext.%s: hooks are declared for the unknown %q scenario", optionsKey, name)
+		}
+		if commands != nil {
+			th.scenarios[name] = &scenarioHooks{commands: commands}
+		}
+	}
+
+	logger := state.Logger
+
+	if preflight := opts.Preflight; preflight != nil {
+		logger.Infof("running the preflight check %s", preflight.Command[0])
+		if err := preflight.run(); err != nil {
+			logger.WithError(err).Error("the preflight check failed; aborting the test")
+			return &errext.InterruptError{Reason: fmt.Sprintf("%s: the preflight check failed: %s", errext.AbortTest, err)}
+		}
+	}
+
+	if hook := opts.Hooks.TestStart; hook != nil {
+		logger.Infof("running the testStart hook %s", hook.Command[0])
+		if err := hook.run(); err != nil {
+			logger.WithError(err).Error("the testStart hook failed")
+			return fmt.Errorf("the testStart hook failed: %w", err)
+		}
+	}
+
+	th.mu.Lock()
+	th.testEnd, th.logger = opts.Hooks.TestEnd, logger
+	th.mu.Unlock()
+
+	return nil
+}
+
+// end runs the end hooks of the scenarios which didn't run yet, including the ones of the scenarios
+// no command was executed in, unless their start hook failed, then the testEnd hook, once, if the
+// test started successfully, waiting for the start hooks to complete if they are running.
+func (th *testHooks) end() {
+	th.waitStarted()
+	th.startOnce.Do(func() {})
+	if th.startErr != nil {
+		return
+	}
+
+	names := make([]string, 0, len(th.scenarios))
+	for name := range th.scenarios {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	th.mu.Lock()
+	hook, logger := th.testEnd, th.logger
+	th.testEnd = nil
+	th.mu.Unlock()
+
+	for _, name := range names {
+		hooks := th.scenarios[name]
+		hooks.startOnce.Do(func() {})
+		if hooks.startErr == nil {
+			hooks.end(name, logger.WithField("scenario", name))
+		}
+	}
+
+	if hook != nil {
+		runEndHook("testEnd hook", hook, logger)
+	}
+}
+
+// start runs the start hook of the scenario, and schedules its end hook to run once the scenario
+// ended, that is once its progress is complete, unless the test ended before.
+func (sh *scenarioHooks) start(c *Command, scenario *lib.ScenarioState) error {
+	logger := c.logger().WithField("scenario", scenario.Name)
+
+	if hook := sh.commands.Start; hook != nil {
+		logger.Infof("running the start hook of the %s scenario %s", scenario.Name, hook.Command[0])
+		if err := hook.run(); err != nil {
+			logger.WithError(err).Errorf("the start hook of the %s scenario failed", scenario.Name)
+			return fmt.Errorf("the start hook of the %s scenario failed: %w", scenario.Name, err)
+		}
+	}
+
+	if hook := sh.commands.End; hook != nil {
+		es := lib.GetExecutionState(c.vu.Context())
+
+		go func() {
+			if waitForScenarioEnd(es, scenario) {
+				sh.end(scenario.Name, logger)
+			}
+		}()
+	}
+
+	return nil
+}
+
+// end runs the end hook of the named scenario, once.
+func (sh *scenarioHooks) end(name string, logger logrus.FieldLogger) {
+	if hook := sh.commands.End; hook != nil {
+		sh.endOnce.Do(func() { runEndHook("end hook of the "+name+" scenario", hook, logger) })
+	}
+}
+
+// runEndHook runs the named end hook, logging its failure.
+func runEndHook(name string, hook *hookCommand, logger logrus.FieldLogger) {
+	logger.Infof("running the %s %s", name, hook.Command[0])
+	if err := hook.run(); err != nil {
+		logger.WithError(err).Warnf("the %s failed", name)
+	}
+}
+
+// waitForScenarioEnd blocks until the scenario ended, that is until its progress is complete, and
+// returns true then, or until the test ended, if its execution state es is known, returning false.
+func waitForScenarioEnd(es *lib.ExecutionState, scenario *lib.ScenarioState) bool {
+	ticker := time.NewTicker(testEndPollInterval)
+	defer ticker.Stop()
+
+	for {
+		if scenario.ProgressFn != nil {
+			if progress, _ := scenario.ProgressFn(); progress >= 1 {
+				return true
+			}
+		}
+		if es != nil && es.HasEnded() {
+			return false
+		}
+
+		<-ticker.C
+	}
+}
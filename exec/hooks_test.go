@@ -0,0 +1,205 @@
+//go:build !windows
+
+package exec
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/lib/executor"
+)
+
+// hookOptions returns test options declaring the given module options under options.ext.exec.
+func hookOptions(t *testing.T, ext string) lib.Options {
+	t.Helper()
+
+	if !json.Valid([]byte(ext)) {
+		t.Fatalf("invalid options.ext.exec %s", ext)
+	}
+
+	return lib.Options{External: map[string]json.RawMessage{optionsKey: json.RawMessage(ext)}}
+}
+
+// appendingHook returns a hook command appending line to the file at path.
+func appendingHook(path, line string) string {
+	return fmt.Sprintf(`["sh", "-c", "echo %s >> %s"]`, line, path)
+}
+
+// readLines returns the content of the file at path, or an empty string if it doesn't exist.
+func readLines(t *testing.T, path string) string {
+	t.Helper()
+
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		t.Fatal(err)
+	}
+
+	return string(data)
+}
+
+func TestTestHooks(t *testing.T) {
+	t.Parallel()
+
+	t.Run("start and end", func(t *testing.T) {
+		t.Parallel()
+
+		log := filepath.Join(t.TempDir(), "log")
+		options := hookOptions(t, fmt.Sprintf(`{"hooks": {"testStart": %s, "testEnd": %s}}`,
+			appendingHook(log, "testStart"), appendingHook(log, "testEnd")))
+
+		root := New()
+		vus := []*testVU{newScenarioVU(t, root, 1, nil), newScenarioVU(t, root, 2, nil)}
+		end := startScenariosWith(t, root, options, vus...)
+
+		// The commands executed by the VUs wait for the testStart hook to complete.
+		script := fmt.Sprintf(`exec.run("cat", [%q]).then((r) => r.stdout)`, log)
+		for i, vu := range vus {
+			if got := vu.mustRun(script).String(); got != "testStart\n" {
+				t.Errorf("VU %d saw the log %q once the test started, want %q", i+1, got, "testStart\n")
+			}
+		}
+
+		end()
+		if got, want := readLines(t, log), "testStart\ntestEnd\n"; got != want {
+			t.Errorf("the log is %q once the test ended, want %q", got, want)
+		}
+	})
+
+	t.Run("start failed", func(t *testing.T) {
+		t.Parallel()
+
+		log := filepath.Join(t.TempDir(), "log")
+		options := hookOptions(t, fmt.Sprintf(`{"hooks": {"testStart": ["sh", "-c", "echo broken; exit 1"], "testEnd": %s}}`,
+			appendingHook(log, "testEnd")))
+
+		root := New()
+		vu := newScenarioVU(t, root, 1, nil)
+		end := startScenariosWith(t, root, options, vu)
+
+		for i := 0; i < 2; i++ {
+			_, err := vu.run(`exec.run("true")`)
+			if err == nil || !strings.Contains(err.Error(), "the testStart hook failed") || !strings.Contains(err.Error(), "broken") {
+				t.Errorf("command %d failed with %v, want the error of the testStart hook", i+1, err)
+			}
+		}
+
+		end()
+		if got := readLines(t, log); got != "" {
+			t.Errorf("the testEnd hook ran although the test failed to start, logging %q", got)
+		}
+	})
+
+	t.Run("invalid options", func(t *testing.T) {
+		t.Parallel()
+
+		root := New()
+		vu := newScenarioVU(t, root, 1, nil)
+		startScenariosWith(t, root, hookOptions(t, `{"hooks": {"testStart": []}}`), vu)
+
+		if _, err := vu.run(`exec.run("true")`); err == nil || !strings.Contains(err.Error(), "invalid options.ext.exec") {
+			t.Errorf("the command failed with %v, want an error about the options", err)
+		}
+	})
+}
+
+func TestScenarioHooks(t *testing.T) {
+	t.Parallel()
+
+	t.Run("start and end", func(t *testing.T) {
+		t.Parallel()
+
+		log := filepath.Join(t.TempDir(), "log")
+		options := hookOptions(t, fmt.Sprintf(`{"hooks": {"scenarios": {
+			"warm": {"start": %s, "end": %s},
+			"cold": {"start": %s, "end": %s}
+		}}}`, appendingHook(log, "warm-start"), appendingHook(log, "warm-end"),
+			appendingHook(log, "cold-start"), appendingHook(log, "cold-end")))
+
+		root := New()
+		vu := newScenarioVU(t, root, 1, nil)
+		end := startScenariosWith(t, root, options, vu)
+
+		var ended atomic.Bool
+		vu.enterScenario(&lib.ScenarioState{Name: "warm", ProgressFn: func() (float64, []string) {
+			if ended.Load() {
+				return 1, nil
+			}
+			return 0.5, nil
+		}})
+
+		// The commands executed in the scenario wait for its start hook to complete.
+		script := fmt.Sprintf(`exec.run("cat", [%q]).then((r) => r.stdout)`, log)
+		if got := vu.mustRun(script).String(); got != "warm-start\n" {
+			t.Errorf("the log is %q once the scenario started, want %q", got, "warm-start\n")
+		}
+		vu.mustRun(script)
+
+		// The end hook runs once the progress of the scenario is complete.
+		ended.Store(true)
+		want := "warm-start\nwarm-end\n"
+		for deadline := time.Now().Add(5 * time.Second); readLines(t, log) != want && time.Now().Before(deadline); {
+			time.Sleep(10 * time.Millisecond)
+		}
+		if got := readLines(t, log); got != want {
+			t.Fatalf("the log is %q once the scenario ended, want %q", got, want)
+		}
+
+		// The end hooks of the scenarios no command was executed in run once the test ended.
+		end()
+		if got, want := readLines(t, log), "warm-start\nwarm-end\ncold-end\n"; got != want {
+			t.Errorf("the log is %q once the test ended, want %q", got, want)
+		}
+	})
+
+	t.Run("start failed", func(t *testing.T) {
+		t.Parallel()
+
+		log := filepath.Join(t.TempDir(), "log")
+		options := hookOptions(t, fmt.Sprintf(`{"hooks": {"scenarios": {"warm": {"start": ["false"], "end": %s}}}}`,
+			appendingHook(log, "warm-end")))
+
+		root := New()
+		warm, other := newScenarioVU(t, root, 1, nil), newScenarioVU(t, root, 2, nil)
+		end := startScenariosWith(t, root, options, warm, other)
+		warm.enterScenario(&lib.ScenarioState{Name: "warm"})
+		other.enterScenario(&lib.ScenarioState{Name: "other"})
+
+		for i := 0; i < 2; i++ {
+			_, err := warm.run(`exec.run("true")`)
+			if err == nil || !strings.Contains(err.Error(), "the start hook of the warm scenario failed") {
+				t.Errorf("command %d failed with %v, want the error of the start hook", i+1, err)
+			}
+		}
+		if _, err := other.run(`exec.run("true")`); err != nil {
+			t.Errorf("the command executed in another scenario failed with %v", err)
+		}
+
+		end()
+		if got := readLines(t, log); got != "" {
+			t.Errorf("the end hook ran although the scenario failed to start, logging %q", got)
+		}
+	})
+
+	t.Run("unknown scenario", func(t *testing.T) {
+		t.Parallel()
+
+		options := hookOptions(t, `{"hooks": {"scenarios": {"warm": {"start": ["true"]}}}}`)
+		options.Scenarios = lib.ScenarioConfigs{"other": executor.NewPerVUIterationsConfig("other")}
+
+		root := New()
+		vu := newScenarioVU(t, root, 1, nil)
+		startScenariosWith(t, root, options, vu)
+
+		_, err := vu.run(`exec.run("true")`)
+		if err == nil || !strings.Contains(err.Error(), `hooks are declared for the unknown "warm" scenario`) {
+			t.Errorf("the command failed with %v, want an error about the scenario", err)
+		}
+	})
+}
@@ -0,0 +1,123 @@
+package exec
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/dop251/goja"
+	"go.k6.io/k6/lib/types"
+)
+
+// hostLoadPollInterval is how often the load of the host is checked while
+// executions are delayed by the load guard.
+const hostLoadPollInterval = 250 * time.Millisecond
+
+// errHostOverloaded is wrapped by the errors executions fail with when the host is saturated.
+var errHostOverloaded = errors.New("the host is overloaded")
+
+// loadGuard holds the limits the load of the host must be under for commands to
+// be executed, so that overloaded load generators don't skew measurements.
+type loadGuard struct {
+	// maxLoadAverage, if not zero, is the highest 1-minute load average allowed.
+	maxLoadAverage float64
+
+	// minFreeMemory, if not zero, is the least amount of available memory allowed, in bytes.
+	minFreeMemory int64
+
+	// maxDelay is how long executions are delayed for, waiting for the load to
+	// decrease, before failing. Zero makes them fail right away.
+	maxDelay time.Duration
+}
+
+// hostLoad holds measurements of the load of the host.
+type hostLoad struct {
+	loadAverage float64
+	freeMemory  int64
+}
+
+// parseLoadGuard parses the loadGuard configuration option.
+func parseLoadGuard(rt *goja.Runtime, v goja.Value) (*loadGuard, error) {
+	if _, isObject := v.(*goja.Object); !isObject {
+		return nil, errors.New("invalid loadGuard; expected an object")
+	}
+
+	guard := &loadGuard{}
+	obj := v.ToObject(rt)
+	for _, key := range obj.Keys() {
+		value := obj.Get(key)
+
+		switch key {
+		case "maxLoadAverage":
+			guard.maxLoadAverage = value.ToFloat()
+			if guard.maxLoadAverage <= 0 {
+				return nil, fmt.Errorf("invalid loadGuard maxLoadAverage %v; expected a positive number", guard.maxLoadAverage)
+			}
+		case "minFreeMemory":
+			size, err := parseByteSize(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid loadGuard minFreeMemory: %w", err)
+			}
+			guard.minFreeMemory = size
+		case "maxDelay":
+			delay, err := types.GetDurationValue(value.Export())
+			if err != nil {
+				return nil, fmt.Errorf("invalid loadGuard maxDelay: %w", err)
+			}
+			guard.maxDelay = delay
+		default:
+			return nil, fmt.Errorf("unknown loadGuard option %q", key)
+		}
+	}
+
+	return guard, nil
+}
+
+// wait blocks until the load of the host is under the limits of the guard, for up to its
+// maximum delay, and returns an error wrapping errHostOverloaded if it still isn't by then.
+// The guard is a no-op on platforms the load of the host can't be measured on.
+func (g *loadGuard) wait(ctx context.Context) error {
+	if g == nil {
+		return nil
+	}
+
+	deadline := time.Now().Add(g.maxDelay)
+	for {
+		load, ok := readHostLoad()
+		if !ok {
+			return nil
+		}
+
+		reason := g.exceeded(load)
+		if reason == "" {
+			return nil
+		}
+
+		if !time.Now().Before(deadline) {
+			if g.maxDelay > 0 {
+				return fmt.Errorf("%w after waiting for %s: %s", errHostOverloaded, g.maxDelay, reason)
+			}
+			return fmt.Errorf("%w: %s", errHostOverloaded, reason)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(hostLoadPollInterval):
+		}
+	}
+}
+
+// exceeded returns which limit of the guard load exceeds, if any.
+func (g *loadGuard) exceeded(load hostLoad) string {
+	if g.maxLoadAverage > 0 && load.loadAverage > g.maxLoadAverage {
+		return fmt.Sprintf("the load average of %.2f exceeds %g", load.loadAverage, g.maxLoadAverage)
+	}
+
+	if g.minFreeMemory > 0 && load.freeMemory < g.minFreeMemory {
+		return fmt.Sprintf("%d bytes of memory are available, less than %d", load.freeMemory, g.minFreeMemory)
+	}
+
+	return ""
+}
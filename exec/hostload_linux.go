@@ -0,0 +1,54 @@
+//go:build linux
+
+package exec
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// readHostLoad reads the 1-minute load average of the host from /proc/loadavg,
+// and the memory available to start new processes from /proc/meminfo.
+func readHostLoad() (hostLoad, bool) {
+	loadavg, err := os.ReadFile("/proc/loadavg")
+	if err != nil {
+		return hostLoad{}, false
+	}
+
+	fields := strings.Fields(string(loadavg))
+	if len(fields) == 0 {
+		return hostLoad{}, false
+	}
+
+	load := hostLoad{}
+	if load.loadAverage, err = strconv.ParseFloat(fields[0], 64); err != nil {
+		return hostLoad{}, false
+	}
+
+	meminfo, err := os.ReadFile("/proc/meminfo")
+	if err != nil {
+		return hostLoad{}, false
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(meminfo))
+	for scanner.Scan() {
+		// The line reads e.g. "MemAvailable:    8123456 kB".
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || fields[0] != "MemAvailable:" {
+			continue
+		}
+
+		kb, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return hostLoad{}, false
+		}
+		load.freeMemory = kb * 1024
+
+		return load, true
+	}
+
+	return hostLoad{}, false
+}
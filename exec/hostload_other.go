@@ -0,0 +1,9 @@
+//go:build !linux
+
+package exec
+
+// readHostLoad reports the load of the host as unknown on platforms other than Linux,
+// which makes the load guard a no-op on them.
+func readHostLoad() (hostLoad, bool) {
+	return hostLoad{}, false
+}
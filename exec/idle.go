@@ -0,0 +1,44 @@
+package exec
+
+import (
+	"io"
+	"time"
+)
+
+// idleWatchdog calls a function once no activity was reported for a given period.
+type idleWatchdog struct {
+	timer   *time.Timer
+	timeout time.Duration
+}
+
+// newIdleWatchdog returns a started idleWatchdog, calling fn once no activity
+// was reported for timeout.
+func newIdleWatchdog(timeout time.Duration, fn func()) *idleWatchdog {
+	return &idleWatchdog{timer: time.AfterFunc(timeout, fn), timeout: timeout}
+}
+
+// touch reports activity, postponing the call to the watchdog's function.
+func (w *idleWatchdog) touch() {
+	w.timer.Reset(w.timeout)
+}
+
+// stop stops the watchdog.
+func (w *idleWatchdog) stop() {
+	w.timer.Stop()
+}
+
+// activityReader is an io.Reader reporting each successful read from r to touch.
+type activityReader struct {
+	r     io.Reader
+	touch func()
+}
+
+// Read implements the io.Reader interface.
+func (ar activityReader) Read(p []byte) (int, error) {
+	n, err := ar.r.Read(p)
+	if n > 0 {
+		ar.touch()
+	}
+
+	return n, err
+}
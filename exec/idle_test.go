@@ -0,0 +1,65 @@
+//go:build !windows
+
+package exec
+
+import (
+	"testing"
+
+	"go.k6.io/k6/lib"
+)
+
+func TestIdleTimeout(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		script string
+		want   string
+		killed bool
+	}{
+		{
+			name:   "silent",
+			script: `new exec.Cmd("sh").args(["-c", "echo started; sleep 5 >/dev/null 2>&1 & wait"]).exec({ idleTimeout: "200ms" })`,
+			want:   `["rejected",true,"idle_timeout"]`,
+			killed: true,
+		},
+		{
+			name: "producing output",
+			script: `new exec.Cmd("sh").args(["-c", "for i in 1 2 3 4 5 6; do echo $i; sleep 0.1; done"])
+				.exec({ idleTimeout: "1s" })`,
+			want: `["resolved",false,0]`,
+		},
+		{
+			name: "producing output on the standard error",
+			script: `new exec.Cmd("sh").args(["-c", "for i in 1 2 3 4 5 6; do echo $i >&2; sleep 0.1; done"])
+				.exec({ idleTimeout: "1s" })`,
+			want: `["resolved",false,0]`,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			vu := newTestVU(t, nil)
+			vu.moveToVUContext(lib.Options{})
+
+			got := vu.mustRun(tt.script + `.then(
+				(r) => JSON.stringify(["resolved", r.timedOut, r.exitCode]),
+				(err) => JSON.stringify(["rejected", err.timedOut, err.reason]))`).String()
+			if got != tt.want {
+				t.Errorf("the script's outcome is %s, want %s", got, tt.want)
+			}
+
+			if !tt.killed {
+				return
+			}
+
+			samples := vu.awaitSamplesOf("exec_commands_killed")
+			if reason := samples[0].Tags.Map()["reason"]; reason != killReasonIdleTimeout {
+				t.Errorf("the command was killed for %q, want %q", reason, killReasonIdleTimeout)
+			}
+		})
+	}
+}
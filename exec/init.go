@@ -0,0 +1,122 @@
+package exec
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/dop251/goja"
+	"go.k6.io/k6/js/common"
+	"go.k6.io/k6/js/modules"
+)
+
+// allowInitEnvVar is the environment variable which must be set to true
+// to allow executing commands from the init context.
+const allowInitEnvVar = "K6_EXEC_ALLOW_INIT"
+
+// RunInit synchronously executes the named command with the provided arguments and
+// options, and returns its result. It can only be used from the init context, where
+// promises can't be awaited, for instance to compute the test's options, and only if
+// the K6_EXEC_ALLOW_INIT environment variable is set to true.
+func (mi *ModuleInstance) RunInit(name string, args []string, options goja.Value) goja.Value {
+	rt := mi.vu.Runtime()
+
+	if mi.vu.State() != nil {
+		common.Throw(rt, errors.New("runInit can only be used in the init context; use run instead"))
+	}
+
+	command := mi.newCommand(name)
+	if err := command.checkInitAllowed(); err != nil {
+		common.Throw(rt, err)
+	}
+
+	command.args = append(command.args, args...)
+
+	opts, err := parseExecOptions(rt, options)
+	if err != nil {
+		common.Throw(rt, err)
+	}
+
+	return command.runSync(opts, "runInit")
+}
+
+// runSync executes the command, blocking until it exited, retrying it according to its retry
+// policy, if any, and returns its result. The caller names the function executing it in the
+// errors thrown for unsupported options.
+func (c *Command) runSync(opts *execOptions, caller string) goja.Value {
+	rt := c.vu.Runtime()
+
+	// The stdin iterator is pulled from, and the line callbacks are called, on the event loop, which is blocked.
+	if c.stdinSource(opts).iterates() {
+		common.Throw(rt, fmt.Errorf("the stdin option isn't supported by %s, unless it holds data, or the path of a file", caller))
+	}
+	if c.lineCallback("stdout", opts) != nil || c.lineCallback("stderr", opts) != nil {
+		common.Throw(rt, fmt.Errorf("line callbacks aren't supported by %s", caller))
+	}
+	if opts.signal != nil {
+		common.Throw(rt, fmt.Errorf("the signal option isn't supported by %s, whose executions can't be aborted", caller))
+	}
+
+	for n := 1; ; n++ {
+		attemptCmd := *c
+		if c.retry != nil {
+			attemptCmd.attempt = n
+		}
+
+		complete, err := attemptCmd.run(opts)
+		if err != nil {
+			c.notifyExitSync(nil, err)
+			c.throw(err)
+		}
+
+		result, err := complete()
+
+		exitCode, exited := failedExitCode(result, err)
+		if c.retry != nil && exited && n < c.retry.attempts && c.retry.retries(exitCode) && c.waitBackoff() {
+			attemptCmd.pushRetry(c.vu.Context(), c.vu.State(), exitCode, time.Now())
+			continue
+		}
+
+		if err != nil {
+			c.notifyExitSync(nil, err)
+			c.throw(err)
+		}
+
+		result.Attempts = n
+		value := result.toJSValue(rt)
+		c.notifyExitSync(value, nil)
+		return value
+	}
+}
+
+// errInitExecDisabled is the error executing commands from the init context fails with, unless allowed.
+var errInitExecDisabled = errors.New("executing commands from the init context is disabled; set " +
+	allowInitEnvVar + "=true to enable it")
+
+// checkInitAllowed returns errInitExecDisabled if the command is executed from the init context,
+// and executing commands from it wasn't allowed. It is checked by every function starting commands.
+func (c *Command) checkInitAllowed() error {
+	if c.vu.InitEnv() != nil && c.vu.State() == nil && !initExecAllowed(c.vu) {
+		return errInitExecDisabled
+	}
+
+	return nil
+}
+
+// initExecAllowed returns true if executing commands from the init context was allowed.
+func initExecAllowed(vu modules.VU) bool {
+	allowed, _ := strconv.ParseBool(lookupEnv(vu, allowInitEnvVar))
+	return allowed
+}
+
+// lookupEnv returns the value of the named environment variable, as seen by the test.
+func lookupEnv(vu modules.VU, key string) string {
+	env := vu.InitEnv()
+	if env == nil || env.LookupEnv == nil {
+		return ""
+	}
+
+	value, _ := env.LookupEnv(key)
+	return value
+}
@@ -0,0 +1,66 @@
+//go:build !windows
+
+package exec
+
+import (
+	"strings"
+	"testing"
+
+	"go.k6.io/k6/lib"
+)
+
+func TestInitExecution(t *testing.T) {
+	t.Parallel()
+
+	scripts := map[string]string{
+		"exec":     `new exec.Cmd("true").exec()`,
+		"execSync": `new exec.Cmd("true").execSync()`,
+		"spawn":    `new exec.Cmd("true").spawn().exited`,
+		"run":      `exec.run("true")`,
+		"runInit":  `exec.runInit("true")`,
+		"sh":       `exec.sh("true")`,
+		"shSync":   `exec.shSync("true")`,
+		"service":  `exec.service("true", { ready: { delay: "1ms" } })`,
+	}
+
+	for name, script := range scripts {
+		script := script
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := newTestVU(t, nil).run(script)
+			if err == nil || !strings.Contains(err.Error(), errInitExecDisabled.Error()) {
+				t.Errorf("%s from the init context error = %v, want %q", script, err, errInitExecDisabled)
+			}
+		})
+	}
+
+	t.Run("allowed", func(t *testing.T) {
+		t.Parallel()
+
+		vu := newTestVU(t, map[string]string{allowInitEnvVar: "true"})
+		for name, script := range scripts {
+			if name == "service" {
+				continue
+			}
+			if _, err := vu.run(script); err != nil {
+				t.Errorf("%s from the init context with %s set error = %v", script, allowInitEnvVar, err)
+			}
+		}
+	})
+
+	t.Run("iterations", func(t *testing.T) {
+		t.Parallel()
+
+		vu := newTestVU(t, nil)
+		vu.moveToVUContext(lib.Options{})
+		for name, script := range scripts {
+			if name == "runInit" || name == "service" {
+				continue
+			}
+			if _, err := vu.run(script); err != nil {
+				t.Errorf("%s from an iteration error = %v", script, err)
+			}
+		}
+	})
+}
@@ -0,0 +1,46 @@
+package exec
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// The I/O scheduling classes, by name.
+var ioPriorityClasses = map[string]int{ //nolint:gochecknoglobals
+	"realtime":    1,
+	"best-effort": 2,
+	"idle":        3,
+}
+
+// defaultIOPriorityLevel is the level of the realtime and best-effort
+// classes, when the I/O priority doesn't specify one.
+const defaultIOPriorityLevel = 4
+
+// parseIOPriority parses the ioPriority option, the name of an I/O scheduling class, optionally
+// followed by a level between 0, the highest priority, and 7, such as best-effort:7. It returns
+// the I/O priority value, combining the class and the level.
+func parseIOPriority(v string) (int, error) {
+	name, level, hasLevel := strings.Cut(v, ":")
+
+	class, ok := ioPriorityClasses[name]
+	if !ok {
+		return 0, fmt.Errorf("unsupported ioPriority class %q; expected realtime, best-effort or idle", name)
+	}
+
+	data := defaultIOPriorityLevel
+	switch {
+	case class == ioPriorityClasses["idle"]:
+		if hasLevel {
+			return 0, fmt.Errorf("invalid ioPriority %q; the idle class has no level", v)
+		}
+		data = 0
+	case hasLevel:
+		var err error
+		if data, err = strconv.Atoi(level); err != nil || data < 0 || data > 7 {
+			return 0, fmt.Errorf("invalid ioPriority level %q; expected a level between 0 and 7", level)
+		}
+	}
+
+	return class<<13 | data, nil
+}
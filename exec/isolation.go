@@ -0,0 +1,170 @@
+package exec
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/dop251/goja"
+)
+
+// The supported isolation runtimes.
+const (
+	isolationGVisor = "gvisor"
+	isolationBwrap  = "bwrap"
+)
+
+// isolationRuntimes maps the supported isolation runtimes to their executable.
+var isolationRuntimes = map[string]string{ //nolint:gochecknoglobals
+	isolationGVisor: "runsc",
+	isolationBwrap:  "bwrap",
+}
+
+// isolation describes the sandbox a command is run in.
+type isolation struct {
+	// runtime is the name of the isolation runtime.
+	runtime string
+
+	// roBinds and binds are the host paths mounted, read-only and read-write, in the
+	// bubblewrap sandbox, as a path or a source:target pair.
+	roBinds []string
+	binds   []string
+	// tmpfs are the paths a fresh tmpfs is mounted on in the bubblewrap sandbox.
+	tmpfs []string
+	// unshare makes the bubblewrap sandbox use new namespaces of all kinds.
+	unshare bool
+	// newSession makes the bubblewrap sandbox run the command in a new terminal session.
+	newSession bool
+}
+
+// parseIsolation parses the isolation option, either the name of the isolation runtime,
+// or for bubblewrap, an object holding the type of isolation and the sandbox's options.
+func parseIsolation(rt *goja.Runtime, v goja.Value) (*isolation, error) {
+	iso := &isolation{runtime: v.String()}
+
+	if obj, ok := v.(*goja.Object); ok {
+		var err error
+		if iso, err = parseBwrapIsolation(rt, obj); err != nil {
+			return nil, err
+		}
+	}
+
+	if _, ok := isolationRuntimes[iso.runtime]; !ok {
+		return nil, fmt.Errorf("unsupported isolation %q; expected %s or %s", iso.runtime, isolationGVisor, isolationBwrap)
+	}
+
+	return iso, nil
+}
+
+// parseBwrapIsolation parses the object form of the isolation option, declaring a bubblewrap sandbox.
+func parseBwrapIsolation(rt *goja.Runtime, obj *goja.Object) (*isolation, error) {
+	iso := &isolation{}
+
+	for _, key := range obj.Keys() {
+		value := obj.Get(key)
+
+		var err error
+		switch key {
+		case "type":
+			iso.runtime = value.String()
+		case "roBinds":
+			err = rt.ExportTo(value, &iso.roBinds)
+		case "binds":
+			err = rt.ExportTo(value, &iso.binds)
+		case "tmpfs":
+			err = rt.ExportTo(value, &iso.tmpfs)
+		case "unshare":
+			iso.unshare = value.ToBoolean()
+		case "newSession":
+			iso.newSession = value.ToBoolean()
+		default:
+			return nil, fmt.Errorf("unknown isolation option %q", key)
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("invalid isolation option %q: %w", key, err)
+		}
+	}
+
+	if iso.runtime != isolationBwrap {
+		return nil, fmt.Errorf("isolation options are only supported by %s; got type %q", isolationBwrap, iso.runtime)
+	}
+
+	return iso, nil
+}
+
+// wrap returns the executable and arguments running the named command in the sandbox.
+func (iso *isolation) wrap(name string, args []string) (string, []string, error) {
+	runtime := isolationRuntimes[iso.runtime]
+	if _, err := exec.LookPath(runtime); errors.Is(err, exec.ErrNotFound) {
+		return "", nil, fmt.Errorf("%s isolation requires %s, which couldn't be found in the PATH", iso.runtime, runtime)
+	}
+
+	var wrapped []string
+	switch iso.runtime {
+	case isolationGVisor:
+		wrapped = iso.runscArgs()
+	case isolationBwrap:
+		wrapped = iso.bwrapArgs()
+	}
+	wrapped = append(wrapped, name)
+
+	return runtime, append(wrapped, args...), nil
+}
+
+// runscArgs returns the runsc arguments running a command in a gVisor sandbox. runsc do runs
+// it in a sandbox sharing the host's filesystem, through an overlay. Unprivileged users need
+// the sandbox to be rootless.
+func (iso *isolation) runscArgs() []string {
+	args := make([]string, 0, 2)
+	if os.Geteuid() != 0 {
+		args = append(args, "--rootless")
+	}
+
+	return append(args, "do")
+}
+
+// bwrapArgs returns the bubblewrap arguments composing the declared sandbox. Without
+// binds, the host's root filesystem is mounted read-only. bubblewrap always prevents
+// the command from gaining privileges, and the sandbox dies with k6.
+func (iso *isolation) bwrapArgs() []string {
+	args := []string{"--die-with-parent"}
+
+	if len(iso.roBinds) == 0 && len(iso.binds) == 0 {
+		args = append(args, "--ro-bind", "/", "/")
+	}
+	for _, bind := range iso.roBinds {
+		source, target := splitBind(bind)
+		args = append(args, "--ro-bind", source, target)
+	}
+	for _, bind := range iso.binds {
+		source, target := splitBind(bind)
+		args = append(args, "--bind", source, target)
+	}
+
+	args = append(args, "--dev", "/dev", "--proc", "/proc")
+	for _, path := range iso.tmpfs {
+		args = append(args, "--tmpfs", path)
+	}
+
+	if iso.unshare {
+		args = append(args, "--unshare-all")
+	}
+	if iso.newSession {
+		args = append(args, "--new-session")
+	}
+
+	return append(args, "--")
+}
+
+// splitBind returns the source and target of a bind, either a path, mounted
+// at the same path in the sandbox, or a source:target pair.
+func splitBind(bind string) (string, string) {
+	if source, target, ok := strings.Cut(bind, ":"); ok {
+		return source, target
+	}
+
+	return bind, bind
+}
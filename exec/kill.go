@@ -0,0 +1,134 @@
+package exec
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/dop251/goja"
+	"go.k6.io/k6/js/common"
+)
+
+// processRegistry holds the executions a VU started which didn't exit yet, keyed by their pid.
+type processRegistry struct {
+	mu      sync.Mutex
+	running map[int]*execution
+}
+
+// add registers the started execution.
+func (r *processRegistry) add(e *execution) {
+	if r == nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.running == nil {
+		r.running = make(map[int]*execution)
+	}
+	r.running[e.cmd.Process.Pid] = e
+}
+
+// remove unregisters the execution, once its command exited.
+func (r *processRegistry) remove(e *execution) {
+	if r == nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.running[e.cmd.Process.Pid] == e {
+		delete(r.running, e.cmd.Process.Pid)
+	}
+}
+
+// get returns the running execution whose command has the provided pid, if any.
+func (r *processRegistry) get(pid int) *execution {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.running[pid]
+}
+
+// all returns the running executions, ordered by the pid of their command.
+func (r *processRegistry) all() []*execution {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	executions := make([]*execution, 0, len(r.running))
+	for _, e := range r.running {
+		executions = append(executions, e)
+	}
+	sort.Slice(executions, func(i, j int) bool {
+		return executions[i].cmd.Process.Pid < executions[j].cmd.Process.Pid
+	})
+
+	return executions
+}
+
+// Kill sends the named signal, SIGTERM by default, to a command the VU started which is still
+// running, either a spawned Process, or the pid of a command, executed or spawned. Only the
+// commands started by the VU can be signalled, so that a pid reused by an unrelated process
+// isn't. Commands killed this way are reported as killed for the manual reason.
+func (mi *ModuleInstance) Kill(target goja.Value, signal goja.Value) {
+	rt := mi.vu.Runtime()
+
+	name, err := signalArgument(signal, "SIGTERM")
+	if err != nil {
+		common.Throw(rt, err)
+	}
+
+	var pid int
+	switch exported := target.Export().(type) {
+	case *Process:
+		pid = exported.Pid
+	case int64:
+		pid = int(exported)
+	default:
+		common.Throw(rt, errors.New("kill expects a Process, or the pid of a command, and optionally a signal"))
+	}
+
+	execution := mi.processes.get(pid)
+	if execution == nil {
+		common.Throw(rt, fmt.Errorf("no running command started by the VU has pid %d", pid))
+	}
+
+	if err := execution.signalProcess(name); err != nil {
+		common.Throw(rt, err)
+	}
+}
+
+// KillAll sends the named signal, SIGKILL by default, to all the commands the VU started which
+// are still running, executed or spawned, and returns how many were signalled. The services
+// shared between VUs aren't signalled, as other VUs might still be using them.
+func (mi *ModuleInstance) KillAll(signal goja.Value) int {
+	rt := mi.vu.Runtime()
+
+	name, err := signalArgument(signal, "SIGKILL")
+	if err != nil {
+		common.Throw(rt, err)
+	}
+
+	var signalled int
+	for _, execution := range mi.processes.all() {
+		// Commands exiting in the meantime are skipped.
+		if err := execution.signalProcess(name); err == nil {
+			signalled++
+		}
+	}
+
+	return signalled
+}
+
+// signalArgument returns the portable name of the signal passed as an optional argument,
+// or def if it wasn't.
+func signalArgument(v goja.Value, def string) (string, error) {
+	if common.IsNullish(v) {
+		return def, nil
+	}
+
+	return normalizeSignal(v.String())
+}
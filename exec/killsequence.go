@@ -0,0 +1,70 @@
+package exec
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/dop251/goja"
+	"go.k6.io/k6/lib/types"
+)
+
+// killStep is a step of a kill sequence: a signal sent to the command,
+// and how long it is given to exit before moving on to the next step.
+type killStep struct {
+	signal string
+	wait   time.Duration
+}
+
+// parseKillSequence parses the killSequence option, an array of [signal, wait] pairs.
+func parseKillSequence(rt *goja.Runtime, v goja.Value) ([]killStep, error) {
+	var entries [][]goja.Value
+	if err := rt.ExportTo(v, &entries); err != nil {
+		return nil, fmt.Errorf("killSequence must be an array of [signal, wait] pairs: %w", err)
+	}
+
+	steps := make([]killStep, 0, len(entries))
+	for _, entry := range entries {
+		if len(entry) != 2 {
+			return nil, fmt.Errorf("invalid killSequence step of length %d; expected a [signal, wait] pair", len(entry))
+		}
+
+		signal, err := normalizeSignal(entry[0].String())
+		if err != nil {
+			return nil, fmt.Errorf("invalid killSequence step: %w", err)
+		}
+
+		wait, err := types.GetDurationValue(entry[1].Export())
+		if err != nil {
+			return nil, fmt.Errorf("invalid killSequence wait for %s: %w", signal, err)
+		}
+
+		steps = append(steps, killStep{signal: signal, wait: wait})
+	}
+
+	return steps, nil
+}
+
+// killSequenceDuration returns how long the kill sequence takes to run through, at most.
+func killSequenceDuration(steps []killStep) time.Duration {
+	var total time.Duration
+	for _, step := range steps {
+		total += step.wait
+	}
+
+	return total
+}
+
+// escalate runs through the provided kill sequence, until the command exited.
+func (e *execution) escalate(steps []killStep) {
+	for _, step := range steps {
+		if err := e.deliver(step.signal); err != nil {
+			e.logger().WithError(err).Debugf("unable to send %s to %s", step.signal, e.command.Name)
+		}
+
+		select {
+		case <-e.exited:
+			return
+		case <-time.After(step.wait):
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package exec
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dop251/goja"
+)
+
+func TestParseKillSequence(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		sequence string
+		want     []killStep
+		wantErr  string
+	}{
+		{name: "empty", sequence: "[]", want: []killStep{}},
+		{
+			name:     "signals and durations",
+			sequence: `[["TERM", "2s"], ["sigint", "1m30s"], ["SIGKILL", 0]]`,
+			want: []killStep{
+				{signal: "SIGTERM", wait: 2 * time.Second},
+				{signal: "SIGINT", wait: 90 * time.Second},
+				{signal: "SIGKILL"},
+			},
+		},
+		{
+			name:     "milliseconds",
+			sequence: `[["HUP", 500], ["QUIT", 1.5]]`,
+			want: []killStep{
+				{signal: "SIGHUP", wait: 500 * time.Millisecond},
+				{signal: "SIGQUIT", wait: 1500 * time.Microsecond},
+			},
+		},
+		{name: "not an array", sequence: `"TERM"`, wantErr: "killSequence must be an array of [signal, wait] pairs"},
+		{name: "not pairs", sequence: `["TERM", "1s"]`, wantErr: "killSequence must be an array of [signal, wait] pairs"},
+		{name: "missing wait", sequence: `[["TERM"]]`, wantErr: "invalid killSequence step of length 1"},
+		{name: "extra element", sequence: `[["TERM", "1s", "2s"]]`, wantErr: "invalid killSequence step of length 3"},
+		{name: "unsupported signal", sequence: `[["SIGWINCH", "1s"]]`, wantErr: `unsupported signal "SIGWINCH"`},
+		{name: "invalid wait", sequence: `[["TERM", "soon"]]`, wantErr: "invalid killSequence wait for SIGTERM"},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			rt := goja.New()
+			v, err := rt.RunString(tt.sequence)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := parseKillSequence(rt, v)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("parseKillSequence(%s) error = %v, want it to contain %q", tt.sequence, err, tt.wantErr)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("parseKillSequence(%s) error = %v", tt.sequence, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseKillSequence(%s) = %v, want %v", tt.sequence, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestKillSequenceDuration(t *testing.T) {
+	t.Parallel()
+
+	steps := []killStep{{signal: "SIGTERM", wait: 2 * time.Second}, {signal: "SIGINT", wait: 500 * time.Millisecond}, {signal: "SIGKILL"}}
+	if got, want := killSequenceDuration(steps), 2500*time.Millisecond; got != want {
+		t.Errorf("killSequenceDuration() = %s, want %s", got, want)
+	}
+
+	if got := killSequenceDuration(nil); got != 0 {
+		t.Errorf("killSequenceDuration(nil) = %s, want 0", got)
+	}
+}
@@ -0,0 +1,65 @@
+package exec
+
+import (
+	"math/rand"
+
+	"github.com/sirupsen/logrus"
+	"go.k6.io/k6/lib"
+)
+
+// The events of the lifecycle of executions which are logged.
+const (
+	lifecycleEventStart  = "start"
+	lifecycleEventKill   = "kill"
+	lifecycleEventFinish = "finish"
+)
+
+// sampleLifecycleLogs decides whether the lifecycle events of the execution are logged, given
+// the sampling rate, between 0 and 1, of the configuration. All the events of a sampled execution
+// are logged, so that they can be correlated.
+func (e *execution) sampleLifecycleLogs(rate float64) {
+	if rate <= 0 || (rate < 1 && rand.Float64() >= rate) { //nolint:gosec
+		return
+	}
+
+	fields := logrus.Fields{
+		"executable": e.command.Name,
+		"pid":        e.cmd.Process.Pid,
+	}
+	if state := e.command.vu.State(); state != nil {
+		fields["vu"] = state.VUID
+	}
+	if scenario := lib.GetScenarioState(e.ctx); scenario != nil {
+		fields["scenario"] = scenario.Name
+	}
+	e.lifecycleFields = fields
+}
+
+// logLifecycle logs the named lifecycle event of the execution, with the provided fields,
+// if the execution was sampled.
+func (e *execution) logLifecycle(event string, fields logrus.Fields) {
+	if e.lifecycleFields == nil {
+		return
+	}
+
+	e.logger().WithFields(e.lifecycleFields).WithFields(fields).WithField("event", event).
+		Infof("%s: %s", e.command.Name, event)
+}
+
+// logExit logs the kill event of the exited execution, if it was killed, followed by its finish event.
+func (e *execution) logExit() {
+	if e.lifecycleFields == nil {
+		return
+	}
+
+	fields := logrus.Fields{
+		"exit_code": e.exitCode,
+		"duration":  e.end.Sub(e.start).String(),
+	}
+	if e.killedBy != "" {
+		e.logLifecycle(lifecycleEventKill, logrus.Fields{"kill_reason": e.killedBy, "signal": e.signal})
+		fields["kill_reason"] = e.killedBy
+	}
+
+	e.logLifecycle(lifecycleEventFinish, fields)
+}
@@ -0,0 +1,130 @@
+package exec
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/metrics"
+)
+
+// maxConcurrentEnvVar is the environment variable the operator of k6 caps the number
+// of commands executing concurrently, across all the VUs of the test, with.
+const maxConcurrentEnvVar = "K6_EXEC_MAX_CONCURRENT"
+
+// concurrencyLimiter caps the number of commands executing concurrently across all the VUs of
+// the test, so that hundreds of VUs executing commands don't overwhelm the load generator. The
+// commands started once the cap was reached are queued until one of the executing commands exits.
+type concurrencyLimiter struct {
+	once sync.Once
+	err  error
+
+	// slots holds a value per executing command, and is nil if the number of commands isn't capped.
+	slots chan struct{}
+
+	// inFlight is the number of commands executing.
+	inFlight int64
+}
+
+// load reads the cap set by the operator of k6, once per k6 instance.
+func (l *concurrencyLimiter) load() error {
+	l.once.Do(func() {
+		value := os.Getenv(maxConcurrentEnvVar)
+		if value == "" {
+			return
+		}
+
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit <= 0 {
+			l.err = fmt.Errorf("invalid %s %q; expected a positive number of commands", maxConcurrentEnvVar, value)
+			return
+		}
+
+		l.slots = make(chan struct{}, limit)
+	})
+
+	return l.err
+}
+
+// acquireSlot waits for the command to be allowed to execute, as fewer commands than the cap
+// set by the operator of k6 are executing, or for the VU context to be done. It returns the
+// function releasing the slot the command was granted, or a no-op if the number of commands
+// isn't capped. Pipelines are granted a single slot, rather than one per stage, so that the
+// stages started first don't keep the ones reading their output from starting.
+func (c *Command) acquireSlot() (func(), error) {
+	l := c.limiter
+	release := func() {}
+	if l == nil || c.stage != nil {
+		return release, nil
+	}
+
+	if err := l.load(); err != nil {
+		return nil, err
+	}
+	if l.slots == nil {
+		return release, nil
+	}
+
+	ctx, state := c.vu.Context(), c.vu.State()
+	queued := time.Now()
+
+	select {
+	case l.slots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, fmt.Errorf("executing %q: waiting for one of the %d commands executing concurrently to exit: %w",
+			c.Name, cap(l.slots), ctx.Err())
+	}
+
+	c.pushQueueWait(ctx, state, time.Since(queued), atomic.AddInt64(&l.inFlight, 1))
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			inFlight := atomic.AddInt64(&l.inFlight, -1)
+			<-l.slots
+			c.pushInFlight(ctx, state, inFlight, time.Now())
+		})
+	}, nil
+}
+
+// pushQueueWait emits samples measuring how long a command was queued for before executing,
+// and the number of commands executing concurrently once it was granted its slot.
+func (c *Command) pushQueueWait(ctx context.Context, state *lib.State, waited time.Duration, inFlight int64) {
+	if state == nil {
+		return
+	}
+
+	now := time.Now()
+	tags := c.withCommandTags(state.Tags.GetCurrentValues().Tags)
+
+	c.pushSamples(ctx, state,
+		metrics.Sample{
+			TimeSeries: metrics.TimeSeries{Metric: c.metrics.ExecCommandQueueWait, Tags: tags},
+			Value:      milliseconds(waited),
+			Time:       now,
+		},
+		metrics.Sample{
+			TimeSeries: metrics.TimeSeries{Metric: c.metrics.ExecCommandsInFlight, Tags: tags},
+			Value:      float64(inFlight),
+			Time:       now,
+		},
+	)
+}
+
+// pushInFlight emits a sample of the number of commands executing concurrently.
+func (c *Command) pushInFlight(ctx context.Context, state *lib.State, inFlight int64, now time.Time) {
+	if state == nil {
+		return
+	}
+
+	c.pushSamples(ctx, state, metrics.Sample{
+		TimeSeries: metrics.TimeSeries{Metric: c.metrics.ExecCommandsInFlight, Tags: c.withCommandTags(state.Tags.GetCurrentValues().Tags)},
+		Value:      float64(inFlight),
+		Time:       now,
+	})
+}
@@ -0,0 +1,32 @@
+package exec
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/dop251/goja"
+)
+
+// defaultLocale is the locale suggested for deterministic output, available on most systems.
+const defaultLocale = "C.UTF-8"
+
+// parseLocale parses a locale, as set by the locale execution option and configuration key.
+func parseLocale(v goja.Value) (string, error) {
+	locale := v.String()
+	if locale == "" || strings.ContainsAny(locale, "= \t\n\x00") {
+		return "", fmt.Errorf("invalid locale %q; expected the name of a locale, such as %s", locale, defaultLocale)
+	}
+
+	return locale, nil
+}
+
+// localeEnviron returns the variables making commands use locale, LC_ALL overriding
+// the LC_* variables they might inherit, and LANG being set for the programs only
+// looking it up.
+func localeEnviron(locale string) []string {
+	if locale == "" {
+		return nil
+	}
+
+	return []string{"LANG=" + locale, "LC_ALL=" + locale}
+}
@@ -0,0 +1,140 @@
+package exec
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/dop251/goja"
+	"go.k6.io/k6/js/common"
+)
+
+// namedLocks holds the mutexes acquired through Lock, shared by all the VUs of the test.
+// They are channels holding a value while acquired, so that waiting for them can be
+// abandoned once the VU context is done.
+type namedLocks struct {
+	mu    sync.Mutex
+	locks map[string]chan struct{}
+}
+
+// get returns the named mutex, creating it if it doesn't exist yet.
+func (nl *namedLocks) get(name string) chan struct{} {
+	nl.mu.Lock()
+	defer nl.mu.Unlock()
+
+	if nl.locks == nil {
+		nl.locks = make(map[string]chan struct{})
+	}
+
+	lock, ok := nl.locks[name]
+	if !ok {
+		lock = make(chan struct{}, 1)
+		nl.locks[name] = lock
+	}
+
+	return lock
+}
+
+// Lock acquires the named mutex, shared by all the VUs of the test instance, calls fn once it
+// is acquired, and releases it once fn returned, or once the promise it returned settled, so that
+// the commands fn executes don't run concurrently with the ones executed under the same mutex by
+// other VUs. It returns a promise settled like the one returned by fn. Waiting for the mutex is
+// abandoned, and the mutex released, once the VU context is done.
+func (mi *ModuleInstance) Lock(name string, fn goja.Value) *goja.Promise {
+	callable, ok := goja.AssertFunction(fn)
+	if !ok {
+		common.Throw(mi.vu.Runtime(), errors.New("lock expects a name and a function"))
+	}
+
+	lock := mi.locks.get(name)
+
+	return mi.callLocked(callable, func(ctx context.Context) (func(), error) {
+		select {
+		case lock <- struct{}{}:
+			return func() { <-lock }, nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	})
+}
+
+// callLocked acquires a lock using acquire, which blocks until the lock is acquired, or the
+// provided context is done, and returns the function releasing it. It then calls fn, releasing
+// the lock once fn returned, or once the promise it returned settled, and returns a promise
+// settled like the one returned by fn.
+func (mi *ModuleInstance) callLocked(fn goja.Callable, acquire func(context.Context) (func(), error)) *goja.Promise {
+	rt := mi.vu.Runtime()
+	promise, resolve, reject := makeHandledPromise(mi.vu)
+	ctx := mi.vu.Context()
+	callback := mi.vu.RegisterCallback()
+
+	go func() {
+		unlock, err := acquire(ctx)
+		if err != nil {
+			callback(func() error { return nil })
+			reject(err)
+			return
+		}
+
+		// The lock is also released once the VU context is done, as the
+		// promise returned by fn might never settle once the VU stopped.
+		var releaseOnce sync.Once
+		released := make(chan struct{})
+		release := func() {
+			releaseOnce.Do(func() {
+				unlock()
+				close(released)
+			})
+		}
+		go func() {
+			select {
+			case <-ctx.Done():
+				release()
+			case <-released:
+			}
+		}()
+
+		callback(func() error {
+			v, err := fn(goja.Undefined())
+			if err != nil {
+				release()
+				reject(exceptionValue(err))
+				return nil
+			}
+
+			if _, isPromise := v.Export().(*goja.Promise); !isPromise {
+				release()
+				resolve(v)
+				return nil
+			}
+
+			then, _ := goja.AssertFunction(v.ToObject(rt).Get("then"))
+			onFulfilled := func(value goja.Value) {
+				release()
+				resolve(value)
+			}
+			onRejected := func(reason goja.Value) {
+				release()
+				reject(reason)
+			}
+			if _, err := then(v, rt.ToValue(onFulfilled), rt.ToValue(onRejected)); err != nil {
+				release()
+				reject(exceptionValue(err))
+			}
+
+			return nil
+		})
+	}()
+
+	return promise
+}
+
+// exceptionValue returns the value thrown by a JS exception, or err itself if it isn't one.
+func exceptionValue(err error) interface{} {
+	var exception *goja.Exception
+	if errors.As(err, &exception) {
+		return exception.Value()
+	}
+
+	return err
+}
@@ -0,0 +1,86 @@
+//go:build !windows
+
+package exec
+
+import (
+	"encoding/json"
+	"sync"
+	"testing"
+
+	"go.k6.io/k6/lib"
+)
+
+func TestLock(t *testing.T) {
+	t.Parallel()
+
+	t.Run("across VUs", func(t *testing.T) {
+		t.Parallel()
+
+		root := New()
+		vus := []*testVU{newTestVUOf(t, root, nil), newTestVUOf(t, root, nil)}
+
+		// Each VU holds the mutex while a command runs, reporting when it held it.
+		intervals := make([][2]int64, len(vus))
+		var wg sync.WaitGroup
+		for i, vu := range vus {
+			vu.moveToVUContext(lib.Options{})
+
+			wg.Add(1)
+			go func(i int, vu *testVU) {
+				defer wg.Done()
+
+				got, err := vu.run(`exec.lock("migration", async () => {
+					const start = Date.now();
+					await new exec.Cmd("sleep").arg("0.2").exec();
+					return JSON.stringify([start, Date.now()]);
+				})`)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if err := json.Unmarshal([]byte(got.String()), &intervals[i]); err != nil {
+					t.Error(err)
+				}
+			}(i, vu)
+		}
+		wg.Wait()
+
+		first, second := intervals[0], intervals[1]
+		if first[0] > second[0] {
+			first, second = second, first
+		}
+		if second[0] < first[1] {
+			t.Errorf("the VUs held the mutex at the same time, during %v and %v", first, second)
+		}
+	})
+
+	t.Run("released once the function threw", func(t *testing.T) {
+		t.Parallel()
+
+		vu := newTestVU(t, nil)
+		vu.moveToVUContext(lib.Options{})
+
+		got := vu.mustRun(`(async () => {
+			const err = await exec.lock("m", () => { throw new Error("boom"); }).then(() => null, (e) => e.message);
+			return JSON.stringify([err, await exec.lock("m", () => 1)]);
+		})()`).String()
+		if want := `["boom",1]`; got != want {
+			t.Errorf("the script returned %s, want %s", got, want)
+		}
+	})
+
+	t.Run("released once the promise was rejected", func(t *testing.T) {
+		t.Parallel()
+
+		vu := newTestVU(t, nil)
+		vu.moveToVUContext(lib.Options{})
+
+		got := vu.mustRun(`(async () => {
+			const err = await exec.lock("m", async () => { throw new Error("boom"); }).then(() => null, (e) => e.message);
+			return JSON.stringify([err, await exec.lock("m", async () => 1)]);
+		})()`).String()
+		if want := `["boom",1]`; got != want {
+			t.Errorf("the script returned %s, want %s", got, want)
+		}
+	})
+}
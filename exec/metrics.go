@@ -0,0 +1,332 @@
+package exec
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/metrics"
+)
+
+// CustomMetrics are the custom k6 metrics used by xk6-browser.
+type CustomMetrics struct {
+	ExecCommandDuration         *metrics.Metric
+	ExecCommandsTotal           *metrics.Metric
+	ExecCommandStdoutBytesTotal *metrics.Metric
+	ExecCommandStderrBytesTotal *metrics.Metric
+	ExecCommandFailedRate       *metrics.Metric
+	ExecCommandRunningSeconds   *metrics.Metric
+	ExecCommandsSlow            *metrics.Metric
+	ExecCommandOutputThroughput *metrics.Metric
+	ExecCommandStdoutLines      *metrics.Metric
+	ExecCommandStderrLines      *metrics.Metric
+	ExecCommandsKilled          *metrics.Metric
+	ExecCommandStartRetries     *metrics.Metric
+	ExecCommandRetries          *metrics.Metric
+	ExecCommandCPUUserTime      *metrics.Metric
+	ExecCommandCPUSystemTime    *metrics.Metric
+	ExecCommandMaxRSSBytes      *metrics.Metric
+	ExecCommandQueueWait        *metrics.Metric
+	ExecCommandsInFlight        *metrics.Metric
+
+	// registry is the registry the metrics were registered with, and
+	// user-defined metrics are registered with.
+	registry *metrics.Registry
+}
+
+// builtin returns true if name is the name of one of the metrics of the module,
+// as opposed to a user-defined one.
+func (m *CustomMetrics) builtin(name string) bool {
+	for _, metric := range []*metrics.Metric{
+		m.ExecCommandDuration, m.ExecCommandsTotal, m.ExecCommandStdoutBytesTotal, m.ExecCommandStderrBytesTotal,
+		m.ExecCommandFailedRate, m.ExecCommandRunningSeconds, m.ExecCommandsSlow, m.ExecCommandOutputThroughput,
+		m.ExecCommandStdoutLines, m.ExecCommandStderrLines, m.ExecCommandsKilled, m.ExecCommandStartRetries,
+		m.ExecCommandRetries, m.ExecCommandCPUUserTime, m.ExecCommandCPUSystemTime, m.ExecCommandMaxRSSBytes,
+		m.ExecCommandQueueWait, m.ExecCommandsInFlight,
+	} {
+		if metric.Name == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// RegisterCustomMetrics creates and registers our custom metrics with the k6
+// VU Registry and returns our internal struct pointer.
+func RegisterCustomMetrics(registry *metrics.Registry) *CustomMetrics {
+	return &CustomMetrics{
+		registry:                    registry,
+		ExecCommandsTotal:           registry.MustNewMetric("exec_commands_total", metrics.Counter),
+		ExecCommandDuration:         registry.MustNewMetric("exec_command_duration", metrics.Trend, metrics.Time),
+		ExecCommandStdoutBytesTotal: registry.MustNewMetric("exec_command_stdout_bytes", metrics.Trend, metrics.Data),
+		ExecCommandStderrBytesTotal: registry.MustNewMetric("exec_command_stderr_bytes", metrics.Trend, metrics.Data),
+		ExecCommandFailedRate:       registry.MustNewMetric("exec_command_failed_rate", metrics.Rate),
+		ExecCommandRunningSeconds:   registry.MustNewMetric("exec_command_running_seconds", metrics.Gauge),
+		ExecCommandsSlow:            registry.MustNewMetric("exec_commands_slow", metrics.Counter),
+		ExecCommandOutputThroughput: registry.MustNewMetric("exec_command_output_throughput", metrics.Trend, metrics.Data),
+		ExecCommandStdoutLines:      registry.MustNewMetric("exec_command_stdout_lines", metrics.Counter),
+		ExecCommandStderrLines:      registry.MustNewMetric("exec_command_stderr_lines", metrics.Counter),
+		ExecCommandsKilled:          registry.MustNewMetric("exec_commands_killed", metrics.Counter),
+		ExecCommandStartRetries:     registry.MustNewMetric("exec_command_start_retries", metrics.Counter),
+		ExecCommandRetries:          registry.MustNewMetric("exec_command_retries", metrics.Counter),
+		ExecCommandCPUUserTime:      registry.MustNewMetric("exec_command_cpu_user_time", metrics.Trend, metrics.Time),
+		ExecCommandCPUSystemTime:    registry.MustNewMetric("exec_command_cpu_system_time", metrics.Trend, metrics.Time),
+		ExecCommandMaxRSSBytes:      registry.MustNewMetric("exec_command_max_rss_bytes", metrics.Trend, metrics.Data),
+		ExecCommandQueueWait:        registry.MustNewMetric("exec_command_queue_wait", metrics.Trend, metrics.Time),
+		ExecCommandsInFlight:        registry.MustNewMetric("exec_commands_in_flight", metrics.Gauge),
+	}
+}
+
+// executionStats holds the measurements of a command execution.
+type executionStats struct {
+	runID       string
+	exitCode    int
+	start       time.Time
+	end         time.Time
+	stdoutBytes int64
+	stderrBytes int64
+	stdoutLines int64
+	stderrLines int64
+	oomKilled   bool
+	outcome     string
+	killReason  string
+	killedBy    string
+
+	// expectations is whether the execution met its expectations, if it had any.
+	expectations string
+
+	// outputDiscarded is set if the output streams weren't read, and their measurements are unknown.
+	outputDiscarded bool
+
+	// usage holds the resources the command consumed.
+	usage resourceUsage
+}
+
+// pushMetrics emits the metric samples of a command execution. No samples are
+// emitted for commands executed outside of a VU state.
+func (c *Command) pushMetrics(ctx context.Context, state *lib.State, stats executionStats) {
+	if state == nil {
+		return
+	}
+
+	var failed float64
+	if stats.exitCode != 0 {
+		failed = 1
+	}
+
+	tags := state.Tags.GetCurrentValues().Tags
+	tags = c.withCommandTags(tags)
+	tags = c.withGuardedTag(tags, "exit_code", strconv.Itoa(stats.exitCode))
+	if stats.oomKilled {
+		tags = tags.With("oom_killed", "true")
+	}
+	if stats.outcome != "" {
+		tags = tags.With("outcome", stats.outcome)
+	}
+	if stats.killReason != "" {
+		tags = tags.With("kill_reason", stats.killReason)
+	}
+	if stats.expectations != "" {
+		tags = tags.With("expectations", stats.expectations)
+	}
+	if c.config.resolve(c.vu).runIDTag {
+		tags = tags.With("run_id", stats.runID)
+	}
+
+	end := stats.end
+	samples := metrics.ConnectedSamples{
+		Samples: []metrics.Sample{
+			{
+				TimeSeries: metrics.TimeSeries{Metric: c.metrics.ExecCommandDuration, Tags: tags},
+				Value:      float64(end.Sub(stats.start).Milliseconds()),
+				Time:       end,
+			},
+			{
+				TimeSeries: metrics.TimeSeries{Metric: c.metrics.ExecCommandsTotal, Tags: tags},
+				Value:      1,
+				Time:       end,
+			},
+			{
+				TimeSeries: metrics.TimeSeries{Metric: c.metrics.ExecCommandFailedRate, Tags: tags},
+				Value:      failed,
+				Time:       end,
+			},
+		},
+	}
+
+	if !stats.outputDiscarded {
+		samples.Samples = append(samples.Samples, c.outputSamples(stats, tags)...)
+	}
+
+	samples.Samples = append(samples.Samples, c.usageSamples(stats, tags)...)
+
+	if stats.killedBy != "" {
+		samples.Samples = append(samples.Samples, metrics.Sample{
+			TimeSeries: metrics.TimeSeries{Metric: c.metrics.ExecCommandsKilled, Tags: tags.With("reason", stats.killedBy)},
+			Value:      1,
+			Time:       end,
+		})
+	}
+
+	c.pushSamples(ctx, state, samples.Samples...)
+}
+
+// pushSamples emits the samples of the metrics of the module which weren't disabled using
+// the disabledMetrics configuration option, connected to one another.
+func (c *Command) pushSamples(ctx context.Context, state *lib.State, samples ...metrics.Sample) {
+	disabled := c.config.resolve(c.vu).disabledMetrics
+
+	enabled := samples[:0:0]
+	for _, sample := range samples {
+		if !disabled[sample.Metric.Name] {
+			enabled = append(enabled, sample)
+		}
+	}
+
+	if len(enabled) > 0 {
+		metrics.PushIfNotDone(ctx, state.Samples, metrics.ConnectedSamples{Samples: enabled})
+	}
+}
+
+// usageSamples returns the samples measuring the resources a command execution consumed, if known.
+func (c *Command) usageSamples(stats executionStats, tags *metrics.TagSet) []metrics.Sample {
+	if !stats.usage.known {
+		return nil
+	}
+
+	end := stats.end
+	samples := []metrics.Sample{
+		{
+			TimeSeries: metrics.TimeSeries{Metric: c.metrics.ExecCommandCPUUserTime, Tags: tags},
+			Value:      milliseconds(stats.usage.userTime),
+			Time:       end,
+		},
+		{
+			TimeSeries: metrics.TimeSeries{Metric: c.metrics.ExecCommandCPUSystemTime, Tags: tags},
+			Value:      milliseconds(stats.usage.systemTime),
+			Time:       end,
+		},
+	}
+
+	if stats.usage.maxRSS > 0 {
+		samples = append(samples, metrics.Sample{
+			TimeSeries: metrics.TimeSeries{Metric: c.metrics.ExecCommandMaxRSSBytes, Tags: tags},
+			Value:      float64(stats.usage.maxRSS),
+			Time:       end,
+		})
+	}
+
+	return samples
+}
+
+// outputSamples returns the samples measuring the output of a command execution.
+func (c *Command) outputSamples(stats executionStats, tags *metrics.TagSet) []metrics.Sample {
+	end := stats.end
+	samples := []metrics.Sample{
+		{
+			TimeSeries: metrics.TimeSeries{Metric: c.metrics.ExecCommandStdoutBytesTotal, Tags: tags},
+			Value:      float64(stats.stdoutBytes),
+			Time:       end,
+		},
+		{
+			TimeSeries: metrics.TimeSeries{Metric: c.metrics.ExecCommandStderrBytesTotal, Tags: tags},
+			Value:      float64(stats.stderrBytes),
+			Time:       end,
+		},
+		{
+			TimeSeries: metrics.TimeSeries{Metric: c.metrics.ExecCommandStdoutLines, Tags: tags},
+			Value:      float64(stats.stdoutLines),
+			Time:       end,
+		},
+		{
+			TimeSeries: metrics.TimeSeries{Metric: c.metrics.ExecCommandStderrLines, Tags: tags},
+			Value:      float64(stats.stderrLines),
+			Time:       end,
+		},
+	}
+
+	// The throughput is the number of bytes written to both output streams per second.
+	if duration := end.Sub(stats.start).Seconds(); duration > 0 {
+		samples = append(samples, metrics.Sample{
+			TimeSeries: metrics.TimeSeries{Metric: c.metrics.ExecCommandOutputThroughput, Tags: tags},
+			Value:      float64(stats.stdoutBytes+stats.stderrBytes) / duration,
+			Time:       end,
+		})
+	}
+
+	return samples
+}
+
+// pushHeartbeat emits a sample of how long a still running command has been running for.
+func (c *Command) pushHeartbeat(ctx context.Context, state *lib.State, start, now time.Time) {
+	if state == nil {
+		return
+	}
+
+	tags := state.Tags.GetCurrentValues().Tags
+	tags = c.withCommandTags(tags)
+
+	c.pushSamples(ctx, state, metrics.Sample{
+		TimeSeries: metrics.TimeSeries{Metric: c.metrics.ExecCommandRunningSeconds, Tags: tags},
+		Value:      now.Sub(start).Seconds(),
+		Time:       now,
+	})
+}
+
+// pushSlow emits a sample counting a command which exceeded its warnAfter threshold.
+func (c *Command) pushSlow(ctx context.Context, state *lib.State, now time.Time) {
+	if state == nil {
+		return
+	}
+
+	tags := state.Tags.GetCurrentValues().Tags
+	tags = c.withCommandTags(tags)
+
+	c.pushSamples(ctx, state, metrics.Sample{
+		TimeSeries: metrics.TimeSeries{Metric: c.metrics.ExecCommandsSlow, Tags: tags},
+		Value:      1,
+		Time:       now,
+	})
+}
+
+// pushStartRetries emits a sample counting each retry of starting a command which
+// failed to start with a transient error, tagged with the error it failed with.
+func (c *Command) pushStartRetries(ctx context.Context, state *lib.State, retried []string, now time.Time) {
+	if state == nil || len(retried) == 0 {
+		return
+	}
+
+	tags := state.Tags.GetCurrentValues().Tags
+	tags = c.withCommandTags(tags)
+
+	samples := make([]metrics.Sample, 0, len(retried))
+	for _, errno := range retried {
+		samples = append(samples, metrics.Sample{
+			TimeSeries: metrics.TimeSeries{Metric: c.metrics.ExecCommandStartRetries, Tags: tags.With("error", errno)},
+			Value:      1,
+			Time:       now,
+		})
+	}
+
+	c.pushSamples(ctx, state, samples...)
+}
+
+// pushRetry emits a sample counting a retry of a command according to its retry policy,
+// after the attempt the command is executing exited with exitCode.
+func (c *Command) pushRetry(ctx context.Context, state *lib.State, exitCode int, now time.Time) {
+	if state == nil {
+		return
+	}
+
+	tags := state.Tags.GetCurrentValues().Tags
+	tags = c.withCommandTags(tags)
+	tags = c.withGuardedTag(tags, "exit_code", strconv.Itoa(exitCode))
+
+	c.pushSamples(ctx, state, metrics.Sample{
+		TimeSeries: metrics.TimeSeries{Metric: c.metrics.ExecCommandRetries, Tags: tags},
+		Value:      1,
+		Time:       now,
+	})
+}
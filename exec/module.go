@@ -6,14 +6,15 @@ such as duration, stdout/stderr bytes, and failure rate. The metrics are exposed
 appear in the summary at the end of a k6 test execution.
 
 The exec package introduces a new global object 'Cmd' in the k6 JavaScript context, which can be
-used to construct commands. Each 'Cmd' object has an 'Arg' method for adding command-line arguments, an
-'Env' method for setting environment variables, and an 'Exec' method for executing the command and
-returning a promise that resolves with the command's result.
+used to construct commands. Each 'Cmd' object has an 'arg' method for adding command-line arguments, an
+'env' method for setting environment variables, and an 'exec' method for executing the command and
+returning a promise that resolves with the command's result. As the Go methods are exposed with their
+first letter lowercased, the JS API follows the usual camelCase naming of JavaScript.
 
-The 'Cmd' object's 'Exec' method runs the command in a non-blocking manner and returns a promise, making
+The 'Cmd' object's 'exec' method runs the command in a non-blocking manner and returns a promise, making
 it compatible with the k6 event loop.
 
-Command executions are done within the context of the Virtual User (VU) that called the 'Exec' method, and
+Command executions are done within the context of the Virtual User (VU) that called the 'exec' method, and
 the command will be interrupted if the VU context is cancelled.
 
 Note: The current implementation of the exec package should be considered experimental and potentially
@@ -23,12 +24,12 @@ risk if k6 is used to run untrusted scripts.
 Example usage:
 
 ```
-import exec from 'k6/x/exec';
+import exec from 'k6/x/cmd';
 
 	export default async function() {
-		let cmd = new exec.Cmd("echo").Arg("Hello, World!");
+		let cmd = new exec.Cmd("echo").arg("Hello, World!");
 
-		const result = await cmd.Exec();
+		const result = await cmd.exec();
 
 		console.log(result.stdout); // Output: "Hello, World!"
 	}
@@ -38,26 +39,78 @@ import exec from 'k6/x/exec';
 package exec
 
 import (
-	"errors"
-	"io"
-	"os/exec"
-	"strconv"
-	"time"
-
 	"github.com/dop251/goja"
 	"go.k6.io/k6/js/common"
 	"go.k6.io/k6/js/modules"
-	"go.k6.io/k6/metrics"
 )
 
 type (
 	// RootModule is the global module instance that will create Client
 	// instances for each VU.
-	RootModule struct{}
+	RootModule struct {
+		// version is the major version of the JS API exposed by the module.
+		// The zero value stands for the original API.
+		version int
+
+		// shared holds the outputs shared by all VUs through SharedOutput.
+		shared sharedOutputs
+
+		// aborts holds the commands registered by all VUs through OnAbort.
+		aborts abortHandlers
+
+		// locks holds the mutexes shared by all VUs through Lock.
+		locks namedLocks
+
+		// onces holds the executions shared by all VUs through Once.
+		onces onceExecutions
+
+		// services holds the service processes shared by all VUs through Service.
+		services services
+
+		// hooks runs the hook commands declared in the test options.
+		hooks testHooks
+
+		// testRun tracks the phases of the test run, and runs what is bound to them.
+		testRun testRun
+
+		// policy is the execution policy set by the operator of k6, shared by all VUs.
+		policy operatorPolicy
+
+		// limiter caps the number of commands executing concurrently across all VUs.
+		limiter concurrencyLimiter
+
+		// thresholds holds the commands registered by all VUs through OnThreshold.
+		thresholds thresholdTriggers
+
+		// cardinality caps the number of distinct values of the tags set by all VUs.
+		cardinality tagCardinality
+	}
 
 	// ModuleInstance represents an instance of the JS module.
 	ModuleInstance struct {
-		vu modules.VU
+		vu          modules.VU
+		version     int
+		config      *moduleConfig
+		shared      *sharedOutputs
+		aborts      *abortHandlers
+		locks       *namedLocks
+		onces       *onceExecutions
+		services    *services
+		hooks       *testHooks
+		testRun     *testRun
+		policy      *operatorPolicy
+		limiter     *concurrencyLimiter
+		thresholds  *thresholdTriggers
+		cardinality *tagCardinality
+
+		// warmups tracks the warm-up commands the VU executed.
+		warmups *warmups
+
+		// heldServices holds the services the VU holds a reference on, guarded by the services mutex.
+		heldServices map[*service]bool
+
+		// processes holds the commands the VU started which didn't exit yet.
+		processes *processRegistry
 
 		*Command
 		Metrics *CustomMetrics
@@ -72,16 +125,49 @@ var (
 
 // New returns a pointer to a new RootModule instance
 func New() *RootModule {
-	return &RootModule{}
+	return newRootModule(0)
+}
+
+// NewV2 returns a pointer to a new RootModule instance exposing the v2 JS API,
+// whose promises are rejected with JS Error objects rather than Go values.
+func NewV2() *RootModule {
+	return newRootModule(2)
+}
+
+// newRootModule returns a pointer to a new RootModule instance exposing the given major version of the JS API.
+func newRootModule(version int) *RootModule {
+	rm := &RootModule{version: version}
+	rm.testRun.hooks, rm.testRun.aborts, rm.testRun.policy = &rm.hooks, &rm.aborts, &rm.policy
+
+	return rm
 }
 
 // NewModuleInstance implements the modules.Module interface and returns
 // a new instance for each VU.
-func (*RootModule) NewModuleInstance(vu modules.VU) modules.Instance {
+func (rm *RootModule) NewModuleInstance(vu modules.VU) modules.Instance {
 	vu.Runtime().SetFieldNameMapper(goja.TagFieldNameMapper("js", true))
+	asyncDisposeSymbol(vu.Runtime())
+	config := newModuleConfig()
+	rm.testRun.observe(vu, config)
 
 	return &ModuleInstance{
-		vu:      vu,
+		vu:          vu,
+		version:     rm.version,
+		config:      config,
+		shared:      &rm.shared,
+		aborts:      &rm.aborts,
+		locks:       &rm.locks,
+		onces:       &rm.onces,
+		services:    &rm.services,
+		hooks:       &rm.hooks,
+		testRun:     &rm.testRun,
+		policy:      &rm.policy,
+		limiter:     &rm.limiter,
+		thresholds:  &rm.thresholds,
+		cardinality: &rm.cardinality,
+		warmups:     &warmups{},
+		processes:   &processRegistry{},
+
 		Command: &Command{vu: vu},
 		Metrics: RegisterCustomMetrics(vu.InitEnv().Registry),
 	}
@@ -91,52 +177,42 @@ func (*RootModule) NewModuleInstance(vu modules.VU) modules.Instance {
 // the exports of the JS module.
 func (mi *ModuleInstance) Exports() modules.Exports {
 	return modules.Exports{Named: map[string]interface{}{
-		"Cmd": mi.NewCmd,
+		"Cmd":          mi.NewCmd,
+		"SharedOutput": mi.NewSharedOutput,
+		"run":          mi.Run,
+		"sourceEnv":    mi.SourceEnv,
+		"configure":    mi.Configure,
+		"runInit":      mi.RunInit,
+		"onAbort":      mi.OnAbort,
+		"onThreshold":  mi.OnThreshold,
+		"CmdGroup":     mi.NewCmdGroup,
+		"fixture":      mi.Fixture,
+		"lock":         mi.Lock,
+		"flock":        mi.Flock,
+		"environ":      mi.Environ,
+		"kill":         mi.Kill,
+		"killAll":      mi.KillAll,
+		"once":         mi.Once,
+		"service":      mi.Service,
+		"sh":           mi.Sh,
+		"ssh":          mi.SSH,
+		"sshAll":       mi.SSHAll,
+		"shSync":       mi.ShSync,
+		"parseCommand": mi.ParseCommand,
 	}}
 }
 
-// CustomMetrics are the custom k6 metrics used by xk6-browser.
-type CustomMetrics struct {
-	ExecCommandDuration         *metrics.Metric
-	ExecCommandsTotal           *metrics.Metric
-	ExecCommandStdoutBytesTotal *metrics.Metric
-	ExecCommandStderrBytesTotal *metrics.Metric
-	ExecCommandFailedRate       *metrics.Metric
-}
+// NewCmd is the JS constructor for the Cmd object. It accepts an optional object describing
+// the command declaratively, whose args, env, cwd and timeout keys are respectively equivalent
+// to calling Arg with each of its arguments, Env, Cwd and Timeout.
+func (mi *ModuleInstance) NewCmd(call goja.ConstructorCall) *goja.Object {
+	rt := mi.vu.Runtime()
 
-// RegisterCustomMetrics creates and registers our custom metrics with the k6
-// VU Registry and returns our internal struct pointer.
-func RegisterCustomMetrics(registry *metrics.Registry) *CustomMetrics {
-	return &CustomMetrics{
-		ExecCommandsTotal: registry.MustNewMetric(
-			"exec_commands_total",
-			metrics.Counter,
-			metrics.ValueType(metrics.Counter),
-		),
-		ExecCommandDuration: registry.MustNewMetric(
-			"exec_command_duration",
-			metrics.Trend,
-			metrics.Time,
-		),
-		ExecCommandStdoutBytesTotal: registry.MustNewMetric(
-			"exec_command_stdout_bytes",
-			metrics.Trend,
-			metrics.Data,
-		),
-		ExecCommandStderrBytesTotal: registry.MustNewMetric(
-			"exec_command_stderr_bytes",
-			metrics.Trend,
-			metrics.Data,
-		),
-		ExecCommandFailedRate: registry.MustNewMetric(
-			"exec_command_failed_rate",
-			metrics.Rate,
-		),
-	}
+	return rt.ToValue(mi.constructCommand(call)).ToObject(rt)
 }
 
-// NewCmd is the JS constructor for the Cmd object.
-func (mi *ModuleInstance) NewCmd(call goja.ConstructorCall) *goja.Object {
+// constructCommand returns the command built out of the arguments of the Cmd constructor.
+func (mi *ModuleInstance) constructCommand(call goja.ConstructorCall) *Command {
 	rt := mi.vu.Runtime()
 
 	var name string
@@ -145,183 +221,42 @@ func (mi *ModuleInstance) NewCmd(call goja.ConstructorCall) *goja.Object {
 		common.Throw(rt, err)
 	}
 
-	command := &Command{
-		Name:    name,
-		args:    make([]string, 0),
-		env:     make(map[string]string),
-		vu:      mi.vu,
-		metrics: mi.Metrics,
+	command := mi.newCommand(name)
+	if options := call.Argument(1); !common.IsNullish(options) {
+		*command = command.describe(options.ToObject(rt))
 	}
 
-	return rt.ToValue(command).ToObject(rt)
+	return command
 }
 
-// Command represents a command to be executed.
-type Command struct {
-	Name string
+// Run executes the named command with the provided arguments and options, and returns
+// a promise resolved with its result. It is a shorthand for building a Cmd and calling
+// its Exec method.
+func (mi *ModuleInstance) Run(name string, args []string, options goja.Value) *goja.Promise {
+	command := mi.newCommand(name)
+	command.args = append(command.args, args...)
 
-	args []string
-	env  map[string]string
-
-	vu      modules.VU
-	metrics *CustomMetrics
-}
-
-// Arg adds an argument to the command.
-func (c Command) Arg(arg string) Command {
-	c.args = append(c.args, arg)
-	return c
-}
-
-// Env sets an environment variable for the command.
-func (c Command) Env(key, value string) Command {
-	c.env[key] = value
-	return c
+	return command.Exec(options)
 }
 
-// Exec runs the command and returns a promise that will be resolved when the command finishes.
-// FIXME: this is probably very unsafe.
-func (c *Command) Exec() *goja.Promise {
-	vuContext := c.vu.Context()
-	vuState := c.vu.State()
-
-	promise, resolve, reject := makeHandledPromise(c.vu)
-
-	cmdPath, err := exec.LookPath(c.Name)
-	if errors.Is(err, exec.ErrDot) {
-		err = nil
-	}
-
-	if err != nil {
-		reject(err)
-		return promise
-	}
-
-	environ := make([]string, 0, len(c.env))
-	for k, v := range c.env {
-		environ = append(environ, k+"="+v)
-	}
-
-	cmd := exec.CommandContext(vuContext, cmdPath, c.args...)
-	cmd.Env = append(cmd.Environ(), environ...)
-
-	stdout, err := cmd.StdoutPipe()
-	if err != nil {
-		reject(err)
-		return promise
-	}
+// newCommand returns a new command running the named executable.
+func (mi *ModuleInstance) newCommand(name string) *Command {
+	return &Command{
+		Name:    name,
+		args:    make([]string, 0),
+		env:     make(map[string]string),
+		vu:      mi.vu,
+		metrics: mi.Metrics,
+		config:  mi.config,
+		warmups: mi.warmups,
+		hooks:   mi.hooks,
+		testRun: mi.testRun,
 
-	stderr, err := cmd.StderrPipe()
-	if err != nil {
-		reject(err)
-		return promise
-	}
+		cardinality: mi.cardinality,
+		processes:   mi.processes,
+		policy:      mi.policy,
+		limiter:     mi.limiter,
 
-	start := time.Now()
-	if err := cmd.Start(); err != nil {
-		reject(err)
-		return promise
+		typedErrors: mi.version >= 2,
 	}
-
-	go func() {
-		stdoutBytes, err := io.ReadAll(stdout)
-		if err != nil {
-			reject(err)
-			return
-		}
-
-		stderrBytes, err := io.ReadAll(stderr)
-		if err != nil {
-			reject(err)
-			return
-		}
-
-		var exitCode int
-		if err := cmd.Wait(); err != nil {
-			var exitErr *exec.ExitError
-			if errors.As(err, &exitErr) {
-				exitCode = exitErr.ExitCode()
-			}
-		}
-
-		end := time.Now()
-		duration := end.Sub(start)
-
-		// FIXME: still somewhat confused as to how rate metrics
-		// function when used as a "boolean" metric.
-		// I would imagine the reverse logic would produce the output
-		// I would naively expect, but it does not.
-		var failed float64
-		if exitCode == 0 {
-			failed = 1
-		}
-
-		tags := vuState.Tags.GetCurrentValues().Tags
-		tags = tags.With("executable", c.Name)
-		tags = tags.With("exit_code", strconv.Itoa(exitCode))
-
-		metrics.PushIfNotDone(vuContext, vuState.Samples, metrics.ConnectedSamples{
-			Samples: []metrics.Sample{
-				{
-					TimeSeries: metrics.TimeSeries{Metric: c.metrics.ExecCommandDuration, Tags: tags},
-					Value:      float64(duration.Milliseconds()),
-					Time:       end,
-				},
-				{
-					TimeSeries: metrics.TimeSeries{Metric: c.metrics.ExecCommandsTotal, Tags: tags},
-					Value:      1,
-					Time:       end,
-				},
-				{
-					TimeSeries: metrics.TimeSeries{Metric: c.metrics.ExecCommandStdoutBytesTotal, Tags: tags},
-					Value:      float64(len(stdoutBytes)),
-					Time:       end,
-				},
-				{
-					TimeSeries: metrics.TimeSeries{Metric: c.metrics.ExecCommandStderrBytesTotal, Tags: tags},
-					Value:      float64(len(stderrBytes)),
-					Time:       end,
-				},
-				{
-					TimeSeries: metrics.TimeSeries{Metric: c.metrics.ExecCommandFailedRate, Tags: tags},
-					Value:      failed,
-					Time:       end,
-				},
-			},
-		})
-
-		resolve(CommandResult{ExitCode: exitCode, Stdout: string(stdoutBytes), Stderr: string(stderrBytes)})
-	}()
-
-	return promise
-}
-
-// CommandResult holds the result of a command execution.
-type CommandResult struct {
-	ExitCode int    `js:"exitCode"`
-	Stdout   string `js:"stdout"`
-	Stderr   string `js:"stderr"`
-}
-
-// makeHandledPromise will create a promise and return its resolve and reject methods,
-// wrapped in such a way that it will block the eventloop from exiting before they are
-// called even if the promise isn't resolved by the time the current script ends executing.
-func makeHandledPromise(vu modules.VU) (*goja.Promise, func(interface{}), func(interface{})) {
-	runtime := vu.Runtime()
-	callback := vu.RegisterCallback()
-	p, resolve, reject := runtime.NewPromise()
-
-	return p, func(i interface{}) {
-			// more stuff
-			callback(func() error {
-				resolve(i)
-				return nil
-			})
-		}, func(i interface{}) {
-			// more stuff
-			callback(func() error {
-				reject(i)
-				return nil
-			})
-		}
 }
@@ -0,0 +1,25 @@
+package exec
+
+import "fmt"
+
+// Network modes, controlling the network commands have access to.
+const (
+	// networkHost leaves commands in the network namespace of k6.
+	networkHost = ""
+
+	// networkNone runs commands in a new network namespace without interfaces.
+	networkNone = "none"
+
+	// networkLoopback runs commands in a new network namespace
+	// holding only a loopback interface.
+	networkLoopback = "loopback"
+)
+
+// validateNetworkMode returns an error if mode is not a supported network mode.
+func validateNetworkMode(mode string) error {
+	if mode != networkNone && mode != networkLoopback {
+		return fmt.Errorf("unsupported network mode %q; expected %s or %s", mode, networkNone, networkLoopback)
+	}
+
+	return nil
+}
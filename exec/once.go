@@ -0,0 +1,164 @@
+package exec
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/dop251/goja"
+	"go.k6.io/k6/js/common"
+	"go.k6.io/k6/js/modules"
+)
+
+// onceExecutions holds the executions run through Once, shared by all the VUs of the test.
+type onceExecutions struct {
+	mu         sync.Mutex
+	executions map[string]*onceExecution
+}
+
+// onceExecution is the single execution of a command run through Once.
+type onceExecution struct {
+	// done is closed once the command completed, after which result and err are set.
+	done   chan struct{}
+	result *CommandResult
+	err    error
+}
+
+// get returns the named execution, and true if it was created by the call, in
+// which case the caller is responsible for running it.
+func (oe *onceExecutions) get(name string) (*onceExecution, bool) {
+	oe.mu.Lock()
+	defer oe.mu.Unlock()
+
+	if oe.executions == nil {
+		oe.executions = make(map[string]*onceExecution)
+	}
+
+	if execution, ok := oe.executions[name]; ok {
+		return execution, false
+	}
+
+	execution := &onceExecution{done: make(chan struct{})}
+	oe.executions[name] = execution
+
+	return execution, true
+}
+
+// forget forgets the named execution, if it is execution, so that the next call runs it again.
+func (oe *onceExecutions) forget(name string, execution *onceExecution) {
+	oe.mu.Lock()
+	defer oe.mu.Unlock()
+
+	if oe.executions[name] == execution {
+		delete(oe.executions, name)
+	}
+}
+
+// Once executes the command, either a Cmd or an array holding the name of a command followed
+// by its arguments, with the provided options, exactly once per test instance under the given
+// name, no matter how many VUs call it. It returns a promise settled with the outcome of the
+// single execution, for all the callers, once it completed.
+func (mi *ModuleInstance) Once(name string, spec goja.Value, options goja.Value) *goja.Promise {
+	rt := mi.vu.Runtime()
+
+	command, ok := mi.commandFromSpec(spec)
+	if !ok {
+		common.Throw(rt, errors.New("once expects a name, and a Cmd or an array holding a command and its arguments"))
+	}
+
+	promise, resolve, reject := makeHandledPromise(mi.vu)
+
+	// Invalid options are the caller's, and aren't recorded as the outcome of the execution.
+	opts, err := parseExecOptions(rt, options)
+	if err != nil {
+		reject(command.rejection(err))
+		return promise
+	}
+
+	execution, first := mi.onces.get(name)
+	if first {
+		mi.startOnce(name, execution, command, opts)
+	}
+
+	ctx := mi.vu.Context()
+	go func() {
+		select {
+		case <-execution.done:
+		case <-ctx.Done():
+			reject(command.rejection(ctx.Err()))
+			return
+		}
+
+		if execution.err != nil {
+			reject(command.rejection(execution.err))
+			return
+		}
+
+		resolve(execution.result)
+	}()
+
+	return promise
+}
+
+// startOnce starts the named execution of command with the provided options, and records its
+// outcome once it completed. As it is shared by all the callers, it is run with a context scoped
+// to the test, rather than to the iteration of the VU starting it, so that this iteration ending
+// doesn't fail it for all of them. When the end of the test can't be observed, the context of the
+// VU is used, and the execution is forgotten if it failed as the context was done, so that the
+// next call runs it again.
+func (mi *ModuleInstance) startOnce(name string, execution *onceExecution, command *Command, opts *execOptions) {
+	vuContext := mi.vu.Context()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	if mi.testRun.onEnd(vuContext, cancel) {
+		shared := *command
+		shared.vu = scopedVU{VU: mi.vu, ctx: testContext{Context: ctx, values: vuContext}}
+		command = &shared
+	} else {
+		cancel()
+		ctx = vuContext
+	}
+
+	complete, err := command.run(opts)
+	if err != nil {
+		execution.complete(nil, err)
+		return
+	}
+
+	go func() {
+		result, err := complete()
+		if err != nil && ctx.Err() != nil {
+			mi.onces.forget(name, execution)
+		}
+		execution.complete(result, err)
+	}()
+}
+
+// complete records the outcome of the execution.
+func (oe *onceExecution) complete(result *CommandResult, err error) {
+	oe.result, oe.err = result, err
+	close(oe.done)
+}
+
+// scopedVU is a VU whose context is replaced, such as by one scoped to the test.
+type scopedVU struct {
+	modules.VU
+	ctx context.Context
+}
+
+// Context implements the modules.VU interface.
+func (vu scopedVU) Context() context.Context {
+	return vu.ctx
+}
+
+// testContext is a context scoped to the test, done once it ended, holding the values of a context
+// of a VU, such as the execution state of the test, without being done along with it.
+type testContext struct {
+	context.Context
+	values context.Context
+}
+
+// Value implements the context.Context interface.
+func (ctx testContext) Value(key interface{}) interface{} {
+	return ctx.values.Value(key)
+}
@@ -0,0 +1,106 @@
+//go:build !windows
+
+package exec
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"go.k6.io/k6/lib"
+)
+
+func TestOnce(t *testing.T) {
+	t.Parallel()
+
+	t.Run("across VUs", func(t *testing.T) {
+		t.Parallel()
+
+		// The command appends a line to the file, so that its executions can be counted.
+		runs := filepath.Join(t.TempDir(), "runs")
+		script := fmt.Sprintf(`exec.once("seed", ["sh", "-c", "echo >> %s; sleep 0.1; echo seeded"]).then((r) => r.stdout)`, runs)
+
+		root := New()
+		vus := []*testVU{newTestVUOf(t, root, nil), newTestVUOf(t, root, nil), newTestVUOf(t, root, nil)}
+
+		outputs := make([]string, len(vus))
+		var wg sync.WaitGroup
+		for i, vu := range vus {
+			vu.moveToVUContext(lib.Options{})
+
+			wg.Add(1)
+			go func(i int, vu *testVU) {
+				defer wg.Done()
+
+				output, err := vu.run(script)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				outputs[i] = output.String()
+			}(i, vu)
+		}
+		wg.Wait()
+
+		// Calls made once the execution completed are settled with its outcome as well.
+		outputs = append(outputs, vus[0].mustRun(script).String())
+
+		for i, output := range outputs {
+			if output != "seeded\n" {
+				t.Errorf("call %d was resolved with the output %q, want %q", i, output, "seeded\n")
+			}
+		}
+
+		data, err := os.ReadFile(runs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n := strings.Count(string(data), "\n"); n != 1 {
+			t.Errorf("the command was executed %d times, want 1", n)
+		}
+	})
+
+	t.Run("failure", func(t *testing.T) {
+		t.Parallel()
+
+		root := New()
+		const script = `exec.once("seed", ["false"], { throwOnError: true }).then(() => "resolved", (err) => "" + err.exitCode)`
+
+		for i, vu := range []*testVU{newTestVUOf(t, root, nil), newTestVUOf(t, root, nil)} {
+			vu.moveToVUContext(lib.Options{})
+			if got := vu.mustRun(script).String(); got != "1" {
+				t.Errorf("call %d was settled with %s, want a rejection with the exit code 1", i, got)
+			}
+		}
+	})
+
+	t.Run("invalid options", func(t *testing.T) {
+		t.Parallel()
+
+		vu := newTestVU(t, nil)
+		vu.moveToVUContext(lib.Options{})
+
+		// The options of the rejected call aren't recorded, so that the next call executes the command.
+		got := vu.mustRun(`(async () => {
+			const err = await exec.once("seed", ["echo", "a"], { timeout: "soon" }).then(() => null, (e) => "" + e);
+			return JSON.stringify([err !== null, (await exec.once("seed", ["echo", "b"])).stdout]);
+		})()`).String()
+		if want := `[true,"b\n"]`; got != want {
+			t.Errorf("the script returned %s, want %s", got, want)
+		}
+	})
+
+	t.Run("invalid command", func(t *testing.T) {
+		t.Parallel()
+
+		vu := newTestVU(t, nil)
+		vu.moveToVUContext(lib.Options{})
+
+		if _, err := vu.run(`exec.once("seed", 42)`); err == nil || !strings.Contains(err.Error(), "once expects a name") {
+			t.Errorf("the script failed with %v, want an error about the command", err)
+		}
+	})
+}
@@ -0,0 +1,70 @@
+package exec
+
+import (
+	"errors"
+
+	"github.com/dop251/goja"
+	"go.k6.io/k6/js/common"
+)
+
+// OnExit returns a copy of the command calling fn, on the event loop, once an execution of it
+// using Exec or ExecSync completed, with its result, or with null and the error it failed with,
+// before the promise returned by Exec is settled, so that scripts can react to the completion of
+// commands they don't await. Spawned processes emit exit events instead.
+func (c Command) OnExit(fn goja.Value) Command {
+	callback, ok := goja.AssertFunction(fn)
+	if !ok {
+		common.Throw(c.vu.Runtime(), errors.New("onExit expects a function"))
+	}
+
+	c.onExit = callback
+	return c
+}
+
+// notifyExit returns resolve and reject, the functions settling the promise returned by Exec,
+// wrapped so that the onExit callback of the command, if any, is called before it is settled.
+func (c *Command) notifyExit(resolve, reject func(interface{})) (func(interface{}), func(interface{})) {
+	if c.onExit == nil {
+		return resolve, reject
+	}
+
+	rt := c.vu.Runtime()
+	callback := c.vu.RegisterCallback()
+
+	notify := func(settle func(interface{}), failed bool) func(interface{}) {
+		return func(value interface{}) {
+			callback(func() error {
+				settled := toValue(rt, value)
+				defer settle(settled)
+
+				result, reason := settled, goja.Undefined()
+				if failed {
+					result, reason = goja.Null(), settled
+				}
+
+				_, err := c.onExit(goja.Undefined(), result, reason)
+				return err
+			})
+		}
+	}
+
+	return notify(resolve, false), notify(reject, true)
+}
+
+// notifyExitSync calls the onExit callback of the command, if any, once an execution of it using
+// ExecSync completed, with result, or with null and the error it failed with, if err isn't nil.
+func (c *Command) notifyExitSync(result goja.Value, err error) {
+	if c.onExit == nil {
+		return
+	}
+
+	rt := c.vu.Runtime()
+	reason := goja.Undefined()
+	if err != nil {
+		result, reason = goja.Null(), toValue(rt, c.rejection(err))
+	}
+
+	if _, err := c.onExit(goja.Undefined(), result, reason); err != nil {
+		common.Throw(rt, err)
+	}
+}
@@ -0,0 +1,84 @@
+//go:build !windows
+
+package exec
+
+import (
+	"testing"
+
+	"go.k6.io/k6/lib"
+)
+
+func TestOnLine(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		script string
+		want   string
+	}{
+		{
+			name: "captured",
+			script: `(async () => {
+				const lines = [];
+				const r = await new exec.Cmd("sh").args(["-c", "printf 'a\\nb\\n'; echo c >&2"])
+					.onLine((line, stream) => lines.push(stream + ":" + line)).exec();
+				return JSON.stringify([lines.sort(), r.stdout, r.stderr]);
+			})()`,
+			want: `[["stderr:c","stdout:a","stdout:b"],"a\nb\n","c\n"]`,
+		},
+		{
+			name: "filtered",
+			script: `(async () => {
+				const lines = [];
+				const r = await new exec.Cmd("sh").args(["-c", "printf 'a\\nb\\n'"])
+					.onLine((line) => lines.push(line)).exec({ filter: "b" });
+				return JSON.stringify([lines, r.stdout]);
+			})()`,
+			want: `[["a","b"],"b\n"]`,
+		},
+		{
+			name: "truncated",
+			script: `(async () => {
+				const lines = [];
+				const r = await new exec.Cmd("sh").args(["-c", "printf 'a\\nb\\n'"])
+					.exec({ onStdout: (line) => lines.push(line), maxOutputBytes: 2 });
+				return JSON.stringify([lines, r.stdout]);
+			})()`,
+			want: `[["a","b"],"a\n"]`,
+		},
+		{
+			name: "discarded",
+			script: `(async () => {
+				const lines = [];
+				const r = await new exec.Cmd("sh").args(["-c", "printf 'a\\nb\\n'"])
+					.onLine((line) => lines.push(line)).exec({ discardOutput: true });
+				return JSON.stringify([lines, r.stdout]);
+			})()`,
+			want: `[["a","b"],""]`,
+		},
+		{
+			name: "expectations",
+			script: `(async () => {
+				const lines = [];
+				const r = await new exec.Cmd("sh").args(["-c", "echo ok"])
+					.onLine((line) => lines.push(line)).exec({ expect: { stdoutMatches: /ok/ } });
+				return JSON.stringify([lines, r.stdout]);
+			})()`,
+			want: `[["ok"],"ok\n"]`,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			vu := newTestVU(t, nil)
+			vu.moveToVUContext(lib.Options{})
+
+			if got := vu.mustRun(tt.script).String(); got != tt.want {
+				t.Errorf("the script returned %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,33 @@
+package exec
+
+import (
+	"os"
+	"syscall"
+)
+
+// oomKillCount is a snapshot of a counter of the processes killed by the OOM killer.
+type oomKillCount struct {
+	value int64
+	valid bool
+}
+
+// wasOOMKilled returns true if the process whose state is provided was killed
+// by the OOM killer, since the OOM kill counter value at its start was read.
+//
+// A process is considered as killed by the OOM killer if it was killed by
+// SIGKILL and the counter increased while it was running. While this can't
+// tell apart an OOM kill from a SIGKILL sent concurrently with an unrelated
+// OOM kill, it is much less ambiguous than an exit code of 137.
+func wasOOMKilled(state *os.ProcessState, before oomKillCount) bool {
+	if state == nil || !before.valid {
+		return false
+	}
+
+	ws, ok := state.Sys().(syscall.WaitStatus)
+	if !ok || !ws.Signaled() || ws.Signal() != syscall.SIGKILL {
+		return false
+	}
+
+	after := readOOMKillCount()
+	return after.valid && after.value > before.value
+}
@@ -0,0 +1,76 @@
+//go:build linux
+
+package exec
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// readOOMKillCount reads the number of processes killed by the OOM killer in the
+// cgroup of the k6 process, which spawned commands are part of, falling back to the
+// system-wide counter when cgroup v2 memory events are unavailable.
+func readOOMKillCount() oomKillCount {
+	if path, ok := cgroupMemoryEventsPath(); ok {
+		if count := readCounter(path, "oom_kill"); count.valid {
+			return count
+		}
+	}
+
+	return readCounter("/proc/vmstat", "oom_kill")
+}
+
+// cgroupMemoryEventsPath returns the path of the memory.events file of
+// the cgroup v2 the k6 process belongs to.
+func cgroupMemoryEventsPath() (string, bool) {
+	b, err := os.ReadFile("/proc/self/cgroup")
+	if err != nil {
+		return "", false
+	}
+
+	for _, line := range strings.Split(string(b), "\n") {
+		if cgroup := strings.TrimPrefix(line, "0::"); cgroup != line {
+			return filepath.Join("/sys/fs/cgroup", cgroup, "memory.events"), true
+		}
+	}
+
+	return "", false
+}
+
+// readCounter reads the value of the named counter from a file holding
+// one "name value" pair per line.
+func readCounter(path, name string) oomKillCount {
+	b, err := os.ReadFile(path) //nolint:gosec
+	if err != nil {
+		return oomKillCount{}
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 || fields[0] != name {
+			continue
+		}
+
+		value, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return oomKillCount{}
+		}
+
+		return oomKillCount{value: value, valid: true}
+	}
+
+	return oomKillCount{}
+}
+
+// adjustOOMScore sets the OOM score adjustment of the process with the provided pid.
+// As it is applied once the process was started, processes it started right away
+// might not be affected.
+func adjustOOMScore(pid, adj int) error {
+	path := filepath.Join("/proc", strconv.Itoa(pid), "oom_score_adj")
+	return os.WriteFile(path, []byte(strconv.Itoa(adj)), 0o644) //nolint:gosec
+}
@@ -0,0 +1,14 @@
+//go:build !linux
+
+package exec
+
+// readOOMKillCount returns an invalid count on platforms other than
+// Linux, as OOM kills can't be detected on them.
+func readOOMKillCount() oomKillCount {
+	return oomKillCount{}
+}
+
+// adjustOOMScore is a no-op on platforms other than Linux, which lack an OOM killer.
+func adjustOOMScore(_, _ int) error {
+	return nil
+}
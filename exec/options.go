@@ -0,0 +1,421 @@
+package exec
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/dop251/goja"
+	"go.k6.io/k6/js/common"
+	"go.k6.io/k6/lib/types"
+)
+
+// defaultHeartbeatInterval is the default interval at which how long
+// running commands have been running for is emitted.
+const defaultHeartbeatInterval = time.Second
+
+// defaultMaxPendingLines is the default maximum amount of lines waiting
+// for the OnLine callback to be called, before the output stops being read.
+const defaultMaxPendingLines = 1024
+
+// execOptions holds the options a command can be executed with.
+type execOptions struct {
+	// compressOutput makes the captured output be stored gzip
+	// compressed, and decompressed only when accessed.
+	compressOutput bool
+
+	// keepOutput, if set, makes only the first and
+	// last lines of the output be captured.
+	keepOutput *keepOutput
+
+	// filter, if set, makes only the lines matching it be captured.
+	filter *regexp.Regexp
+
+	// until, if set, makes the command be stopped once a line of its output matches it.
+	until *regexp.Regexp
+
+	// maxPendingLines bounds the amount of lines waiting for the OnLine
+	// callback to be called, before the output stops being read.
+	maxPendingLines int
+
+	// normalizeNewlines makes CRLF line endings be converted
+	// to LF in the output.
+	normalizeNewlines bool
+
+	// outputEncoding is the encoding the captured output is exposed in, or
+	// arraybuffer if it is exposed as ArrayBuffers, as set by the output option.
+	outputEncoding string
+
+	// maxOutputBytes, if not zero, is the maximum amount of bytes of each output stream captured.
+	maxOutputBytes int64
+
+	// coreDumps is the core dump policy applied to the command.
+	coreDumps string
+
+	// transcriptDir, if set, is the directory a transcript of each execution is written to.
+	transcriptDir string
+
+	// scratchDir, if set, makes a scratch directory be created for each execution.
+	scratchDir *scratchDir
+
+	// ioPriority, if not zero, is the I/O priority the command is run with,
+	// combining its I/O scheduling class and level.
+	ioPriority int
+
+	// oomScoreAdj, if set, is the OOM score adjustment applied to the command.
+	oomScoreAdj *int
+
+	// waitDelay bounds the time waited for the command to exit, and for its
+	// output to be drained, once the VU context is done, before force-closing.
+	waitDelay time.Duration
+
+	// timeout, if not zero, is how long the command is allowed to
+	// run before being killed and the execution failing.
+	timeout time.Duration
+
+	// idleTimeout, if not zero, is how long the command is allowed to run
+	// without producing output before being killed and the execution failing.
+	idleTimeout time.Duration
+
+	// warnAfter, if not zero, is how long the command can run before
+	// a warning about it being slow is logged.
+	warnAfter time.Duration
+
+	// heartbeatInterval is the interval at which how long the command has been
+	// running for is emitted while it runs. Zero disables the heartbeat.
+	heartbeatInterval time.Duration
+
+	// gracefulStop is how long the command is given to exit once the VU
+	// context is done, before being killed.
+	gracefulStop time.Duration
+	// scenarioGracefulStop makes the gracefulStop of the current
+	// scenario be used instead of gracefulStop.
+	scenarioGracefulStop bool
+
+	// signal, if set, is the AbortSignal cancelling the execution once aborted.
+	signal *abortSignal
+
+	// killTree makes the command be killed along with the processes it started.
+	killTree bool
+
+	// killSequence, if not empty, is the sequence of signals the command is sent,
+	// rather than being killed, once it timed out or the VU context is done.
+	killSequence []killStep
+
+	// suspendOnPause makes the command be suspended while the test is paused.
+	suspendOnPause bool
+
+	// throwOnError makes the execution fail if the command exits with
+	// a non-zero exit code.
+	throwOnError bool
+
+	// outcomes maps exit codes to named outcomes.
+	outcomes *outcomeMapping
+
+	// lazyOutput makes the captured output be materialized into
+	// JS strings only when accessed.
+	lazyOutput bool
+
+	// extraFiles are additional files passed to the command,
+	// as file descriptor 3 and onwards.
+	extraFiles []extraFile
+
+	// controlChannel enables creating a control channel for
+	// communicating with a spawned process.
+	controlChannel bool
+
+	// metricsChannel enables passing the command a file descriptor it
+	// can write statsd-style metric lines to.
+	metricsChannel bool
+
+	// network is the network mode the command is run with.
+	network string
+
+	// readOnlyCwd makes the working directory be read-only for the command.
+	readOnlyCwd bool
+
+	// dropCapabilities and keepCapabilities are the numbers of the Linux
+	// capabilities, respectively dropped and kept, for the command.
+	dropCapabilities []int
+	keepCapabilities []int
+
+	// isolation, if set, makes the command be run in a sandbox.
+	isolation *isolation
+
+	// systemd, if set, makes the command be run as a transient systemd scope unit.
+	systemd *systemdUnit
+
+	// pipeSize, if not zero, is the capacity, in bytes, of the pipes the command's
+	// output streams are written to.
+	pipeSize int
+
+	// readBufferSize is the size, in bytes, of the buffers the command's output streams are read with.
+	readBufferSize int
+
+	// discardOutput makes the output not be captured, only consumed.
+	discardOutput bool
+
+	// expect, if set, holds the expectations the execution is checked against.
+	expect *expectations
+
+	// stdin, if set, is the source of the chunks fed to the command's standard input.
+	stdin *stdinSource
+
+	// onStdout and onStderr, if set, are called with each line written to the standard output
+	// and the standard error respectively, taking precedence over the command's OnLine callback.
+	onStdout goja.Callable
+	onStderr goja.Callable
+
+	// pty, if set, is the size of the pseudo-terminal the command is run in.
+	pty *terminalSize
+
+	// locale, if set, is the locale the command is executed with, overriding the configured one.
+	locale string
+
+	// debug makes how the command is executed be logged at debug level.
+	debug bool
+}
+
+// parseExecOptions parses the options object optionally passed to Exec.
+func parseExecOptions(rt *goja.Runtime, v goja.Value) (*execOptions, error) {
+	opts := &execOptions{
+		maxPendingLines:   defaultMaxPendingLines,
+		heartbeatInterval: defaultHeartbeatInterval,
+		readBufferSize:    defaultReadBufferSize,
+		killTree:          true,
+	}
+	if common.IsNullish(v) {
+		return opts, nil
+	}
+
+	obj := v.ToObject(rt)
+	for _, key := range obj.Keys() {
+		handled, err := opts.parseOption(rt, key, obj.Get(key))
+		if err != nil {
+			return nil, err
+		}
+		if !handled {
+			return nil, fmt.Errorf("unknown exec option %q", key)
+		}
+	}
+
+	return opts, nil
+}
+
+// parseOption parses the named option into opts, and returns false if there is no such option.
+func (opts *execOptions) parseOption(rt *goja.Runtime, key string, value goja.Value) (bool, error) {
+	for _, parse := range []func(*goja.Runtime, string, goja.Value) (bool, error){
+		opts.parseCaptureOption,
+		opts.parseStreamOption,
+		opts.parseTerminationOption,
+		opts.parseProcessOption,
+	} {
+		if handled, err := parse(rt, key, value); handled || err != nil {
+			return handled, err
+		}
+	}
+
+	return false, nil
+}
+
+// parseCaptureOption parses the options of how the output is captured.
+func (opts *execOptions) parseCaptureOption(rt *goja.Runtime, key string, value goja.Value) (bool, error) {
+	var err error
+
+	switch key {
+	case "compressOutput":
+		opts.compressOutput = value.ToBoolean()
+	case "keepOutput":
+		opts.keepOutput, err = parseKeepOutput(rt, value)
+	case "filter":
+		opts.filter, err = toRegexp(value)
+	case "normalizeNewlines":
+		opts.normalizeNewlines = value.ToBoolean()
+	case "outputEncoding":
+		opts.outputEncoding = value.String()
+		if !contains(outputEncodings, opts.outputEncoding) {
+			err = fmt.Errorf("unsupported output encoding %q; expected one of %s",
+				opts.outputEncoding, strings.Join(outputEncodings, ", "))
+		}
+	case "output":
+		opts.outputEncoding = value.String()
+		if forms := append([]string{outputArrayBuffer}, outputEncodings...); !contains(forms, opts.outputEncoding) {
+			err = fmt.Errorf("unsupported output %q; expected one of %s",
+				opts.outputEncoding, strings.Join(forms, ", "))
+		}
+	case "maxOutputBytes":
+		opts.maxOutputBytes = value.ToInteger()
+		if opts.maxOutputBytes <= 0 {
+			err = fmt.Errorf("invalid maxOutputBytes %d; expected a positive number of bytes", opts.maxOutputBytes)
+		}
+	case "lazyOutput":
+		opts.lazyOutput = value.ToBoolean()
+	case "discardOutput":
+		opts.discardOutput = value.ToBoolean()
+	case "expect":
+		if !common.IsNullish(value) {
+			opts.expect, err = parseExpectations(rt, value)
+		}
+	default:
+		return false, nil
+	}
+
+	return true, err
+}
+
+// parseStreamOption parses the options of how the standard streams of the command are read and written.
+func (opts *execOptions) parseStreamOption(rt *goja.Runtime, key string, value goja.Value) (bool, error) {
+	var err error
+
+	switch key {
+	case "until":
+		opts.until, err = toRegexp(value)
+	case "maxPendingLines":
+		opts.maxPendingLines = int(value.ToInteger())
+		if opts.maxPendingLines <= 0 {
+			err = fmt.Errorf("invalid maxPendingLines %d; expected a positive number", opts.maxPendingLines)
+		}
+	case "onStdout", "onStderr":
+		if common.IsNullish(value) {
+			break
+		}
+		callback, ok := goja.AssertFunction(value)
+		if !ok {
+			return true, fmt.Errorf("invalid %s; expected a function", key)
+		}
+		if key == "onStdout" {
+			opts.onStdout = callback
+		} else {
+			opts.onStderr = callback
+		}
+	case "stdin":
+		if !common.IsNullish(value) {
+			opts.stdin, err = parseStdin(rt, value)
+		}
+	case "pty":
+		opts.pty, err = parseTerminalSize(rt, value)
+	case "pipeSize":
+		opts.pipeSize = int(value.ToInteger())
+		if opts.pipeSize <= 0 {
+			err = fmt.Errorf("invalid pipeSize %d; expected a positive number of bytes", opts.pipeSize)
+		}
+	case "readBufferSize":
+		opts.readBufferSize = int(value.ToInteger())
+		if opts.readBufferSize <= 0 {
+			err = fmt.Errorf("invalid readBufferSize %d; expected a positive number of bytes", opts.readBufferSize)
+		}
+	case "extraFiles":
+		opts.extraFiles, err = parseExtraFiles(rt, value)
+	case "controlChannel":
+		opts.controlChannel = value.ToBoolean()
+	case "metricsChannel":
+		opts.metricsChannel = value.ToBoolean()
+	default:
+		return false, nil
+	}
+
+	return true, err
+}
+
+// parseTerminationOption parses the options of how long the command runs, and how it is terminated.
+func (opts *execOptions) parseTerminationOption(rt *goja.Runtime, key string, value goja.Value) (bool, error) {
+	var err error
+
+	if duration, ok := map[string]*time.Duration{
+		"waitDelay":         &opts.waitDelay,
+		"timeout":           &opts.timeout,
+		"idleTimeout":       &opts.idleTimeout,
+		"warnAfter":         &opts.warnAfter,
+		"heartbeatInterval": &opts.heartbeatInterval,
+	}[key]; ok {
+		if *duration, err = types.GetDurationValue(value.Export()); err != nil {
+			return true, fmt.Errorf("invalid %s: %w", key, err)
+		}
+		return true, nil
+	}
+
+	switch key {
+	case "gracefulStop":
+		if b, ok := value.Export().(bool); ok {
+			opts.scenarioGracefulStop = b
+			break
+		}
+		if opts.gracefulStop, err = types.GetDurationValue(value.Export()); err != nil {
+			return true, fmt.Errorf("invalid gracefulStop: %w", err)
+		}
+	case "signal":
+		opts.signal, err = parseAbortSignal(rt, value)
+	case "killTree":
+		opts.killTree = value.ToBoolean()
+	case "killSequence":
+		opts.killSequence, err = parseKillSequence(rt, value)
+	case "suspendOnPause":
+		opts.suspendOnPause = value.ToBoolean()
+	case "throwOnError":
+		opts.throwOnError = value.ToBoolean()
+	case "outcomes":
+		opts.outcomes, err = parseOutcomeMapping(rt, value)
+	default:
+		return false, nil
+	}
+
+	return true, err
+}
+
+// parseProcessOption parses the options of the environment the process of the command runs in.
+func (opts *execOptions) parseProcessOption(rt *goja.Runtime, key string, value goja.Value) (bool, error) {
+	var err error
+
+	switch key {
+	case "coreDumps":
+		opts.coreDumps = value.String()
+		err = validateCoreDumpPolicy(opts.coreDumps)
+	case "transcript":
+		opts.transcriptDir = value.String()
+	case "scratchDir":
+		opts.scratchDir, err = parseScratchDir(rt, value)
+	case "ioPriority":
+		opts.ioPriority, err = parseIOPriority(value.String())
+	case "oomScoreAdj":
+		adj := int(value.ToInteger())
+		if adj < -1000 || adj > 1000 {
+			return true, fmt.Errorf("invalid oomScoreAdj %d; expected a value between -1000 and 1000", adj)
+		}
+		opts.oomScoreAdj = &adj
+	case "network":
+		opts.network = value.String()
+		err = validateNetworkMode(opts.network)
+	case "readOnlyCwd":
+		opts.readOnlyCwd = value.ToBoolean()
+	case "dropCapabilities":
+		opts.dropCapabilities, err = parseCapabilities(rt, key, value)
+	case "keepCapabilities":
+		opts.keepCapabilities, err = parseCapabilities(rt, key, value)
+	case "isolation":
+		opts.isolation, err = parseIsolation(rt, value)
+	case "systemd":
+		opts.systemd, err = parseSystemdUnit(rt, value)
+	case "locale":
+		if !common.IsNullish(value) {
+			opts.locale, err = parseLocale(value)
+		}
+	case "debug":
+		opts.debug = value.ToBoolean()
+	default:
+		return false, nil
+	}
+
+	return true, err
+}
+
+// contains returns true if values contains value.
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,84 @@
+package exec
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+
+	"github.com/dop251/goja"
+)
+
+// outcomeRangePattern matches exit code ranges, such as 64-78.
+var outcomeRangePattern = regexp.MustCompile(`^(-?\d+)-(-?\d+)$`) //nolint:gochecknoglobals
+
+// outcomeRule maps the exit codes between low and high, inclusive, to a named outcome.
+type outcomeRule struct {
+	low, high int
+	name      string
+}
+
+// outcomeMapping maps exit codes to named outcomes.
+type outcomeMapping struct {
+	// codes maps individual exit codes, and takes precedence over ranges.
+	codes map[int]string
+	// ranges maps ranges of exit codes, in the order they were declared.
+	ranges []outcomeRule
+	// fallback is the outcome of exit codes matching no other rule.
+	fallback string
+}
+
+// parseOutcomeMapping parses the outcomes option, an object whose keys are either
+// an exit code, a range of exit codes such as "64-78", or "*" for any other exit code,
+// and whose values are the name of the corresponding outcome.
+func parseOutcomeMapping(rt *goja.Runtime, v goja.Value) (*outcomeMapping, error) {
+	obj := v.ToObject(rt)
+	mapping := &outcomeMapping{codes: make(map[int]string)}
+
+	for _, key := range obj.Keys() {
+		name := obj.Get(key).String()
+
+		if key == "*" {
+			mapping.fallback = name
+			continue
+		}
+
+		if code, err := strconv.Atoi(key); err == nil {
+			mapping.codes[code] = name
+			continue
+		}
+
+		m := outcomeRangePattern.FindStringSubmatch(key)
+		if m == nil {
+			return nil, fmt.Errorf("invalid outcome exit code %q; expected an exit code, a range such as 64-78, or *", key)
+		}
+
+		low, _ := strconv.Atoi(m[1])
+		high, _ := strconv.Atoi(m[2])
+		if low > high {
+			return nil, fmt.Errorf("invalid outcome exit code range %q", key)
+		}
+
+		mapping.ranges = append(mapping.ranges, outcomeRule{low: low, high: high, name: name})
+	}
+
+	return mapping, nil
+}
+
+// resolve returns the name of the outcome exitCode maps to, or an empty string if none.
+func (m *outcomeMapping) resolve(exitCode int) string {
+	if m == nil {
+		return ""
+	}
+
+	if name, ok := m.codes[exitCode]; ok {
+		return name
+	}
+
+	for _, rule := range m.ranges {
+		if exitCode >= rule.low && exitCode <= rule.high {
+			return rule.name
+		}
+	}
+
+	return m.fallback
+}
@@ -0,0 +1,524 @@
+package exec
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"hash"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"go.k6.io/k6/lib"
+)
+
+// OutputFileOptions configures how a command's output is redirected to a file.
+type OutputFileOptions struct {
+	// Append makes the output be appended to the file instead of
+	// truncating it on each execution.
+	Append bool `js:"append"`
+}
+
+// outputFile describes a file a command's output stream should be redirected to.
+type outputFile struct {
+	path   string
+	append bool
+}
+
+// open expands the placeholders in the file path using the provided
+// VU state, and opens the resulting file for writing.
+//
+// The supported placeholders are:
+//   - {vu}: the ID of the VU executing the command.
+func (of *outputFile) open(state *lib.State) (*os.File, error) {
+	path := of.path
+	if state != nil {
+		path = strings.ReplaceAll(path, "{vu}", strconv.FormatUint(state.VUID, 10))
+	}
+
+	flags := os.O_CREATE | os.O_WRONLY
+	if of.append {
+		flags |= os.O_APPEND
+	} else {
+		flags |= os.O_TRUNC
+	}
+
+	return os.OpenFile(path, flags, 0o644) //nolint:gosec
+}
+
+// capturedOutput holds the output captured from one of a command's streams.
+type capturedOutput struct {
+	data       []byte
+	compressed bool
+
+	// truncated is true if the stream produced more output than the maximum amount captured.
+	truncated bool
+
+	// fileBytes is the amount of bytes written to the file the stream is redirected to, if any.
+	fileBytes int64
+}
+
+// bytes returns the captured output, decompressing it if needed.
+func (co capturedOutput) bytes() ([]byte, error) {
+	if !co.compressed {
+		return co.data, nil
+	}
+
+	r, err := gzip.NewReader(bytes.NewReader(co.data))
+	if err != nil {
+		return nil, err
+	}
+
+	return io.ReadAll(r)
+}
+
+// streamCapture describes how one of a command's output streams is consumed.
+type streamCapture struct {
+	// file is the file the stream is redirected to, if any.
+	file *os.File
+
+	// hash is fed all the data written to the stream, if not nil.
+	hash hash.Hash
+
+	// compress makes the captured output be stored gzip compressed.
+	compress bool
+
+	// keep, if not nil, makes only the first and last lines of the
+	// output be captured. The retained output is not compressed.
+	keep *keepOutput
+
+	// filter, if not nil, makes only the lines matching it be
+	// captured, or written to the file the stream is redirected to.
+	filter *regexp.Regexp
+
+	// normalizeNewlines makes CRLF line endings be converted to LF.
+	normalizeNewlines bool
+
+	// onLine, if not nil, is called with each line written to the stream,
+	// without its trailing newline, whether it matches filter or not.
+	onLine func(line string)
+
+	// readBufferSize is the size of the buffer the stream is read with.
+	readBufferSize int
+
+	// maxBytes, if not zero, is the maximum amount of bytes captured in memory. The
+	// output past it is consumed, counted and hashed, but not captured.
+	maxBytes int64
+
+	// discard makes the stream not be captured: it is only consumed, counted and hashed,
+	// and the lines matching filter, if set, are counted, so that memory usage stays flat
+	// however much output the command produces.
+	discard bool
+}
+
+// drain consumes r until EOF. If the stream is redirected to a file, the data is
+// written straight to it, otherwise, unless it is discarded, it is captured in
+// memory and returned.
+//
+// The number of bytes consumed is returned in all cases. The lines written to the file,
+// or captured, that is the ones matching filter if it is set, are counted by lines.
+func (sc streamCapture) drain(r io.Reader, lines *lineCounter) (capturedOutput, int64, error) {
+	if sc.hash != nil {
+		r = io.TeeReader(r, sc.hash)
+	}
+
+	sink := sc.newSink()
+	dst := io.MultiWriter(sink.dst, lines)
+
+	var lf *lineFilterWriter
+	if sc.filter != nil {
+		lf = &lineFilterWriter{dst: dst, filter: sc.filter}
+		dst = lf
+	}
+
+	onLine := sc.onLine
+	var cw *crlfWriter
+	if sc.normalizeNewlines {
+		cw = &crlfWriter{dst: dst}
+		dst = cw
+
+		if onLine != nil {
+			onLine = func(line string) { sc.onLine(strings.TrimSuffix(line, "\r")) }
+		}
+	}
+
+	var (
+		n   int64
+		err error
+	)
+	bufSize := sc.readBufferSize
+	if bufSize <= 0 {
+		bufSize = defaultReadBufferSize
+	}
+	if onLine != nil {
+		n, err = copyLines(dst, bufio.NewReaderSize(r, bufSize), onLine)
+	} else {
+		n, err = io.CopyBuffer(dst, r, make([]byte, bufSize))
+	}
+
+	if err == nil && cw != nil {
+		err = cw.flush()
+	}
+
+	if err == nil && lf != nil {
+		err = lf.flush()
+	}
+
+	if err != nil {
+		return capturedOutput{}, n, err
+	}
+
+	output, err := sink.output()
+	return output, n, err
+}
+
+// captureSink is where drain writes a stream to: the file it is redirected to, or the memory
+// it is captured in, unless it is discarded, through the writers keeping track of what was written.
+type captureSink struct {
+	dst io.Writer
+
+	buf *bytes.Buffer
+	zw  *gzip.Writer
+	ht  *headTailWriter
+	fw  *countingWriter
+	lw  *limitWriter
+
+	// captures is set if the stream is captured in buf as is.
+	captures bool
+}
+
+// newSink returns the sink the stream is written to.
+func (sc streamCapture) newSink() *captureSink {
+	s := &captureSink{buf: &bytes.Buffer{}}
+
+	switch {
+	case sc.file != nil:
+		s.fw = &countingWriter{dst: sc.file}
+		s.dst = s.fw
+	case sc.discard:
+		s.dst = io.Discard
+	case sc.keep != nil:
+		s.ht = newHeadTailWriter(*sc.keep)
+		s.dst = s.ht
+	case sc.compress:
+		s.zw = gzip.NewWriter(s.buf)
+		s.dst = s.zw
+	default:
+		s.dst, s.captures = s.buf, true
+	}
+
+	if sc.maxBytes > 0 && sc.file == nil && s.dst != io.Discard {
+		s.lw = &limitWriter{dst: s.dst, remaining: sc.maxBytes}
+		s.dst = s.lw
+	}
+
+	return s
+}
+
+// output returns the output the sink captured, once the stream was written to it.
+func (s *captureSink) output() (capturedOutput, error) {
+	truncated := s.lw != nil && s.lw.truncated
+
+	switch {
+	case s.ht != nil:
+		return capturedOutput{data: s.ht.bytes(), truncated: truncated}, nil
+	case s.zw != nil:
+		if err := s.zw.Close(); err != nil {
+			return capturedOutput{}, err
+		}
+
+		return capturedOutput{data: s.buf.Bytes(), compressed: true, truncated: truncated}, nil
+	case s.captures:
+		return capturedOutput{data: s.buf.Bytes(), truncated: truncated}, nil
+	case s.fw != nil:
+		return capturedOutput{fileBytes: s.fw.n}, nil
+	default:
+		return capturedOutput{}, nil
+	}
+}
+
+// countingWriter is an io.Writer counting the bytes written to dst.
+type countingWriter struct {
+	dst io.Writer
+	n   int64
+}
+
+// Write implements the io.Writer interface.
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.dst.Write(p)
+	w.n += int64(n)
+
+	return n, err
+}
+
+// limitWriter is an io.Writer writing at most remaining bytes to dst, and
+// discarding the rest, so that the output keeps being consumed.
+type limitWriter struct {
+	dst       io.Writer
+	remaining int64
+
+	// truncated is set once some of the data written was discarded.
+	truncated bool
+}
+
+// Write implements the io.Writer interface.
+func (w *limitWriter) Write(p []byte) (int, error) {
+	n := len(p)
+	if int64(n) > w.remaining {
+		p, w.truncated = p[:w.remaining], true
+	}
+
+	if len(p) > 0 {
+		if _, err := w.dst.Write(p); err != nil {
+			return 0, err
+		}
+		w.remaining -= int64(len(p))
+	}
+
+	return n, nil
+}
+
+// drainResult is the outcome of draining one of a command's output streams.
+type drainResult struct {
+	output capturedOutput
+	n      int64
+	lines  int64
+	err    error
+}
+
+// totals returns the amount of bytes and lines drained from the stream.
+func (dr drainResult) totals() outputTotals {
+	return outputTotals{bytes: dr.n, lines: dr.lines}
+}
+
+// drainAsync drains r in the background, and returns a channel
+// receiving the outcome once r reached EOF.
+func (sc streamCapture) drainAsync(r io.Reader) <-chan drainResult {
+	done := make(chan drainResult, 1)
+
+	go func() {
+		var lines lineCounter
+		output, n, err := sc.drain(r, &lines)
+		done <- drainResult{output: output, n: n, lines: lines.count(), err: err}
+	}()
+
+	return done
+}
+
+// outputTotals holds the amount of bytes and lines one of a command's output streams produced.
+type outputTotals struct {
+	bytes int64
+	lines int64
+}
+
+// lineCounter is an io.Writer counting the lines written to it, a last line
+// lacking a trailing newline included. It is safe for concurrent use.
+type lineCounter struct {
+	newlines int64
+
+	// partial is set to 1 when the last byte written isn't a newline.
+	partial int32
+}
+
+// Write implements the io.Writer interface.
+func (lc *lineCounter) Write(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+
+	atomic.AddInt64(&lc.newlines, int64(bytes.Count(p, []byte{'\n'})))
+	if p[len(p)-1] == '\n' {
+		atomic.StoreInt32(&lc.partial, 0)
+	} else {
+		atomic.StoreInt32(&lc.partial, 1)
+	}
+
+	return len(p), nil
+}
+
+// count returns the amount of lines written so far.
+func (lc *lineCounter) count() int64 {
+	return atomic.LoadInt64(&lc.newlines) + int64(atomic.LoadInt32(&lc.partial))
+}
+
+// countLines returns the amount of lines of data.
+func countLines(data []byte) int64 {
+	var lc lineCounter
+	_, _ = lc.Write(data)
+
+	return lc.count()
+}
+
+// copyLines copies br to dst line by line, calling fn with each line
+// without its trailing newline. It returns the number of bytes copied.
+func copyLines(dst io.Writer, br *bufio.Reader, fn func(line string)) (int64, error) {
+	var n int64
+
+	for {
+		line, err := br.ReadString('\n')
+		if len(line) > 0 {
+			written, werr := io.WriteString(dst, line)
+			n += int64(written)
+			if werr != nil {
+				return n, werr
+			}
+
+			fn(strings.TrimSuffix(line, "\n"))
+		}
+
+		if errors.Is(err, io.EOF) {
+			return n, nil
+		}
+
+		if err != nil {
+			return n, err
+		}
+	}
+}
+
+// lineFilterWriter is an io.Writer only writing the lines matching a filter to dst.
+type lineFilterWriter struct {
+	dst    io.Writer
+	filter *regexp.Regexp
+
+	// partial holds the last, incomplete, line written.
+	partial []byte
+}
+
+// Write implements the io.Writer interface.
+func (w *lineFilterWriter) Write(p []byte) (int, error) {
+	n := len(p)
+
+	for len(p) > 0 {
+		i := bytes.IndexByte(p, '\n')
+		if i < 0 {
+			w.partial = append(w.partial, p...)
+			break
+		}
+
+		line := p[:i+1]
+		if len(w.partial) > 0 {
+			line = append(w.partial, line...) //nolint:gocritic
+			w.partial = nil
+		}
+
+		if err := w.writeIfMatching(line); err != nil {
+			return n - len(p), err
+		}
+
+		p = p[i+1:]
+	}
+
+	return n, nil
+}
+
+// flush writes the last, incomplete, line if it matches the filter.
+func (w *lineFilterWriter) flush() error {
+	if len(w.partial) == 0 {
+		return nil
+	}
+
+	line := w.partial
+	w.partial = nil
+
+	return w.writeIfMatching(line)
+}
+
+// writeIfMatching writes line to dst if it matches the filter.
+func (w *lineFilterWriter) writeIfMatching(line []byte) error {
+	if !w.filter.Match(bytes.TrimSuffix(line, []byte("\n"))) {
+		return nil
+	}
+
+	_, err := w.dst.Write(line)
+	return err
+}
+
+// crlfWriter is an io.Writer converting CRLF line endings to LF before writing to dst.
+type crlfWriter struct {
+	dst io.Writer
+
+	// pendingCR is set when the last byte written was a CR, which
+	// is only written once it is known not to be followed by a LF.
+	pendingCR bool
+
+	buf []byte
+}
+
+// Write implements the io.Writer interface.
+func (w *crlfWriter) Write(p []byte) (int, error) {
+	out := w.buf[:0]
+
+	if w.pendingCR && len(p) > 0 {
+		if p[0] != '\n' {
+			out = append(out, '\r')
+		}
+		w.pendingCR = false
+	}
+
+	for i, b := range p {
+		if b == '\r' {
+			if i+1 == len(p) {
+				w.pendingCR = true
+				continue
+			}
+
+			if p[i+1] == '\n' {
+				continue
+			}
+		}
+
+		out = append(out, b)
+	}
+
+	w.buf = out
+	if _, err := w.dst.Write(out); err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
+
+// flush writes the last CR written, if it is still pending.
+func (w *crlfWriter) flush() error {
+	if !w.pendingCR {
+		return nil
+	}
+
+	w.pendingCR = false
+	_, err := w.dst.Write([]byte{'\r'})
+	return err
+}
+
+// openOutputFiles opens the files the command's output streams are redirected to, if any.
+func (c *Command) openOutputFiles(state *lib.State) (stdout *os.File, stderr *os.File, err error) {
+	if c.stdoutFile != nil {
+		stdout, err = c.stdoutFile.open(state)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if c.stderrFile != nil {
+		stderr, err = c.stderrFile.open(state)
+		if err != nil {
+			closeFiles(stdout)
+			return nil, nil, err
+		}
+	}
+
+	return stdout, stderr, nil
+}
+
+// closeFiles closes all the non-nil files provided.
+func closeFiles(files ...*os.File) {
+	for _, f := range files {
+		if f != nil {
+			_ = f.Close()
+		}
+	}
+}
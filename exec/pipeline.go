@@ -0,0 +1,204 @@
+package exec
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"sync"
+
+	"github.com/dop251/goja"
+	"go.k6.io/k6/js/common"
+)
+
+// pipeStage wires a stage of a pipeline to the adjacent ones, at the OS level,
+// so that the output flowing through the pipeline doesn't go through the module.
+type pipeStage struct {
+	// stdin, if set, is the read end of the pipe the previous stage writes its output to.
+	stdin *os.File
+
+	// stdout, if set, is the write end of the pipe the next stage reads its input from.
+	stdout *os.File
+
+	// execution is the execution of the stage, once it was started.
+	execution *execution
+}
+
+// wire makes cmd read its standard input from the previous stage, and
+// write its standard output to the next one, if any.
+func (s *pipeStage) wire(cmd *exec.Cmd) {
+	if s.stdin != nil {
+		cmd.Stdin = s.stdin
+	}
+	if s.stdout != nil {
+		cmd.Stdout = s.stdout
+	}
+}
+
+// Pipe returns a copy of the command whose standard output is piped to the standard input
+// of next, like the shell's | operator. The stages of the pipeline are connected through
+// OS pipes, so that their output doesn't go through the script.
+func (c Command) Pipe(next Command) Command {
+	if len(c.chain) > 0 || len(next.chain) > 0 {
+		common.Throw(c.vu.Runtime(), errors.New("chained commands can't be piped; pipe the commands before chaining them"))
+	}
+
+	stage := next
+	stage.pipeline = nil
+
+	// The full slice expression makes appending copy the stages,
+	// leaving the ones of the original command untouched.
+	c.pipeline = append(c.pipeline[:len(c.pipeline):len(c.pipeline)], stage)
+	c.pipeline = append(c.pipeline, next.pipeline...)
+	return c
+}
+
+// validatePipeline returns an error if the output of the stages of the pipeline but the last,
+// which is piped to the next stage, is processed, or if the input of the stages but the first,
+// which is read from the previous stage, is fed from elsewhere.
+func validatePipeline(stages []Command) error {
+	for i, stage := range stages {
+		if i < len(stages)-1 && (stage.stdoutFile != nil || stage.onLine != nil || len(stage.emitted) > 0 ||
+			stage.powerShell != nil || stage.azure != nil) {
+			return fmt.Errorf("the standard output of %q is piped to the next stage of the pipeline; "+
+				"it can't be redirected to a file, passed to an onLine callback, nor parsed", stage.Name)
+		}
+
+		if i > 0 && (stage.stdin != nil || (stage.sudo != nil && stage.sudo.PasswordEnv != "")) {
+			return fmt.Errorf("the standard input of %q is read from the previous stage of the pipeline; "+
+				"it can't be fed otherwise", stage.Name)
+		}
+	}
+
+	return nil
+}
+
+// execPipeline starts the stages of the pipeline from left to right, and settles the promise with the
+// result of the last one once all of them completed, holding the results of all of them in its stages
+// property. The options apply to each stage, but for stdin, which feeds the first stage, and for expect,
+// onStdout and throwOnError, which apply to the last one. As in shells, the pipeline fails if its last
+// stage does.
+func (c *Command) execPipeline(opts *execOptions, resolve, reject func(interface{})) {
+	first := *c
+	first.pipeline = nil
+	stages := append([]Command{first}, c.pipeline...)
+
+	if err := validatePipeline(stages); err != nil {
+		reject(c.rejection(err))
+		return
+	}
+
+	release, err := c.acquireSlot()
+	if err != nil {
+		reject(c.rejection(err))
+		return
+	}
+
+	completes := make([]func() (*CommandResult, error), 0, len(stages))
+	var stdin *os.File
+	for i := range stages {
+		stage := &stages[i]
+
+		stageOpts := *opts
+		stageOpts.throwOnError = false
+		if i > 0 {
+			stageOpts.stdin = nil
+		}
+
+		var next, stdout *os.File
+		if i < len(stages)-1 {
+			stageOpts.expect, stageOpts.onStdout = nil, nil
+
+			var err error
+			if next, stdout, err = os.Pipe(); err != nil {
+				closeFiles(stdin)
+				release()
+				c.abortPipeline(stages[:i], completes, err, reject)
+				return
+			}
+		}
+
+		stage.stage = &pipeStage{stdin: stdin, stdout: stdout}
+		complete, err := stage.run(&stageOpts)
+		// The started stage holds its ends of the pipes.
+		closeFiles(stdin, stdout)
+		stdin = next
+		if err != nil {
+			closeFiles(next)
+			release()
+			c.abortPipeline(stages[:i], completes, err, reject)
+			return
+		}
+
+		completes = append(completes, complete)
+	}
+
+	go func() {
+		results, err := completePipeline(completes)
+		release()
+		if err != nil {
+			reject(c.rejection(err))
+			return
+		}
+
+		final := *results[len(results)-1]
+		final.stages = results
+
+		if opts.throwOnError && final.ExitCode != 0 {
+			reject(c.rejection(final.execError(stages[len(stages)-1].Name)))
+			return
+		}
+
+		resolve(&final)
+	}()
+}
+
+// abortPipeline kills the stages of the pipeline which were started, as they might otherwise wait
+// forever for input or for their output to be read, and rejects the promise with err once they exited.
+func (c *Command) abortPipeline(started []Command, completes []func() (*CommandResult, error), err error, reject func(interface{})) {
+	for _, stage := range started {
+		if e := stage.stage.execution; e != nil {
+			_ = e.deliver("SIGKILL")
+		}
+	}
+
+	go func() {
+		_, _ = completePipeline(completes)
+		reject(c.rejection(err))
+	}()
+}
+
+// completePipeline waits for the stages of a pipeline to complete, concurrently, as each of them
+// drains its output while waiting, and returns their results, or the error the first one failed with.
+func completePipeline(completes []func() (*CommandResult, error)) ([]*CommandResult, error) {
+	results := make([]*CommandResult, len(completes))
+	errs := make([]error, len(completes))
+
+	var wg sync.WaitGroup
+	for i, complete := range completes {
+		wg.Add(1)
+		go func(i int, complete func() (*CommandResult, error)) {
+			defer wg.Done()
+			results[i], errs[i] = complete()
+		}(i, complete)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return results, nil
+}
+
+// stagesJSValue returns the results of the stages of a pipeline as a JS array.
+func (r *CommandResult) stagesJSValue(rt *goja.Runtime) goja.Value {
+	stages := make([]interface{}, len(r.stages))
+	for i, stage := range r.stages {
+		stages[i] = stage.toJSValue(rt)
+	}
+
+	return rt.NewArray(stages...)
+}
@@ -0,0 +1,116 @@
+package exec
+
+import (
+	"errors"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// defaultReadBufferSize is the size of the buffers output streams are read with by default.
+const defaultReadBufferSize = 32 * 1024
+
+// outputPipe is the pipe one of a command's output streams is written to.
+//
+// By default, it is an in-memory pipe, which os/exec copies the output to from the OS pipe it
+// creates, so that waiting for the command is bounded by its WaitDelay even if the OS pipe is
+// kept open by a child. When a pipe size is set, the command writes straight to an OS pipe of
+// that capacity, whose reads are bounded by the WaitDelay once the command exited instead.
+type outputPipe struct {
+	r io.Reader
+	w io.WriteCloser
+
+	// file is the read end of the OS pipe, if the command writes to one directly.
+	file *os.File
+}
+
+// newOutputPipe returns a new outputPipe, whose capacity is size bytes if it isn't zero.
+func newOutputPipe(size int) (*outputPipe, error) {
+	if size == 0 {
+		r, w := io.Pipe()
+		return &outputPipe{r: r, w: w}, nil
+	}
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := setPipeSize(w, size); err != nil {
+		closeFiles(r, w)
+		return nil, err
+	}
+
+	return &outputPipe{r: deadlineReader{r: r}, w: w, file: r}, nil
+}
+
+// started releases the write end of the OS pipe, which the started command holds, if any.
+func (p *outputPipe) started() {
+	if p.file != nil {
+		_ = p.w.Close()
+	}
+}
+
+// exited closes the in-memory pipe, as os/exec copied all the output to it once the command
+// exited, or gives the reads of the OS pipe waitDelay to complete, if it isn't zero.
+func (p *outputPipe) exited(waitDelay time.Duration) {
+	if p.file == nil {
+		_ = p.w.Close()
+		return
+	}
+
+	if waitDelay > 0 {
+		_ = p.file.SetReadDeadline(time.Now().Add(waitDelay))
+	}
+}
+
+// close closes both ends of the pipe.
+func (p *outputPipe) close() {
+	_ = p.w.Close()
+	closeFiles(p.file)
+}
+
+// deadlineReader is an io.Reader reading from an OS pipe, which reaches EOF
+// once the read deadline of the pipe is exceeded.
+type deadlineReader struct {
+	r *os.File
+}
+
+// Read implements the io.Reader interface.
+func (dr deadlineReader) Read(p []byte) (int, error) {
+	n, err := dr.r.Read(p)
+	if errors.Is(err, os.ErrDeadlineExceeded) {
+		err = io.EOF
+	}
+
+	return n, err
+}
+
+// newInputPipe returns the OS pipe a command's standard input is read from, rather than one
+// obtained through StdinPipe, which os/exec closes if the command fails to start, so that
+// starting it can be retried. The read end should be closed once the command was started, and
+// the write end, which can safely be closed several times, once the command exited.
+func newInputPipe() (*os.File, *inputPipeWriter, error) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return r, &inputPipeWriter{File: w}, nil
+}
+
+// inputPipeWriter is the write end of the pipe a command's standard input is read from.
+type inputPipeWriter struct {
+	*os.File
+
+	closeOnce sync.Once
+	closeErr  error
+}
+
+// Close closes the write end of the pipe, and returns the error closing it
+// the first time returned, if it already was.
+func (w *inputPipeWriter) Close() error {
+	w.closeOnce.Do(func() { w.closeErr = w.File.Close() })
+	return w.closeErr
+}
@@ -0,0 +1,32 @@
+//go:build linux
+
+package exec
+
+import (
+	"fmt"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// setPipeSize sets the capacity of the pipe f is an end of to size bytes. Unprivileged
+// processes can't exceed the limit set by /proc/sys/fs/pipe-max-size.
+func setPipeSize(f *os.File, size int) error {
+	conn, err := f.SyscallConn()
+	if err != nil {
+		return err
+	}
+
+	var serr error
+	if err := conn.Control(func(fd uintptr) {
+		_, serr = unix.FcntlInt(fd, unix.F_SETPIPE_SZ, size)
+	}); err != nil {
+		return err
+	}
+
+	if serr != nil {
+		return fmt.Errorf("unable to set the pipe size to %d bytes: %w", size, serr)
+	}
+
+	return nil
+}
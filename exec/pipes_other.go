@@ -0,0 +1,11 @@
+//go:build !linux
+
+package exec
+
+import "os"
+
+// setPipeSize is a no-op on platforms other than Linux, where
+// the capacity of pipes can't be changed.
+func setPipeSize(_ *os.File, _ int) error {
+	return nil
+}
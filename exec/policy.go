@@ -0,0 +1,394 @@
+package exec
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/dop251/goja"
+)
+
+// The environment variables the operator of k6 sets the execution policy with, which
+// scripts can't change, as opposed to the policy set through the configuration.
+const (
+	// policyEnvVar, set to strict, denies executing commands which aren't explicitly allowed.
+	policyEnvVar = "K6_EXEC_POLICY"
+
+	// allowedBinariesEnvVar and deniedBinariesEnvVar hold comma-separated lists
+	// of the executables commands are allowed, respectively denied, to run.
+	allowedBinariesEnvVar = "K6_EXEC_ALLOWED_BINARIES"
+	deniedBinariesEnvVar  = "K6_EXEC_DENIED_BINARIES"
+
+	// policyFileEnvVar is the path of a JSON file holding a policy, whose rules
+	// can also restrict the arguments executables are allowed to run with.
+	policyFileEnvVar = "K6_EXEC_POLICY_FILE"
+)
+
+// policyStrict is the value of K6_EXEC_POLICY denying executing commands which aren't explicitly allowed.
+const policyStrict = "strict"
+
+// policyAnyExecutable is the executable of the rules matching any executable.
+const policyAnyExecutable = "*"
+
+// execPolicy decides which commands are allowed to be executed, according to their
+// executable, and the arguments they're executed with.
+type execPolicy struct {
+	// strict denies executing the commands not matching any allow rule, even if there is none.
+	strict bool
+
+	allow []policyRule
+	deny  []policyRule
+}
+
+// policyRule matches the commands running an executable, and optionally whose arguments match a pattern.
+type policyRule struct {
+	// executable is either an absolute path, matching the executable at this path, or a name,
+	// matching the executables with this name wherever they are, or * matching any executable.
+	executable string
+
+	// args, if set, is expected to match the arguments of the command as a whole, once quoted
+	// as by a shell, and joined with spaces, so that an argument can't pose as several.
+	args *regexp.Regexp
+
+	// argv, if set, holds the patterns each argument of the command is expected to match, in
+	// order, the command being expected to have as many arguments as there are patterns.
+	argv []*regexp.Regexp
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+func (p *execPolicy) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Strict bool              `json:"strict"`
+		Allow  []json.RawMessage `json:"allow"`
+		Deny   []json.RawMessage `json:"deny"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return errors.New("invalid policy; expected an object holding allow and deny rules")
+	}
+
+	p.strict = raw.Strict
+	for _, rules := range []struct {
+		raw  []json.RawMessage
+		dest *[]policyRule
+	}{{raw.Allow, &p.allow}, {raw.Deny, &p.deny}} {
+		for _, r := range rules.raw {
+			rule, err := parsePolicyRule(r)
+			if err != nil {
+				return err
+			}
+			*rules.dest = append(*rules.dest, rule)
+		}
+	}
+
+	return nil
+}
+
+// parsePolicyRule parses a rule, either the executable it matches, or an object holding
+// it as executable, and the pattern the arguments are expected to match, or the patterns
+// each of them is expected to match, as args.
+func parsePolicyRule(data []byte) (policyRule, error) {
+	var rule struct {
+		Executable string          `json:"executable"`
+		Args       json.RawMessage `json:"args"`
+	}
+	if err := json.Unmarshal(data, &rule.Executable); err != nil {
+		if err := json.Unmarshal(data, &rule); err != nil {
+			return policyRule{}, errors.New("invalid policy rule; expected an executable, or an object holding one")
+		}
+	}
+
+	if rule.Executable == "" {
+		return policyRule{}, errors.New("invalid policy rule; expected an executable")
+	}
+
+	parsed := policyRule{executable: rule.Executable}
+	if parsed.executable != policyAnyExecutable && filepath.IsAbs(parsed.executable) {
+		parsed.executable = filepath.Clean(parsed.executable)
+	}
+
+	var pattern string
+	var patterns []string
+	switch {
+	case len(rule.Args) == 0 || string(rule.Args) == "null":
+	case json.Unmarshal(rule.Args, &pattern) == nil:
+		if pattern == "" {
+			break
+		}
+		re, err := compileArgsPattern(rule.Executable, pattern)
+		if err != nil {
+			return policyRule{}, err
+		}
+		parsed.args = re
+	case json.Unmarshal(rule.Args, &patterns) == nil:
+		parsed.argv = make([]*regexp.Regexp, 0, len(patterns))
+		for _, pattern := range patterns {
+			re, err := compileArgsPattern(rule.Executable, pattern)
+			if err != nil {
+				return policyRule{}, err
+			}
+			parsed.argv = append(parsed.argv, re)
+		}
+	default:
+		return policyRule{}, fmt.Errorf("invalid arguments of the policy rule for %s; expected a pattern, "+
+			"or an array holding the pattern of each argument", rule.Executable)
+	}
+
+	return parsed, nil
+}
+
+// compileArgsPattern compiles a pattern of the arguments of the rule for executable, matching them as a whole.
+func compileArgsPattern(executable, pattern string) (*regexp.Regexp, error) {
+	re, err := regexp.Compile("^(?:" + pattern + ")$")
+	if err != nil {
+		return nil, fmt.Errorf("invalid arguments pattern of the policy rule for %s: %w", executable, err)
+	}
+
+	return re, nil
+}
+
+// argsPattern returns the pattern re was compiled from by compileArgsPattern.
+func argsPattern(re *regexp.Regexp) string {
+	return strings.TrimSuffix(strings.TrimPrefix(re.String(), "^(?:"), ")$")
+}
+
+// parsePolicy parses the policy configuration key.
+func parsePolicy(v goja.Value) (*execPolicy, error) {
+	b, err := json.Marshal(v.Export())
+	if err != nil {
+		return nil, fmt.Errorf("invalid policy: %w", err)
+	}
+
+	policy := &execPolicy{}
+	if err := json.Unmarshal(b, policy); err != nil {
+		return nil, err
+	}
+
+	return policy, nil
+}
+
+// String returns the rule as it is written in policies.
+func (r policyRule) String() string {
+	switch {
+	case r.argv != nil:
+		patterns := make([]string, len(r.argv))
+		for i, re := range r.argv {
+			patterns[i] = argsPattern(re)
+		}
+		return fmt.Sprintf("%s %q", r.executable, patterns)
+	case r.args != nil:
+		return fmt.Sprintf("%s %s", r.executable, argsPattern(r.args))
+	default:
+		return r.executable
+	}
+}
+
+// matches returns true if the rule matches the executable at path, run with args.
+func (r policyRule) matches(path string, args []string) bool {
+	switch {
+	case r.executable == policyAnyExecutable:
+	case filepath.IsAbs(r.executable):
+		if r.executable != path {
+			return false
+		}
+	case r.executable != filepath.Base(path):
+		return false
+	}
+
+	switch {
+	case r.argv != nil:
+		if len(args) != len(r.argv) {
+			return false
+		}
+		for i, re := range r.argv {
+			if !re.MatchString(args[i]) {
+				return false
+			}
+		}
+		return true
+	case r.args != nil:
+		return r.args.MatchString(quoteArgs(args))
+	default:
+		return true
+	}
+}
+
+// violation returns why executing the executable at path with args isn't allowed by the
+// policy, or an empty string if it is. Deny rules take precedence over allow rules.
+func (p *execPolicy) violation(path string, args []string) string {
+	if p == nil {
+		return ""
+	}
+
+	for _, rule := range p.deny {
+		if rule.matches(path, args) {
+			return fmt.Sprintf("it matches the deny rule %q", rule)
+		}
+	}
+
+	if len(p.allow) == 0 && !p.strict {
+		return ""
+	}
+
+	for _, rule := range p.allow {
+		if rule.matches(path, args) {
+			return ""
+		}
+	}
+
+	return "it doesn't match any allow rule"
+}
+
+// restricts returns true if the policy only allows some executables, which executables
+// planted in the current directory mustn't be able to impersonate.
+func (p *execPolicy) restricts() bool {
+	return p != nil && (p.strict || len(p.allow) > 0)
+}
+
+// operatorPolicy is the execution policy set by the operator of k6, through its
+// environment, loaded once per k6 instance.
+type operatorPolicy struct {
+	once   sync.Once
+	policy *execPolicy
+	err    error
+}
+
+// get returns the policy set by the operator, or nil if there is none.
+func (op *operatorPolicy) get() (*execPolicy, error) {
+	if op == nil {
+		return nil, nil
+	}
+
+	op.once.Do(func() { op.policy, op.err = loadOperatorPolicy() })
+
+	return op.policy, op.err
+}
+
+// loadOperatorPolicy loads the policy set by the operator of k6 through its environment.
+func loadOperatorPolicy() (*execPolicy, error) {
+	policy := &execPolicy{}
+	configured := false
+
+	if path := os.Getenv(policyFileEnvVar); path != "" {
+		b, err := os.ReadFile(path) //nolint:gosec
+		if err != nil {
+			return nil, fmt.Errorf("unable to read the execution policy set by %s: %w", policyFileEnvVar, err)
+		}
+		if err := json.Unmarshal(b, policy); err != nil {
+			return nil, fmt.Errorf("invalid execution policy set by %s: %w", policyFileEnvVar, err)
+		}
+		configured = true
+	}
+
+	switch mode := os.Getenv(policyEnvVar); mode {
+	case "":
+	case policyStrict:
+		policy.strict, configured = true, true
+	default:
+		return nil, fmt.Errorf("invalid %s %q; expected %s", policyEnvVar, mode, policyStrict)
+	}
+
+	for _, list := range []struct {
+		envVar string
+		dest   *[]policyRule
+	}{{allowedBinariesEnvVar, &policy.allow}, {deniedBinariesEnvVar, &policy.deny}} {
+		for _, executable := range strings.Split(os.Getenv(list.envVar), ",") {
+			if executable = strings.TrimSpace(executable); executable == "" {
+				continue
+			}
+			if filepath.IsAbs(executable) {
+				executable = filepath.Clean(executable)
+			}
+			*list.dest = append(*list.dest, policyRule{executable: executable})
+			configured = true
+		}
+	}
+
+	if !configured {
+		return nil, nil
+	}
+
+	return policy, nil
+}
+
+// policyPath returns the path the executable of the command is checked against the policies at,
+// before being wrapped: the one it resolves to locally, or its name if it is run on a remote host,
+// or can't be resolved, such as the executables only found by sudo, or PowerShell cmdlets.
+func (c *Command) policyPath() string {
+	if c.ssh != nil || c.azure != nil {
+		return c.Name
+	}
+
+	name := c.Name
+	if c.dir != "" && strings.ContainsRune(name, filepath.Separator) && !filepath.IsAbs(name) {
+		name = filepath.Join(c.dir, name)
+	}
+
+	if path, err := exec.LookPath(name); err == nil || errors.Is(err, exec.ErrDot) {
+		return path
+	}
+
+	return name
+}
+
+// checkPolicy returns an error if executing the named executable, resolved to path, with args
+// is denied by the policy set by the operator of k6, or by the configuration. It is called both
+// with the command itself, and with the executable actually executed, so that the wrappers of
+// commands, such as sudo, are checked too.
+func (c *Command) checkPolicy(config *moduleConfig, name, path string, args []string) error {
+	operator, err := c.policy.get()
+	if err != nil {
+		return err
+	}
+
+	return checkPolicies(operator, config.policy, name, path, args)
+}
+
+// checkPolicies returns an error if executing the named executable, resolved to path, with args
+// is denied by operator, the policy set by the operator of k6, or by configured, the one set by
+// the configuration, either of which is nil if there is none.
+func checkPolicies(operator, configured *execPolicy, name, path string, args []string) error {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+
+	for _, policy := range []struct {
+		source string
+		policy *execPolicy
+	}{{"the environment of k6", operator}, {"the configuration", configured}} {
+		if violation := policy.policy.violation(path, args); violation != "" {
+			return fmt.Errorf("executing %q, resolved to %s, is denied by the execution policy set by %s: %s",
+				name, path, policy.source, violation)
+		}
+	}
+
+	return nil
+}
+
+// lookExecutable returns the path the named executable resolves to, and whether it was resolved
+// relative to the current directory, through a relative entry of PATH, such as ".", which is
+// prohibited unless config allows it. It is always prohibited when operator, the policy set by
+// the operator of k6, if any, restricts the executables allowed, so that scripts can't allow it.
+func lookExecutable(name string, operator *execPolicy, config *moduleConfig) (string, bool, error) {
+	path, err := exec.LookPath(name)
+	if !errors.Is(err, exec.ErrDot) {
+		return path, false, err
+	}
+
+	if operator.restricts() {
+		return "", false, fmt.Errorf("%q resolves to %s, relative to the current directory, through a relative PATH entry, "+
+			"which is prohibited by the execution policy set by the environment of k6; use an explicit path instead", name, path)
+	}
+
+	if !config.allowCwdExecutables {
+		return "", false, fmt.Errorf("%q resolves to %s, relative to the current directory, through a relative PATH entry, "+
+			"which is prohibited as allowCwdExecutables is false; use an explicit path instead", name, path)
+	}
+
+	return path, true, nil
+}
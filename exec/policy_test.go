@@ -0,0 +1,361 @@
+//go:build !windows
+
+package exec
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.k6.io/k6/lib"
+)
+
+func TestParsePolicyRule(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		rule       string
+		executable string
+		args       string
+		wantErr    string
+	}{
+		{name: "name", rule: `"curl"`, executable: "curl"},
+		{name: "any executable", rule: `"*"`, executable: "*"},
+		{name: "path", rule: `"/usr/bin/../bin/curl"`, executable: "/usr/bin/curl"},
+		{name: "object", rule: `{"executable": "/usr/bin/./git"}`, executable: "/usr/bin/git"},
+		{name: "arguments", rule: `{"executable": "git", "args": "status|log .*"}`, executable: "git", args: "^(?:status|log .*)$"},
+		{name: "relative path kept", rule: `"bin/../tool"`, executable: "bin/../tool"},
+		{name: "empty executable", rule: `""`, wantErr: "invalid policy rule; expected an executable"},
+		{name: "object without executable", rule: `{"args": ".*"}`, wantErr: "invalid policy rule; expected an executable"},
+		{name: "not a rule", rule: `42`, wantErr: "invalid policy rule; expected an executable, or an object holding one"},
+		{name: "invalid pattern", rule: `{"executable": "git", "args": "("}`, wantErr: "invalid arguments pattern of the policy rule for git"},
+		{name: "invalid argument pattern", rule: `{"executable": "git", "args": ["status", "("]}`, wantErr: "invalid arguments pattern of the policy rule for git"},
+		{name: "invalid arguments", rule: `{"executable": "git", "args": 42}`, wantErr: "invalid arguments of the policy rule for git"},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			rule, err := parsePolicyRule([]byte(tt.rule))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("parsePolicyRule(%s) error = %v, want it to contain %q", tt.rule, err, tt.wantErr)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("parsePolicyRule(%s) error = %v", tt.rule, err)
+			}
+			if rule.executable != tt.executable {
+				t.Errorf("parsePolicyRule(%s) executable = %q, want %q", tt.rule, rule.executable, tt.executable)
+			}
+
+			var args string
+			if rule.args != nil {
+				args = rule.args.String()
+			}
+			if args != tt.args {
+				t.Errorf("parsePolicyRule(%s) args = %q, want %q", tt.rule, args, tt.args)
+			}
+		})
+	}
+}
+
+func TestPolicyRuleMatches(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		rule string
+		path string
+		args []string
+		want bool
+	}{
+		{name: "name anywhere", rule: `"curl"`, path: "/usr/local/bin/curl", args: []string{"-s"}, want: true},
+		{name: "other name", rule: `"curl"`, path: "/usr/bin/curlie", want: false},
+		{name: "name as a directory", rule: `"bin"`, path: "/usr/bin/curl", want: false},
+		{name: "same path", rule: `"/usr/bin/curl"`, path: "/usr/bin/curl", want: true},
+		{name: "other path", rule: `"/usr/bin/curl"`, path: "/usr/local/bin/curl", want: false},
+		{name: "any executable", rule: `"*"`, path: "/bin/rm", args: []string{"-rf", "/"}, want: true},
+		{name: "matching arguments", rule: `{"executable": "git", "args": "status( .*)?"}`, path: "/usr/bin/git", args: []string{"status", "-s"}, want: true},
+		{name: "matching no arguments", rule: `{"executable": "git", "args": "status( .*)?"}`, path: "/usr/bin/git", args: []string{"status"}, want: true},
+		{name: "other arguments", rule: `{"executable": "git", "args": "status( .*)?"}`, path: "/usr/bin/git", args: []string{"push"}, want: false},
+		{name: "arguments matched as a whole", rule: `{"executable": "git", "args": "status"}`, path: "/usr/bin/git", args: []string{"status", "&&", "push"}, want: false},
+		{name: "arguments of another executable", rule: `{"executable": "git", "args": ".*"}`, path: "/usr/bin/hg", args: []string{"status"}, want: false},
+		{name: "arguments of any executable", rule: `{"executable": "*", "args": ".*--force.*"}`, path: "/usr/bin/git", args: []string{"push", "--force"}, want: true},
+		{name: "argument posing as several", rule: `{"executable": "git", "args": "push origin"}`, path: "/usr/bin/git", args: []string{"push origin"}, want: false},
+		{name: "quoted arguments", rule: `{"executable": "git", "args": "commit -m '.*'"}`, path: "/usr/bin/git", args: []string{"commit", "-m", "a message"}, want: true},
+		{name: "pattern spanning arguments", rule: `{"executable": "curl", "args": "-s https://x/.*"}`, path: "/usr/bin/curl", args: []string{"-s", "https://x/", "-o", "/etc/passwd"}, want: true},
+		{name: "argument patterns", rule: `{"executable": "git", "args": ["status", "-s|--short"]}`, path: "/usr/bin/git", args: []string{"status", "--short"}, want: true},
+		{name: "other argument", rule: `{"executable": "git", "args": ["status", "-s|--short"]}`, path: "/usr/bin/git", args: []string{"status", "-v"}, want: false},
+		{name: "fewer arguments than patterns", rule: `{"executable": "git", "args": ["status", "-s"]}`, path: "/usr/bin/git", args: []string{"status"}, want: false},
+		{name: "more arguments than patterns", rule: `{"executable": "curl", "args": ["-s", "https://x/.*"]}`, path: "/usr/bin/curl", args: []string{"-s", "https://x/", "-o", "/etc/passwd"}, want: false},
+		{name: "no argument patterns", rule: `{"executable": "git", "args": []}`, path: "/usr/bin/git", want: true},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			rule, err := parsePolicyRule([]byte(tt.rule))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := rule.matches(tt.path, tt.args); got != tt.want {
+				t.Errorf("%s matches(%q, %q) = %t, want %t", rule, tt.path, tt.args, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExecPolicyViolation(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		policy string
+		path   string
+		args   []string
+		want   string
+	}{
+		{name: "no rules", policy: `{}`, path: "/bin/rm", want: ""},
+		{name: "strict without rules", policy: `{"strict": true}`, path: "/bin/ls", want: "it doesn't match any allow rule"},
+		{name: "allowed", policy: `{"allow": ["ls", "/usr/bin/curl"]}`, path: "/usr/bin/curl", want: ""},
+		{name: "not allowed", policy: `{"allow": ["ls"]}`, path: "/bin/rm", want: "it doesn't match any allow rule"},
+		{name: "denied", policy: `{"deny": ["rm"]}`, path: "/bin/rm", want: `it matches the deny rule "rm"`},
+		{name: "not denied", policy: `{"deny": ["rm"]}`, path: "/bin/ls", want: ""},
+		{name: "deny over allow", policy: `{"allow": ["*"], "deny": ["/bin/rm"]}`, path: "/bin/rm", want: `it matches the deny rule "/bin/rm"`},
+		{
+			name:   "denied arguments",
+			policy: `{"allow": ["git"], "deny": [{"executable": "*", "args": ".*--force.*"}]}`,
+			path:   "/usr/bin/git", args: []string{"push", "--force"},
+			want: `it matches the deny rule "* .*--force.*"`,
+		},
+		{
+			name:   "allowed arguments",
+			policy: `{"strict": true, "allow": [{"executable": "git", "args": "status"}]}`,
+			path:   "/usr/bin/git", args: []string{"status"},
+			want: "",
+		},
+		{
+			name:   "not allowed arguments",
+			policy: `{"strict": true, "allow": [{"executable": "git", "args": "status"}]}`,
+			path:   "/usr/bin/git", args: []string{"push"},
+			want: "it doesn't match any allow rule",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			policy := &execPolicy{}
+			if err := json.Unmarshal([]byte(tt.policy), policy); err != nil {
+				t.Fatal(err)
+			}
+
+			if got := policy.violation(tt.path, tt.args); got != tt.want {
+				t.Errorf("violation(%q, %q) = %q, want %q", tt.path, tt.args, got, tt.want)
+			}
+		})
+	}
+
+	t.Run("no policy", func(t *testing.T) {
+		t.Parallel()
+
+		var policy *execPolicy
+		if got := policy.violation("/bin/rm", nil); got != "" {
+			t.Errorf("violation() = %q, want none", got)
+		}
+	})
+}
+
+func TestExecPolicyUnmarshalJSON(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		policy  string
+		strict  bool
+		allow   []string
+		deny    []string
+		wantErr string
+	}{
+		{name: "empty", policy: `{}`},
+		{
+			name:   "rules",
+			policy: `{"strict": true, "allow": ["curl", {"executable": "git", "args": "status"}], "deny": ["/bin/rm"]}`,
+			strict: true, allow: []string{"curl", "git status"}, deny: []string{"/bin/rm"},
+		},
+		{
+			name:   "argument patterns",
+			policy: `{"allow": [{"executable": "git", "args": ["status", "-s|--short"]}]}`,
+			allow:  []string{`git ["status" "-s|--short"]`},
+		},
+		{name: "unknown keys ignored", policy: `{"allow": ["curl"], "comment": "ci"}`, allow: []string{"curl"}},
+		{name: "not an object", policy: `["curl"]`, wantErr: "invalid policy; expected an object holding allow and deny rules"},
+		{name: "rules not an array", policy: `{"allow": "curl"}`, wantErr: "invalid policy; expected an object holding allow and deny rules"},
+		{name: "invalid allow rule", policy: `{"allow": [""]}`, wantErr: "invalid policy rule; expected an executable"},
+		{name: "invalid deny rule", policy: `{"deny": [{"executable": "git", "args": "["}]}`, wantErr: "invalid arguments pattern"},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			policy := &execPolicy{}
+			err := json.Unmarshal([]byte(tt.policy), policy)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("unmarshaling %s error = %v, want it to contain %q", tt.policy, err, tt.wantErr)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unmarshaling %s error = %v", tt.policy, err)
+			}
+			if policy.strict != tt.strict {
+				t.Errorf("unmarshaling %s strict = %t, want %t", tt.policy, policy.strict, tt.strict)
+			}
+			if got := ruleStrings(policy.allow); strings.Join(got, ",") != strings.Join(tt.allow, ",") {
+				t.Errorf("unmarshaling %s allow = %q, want %q", tt.policy, got, tt.allow)
+			}
+			if got := ruleStrings(policy.deny); strings.Join(got, ",") != strings.Join(tt.deny, ",") {
+				t.Errorf("unmarshaling %s deny = %q, want %q", tt.policy, got, tt.deny)
+			}
+		})
+	}
+}
+
+func TestCommandPolicy(t *testing.T) {
+	t.Parallel()
+
+	// The commands run over SSH connect to a closed port, and fail without reaching any host.
+	const remote = `exec.ssh({ host: "127.0.0.1", port: 1, options: { BatchMode: "yes", ConnectTimeout: "1" } })`
+
+	tests := []struct {
+		name    string
+		policy  string
+		script  string
+		wantErr string
+	}{
+		{name: "allowed", policy: `{"allow": ["true"]}`, script: `exec.run("true")`},
+		{name: "not allowed", policy: `{"allow": ["true"]}`, script: `exec.run("false")`, wantErr: `executing "false"`},
+		{name: "denied", policy: `{"deny": ["false"]}`, script: `exec.run("false")`, wantErr: `it matches the deny rule "false"`},
+		{name: "allowed arguments", policy: `{"allow": [{"executable": "sh", "args": ["-c", "exit 0"]}]}`, script: `exec.sh("exit 0")`},
+		{
+			name:    "not allowed arguments",
+			policy:  `{"allow": [{"executable": "sh", "args": ["-c", "exit 0"]}]}`,
+			script:  `exec.sh("exit 0; rm -rf /tmp/x")`,
+			wantErr: `executing "/bin/sh"`,
+		},
+		{name: "wrapped command", policy: `{"allow": ["uptime", "ssh"]}`, script: remote + `.run("uptime")`},
+		{name: "wrapper not allowed", policy: `{"allow": ["uptime"]}`, script: remote + `.run("uptime")`, wantErr: `executing "ssh"`},
+		{name: "command not allowed through its wrapper", policy: `{"allow": ["ssh"]}`, script: remote + `.run("uptime")`, wantErr: `executing "uptime"`},
+		{name: "command denied through its wrapper", policy: `{"deny": ["uptime"]}`, script: remote + `.run("uptime")`, wantErr: `executing "uptime"`},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			vu := newTestVU(t, nil)
+			vu.moveToVUContext(lib.Options{})
+			vu.mustRun("exec.configure({ policy: " + tt.policy + " })")
+
+			_, err := vu.run(tt.script)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("%s under %s error = %v", tt.script, tt.policy, err)
+				}
+				return
+			}
+
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) || !strings.Contains(err.Error(), "execution policy") {
+				t.Errorf("%s under %s error = %v, want it to contain %q", tt.script, tt.policy, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLookExecutable(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "git"), []byte("#!/bin/sh\n"), 0o700); err != nil { //nolint:gosec
+		t.Fatal(err)
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.Chdir(wd) })
+	t.Setenv("PATH", ".")
+
+	tests := []struct {
+		name     string
+		operator string
+		allowCwd bool
+		wantErr  string
+	}{
+		{name: "allowed", allowCwd: true},
+		{name: "prohibited by the configuration", wantErr: "prohibited as allowCwdExecutables is false"},
+		{name: "deny rules", operator: `{"deny": ["rm"]}`, allowCwd: true},
+		{name: "strict policy", operator: `{"strict": true}`, allowCwd: true, wantErr: "prohibited by the execution policy"},
+		{name: "allow rules", operator: `{"allow": ["git"]}`, allowCwd: true, wantErr: "prohibited by the execution policy"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var operator *execPolicy
+			if tt.operator != "" {
+				operator = &execPolicy{}
+				if err := json.Unmarshal([]byte(tt.operator), operator); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			config := newModuleConfig()
+			config.allowCwdExecutables = tt.allowCwd
+
+			path, cwdRelative, err := lookExecutable("git", operator, config)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("lookExecutable() error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("lookExecutable() error = %v", err)
+			}
+			if path != filepath.Join(".", "git") || !cwdRelative {
+				t.Errorf("lookExecutable() = %q, %t, want ./git, relative to the current directory", path, cwdRelative)
+			}
+		})
+	}
+}
+
+// ruleStrings returns the rules as they are written in policies.
+func ruleStrings(rules []policyRule) []string {
+	written := make([]string, len(rules))
+	for i, rule := range rules {
+		written[i] = rule.String()
+	}
+
+	return written
+}
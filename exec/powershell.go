@@ -0,0 +1,107 @@
+package exec
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"go.k6.io/k6/js/common"
+)
+
+// defaultPowerShellDepth is the default depth the output objects of PowerShell
+// pipelines are serialized to. ConvertTo-Json's own default, 2, truncates most
+// of the objects returned by cmdlets.
+const defaultPowerShellDepth = 4
+
+// PowerShellOptions configures how a command is run as a PowerShell pipeline
+// whose output objects are deserialized.
+type PowerShellOptions struct {
+	// Executable is the PowerShell executable, either pwsh (the default),
+	// or powershell for Windows PowerShell.
+	Executable string `js:"executable"`
+
+	// Depth is how deep the output objects are serialized.
+	Depth int `js:"depth"`
+
+	// Array makes the output objects always be deserialized into an array,
+	// even when the pipeline outputs a single object, or none.
+	Array bool `js:"array"`
+}
+
+// PowerShell returns a copy of the command run as a PowerShell pipeline, the name of the command
+// being a cmdlet, function or script, whose output objects are converted to JSON, and deserialized
+// into the data of the execution result.
+func (c Command) PowerShell(opts PowerShellOptions) Command {
+	if opts.Executable == "" {
+		opts.Executable = "pwsh"
+	}
+	if opts.Depth == 0 {
+		opts.Depth = defaultPowerShellDepth
+	}
+
+	if opts.Depth < 0 || opts.Depth > 100 {
+		common.Throw(c.vu.Runtime(), fmt.Errorf("invalid PowerShell depth %d; expected a depth between 1 and 100", opts.Depth))
+	}
+
+	c.powerShell = &opts
+	return c
+}
+
+// wrap returns the executable and arguments running the named command, followed
+// by its arguments, as a PowerShell pipeline whose output is converted to JSON.
+func (o *PowerShellOptions) wrap(name string, args []string) (string, []string) {
+	var pipeline strings.Builder
+	pipeline.WriteString("& " + quotePowerShell(name))
+	for _, arg := range args {
+		// Arguments starting with a dash are parameter names, and are passed as is.
+		if strings.HasPrefix(arg, "-") && !strings.ContainsAny(arg, " \t\n'\"`$;|&(){}") {
+			pipeline.WriteString(" " + arg)
+		} else {
+			pipeline.WriteString(" " + quotePowerShell(arg))
+		}
+	}
+
+	// The output is encoded as UTF-8, rather than using the console encoding, and errors stop
+	// the pipeline, so that they're reported by a non-zero exit code.
+	script := "$ErrorActionPreference = 'Stop'; [Console]::OutputEncoding = [Text.Encoding]::UTF8; " +
+		"ConvertTo-Json -Compress -Depth " + strconv.Itoa(o.Depth) + " -InputObject "
+	if o.Array {
+		script += "@(" + pipeline.String() + ")"
+	} else {
+		script += "(" + pipeline.String() + ")"
+	}
+
+	return o.Executable, []string{"-NoProfile", "-NonInteractive", "-Command", script}
+}
+
+// parse deserializes the JSON output of a PowerShell pipeline. A pipeline outputting
+// no object is deserialized into null, or into an empty array.
+func (o *PowerShellOptions) parse(output []byte) (interface{}, error) {
+	// Windows PowerShell writes a byte order mark when the output encoding is UTF-8.
+	output = bytes.TrimSpace(bytes.TrimPrefix(output, []byte("\xef\xbb\xbf")))
+	if len(output) == 0 || bytes.Equal(output, []byte("null")) {
+		if o.Array {
+			return []interface{}{}, nil
+		}
+		return nil, nil
+	}
+
+	var data interface{}
+	if err := json.Unmarshal(output, &data); err != nil {
+		return nil, fmt.Errorf("unable to parse the output of the PowerShell pipeline as JSON: %w", err)
+	}
+
+	return data, nil
+}
+
+// quotePowerShell quotes s as a PowerShell verbatim string.
+func quotePowerShell(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+// errPowerShellOutput is the error of PowerShell pipelines whose standard output isn't captured.
+var errPowerShellOutput = errors.New("powerShell requires the standard output to be captured as is; " +
+	"it can't be redirected to a file, filtered, truncated, nor discarded")
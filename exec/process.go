@@ -0,0 +1,389 @@
+package exec
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+
+	"github.com/dop251/goja"
+	"go.k6.io/k6/js/common"
+	"go.k6.io/k6/js/modules"
+	"go.k6.io/k6/lib"
+)
+
+// Process represents a command spawned in the background.
+type Process struct {
+	Pid int `js:"pid"`
+
+	// Stdin exposes the process's standard input as a stream.
+	Stdin *InputStream `js:"stdin"`
+
+	// Stdout exposes the process's standard output as a stream.
+	Stdout *OutputStream `js:"stdout"`
+
+	// Control is the process's control channel, when enabled.
+	Control *ControlChannel `js:"control"`
+
+	vu        modules.VU
+	events    *emitter
+	terminal  *terminal
+	process   *os.Process
+	execution *execution
+
+	// obj is the JS object exposing the process, once created.
+	obj *goja.Object
+
+	// exited is closed once the process exited, and result and err are set.
+	exited chan struct{}
+	result *CommandResult
+	err    error
+}
+
+// Spawn starts the command in the background, and returns a handle on the
+// resulting process. Its standard output is exposed as a stream rather than
+// captured, and its standard error is captured in the result of Wait. As the
+// stream is only read when the script reads it, a process writing more than
+// the pipe's buffer holds blocks until it is.
+func (c *Command) Spawn(options goja.Value) *goja.Object {
+	rt := c.vu.Runtime()
+	vuContext := c.vu.Context()
+
+	if err := c.checkInitAllowed(); err != nil {
+		common.Throw(rt, err)
+	}
+
+	opts, err := parseExecOptions(rt, options)
+	if err != nil {
+		common.Throw(rt, err)
+	}
+	opts = c.withTimeout(opts)
+
+	config := c.config.resolve(c.vu)
+	if reason := prohibitedReason(config); reason != "" {
+		common.Throw(rt, fmt.Errorf("spawning %q is prohibited in this environment (%s)", c.Name, reason))
+	}
+
+	if err := c.checkSpawnable(opts); err != nil {
+		common.Throw(rt, err)
+	}
+
+	if err := c.hooks.start(c); err != nil {
+		common.Throw(rt, err)
+	}
+
+	if err := c.warmUp(config); err != nil {
+		common.Throw(rt, err)
+	}
+
+	if err := config.loadGuard.wait(vuContext); err != nil {
+		common.Throw(rt, fmt.Errorf("spawning %q: %w", c.Name, err))
+	}
+
+	cmd, err := c.build(vuContext, opts)
+	if err != nil {
+		common.Throw(rt, err)
+	}
+	defer closeFiles(cmd.ExtraFiles...)
+
+	pipes, err := c.openProcessPipes(cmd, opts)
+	if err != nil {
+		common.Throw(rt, err)
+	}
+
+	execution, err := c.startExecution(cmd, opts)
+	if err != nil {
+		pipes.close()
+		common.Throw(rt, err)
+	}
+	closeFiles(pipes.stdoutWriter, pipes.stdinReader)
+
+	p := &Process{
+		Pid:       cmd.Process.Pid,
+		Stdin:     newInputStream(c.vu, pipes.stdin),
+		Stdout:    newOutputStream(vuContext, c.vu, pipes.stdoutReader, opts.readBufferSize),
+		Control:   pipes.control,
+		vu:        c.vu,
+		terminal:  pipes.terminal,
+		events:    newEmitter(c.vu),
+		process:   cmd.Process,
+		execution: execution,
+		exited:    make(chan struct{}),
+	}
+	if execution.idle != nil {
+		p.Stdout.onRead = execution.idle.touch
+	}
+
+	// With structured concurrency, the process keeps the iteration from ending until it exited.
+	exitedCallback := func(func() error) {}
+	if config.structuredConcurrency {
+		exitedCallback = c.vu.RegisterCallback()
+	}
+
+	p.events.open()
+	go c.superviseProcess(p, pipes, opts, vuContext, c.vu.State(), func() { exitedCallback(func() error { return nil }) })
+
+	return p.object()
+}
+
+// checkSpawnable returns an error if the command can't be spawned with opts.
+func (c *Command) checkSpawnable(opts *execOptions) error {
+	switch {
+	case c.stdoutFile != nil:
+		return errors.New("the standard output of a spawned process can't be redirected to a file")
+	case c.azure != nil:
+		return errors.New("commands run on Azure virtual machines can't be spawned")
+	case c.powerShell != nil:
+		return errors.New("PowerShell pipelines can't be spawned")
+	case c.stdinSource(opts) != nil:
+		return errors.New("the stdin option isn't supported by spawned processes; write to their stdin instead")
+	case len(c.chain) > 0:
+		return errors.New("chained commands can't be spawned")
+	case len(c.pipeline) > 0:
+		return errors.New("pipelines can't be spawned")
+	case opts.until != nil:
+		return errors.New("spawned processes can't be followed until their output matches; use follow instead")
+	case c.sudo != nil && c.sudo.PasswordEnv != "":
+		return errors.New("a password can't be fed to sudo for spawned processes, as they own their standard input")
+	default:
+		return nil
+	}
+}
+
+// processPipes holds the ends of the standard streams of a spawned process, and its control
+// channel, if any. The ends of its terminal, if it runs in one, are the ones of its standard output.
+type processPipes struct {
+	stdoutReader, stdoutWriter, stdinReader, stderrFile *os.File
+
+	stdin        io.WriteCloser
+	stderr       *io.PipeReader
+	stderrWriter *io.PipeWriter
+
+	terminal *terminal
+	control  *ControlChannel
+}
+
+// openProcessPipes wires the standard streams of cmd, and its control channel, if enabled.
+func (c *Command) openProcessPipes(cmd *exec.Cmd, opts *execOptions) (*processPipes, error) {
+	pipes := &processPipes{}
+	pipes.stderr, pipes.stderrWriter = io.Pipe()
+	cmd.Stderr = pipes.stderrWriter
+
+	// fail releases what was opened for the process, which failed to be spawned, and returns err.
+	fail := func(err error) (*processPipes, error) {
+		pipes.close()
+		return nil, err
+	}
+
+	var err error
+	if opts.controlChannel {
+		if pipes.control, err = newControlChannel(c.vu.Context(), c.vu); err != nil {
+			return fail(err)
+		}
+		cmd.Env = append(cmd.Env, controlSocketEnvVar+"="+pipes.control.Path)
+	}
+
+	if opts.pty != nil {
+		// Processes run in a pseudo-terminal write their merged output to it, exposed as their
+		// standard output, and read their input from it, written to as their standard input.
+		if err := c.validateTerminal(opts); err != nil {
+			return fail(err)
+		}
+		if pipes.terminal, err = openTerminal(*opts.pty); err != nil {
+			return fail(err)
+		}
+		pipes.terminal.attach(cmd)
+
+		terminal := pipes.terminal
+		pipes.stdoutReader, pipes.stdoutWriter, pipes.stdin = terminal.master, terminal.slave, terminal.input()
+	} else {
+		// The standard output is wired to a pipe we own, rather than one obtained through
+		// StdoutPipe, so that waiting for the process doesn't close it before it is fully read.
+		if pipes.stdoutReader, pipes.stdoutWriter, err = os.Pipe(); err != nil {
+			return fail(err)
+		}
+		if opts.pipeSize > 0 {
+			if err := setPipeSize(pipes.stdoutWriter, opts.pipeSize); err != nil {
+				return fail(err)
+			}
+		}
+		cmd.Stdout = pipes.stdoutWriter
+
+		// The writer is assigned separately, so that a nil one isn't stored as a non-nil io.WriteCloser.
+		var stdinWriter *inputPipeWriter
+		if pipes.stdinReader, stdinWriter, err = newInputPipe(); err != nil {
+			return fail(err)
+		}
+		pipes.stdin = stdinWriter
+		cmd.Stdin = pipes.stdinReader
+	}
+
+	if _, pipes.stderrFile, err = c.openOutputFiles(c.vu.State()); err != nil {
+		return fail(err)
+	}
+
+	return pipes, nil
+}
+
+// close releases the pipes of a process which failed to be spawned.
+func (pipes *processPipes) close() {
+	if pipes.stdin != nil {
+		_ = pipes.stdin.Close()
+	}
+	closeFiles(pipes.stdoutReader, pipes.stdoutWriter, pipes.stdinReader, pipes.stderrFile)
+	_ = pipes.stderrWriter.Close()
+	if pipes.control != nil {
+		pipes.control.close()
+	}
+}
+
+// superviseProcess captures the standard error of the spawned process until it exited, then
+// sets its result, calls exited, emits its exit and error events, and its metrics, in the
+// context, and with the state, of the VU at the time it was spawned.
+func (c *Command) superviseProcess(
+	p *Process, pipes *processPipes, opts *execOptions,
+	vuContext context.Context, vuState *lib.State, exited func(),
+) {
+	defer closeFiles(pipes.stderrFile)
+
+	execution := p.execution
+
+	stderrCapture := c.newStreamCapture("stderr", pipes.stderrFile, opts)
+	stderrEvents := emitWriter{em: p.events, event: processEventStderr}
+	stderrDone := stderrCapture.drainAsync(io.TeeReader(execution.observe("stderr", pipes.stderr), stderrEvents))
+
+	execution.wait()
+	_ = pipes.stdin.Close()
+	_ = pipes.stderrWriter.Close()
+	stderrResult := <-stderrDone
+	execution.closeTranscript()
+
+	if pipes.control != nil {
+		pipes.control.closeIfUnused()
+	}
+
+	p.result = newCommandResult(
+		execution.exitCode,
+		capturedOutput{}, stderrResult.output,
+		streamCapture{}, stderrCapture,
+		opts,
+	)
+	execution.annotate(p.result)
+	if err := execution.failure(stderrResult.output); err != nil {
+		p.err = c.rejection(err)
+	}
+	close(p.exited)
+	exited()
+
+	p.events.emit(processEventExit, func(rt *goja.Runtime) []goja.Value {
+		return []goja.Value{p.result.toJSValue(rt)}
+	})
+	if p.err != nil {
+		p.events.emit(processEventError, func(rt *goja.Runtime) []goja.Value {
+			return []goja.Value{toValue(rt, p.err)}
+		})
+	}
+	p.events.done()
+
+	// If the script consumes the standard output, the metrics are emitted once it was read up
+	// to its end, so that they account for all of it. Otherwise, they're emitted right away,
+	// accounting for the output read so far, as it may never be read.
+	if p.Stdout.consumed.Load() {
+		<-p.Stdout.finished
+	}
+
+	execution.debugDrained(p.Stdout.totals(), stderrResult.totals())
+
+	metricsContext, cancel := execution.metricsContext(vuContext)
+	defer cancel()
+
+	c.pushMetrics(metricsContext, vuState, execution.stats(p.Stdout.totals(), stderrResult.totals()))
+}
+
+// Wait returns a promise resolved with the result of the process once it exited,
+// or rejected if it timed out or failed while throwOnError is set.
+func (p *Process) Wait() *goja.Promise {
+	promise, resolve, reject := makeHandledPromise(p.vu)
+
+	go func() {
+		<-p.exited
+		if p.err != nil {
+			reject(p.err)
+			return
+		}
+
+		resolve(p.result)
+	}()
+
+	return promise
+}
+
+// Signal sends the named signal to the process. Signals are named portably, e.g.
+// SIGTERM or TERM, and mapped to the closest equivalent on Windows.
+func (p *Process) Signal(name string) {
+	rt := p.vu.Runtime()
+
+	signal, err := normalizeSignal(name)
+	if err != nil {
+		common.Throw(rt, err)
+	}
+
+	if err := p.execution.signalProcess(signal); err != nil {
+		common.Throw(rt, err)
+	}
+}
+
+// IsRunning returns true if the process didn't exit yet.
+func (p *Process) IsRunning() bool {
+	select {
+	case <-p.exited:
+		return false
+	default:
+		return true
+	}
+}
+
+// Kill sends the named signal to the process, SIGKILL by default, unless it already exited.
+func (p *Process) Kill(signal goja.Value) {
+	if !p.IsRunning() {
+		return
+	}
+
+	name := "SIGKILL"
+	if !common.IsNullish(signal) {
+		name = signal.String()
+	}
+
+	p.Signal(name)
+}
+
+// Resize sets the size, in characters, of the pseudo-terminal the process runs in,
+// which sends it SIGWINCH. It throws if the process wasn't spawned with the pty option.
+func (p *Process) Resize(cols, rows int) {
+	rt := p.vu.Runtime()
+
+	if p.terminal == nil {
+		common.Throw(rt, errors.New("only processes spawned with the pty option can be resized"))
+	}
+
+	if cols <= 0 || cols > 0xffff || rows <= 0 || rows > 0xffff {
+		common.Throw(rt, errors.New("resize expects positive numbers of columns and rows"))
+	}
+
+	if err := p.terminal.resize(terminalSize{cols: uint16(cols), rows: uint16(rows)}); err != nil {
+		common.Throw(rt, err)
+	}
+}
+
+// Write writes data, either a string, an ArrayBuffer or a Uint8Array, to the process's
+// standard input. The returned promise is resolved once the data was written.
+func (p *Process) Write(data goja.Value) *goja.Promise {
+	return p.Stdin.Write(data, goja.Undefined())
+}
+
+// End closes the process's standard input once all the pending writes completed.
+func (p *Process) End() *goja.Promise {
+	return p.Stdin.Close()
+}
@@ -0,0 +1,67 @@
+//go:build !windows
+
+package exec
+
+import (
+	"testing"
+
+	"go.k6.io/k6/lib"
+)
+
+func TestProcessMetrics(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		script      string
+		stdoutBytes float64
+	}{
+		{
+			name:   "unread stdout",
+			script: `new exec.Cmd("sh").args(["-c", "echo hello"]).spawn().wait()`,
+		},
+		{
+			name: "read stdout",
+			script: `(async () => {
+				const proc = new exec.Cmd("sh").args(["-c", "echo hello"]).spawn();
+				const reader = proc.stdout.getReader();
+				while (!(await reader.read()).done);
+				return proc.wait();
+			})()`,
+			stdoutBytes: 6,
+		},
+		{
+			name: "stdout larger than the pipe",
+			script: `(async () => {
+				const proc = new exec.Cmd("head").args(["-c", "100000", "/dev/zero"]).spawn();
+				const reader = proc.stdout.getReader();
+				while (!(await reader.read()).done);
+				return proc.wait();
+			})()`,
+			stdoutBytes: 100000,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			vu := newTestVU(t, nil)
+			vu.moveToVUContext(lib.Options{})
+			vu.mustRun(tt.script)
+
+			if samples := vu.awaitSamplesOf("exec_command_duration"); len(samples) != 1 {
+				t.Errorf("%d exec_command_duration samples were pushed, want 1", len(samples))
+			}
+
+			var stdoutBytes float64
+			for _, sample := range vu.samplesOf("exec_command_stdout_bytes") {
+				stdoutBytes += sample.Value
+			}
+			if stdoutBytes != tt.stdoutBytes {
+				t.Errorf("the process wrote %v bytes of standard output, want %v", stdoutBytes, tt.stdoutBytes)
+			}
+		})
+	}
+}
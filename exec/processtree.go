@@ -0,0 +1,28 @@
+package exec
+
+// signalTree sends the named signal to the process tree of the command, that is to the command
+// and to the processes it started, and reports whether it did, as opposed to the command not being
+// started in a process tree of its own, the tree not being attached to it yet, or the platform not
+// supporting delivering the signal so.
+func (e *execution) signalTree(name string) bool {
+	if e.tree == nil || !e.treeAttached.Load() {
+		return false
+	}
+
+	e.treeSignalled.Store(true)
+	return e.tree.signal(name) == nil
+}
+
+// reapTree kills the processes left in the process tree of the exited command, if it was
+// signalled by the module, so that the children of wrapped commands, such as the ones of shell
+// scripts, npm scripts or make, don't keep running once it was cancelled, and releases the tree.
+func (e *execution) reapTree() {
+	if e.tree == nil || !e.treeAttached.Load() {
+		return
+	}
+
+	if e.treeSignalled.Load() {
+		_ = e.tree.signal("SIGKILL")
+	}
+	e.tree.release()
+}
@@ -0,0 +1,44 @@
+//go:build !windows
+
+package exec
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// processTree is the process group a command is started in, so that the processes it
+// starts, which inherit it, can be signalled along with it.
+type processTree struct {
+	pgid int
+}
+
+// prepareProcessTree makes cmd be started in a process group of its own, unless it is started
+// in a session of its own, as commands run in a pseudo-terminal are, which is one already.
+func prepareProcessTree(cmd *exec.Cmd) *processTree {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+
+	if !cmd.SysProcAttr.Setsid {
+		cmd.SysProcAttr.Setpgid = true
+		cmd.SysProcAttr.Pgid = 0
+	}
+
+	return &processTree{}
+}
+
+// attach records the process group of the started process, whose ID is its pid.
+func (t *processTree) attach(p *os.Process) error {
+	t.pgid = p.Pid
+	return nil
+}
+
+// signal sends the named signal to all the processes of the group.
+func (t *processTree) signal(name string) error {
+	return syscall.Kill(-t.pgid, signals[name])
+}
+
+// release is a no-op on Unix, where process groups don't need to be released.
+func (t *processTree) release() {}
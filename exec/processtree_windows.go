@@ -0,0 +1,61 @@
+//go:build windows
+
+package exec
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+
+	"golang.org/x/sys/windows"
+)
+
+// processTree is the job object a command is assigned to, so that the processes it
+// starts, which are assigned to it as well, can be terminated along with it.
+type processTree struct {
+	job windows.Handle
+}
+
+// prepareProcessTree returns the process tree of cmd, whose job object is created once it started.
+func prepareProcessTree(_ *exec.Cmd) *processTree {
+	return &processTree{}
+}
+
+// attach assigns the started process to a job object of its own. The processes
+// it started before being assigned to it aren't part of its tree.
+func (t *processTree) attach(p *os.Process) error {
+	job, err := windows.CreateJobObject(nil, nil)
+	if err != nil {
+		return fmt.Errorf("creating a job object: %w", err)
+	}
+
+	process, err := windows.OpenProcess(windows.PROCESS_SET_QUOTA|windows.PROCESS_TERMINATE, false, uint32(p.Pid))
+	if err != nil {
+		_ = windows.CloseHandle(job)
+		return fmt.Errorf("opening process %d: %w", p.Pid, err)
+	}
+	defer windows.CloseHandle(process) //nolint:errcheck
+
+	if err := windows.AssignProcessToJobObject(job, process); err != nil {
+		_ = windows.CloseHandle(job)
+		return fmt.Errorf("assigning process %d to a job object: %w", p.Pid, err)
+	}
+
+	t.job = job
+	return nil
+}
+
+// signal terminates all the processes of the job object on SIGKILL. Other signals are
+// delivered to the console process group of the command instead, as sendSignal does.
+func (t *processTree) signal(name string) error {
+	if name != "SIGKILL" {
+		return fmt.Errorf("sending %s to a job object is not supported", name)
+	}
+
+	return windows.TerminateJobObject(t.job, 1)
+}
+
+// release closes the job object, its processes being left running.
+func (t *processTree) release() {
+	_ = windows.CloseHandle(t.job)
+}
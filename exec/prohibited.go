@@ -0,0 +1,72 @@
+package exec
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// disableEnvVar is the environment variable which, set to true, prohibits executing commands.
+const disableEnvVar = "K6_EXEC_DISABLE"
+
+// cloudEnvVar is an environment variable set on k6 Cloud load generators,
+// where executing local commands is prohibited.
+const cloudEnvVar = "K6_CLOUDRUN_INSTANCE_ID"
+
+// The behaviors of the module when executing commands is prohibited.
+const (
+	// prohibitedFail makes executions fail right away.
+	prohibitedFail = "fail"
+
+	// prohibitedWarn makes executions be skipped, logging a warning,
+	// and resolve with a result marked as skipped.
+	prohibitedWarn = "warn"
+
+	// prohibitedSkip makes executions be silently skipped,
+	// and resolve with a result marked as skipped.
+	prohibitedSkip = "skip"
+)
+
+// prohibitedReason returns why executing commands is prohibited in the current
+// environment, or by the configuration, or an empty string if it isn't.
+func prohibitedReason(cfg *moduleConfig) string {
+	if cfg.disabled {
+		return "disabled by the configuration"
+	}
+
+	if cfg.instanceUnknown {
+		return "the configuration is specific to instances, and the instance isn't known in the init context"
+	}
+
+	if disabled, _ := strconv.ParseBool(os.Getenv(disableEnvVar)); disabled {
+		return disableEnvVar + " is set"
+	}
+
+	if _, ok := os.LookupEnv(cloudEnvVar); ok {
+		return "running on k6 Cloud"
+	}
+
+	return ""
+}
+
+// prohibited returns the function completing an execution which is prohibited
+// for the provided reason, according to the whenProhibited configuration.
+func (c *Command) prohibited(reason string) (func() (*CommandResult, error), error) {
+	behavior := prohibitedFail
+	if cfg := c.config.resolve(c.vu); cfg.whenProhibited != "" {
+		behavior = cfg.whenProhibited
+	}
+
+	if behavior == prohibitedFail {
+		return nil, fmt.Errorf("executing %q is prohibited in this environment (%s); "+
+			"configure whenProhibited to skip commands instead", c.Name, reason)
+	}
+
+	if behavior == prohibitedWarn {
+		c.logger().Warnf("skipping %s, as executing commands is prohibited in this environment (%s)", c.Name, reason)
+	}
+
+	return func() (*CommandResult, error) {
+		return &CommandResult{ExitCode: -1, Skipped: true}, nil
+	}, nil
+}
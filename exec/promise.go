@@ -0,0 +1,44 @@
+package exec
+
+import (
+	"github.com/dop251/goja"
+	"go.k6.io/k6/js/modules"
+)
+
+// jsValuer is implemented by values that need the runtime to be converted to JS values.
+// Promises resolved with such values convert them on the event loop.
+type jsValuer interface {
+	toJSValue(rt *goja.Runtime) goja.Value
+}
+
+// makeHandledPromise will create a promise and return its resolve and reject methods,
+// wrapped in such a way that it will block the eventloop from exiting before they are
+// called even if the promise isn't resolved by the time the current script ends executing.
+func makeHandledPromise(vu modules.VU) (*goja.Promise, func(interface{}), func(interface{})) {
+	runtime := vu.Runtime()
+	callback := vu.RegisterCallback()
+	p, resolve, reject := runtime.NewPromise()
+
+	return p, func(i interface{}) {
+			// more stuff
+			callback(func() error {
+				resolve(toValue(runtime, i))
+				return nil
+			})
+		}, func(i interface{}) {
+			// more stuff
+			callback(func() error {
+				reject(toValue(runtime, i))
+				return nil
+			})
+		}
+}
+
+// toValue converts i to a JS value, using its toJSValue method if it implements jsValuer.
+func toValue(rt *goja.Runtime, i interface{}) goja.Value {
+	if v, ok := i.(jsValuer); ok {
+		return v.toJSValue(rt)
+	}
+
+	return rt.ToValue(i)
+}
@@ -0,0 +1,162 @@
+package exec
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+	"syscall"
+
+	"github.com/dop251/goja"
+	"go.k6.io/k6/js/common"
+)
+
+// The size of the pseudo-terminals commands are run in, unless set by the pty option.
+const (
+	defaultTerminalCols = 80
+	defaultTerminalRows = 24
+)
+
+// terminalEOF is the character signaling the end of the input to programs reading a terminal
+// in canonical mode, written to the terminal once the input is closed, ^D.
+const terminalEOF = 0x04
+
+// terminalSize is the size of a pseudo-terminal, in characters.
+type terminalSize struct {
+	cols uint16
+	rows uint16
+}
+
+// parseTerminalSize parses the pty option, either a boolean, or an object holding the
+// size of the pseudo-terminal as cols and rows. It returns nil if it is false.
+func parseTerminalSize(rt *goja.Runtime, v goja.Value) (*terminalSize, error) {
+	size := &terminalSize{cols: defaultTerminalCols, rows: defaultTerminalRows}
+	if _, ok := v.Export().(bool); ok || common.IsNullish(v) {
+		if !v.ToBoolean() {
+			return nil, nil
+		}
+
+		return size, nil
+	}
+
+	obj := v.ToObject(rt)
+	for _, key := range obj.Keys() {
+		value := obj.Get(key).ToInteger()
+		if value <= 0 || value > 0xffff {
+			return nil, fmt.Errorf("pty.%s must be a positive number of characters", key)
+		}
+
+		switch key {
+		case "cols":
+			size.cols = uint16(value)
+		case "rows":
+			size.rows = uint16(value)
+		default:
+			return nil, fmt.Errorf("unknown pty option %q; expected cols or rows", key)
+		}
+	}
+
+	return size, nil
+}
+
+// terminal is a pseudo-terminal a command is run in. The command's standard streams are
+// all wired to its slave end, while its master end is read from, and written to, by k6.
+type terminal struct {
+	master *os.File
+	slave  *os.File
+}
+
+// outputPipe returns the pipe the command's output, standard output and standard error
+// merged, is read from, through the master end of the terminal. Its write end is the
+// slave end, which the command holds once started, and which is released the same way
+// the write ends of the OS pipes commands write to straight are.
+func (t *terminal) outputPipe() *outputPipe {
+	return &outputPipe{r: terminalReader{r: deadlineReader{r: t.master}}, w: t.slave, file: t.master}
+}
+
+// input returns the writer the command's input is written to, through the master end of the terminal.
+func (t *terminal) input() io.WriteCloser {
+	return &terminalInput{File: t.master}
+}
+
+// terminalReader is an io.Reader reading from the master end of a pseudo-terminal, which
+// reaches EOF once the slave end was closed by all the processes holding it, rather than
+// failing with EIO, as it does on Linux.
+type terminalReader struct {
+	r io.Reader
+}
+
+// Read implements the io.Reader interface.
+func (tr terminalReader) Read(p []byte) (int, error) {
+	n, err := tr.r.Read(p)
+	if errors.Is(err, syscall.EIO) {
+		err = io.EOF
+	}
+
+	return n, err
+}
+
+// terminalInput is the writer a command's input is written to, through the master end of
+// its pseudo-terminal. Closing it writes the end-of-file character to the terminal rather
+// than closing the master end, which would hang the terminal up, and which the command's
+// output is still read from.
+type terminalInput struct {
+	*os.File
+
+	closeOnce sync.Once
+}
+
+// Close implements the io.Closer interface.
+func (ti *terminalInput) Close() error {
+	var err error
+	ti.closeOnce.Do(func() { _, err = ti.File.Write([]byte{terminalEOF}) })
+
+	if errors.Is(err, os.ErrClosed) || errors.Is(err, syscall.EIO) {
+		return nil
+	}
+
+	return err
+}
+
+// validateTerminal returns an error if the command can't be run in a pseudo-terminal.
+func (c *Command) validateTerminal(opts *execOptions) error {
+	switch {
+	case c.stdoutFile != nil || c.stderrFile != nil:
+		return errors.New("the output of commands run in a pseudo-terminal can't be redirected to files")
+	case c.stage != nil:
+		return errors.New("the stages of pipelines can't be run in a pseudo-terminal")
+	case c.azure != nil || c.powerShell != nil:
+		return errors.New("commands run on Azure virtual machines, and PowerShell pipelines, can't be run in a pseudo-terminal")
+	case c.sudo != nil && c.sudo.PasswordEnv != "":
+		return errors.New("a password can't be fed to sudo for commands run in a pseudo-terminal, as it reads it from the terminal")
+	case opts.isolation != nil:
+		return errors.New("sandboxed commands can't be run in a pseudo-terminal")
+	default:
+		return nil
+	}
+}
+
+// openTerminal opens the pseudo-terminal cmd is run in, and attaches cmd to it. It
+// returns the pipe the merged output of cmd is read from.
+func (c *Command) openTerminal(cmd *exec.Cmd, opts *execOptions) (*outputPipe, error) {
+	if err := c.validateTerminal(opts); err != nil {
+		return nil, err
+	}
+
+	// The input of commands executed with Exec isn't written to the terminal: they are rather
+	// spawned to be driven interactively.
+	if c.stdinSource(opts) != nil {
+		return nil, errors.New("the stdin option isn't supported by commands run in a pseudo-terminal; " +
+			"spawn them and write to their stdin instead")
+	}
+
+	t, err := openTerminal(*opts.pty)
+	if err != nil {
+		return nil, err
+	}
+	t.attach(cmd)
+
+	return t.outputPipe(), nil
+}
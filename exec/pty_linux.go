@@ -0,0 +1,93 @@
+//go:build linux
+
+package exec
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// openTerminal opens a new pseudo-terminal of the provided size.
+func openTerminal(size terminalSize) (*terminal, error) {
+	master, err := os.OpenFile("/dev/ptmx", os.O_RDWR|unix.O_NOCTTY, 0)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open a pseudo-terminal: %w", err)
+	}
+
+	var n uint32
+	err = control(master, func(fd int) error {
+		if err := unix.IoctlSetPointerInt(fd, unix.TIOCSPTLCK, 0); err != nil {
+			return fmt.Errorf("unable to unlock the pseudo-terminal: %w", err)
+		}
+
+		var err error
+		if n, err = unix.IoctlGetUint32(fd, unix.TIOCGPTN); err != nil {
+			return fmt.Errorf("unable to get the number of the pseudo-terminal: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		_ = master.Close()
+		return nil, err
+	}
+
+	slave, err := os.OpenFile(fmt.Sprintf("/dev/pts/%d", n), os.O_RDWR|unix.O_NOCTTY, 0)
+	if err != nil {
+		_ = master.Close()
+		return nil, fmt.Errorf("unable to open the pseudo-terminal: %w", err)
+	}
+
+	t := &terminal{master: master, slave: slave}
+	if err := t.resize(size); err != nil {
+		closeFiles(master, slave)
+		return nil, err
+	}
+
+	return t, nil
+}
+
+// resize sets the size of the terminal, which sends SIGWINCH to the processes running in it.
+func (t *terminal) resize(size terminalSize) error {
+	ws := &unix.Winsize{Col: size.cols, Row: size.rows}
+	err := control(t.master, func(fd int) error { return unix.IoctlSetWinsize(fd, unix.TIOCSWINSZ, ws) })
+	if err != nil {
+		return fmt.Errorf("unable to resize the pseudo-terminal: %w", err)
+	}
+
+	return nil
+}
+
+// control calls fn with the file descriptor of f. Unlike using Fd, it leaves f
+// in non-blocking mode, so that deadlines can still be set on its reads.
+func control(f *os.File, fn func(fd int) error) error {
+	conn, err := f.SyscallConn()
+	if err != nil {
+		return err
+	}
+
+	var fnErr error
+	if err := conn.Control(func(fd uintptr) { fnErr = fn(int(fd)) }); err != nil {
+		return err
+	}
+
+	return fnErr
+}
+
+// attach wires the standard streams of cmd to the slave end of the terminal, and makes
+// cmd be started in a new session whose controlling terminal it is, so that programs
+// reading /dev/tty, such as ssh prompting for a password, read from it too.
+func (t *terminal) attach(cmd *exec.Cmd) {
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = t.slave, t.slave, t.slave
+
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setsid = true
+	cmd.SysProcAttr.Setctty = true
+	cmd.SysProcAttr.Ctty = 0
+}
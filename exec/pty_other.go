@@ -0,0 +1,21 @@
+//go:build !linux
+
+package exec
+
+import (
+	"errors"
+	"os/exec"
+)
+
+// openTerminal returns an error on platforms other than Linux, where pseudo-terminals aren't supported.
+func openTerminal(_ terminalSize) (*terminal, error) {
+	return nil, errors.New("running commands in a pseudo-terminal is only supported on Linux")
+}
+
+// resize is never called on platforms other than Linux, as no terminal can be opened.
+func (t *terminal) resize(_ terminalSize) error {
+	return nil
+}
+
+// attach is never called on platforms other than Linux, as no terminal can be opened.
+func (t *terminal) attach(_ *exec.Cmd) {}
@@ -0,0 +1,42 @@
+//go:build linux
+
+package exec
+
+import (
+	"fmt"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// mountReadOnly moves the calling thread to a new mount namespace, where dir, or the
+// current working directory if empty, is bind mounted read-only onto itself.
+func mountReadOnly(dir string) error {
+	if dir == "" {
+		var err error
+		if dir, err = os.Getwd(); err != nil {
+			return err
+		}
+	}
+
+	if err := unix.Unshare(unix.CLONE_NEWNS); err != nil {
+		return fmt.Errorf("unable to create a mount namespace: %w", err)
+	}
+
+	// Mounts are made private first, so that the bind mount doesn't propagate to the host.
+	if err := unix.Mount("", "/", "", unix.MS_REC|unix.MS_PRIVATE, ""); err != nil {
+		return fmt.Errorf("unable to make mounts private: %w", err)
+	}
+
+	if err := unix.Mount(dir, dir, "", unix.MS_BIND|unix.MS_REC, ""); err != nil {
+		return fmt.Errorf("unable to bind mount %s: %w", dir, err)
+	}
+
+	if err := unix.Mount("", dir, "", unix.MS_BIND|unix.MS_REMOUNT|unix.MS_RDONLY, ""); err != nil {
+		return fmt.Errorf("unable to remount %s read-only: %w", dir, err)
+	}
+
+	// The thread's working directory still refers to the directory as it was before being
+	// mounted over, and is thus changed to the read-only mount, for the command to inherit.
+	return unix.Chdir(dir)
+}
@@ -0,0 +1,38 @@
+package exec
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/dop251/goja"
+)
+
+// toRegexp compiles v, either a JS RegExp object or a string holding a pattern,
+// into a Go regular expression. The i, m and s flags of RegExp objects are
+// honored; note that the pattern itself must follow the Go regexp syntax.
+func toRegexp(v goja.Value) (*regexp.Regexp, error) {
+	pattern := v.String()
+
+	if obj, ok := v.(*goja.Object); ok && obj.ClassName() == "RegExp" {
+		pattern = obj.Get("source").String()
+
+		var flags string
+		for _, flag := range obj.Get("flags").String() {
+			if strings.ContainsRune("ims", flag) {
+				flags += string(flag)
+			}
+		}
+
+		if flags != "" {
+			pattern = "(?" + flags + ")" + pattern
+		}
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid regular expression %q: %w", pattern, err)
+	}
+
+	return re, nil
+}
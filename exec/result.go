@@ -0,0 +1,326 @@
+package exec
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+
+	"github.com/dop251/goja"
+	"go.k6.io/k6/js/common"
+)
+
+// CommandResult holds the result of a command execution.
+type CommandResult struct {
+	ExitCode int    `js:"exitCode"`
+	Stdout   string `js:"stdout"`
+	Stderr   string `js:"stderr"`
+
+	// StdoutTruncated and StderrTruncated are true if the output streams produced more than
+	// maxOutputBytes bytes, in which case only the first maxOutputBytes bytes were captured.
+	StdoutTruncated bool `js:"stdoutTruncated"`
+	StderrTruncated bool `js:"stderrTruncated"`
+
+	// StdoutFile and StderrFile are the paths of the files the output streams were redirected
+	// to, using StdoutToFile and StderrToFile, placeholders expanded, and StdoutFileBytes and
+	// StderrFileBytes the amount of bytes written to them.
+	StdoutFile      string `js:"stdoutFile"`
+	StderrFile      string `js:"stderrFile"`
+	StdoutFileBytes int64  `js:"stdoutFileBytes"`
+	StderrFileBytes int64  `js:"stderrFileBytes"`
+
+	// Pid is the process ID the command was executed with.
+	Pid int `js:"pid"`
+
+	// StartedAt and FinishedAt are the times the command was started, and exited, at, in
+	// milliseconds since the Unix epoch, as returned by Date.now, and Duration how long
+	// it ran for, in milliseconds.
+	StartedAt  int64   `js:"startedAt"`
+	FinishedAt int64   `js:"finishedAt"`
+	Duration   float64 `js:"duration"`
+
+	// Signaled is true if the command was terminated by a signal, and Signal
+	// is the name of the signal, as in the errors executions fail with.
+	Signaled bool   `js:"signaled"`
+	Signal   string `js:"signal"`
+
+	// Success is true if the command exited with a zero exit code.
+	Success bool `js:"success"`
+
+	// TimedOut is true if the command was terminated for exceeding its timeout, or idle timeout.
+	TimedOut bool `js:"timedOut"`
+
+	// StdoutChecksum and StderrChecksum hold the hex encoded checksums
+	// of the output streams, when enabled using Checksum.
+	StdoutChecksum string `js:"stdoutChecksum"`
+	StderrChecksum string `js:"stderrChecksum"`
+
+	// CoreDumped is true if the command crashed and dumped core. When using the
+	// collect core dump policy, CorePath holds the path of the core dump if it
+	// could be determined.
+	CoreDumped bool   `js:"coreDumped"`
+	CorePath   string `js:"corePath"`
+
+	// OOMKilled is true if the command was killed by the OOM killer.
+	// OOM kills are only detected on Linux.
+	OOMKilled bool `js:"oomKilled"`
+
+	// CPUUserTime and CPUSystemTime are the user and system CPU time the command consumed,
+	// in milliseconds, and MaxRSS its maximum resident set size, in bytes, zero if it is
+	// unknown, as on Windows. They include the descendants the command waited for.
+	CPUUserTime   float64 `js:"cpuUserTime"`
+	CPUSystemTime float64 `js:"cpuSystemTime"`
+	MaxRSS        int64   `js:"maxRss"`
+
+	// Outcome is the named outcome the exit code maps to,
+	// when an outcomes mapping was provided.
+	Outcome string `js:"outcome"`
+
+	// Attempts is the number of times the command was executed, more than one if it was retried.
+	Attempts int `js:"attempts"`
+
+	// Skipped is true if the command wasn't executed, as executing
+	// commands is prohibited in the environment.
+	Skipped bool `js:"skipped"`
+
+	// Data holds the output objects of PowerShell pipelines, deserialized.
+	Data interface{} `js:"data"`
+
+	// RunID uniquely identifies the execution of the command. It is also set in the
+	// environment of the command, as K6_EXEC_RUN_ID, and in its transcript, if any.
+	RunID string `js:"runId"`
+
+	// stdout and stderr hold the captured output when it is only
+	// materialized into JS strings once accessed.
+	stdout *capturedOutput
+	stderr *capturedOutput
+
+	// encoding is the encoding the output is exposed in, or arraybuffer if
+	// it is exposed as ArrayBuffers.
+	encoding string
+
+	// steps holds the results of the chained commands which were executed, if any.
+	steps []*CommandResult
+
+	// stages holds the results of the stages of the pipeline which was executed, if any.
+	stages []*CommandResult
+}
+
+// Ensure the interfaces are implemented correctly
+var _ jsValuer = &CommandResult{}
+
+// newCommandResult builds the result of a command execution out of its
+// exit code and the output captured from its streams. Compressed output
+// is always materialized lazily, as well as output exposed as ArrayBuffers,
+// which can't be held in the fields of the result.
+func newCommandResult(
+	exitCode int,
+	stdout, stderr capturedOutput,
+	stdoutCapture, stderrCapture streamCapture,
+	opts *execOptions,
+) *CommandResult {
+	result := &CommandResult{
+		ExitCode:       exitCode,
+		Success:        exitCode == 0,
+		Attempts:       1,
+		StdoutChecksum: hexSum(stdoutCapture.hash),
+		StderrChecksum: hexSum(stderrCapture.hash),
+		encoding:       opts.outputEncoding,
+
+		StdoutTruncated: stdout.truncated,
+		StderrTruncated: stderr.truncated,
+
+		StdoutFileBytes: stdout.fileBytes,
+		StderrFileBytes: stderr.fileBytes,
+	}
+
+	if stdoutCapture.file != nil {
+		result.StdoutFile = stdoutCapture.file.Name()
+	}
+	if stderrCapture.file != nil {
+		result.StderrFile = stderrCapture.file.Name()
+	}
+
+	if opts.lazyOutput || stdout.compressed || stderr.compressed || result.encoding == outputArrayBuffer {
+		result.stdout, result.stderr = &stdout, &stderr
+	} else {
+		result.Stdout = encodeOutput(stdout.data, result.encoding)
+		result.Stderr = encodeOutput(stderr.data, result.encoding)
+	}
+
+	return result
+}
+
+// Text returns the command's standard output, with leading and trailing white space removed.
+func (r *CommandResult) Text() (string, error) {
+	stdout, err := r.stdoutString()
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(stdout), nil
+}
+
+// Lines returns the non-empty lines of the command's standard output,
+// without their line endings.
+func (r *CommandResult) Lines() ([]string, error) {
+	stdout, err := r.stdoutString()
+	if err != nil {
+		return nil, err
+	}
+
+	lines := make([]string, 0)
+	for _, line := range strings.Split(stdout, "\n") {
+		line = strings.TrimSuffix(line, "\r")
+		if strings.TrimSpace(line) != "" {
+			lines = append(lines, line)
+		}
+	}
+
+	return lines, nil
+}
+
+// Kv parses the command's standard output as KEY=VALUE lines, such as the output of env,
+// into an object. Blank lines and comments are ignored, and quoted values are unquoted.
+func (r *CommandResult) Kv() (map[string]string, error) {
+	stdout, err := r.stdoutString()
+	if err != nil {
+		return nil, err
+	}
+
+	return parseKeyValues(stdout), nil
+}
+
+// Table parses the command's standard output as a whitespace-aligned table, such as the output
+// of df or kubectl get, into an array of objects keyed by the column names of its first line.
+func (r *CommandResult) Table() ([]map[string]string, error) {
+	stdout, err := r.stdoutString()
+	if err != nil {
+		return nil, err
+	}
+
+	return parseTable(stdout), nil
+}
+
+// Yaml parses the command's standard output as YAML, such as the output of kubectl
+// -o yaml or helm. A stream of several documents is parsed into an array of them.
+func (r *CommandResult) Yaml() (interface{}, error) {
+	stdout, err := r.stdoutString()
+	if err != nil {
+		return nil, err
+	}
+
+	return parseYAML(stdout)
+}
+
+// stdoutString returns the command's standard output, materializing it if needed.
+func (r *CommandResult) stdoutString() (string, error) {
+	if r.stdout == nil {
+		return r.Stdout, nil
+	}
+
+	b, err := r.stdout.bytes()
+	if err != nil {
+		return "", err
+	}
+
+	return encodeOutput(b, r.encoding), nil
+}
+
+// outputEncodings holds the names of the supported output encodings.
+var outputEncodings = []string{"utf8", "base64", "hex", "latin1"} //nolint:gochecknoglobals
+
+// outputArrayBuffer is the value of the output option exposing the output as ArrayBuffers.
+const outputArrayBuffer = "arraybuffer"
+
+// encodeOutput returns b as a string, encoded using the named encoding. Output exposed
+// as ArrayBuffers is decoded as UTF-8 by the methods parsing it, such as Text.
+func encodeOutput(b []byte, encoding string) string {
+	switch encoding {
+	case "base64":
+		return base64.StdEncoding.EncodeToString(b)
+	case "hex":
+		return hex.EncodeToString(b)
+	case "latin1":
+		runes := make([]rune, len(b))
+		for i, c := range b {
+			runes[i] = rune(c)
+		}
+
+		return string(runes)
+	default:
+		return string(b)
+	}
+}
+
+// outputValue returns b as a JS value, either an ArrayBuffer, or a string encoded using the named encoding.
+func outputValue(rt *goja.Runtime, b []byte, encoding string) goja.Value {
+	if encoding == outputArrayBuffer {
+		return rt.ToValue(rt.NewArrayBuffer(append([]byte(nil), b...)))
+	}
+
+	return rt.ToValue(encodeOutput(b, encoding))
+}
+
+// toJSValue implements the jsValuer interface. When the output is materialized
+// lazily, the result is exposed as a plain object whose stdout and stderr
+// properties are accessors converting the captured output on first access.
+// The result of chained commands also holds the results of each of them, as steps,
+// and the result of pipelines the results of each of their stages, as stages.
+func (r *CommandResult) toJSValue(rt *goja.Runtime) goja.Value {
+	if r.stdout == nil && len(r.steps) == 0 && len(r.stages) == 0 {
+		return rt.ToValue(r)
+	}
+
+	wrapped := rt.ToValue(r).ToObject(rt)
+	obj := rt.NewObject()
+	for _, key := range wrapped.Keys() {
+		if err := obj.Set(key, wrapped.Get(key)); err != nil {
+			common.Throw(rt, err)
+		}
+	}
+
+	if r.stdout != nil {
+		defineLazyOutput(rt, obj, "stdout", r.stdout, r.encoding)
+		defineLazyOutput(rt, obj, "stderr", r.stderr, r.encoding)
+	}
+
+	if len(r.steps) > 0 {
+		if err := obj.Set("steps", r.stepsJSValue(rt)); err != nil {
+			common.Throw(rt, err)
+		}
+	}
+
+	if len(r.stages) > 0 {
+		if err := obj.Set("stages", r.stagesJSValue(rt)); err != nil {
+			common.Throw(rt, err)
+		}
+	}
+
+	return obj
+}
+
+// defineLazyOutput defines an enumerable accessor property named name on obj, converting
+// the captured output to a string, or an ArrayBuffer, on first access and caching it afterwards.
+func defineLazyOutput(rt *goja.Runtime, obj *goja.Object, name string, output *capturedOutput, encoding string) {
+	var (
+		value   goja.Value
+		decoded bool
+	)
+
+	getter := rt.ToValue(func() goja.Value {
+		if !decoded {
+			b, err := output.bytes()
+			if err != nil {
+				common.Throw(rt, err)
+			}
+
+			value, decoded = outputValue(rt, b, encoding), true
+		}
+
+		return value
+	})
+
+	if err := obj.DefineAccessorProperty(name, getter, nil, goja.FLAG_FALSE, goja.FLAG_TRUE); err != nil {
+		common.Throw(rt, err)
+	}
+}
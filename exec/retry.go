@@ -0,0 +1,156 @@
+package exec
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/dop251/goja"
+	"go.k6.io/k6/js/common"
+	"go.k6.io/k6/lib/types"
+)
+
+// retryPolicy configures how a command whose execution failed is retried.
+type retryPolicy struct {
+	// attempts is the maximum number of times the command is executed, the first one included.
+	attempts int
+
+	// backoff is how long is waited for between two attempts.
+	backoff time.Duration
+
+	// exitCodes are the exit codes the command is retried on, any non-zero one if empty.
+	exitCodes []int
+}
+
+// Retry returns a copy of the command retried when its execution fails, configured by an object
+// whose attempts key is the maximum number of times it is executed, the first one included, whose
+// backoff key is how long is waited for between two attempts, none by default, and whose
+// retryOnExitCodes key lists the exit codes it is retried on, any non-zero one by default. Each
+// attempt is measured separately, its metrics being tagged with its number as attempt, and the
+// result reports the number of attempts made. Retries apply to Exec and ExecSync.
+func (c Command) Retry(options goja.Value) Command {
+	rt := c.vu.Runtime()
+
+	if common.IsNullish(options) {
+		common.Throw(rt, errors.New("retry expects an object with an attempts key"))
+	}
+
+	policy := &retryPolicy{}
+	obj := options.ToObject(rt)
+	for _, key := range obj.Keys() {
+		value := obj.Get(key)
+
+		switch key {
+		case "attempts":
+			policy.attempts = int(value.ToInteger())
+			if policy.attempts < 1 {
+				common.Throw(rt, errors.New("invalid retry attempts; expected a positive number"))
+			}
+		case "backoff":
+			backoff, err := types.GetDurationValue(value.Export())
+			if err != nil || backoff < 0 {
+				common.Throw(rt, errors.New("invalid retry backoff; expected a duration, such as \"500ms\""))
+			}
+			policy.backoff = backoff
+		case "retryOnExitCodes":
+			if err := rt.ExportTo(value, &policy.exitCodes); err != nil {
+				common.Throw(rt, fmt.Errorf("invalid retryOnExitCodes; expected an array of exit codes: %w", err))
+			}
+		default:
+			common.Throw(rt, fmt.Errorf("unknown retry option %q; expected attempts, backoff or retryOnExitCodes", key))
+		}
+	}
+
+	if policy.attempts == 0 {
+		common.Throw(rt, errors.New("retry expects an object with an attempts key"))
+	}
+
+	c.retry = policy
+	return c
+}
+
+// retries reports whether an execution of the command exiting with exitCode is retried.
+func (p *retryPolicy) retries(exitCode int) bool {
+	if len(p.exitCodes) == 0 {
+		return exitCode != 0
+	}
+
+	for _, code := range p.exitCodes {
+		if code == exitCode {
+			return true
+		}
+	}
+
+	return false
+}
+
+// failedExitCode returns the exit code of the attempt which completed with result or err, and
+// whether it exited at all, as opposed to failing to start, or to be waited for.
+func failedExitCode(result *CommandResult, err error) (int, bool) {
+	var execErr *ExecError
+	switch {
+	case err == nil:
+		return result.ExitCode, !result.Skipped
+	case errors.As(err, &execErr):
+		return execErr.ExitCode, true
+	default:
+		return 0, false
+	}
+}
+
+// execWithRetries executes the command, on the event loop, retrying it according to its retry
+// policy, after the backoff, while its attempts exit with an exit code it is retried on. The
+// promise is settled with the outcome of the last attempt.
+func (c *Command) execWithRetries(opts *execOptions, resolve, reject func(interface{})) {
+	var attempt func(n int)
+	attempt = func(n int) {
+		attemptCmd := *c
+		attemptCmd.attempt = n
+
+		complete, err := attemptCmd.run(opts)
+		if err != nil {
+			reject(c.rejection(err))
+			return
+		}
+
+		callback := c.vu.RegisterCallback()
+		go func() {
+			result, err := complete()
+
+			exitCode, exited := failedExitCode(result, err)
+			if exited && n < c.retry.attempts && c.retry.retries(exitCode) && c.waitBackoff() {
+				attemptCmd.pushRetry(c.vu.Context(), c.vu.State(), exitCode, time.Now())
+				callback(func() error {
+					attempt(n + 1)
+					return nil
+				})
+				return
+			}
+
+			callback(func() error { return nil })
+
+			if err != nil {
+				reject(c.rejection(err))
+				return
+			}
+
+			result.Attempts = n
+			resolve(result)
+		}()
+	}
+
+	attempt(1)
+}
+
+// waitBackoff waits for the backoff of the retry policy, and reports
+// whether it elapsed, rather than the VU context being done.
+func (c *Command) waitBackoff() bool {
+	ctx := c.vu.Context()
+
+	select {
+	case <-time.After(c.retry.backoff):
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
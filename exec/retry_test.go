@@ -0,0 +1,75 @@
+//go:build !windows
+
+package exec
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.k6.io/k6/lib"
+)
+
+func TestCommandRetry(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		// exitCodes are the codes the successive attempts exit with, the last one being repeated.
+		exitCodes []int
+		retry     string
+		method    string
+		want      string
+		attempts  int
+	}{
+		{name: "succeeding", exitCodes: []int{1, 1, 0}, retry: `{ attempts: 3 }`, want: `[0,3]`, attempts: 3},
+		{name: "synchronously", exitCodes: []int{1, 0}, retry: `{ attempts: 3 }`, method: "execSync", want: `[0,2]`, attempts: 2},
+		{name: "exhausted", exitCodes: []int{1}, retry: `{ attempts: 2, backoff: "10ms" }`, want: `[1,2]`, attempts: 2},
+		{name: "exit code not retried", exitCodes: []int{2}, retry: `{ attempts: 3, retryOnExitCodes: [1] }`, want: `[2,1]`, attempts: 1},
+		{name: "succeeding at once", exitCodes: []int{0}, retry: `{ attempts: 3 }`, want: `[0,1]`, attempts: 1},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			// Each attempt appends a line to the file, and exits with the code of the line it appended.
+			runs := filepath.Join(t.TempDir(), "runs")
+			codes := make([]string, len(tt.exitCodes))
+			for i, code := range tt.exitCodes {
+				codes[i] = fmt.Sprint(code)
+			}
+			script := fmt.Sprintf(`echo >> %[1]s; n=$(wc -l < %[1]s); set -- %[2]s; shift $(( n < $# ? n - 1 : $# - 1 )); exit $1`,
+				runs, strings.Join(codes, " "))
+
+			method := tt.method
+			if method == "" {
+				method = "exec"
+			}
+
+			vu := newTestVU(t, nil)
+			vu.moveToVUContext(lib.Options{})
+
+			got := vu.mustRun(fmt.Sprintf(`Promise.resolve(new exec.Cmd("sh").args(["-c", %q]).retry(%s).%s())
+				.then((r) => JSON.stringify([r.exitCode, r.attempts]))`, script, tt.retry, method)).String()
+			if got != tt.want {
+				t.Errorf("the result's exit code and attempts are %s, want %s", got, tt.want)
+			}
+
+			data, err := os.ReadFile(runs)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if n := strings.Count(string(data), "\n"); n != tt.attempts {
+				t.Errorf("the command was executed %d times, want %d", n, tt.attempts)
+			}
+
+			if retries := len(vu.samplesOf("exec_command_retries")); retries != tt.attempts-1 {
+				t.Errorf("%d exec_command_retries samples were pushed, want %d", retries, tt.attempts-1)
+			}
+		})
+	}
+}
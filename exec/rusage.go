@@ -0,0 +1,39 @@
+package exec
+
+import (
+	"os"
+	"time"
+)
+
+// resourceUsage holds the resources a command consumed, as reported once it exited.
+type resourceUsage struct {
+	// known is set if the resource usage could be read.
+	known bool
+
+	userTime   time.Duration
+	systemTime time.Duration
+
+	// maxRSS is the maximum resident set size of the command, in bytes, or zero if unknown.
+	maxRSS int64
+}
+
+// readResourceUsage returns the resources consumed by the process whose state is provided,
+// including the descendants it waited for.
+func readResourceUsage(state *os.ProcessState) resourceUsage {
+	if state == nil {
+		return resourceUsage{}
+	}
+
+	return resourceUsage{
+		known:      true,
+		userTime:   state.UserTime(),
+		systemTime: state.SystemTime(),
+		maxRSS:     maxRSS(state),
+	}
+}
+
+// milliseconds returns d as a floating point number of milliseconds, preserving
+// the sub-millisecond precision CPU times of short-lived commands require.
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
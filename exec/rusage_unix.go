@@ -0,0 +1,24 @@
+//go:build !windows
+
+package exec
+
+import (
+	"os"
+	"runtime"
+	"syscall"
+)
+
+// maxRSS returns the maximum resident set size of the process whose state is provided, in bytes.
+// It is reported in bytes on macOS, and in kilobytes on the other Unix systems.
+func maxRSS(state *os.ProcessState) int64 {
+	usage, ok := state.SysUsage().(*syscall.Rusage)
+	if !ok {
+		return 0
+	}
+
+	if runtime.GOOS == "darwin" {
+		return int64(usage.Maxrss)
+	}
+
+	return int64(usage.Maxrss) * 1024
+}
@@ -0,0 +1,10 @@
+//go:build windows
+
+package exec
+
+import "os"
+
+// maxRSS returns zero on Windows, where the memory usage of exited processes isn't reported.
+func maxRSS(_ *os.ProcessState) int64 {
+	return 0
+}
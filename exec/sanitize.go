@@ -0,0 +1,41 @@
+package exec
+
+import "strings"
+
+// sensitiveEnvVars holds the names of the well-known environment variables holding
+// credentials, which are not inherited by commands unless explicitly set using Env.
+var sensitiveEnvVars = []string{ //nolint:gochecknoglobals
+	"AWS_ACCESS_KEY_ID",
+	"AWS_SECRET_ACCESS_KEY",
+	"AWS_SESSION_TOKEN",
+	"AZURE_CLIENT_SECRET",
+	"AZURE_CLIENT_CERTIFICATE_PASSWORD",
+	"GOOGLE_APPLICATION_CREDENTIALS",
+	"GITHUB_TOKEN",
+	"GH_TOKEN",
+	"GITLAB_TOKEN",
+	"CI_JOB_TOKEN",
+	"NPM_TOKEN",
+	"DOCKER_PASSWORD",
+	"DOCKER_AUTH_CONFIG",
+	"KUBECONFIG",
+	"VAULT_TOKEN",
+	"DIGITALOCEAN_ACCESS_TOKEN",
+	"HEROKU_API_KEY",
+	"SLACK_TOKEN",
+	"K6_CLOUD_TOKEN",
+}
+
+// sanitizeEnviron returns environ, a list of KEY=value pairs, without
+// the variables holding credentials, but the kept ones.
+func sanitizeEnviron(environ []string, kept ...string) []string {
+	sanitized := make([]string, 0, len(environ))
+	for _, kv := range environ {
+		key, _, _ := strings.Cut(kv, "=")
+		if !contains(sensitiveEnvVars, key) || contains(kept, key) {
+			sanitized = append(sanitized, kv)
+		}
+	}
+
+	return sanitized
+}
@@ -0,0 +1,162 @@
+package exec
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dop251/goja"
+	"go.k6.io/k6/lib/types"
+)
+
+// scratchDirEnvVar is the environment variable holding the path
+// of the scratch directory created for an execution.
+const scratchDirEnvVar = "K6_EXEC_SCRATCH_DIR"
+
+// defaultQuotaCheckInterval is the default interval at which
+// the size of scratch directories is checked.
+const defaultQuotaCheckInterval = time.Second
+
+// byteSizePattern matches sizes, such as 512MB or 1.5GiB.
+var byteSizePattern = regexp.MustCompile(`^(\d+(?:\.\d+)?)\s*([KMGT]i?B|B)?$`) //nolint:gochecknoglobals
+
+// byteSizeUnits maps the supported size units to their number of bytes.
+var byteSizeUnits = map[string]float64{ //nolint:gochecknoglobals
+	"": 1, "B": 1,
+	"KB": 1e3, "MB": 1e6, "GB": 1e9, "TB": 1e12,
+	"KiB": 1 << 10, "MiB": 1 << 20, "GiB": 1 << 30, "TiB": 1 << 40,
+}
+
+// scratchDir describes the scratch directory created for each execution of a command.
+type scratchDir struct {
+	// quota, if not zero, is the size in bytes the directory's content
+	// can't exceed without the command being killed.
+	quota int64
+	// checkInterval is the interval at which the directory's size is checked.
+	checkInterval time.Duration
+}
+
+// parseScratchDir parses the scratchDir option, either true, or an object holding
+// the quota of the directory, and the interval at which it is enforced.
+func parseScratchDir(rt *goja.Runtime, v goja.Value) (*scratchDir, error) {
+	dir := &scratchDir{checkInterval: defaultQuotaCheckInterval}
+	if b, ok := v.Export().(bool); ok {
+		if !b {
+			return nil, nil //nolint:nilnil
+		}
+		return dir, nil
+	}
+
+	obj := v.ToObject(rt)
+	for _, key := range obj.Keys() {
+		value := obj.Get(key)
+
+		switch key {
+		case "quota":
+			quota, err := parseByteSize(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid scratchDir quota: %w", err)
+			}
+			dir.quota = quota
+		case "checkInterval":
+			interval, err := types.GetDurationValue(value.Export())
+			if err != nil {
+				return nil, fmt.Errorf("invalid scratchDir checkInterval: %w", err)
+			}
+			if interval <= 0 {
+				return nil, fmt.Errorf("invalid scratchDir checkInterval %s; expected a positive duration", interval)
+			}
+			dir.checkInterval = interval
+		default:
+			return nil, fmt.Errorf("unknown scratchDir option %q", key)
+		}
+	}
+
+	return dir, nil
+}
+
+// parseByteSize parses a size, either a number of bytes, or a string such
+// as 512MB or 1GiB, using decimal or binary units.
+func parseByteSize(v goja.Value) (int64, error) {
+	m := byteSizePattern.FindStringSubmatch(strings.TrimSpace(v.String()))
+	if m == nil {
+		return 0, fmt.Errorf("%q is not a size; expected a number of bytes, or a size such as 512MB", v.String())
+	}
+
+	n, err := strconv.ParseFloat(m[1], 64)
+	if err != nil {
+		return 0, err
+	}
+
+	return int64(n * byteSizeUnits[m[2]]), nil
+}
+
+// createScratchDir creates the execution's scratch directory, and points
+// the command to it through its environment.
+func (e *execution) createScratchDir() error {
+	path, err := os.MkdirTemp("", "k6-exec-")
+	if err != nil {
+		return fmt.Errorf("unable to create a scratch directory: %w", err)
+	}
+
+	e.scratch = path
+	for _, key := range []string{scratchDirEnvVar, "TMPDIR", "TEMP", "TMP"} {
+		e.cmd.Env = append(e.cmd.Env, key+"="+path)
+	}
+
+	return nil
+}
+
+// removeScratchDir removes the execution's scratch directory, if any.
+func (e *execution) removeScratchDir() {
+	if e.scratch == "" {
+		return
+	}
+
+	if err := os.RemoveAll(e.scratch); err != nil {
+		e.logger().WithError(err).Warnf("unable to remove the scratch directory %s", e.scratch)
+	}
+}
+
+// enforceQuota periodically checks the size of the scratch directory's
+// content, and kills the command once it exceeds its quota.
+func (e *execution) enforceQuota() {
+	ticker := time.NewTicker(e.opts.scratchDir.checkInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if dirSize(e.scratch) > e.opts.scratchDir.quota {
+				e.kill(killReasonDiskQuota)
+				return
+			}
+		case <-e.exited:
+			return
+		}
+	}
+}
+
+// dirSize returns the total size of the regular files under path. Files which
+// can't be inspected, such as files removed while walking, are ignored.
+func dirSize(path string) int64 {
+	var size int64
+	_ = filepath.WalkDir(path, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return nil //nolint:nilerr
+		}
+
+		if info, err := d.Info(); err == nil {
+			size += info.Size()
+		}
+
+		return nil
+	})
+
+	return size
+}
@@ -0,0 +1,381 @@
+package exec
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/dop251/goja"
+	"github.com/sirupsen/logrus"
+	"go.k6.io/k6/js/common"
+	"go.k6.io/k6/lib/types"
+)
+
+// The default durations services are given to become ready, and to exit once stopped.
+const (
+	defaultServiceReadyTimeout = 30 * time.Second
+	defaultServiceStopTimeout  = 5 * time.Second
+)
+
+// services holds the shared service processes started through Service, shared by all the VUs
+// of the test. Each service is reference-counted by the VUs using it, and stopped once none of
+// them does anymore.
+type services struct {
+	mu      sync.Mutex
+	entries map[string]*service
+}
+
+// service is a shared process, started once, and stopped once the last VU using it released it.
+type service struct {
+	name   string
+	cmd    *exec.Cmd
+	cancel context.CancelFunc
+	logger logrus.FieldLogger
+
+	// refs is the number of VUs using the service.
+	refs int
+
+	// ready is closed once the service is ready, and exited once it exited.
+	ready     chan struct{}
+	readyOnce sync.Once
+	exited    chan struct{}
+	exitCode  int
+
+	stopTimeout time.Duration
+}
+
+// serviceOptions holds the options a service is started with.
+type serviceOptions struct {
+	args []string
+	env  map[string]string
+
+	// ready, if set, makes the service be considered ready once a line of its output matches it.
+	ready        *regexp.Regexp
+	readyTimeout time.Duration
+	stopTimeout  time.Duration
+}
+
+// Service is the handle a VU holds on a shared service process.
+type Service struct {
+	// Pid is the process ID of the service.
+	Pid int `js:"pid"`
+
+	mi      *ModuleInstance
+	service *service
+}
+
+// Service starts the named executable as a service process shared by all the VUs of the test
+// instance, unless it already runs, and returns a promise resolved with a handle on it once it
+// is ready. The service is reference-counted across VUs, and stopped once all the VUs using it
+// released it, or once the scenarios ended at the latest, which is why services can't be started
+// from teardown() nor handleSummary().
+func (mi *ModuleInstance) Service(name string, options goja.Value) *goja.Promise {
+	rt := mi.vu.Runtime()
+	promise, resolve, reject := makeHandledPromise(mi.vu)
+
+	opts, err := parseServiceOptions(rt, options)
+	if err != nil {
+		common.Throw(rt, err)
+	}
+
+	command := mi.newCommand(name)
+	command.args = append(command.args, opts.args...)
+	for k, v := range opts.env {
+		command.env[k] = v
+	}
+
+	if reason := prohibitedReason(command.config.resolve(mi.vu)); reason != "" {
+		common.Throw(rt, fmt.Errorf("starting the %q service is prohibited in this environment (%s)", name, reason))
+	}
+
+	if err := command.checkInitAllowed(); err != nil {
+		reject(command.rejection(err))
+		return promise
+	}
+
+	svc, err := mi.acquireService(command, opts)
+	if err != nil {
+		reject(command.rejection(err))
+		return promise
+	}
+
+	ctx := mi.vu.Context()
+	go func() {
+		timeout := time.NewTimer(opts.readyTimeout)
+		defer timeout.Stop()
+
+		select {
+		case <-svc.ready:
+			resolve(&Service{Pid: svc.cmd.Process.Pid, mi: mi, service: svc})
+		case <-svc.exited:
+			reject(command.rejection(fmt.Errorf("the %q service exited before being ready (exit code %d)",
+				name, svc.exitCode)))
+		case <-timeout.C:
+			reject(command.rejection(fmt.Errorf("the %q service wasn't ready after %s", name, opts.readyTimeout)))
+		case <-ctx.Done():
+			reject(command.rejection(ctx.Err()))
+		}
+	}()
+
+	return promise
+}
+
+// errServiceOutsideTest is the error services started once the end of the test can't be observed fail with.
+var errServiceOutsideTest = errors.New("services can only be started from the init context, setup() or " +
+	"the iterations of a test run, as they are stopped once it ended")
+
+// acquireService returns the service running command, starting it if it doesn't run yet, and
+// makes the VU hold a reference on it, released once the test ended.
+func (mi *ModuleInstance) acquireService(command *Command, opts *serviceOptions) (*service, error) {
+	key := command.key()
+
+	ctx := mi.vu.Context()
+	if !mi.testRun.observable(ctx) {
+		return nil, errServiceOutsideTest
+	}
+
+	mi.services.mu.Lock()
+	defer mi.services.mu.Unlock()
+
+	svc, ok := mi.services.entries[key]
+	if ok && svc.hasExited() {
+		ok = false
+	}
+	if !ok {
+		var err error
+		if svc, err = startService(command, opts); err != nil {
+			return nil, err
+		}
+
+		if mi.services.entries == nil {
+			mi.services.entries = make(map[string]*service)
+		}
+		mi.services.entries[key] = svc
+	}
+
+	if mi.heldServices == nil {
+		mi.heldServices = make(map[*service]bool)
+	}
+	if mi.heldServices[svc] {
+		return svc, nil
+	}
+
+	mi.heldServices[svc] = true
+	svc.refs++
+
+	// VUs run until the end of the test, which k6 waits for the services to be stopped at.
+	if !mi.testRun.onEnd(ctx, func() { mi.releaseService(svc, true) }) {
+		if mi.dropService(svc) {
+			go svc.stop()
+		}
+		return nil, errServiceOutsideTest
+	}
+
+	return svc, nil
+}
+
+// releaseService releases the reference the VU holds on svc, if any, and stops it if no other
+// VU holds a reference on it, waiting for it to exit if wait is set.
+func (mi *ModuleInstance) releaseService(svc *service, wait bool) {
+	mi.services.mu.Lock()
+	last := mi.dropService(svc)
+	mi.services.mu.Unlock()
+
+	switch {
+	case !last:
+	case wait:
+		svc.stop()
+	default:
+		go svc.stop()
+	}
+}
+
+// dropService drops the reference the VU holds on svc, if any, and returns true if it was the
+// last one, svc being removed from the services then. The services mutex must be held.
+func (mi *ModuleInstance) dropService(svc *service) bool {
+	if !mi.heldServices[svc] {
+		return false
+	}
+	delete(mi.heldServices, svc)
+
+	svc.refs--
+	if svc.refs > 0 {
+		return false
+	}
+
+	for key, entry := range mi.services.entries {
+		if entry == svc {
+			delete(mi.services.entries, key)
+		}
+	}
+
+	return true
+}
+
+// startService starts command as a service, detached from the VU context.
+func startService(command *Command, opts *serviceOptions) (*service, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	execOpts, _ := parseExecOptions(command.vu.Runtime(), nil)
+	cmd, err := command.build(ctx, execOpts)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	closeFiles(cmd.ExtraFiles...)
+	cmd.ExtraFiles = nil
+
+	svc := &service{
+		name:        command.Name,
+		cmd:         cmd,
+		cancel:      cancel,
+		logger:      command.logger(),
+		ready:       make(chan struct{}),
+		exited:      make(chan struct{}),
+		stopTimeout: opts.stopTimeout,
+	}
+
+	if opts.ready != nil {
+		cmd.Stdout = svc.readinessWriter(opts.ready)
+		cmd.Stderr = svc.readinessWriter(opts.ready)
+	}
+	cmd.WaitDelay = opts.stopTimeout
+
+	if err := cmd.Start(); err != nil {
+		cancel()
+		return nil, err
+	}
+
+	if opts.ready == nil {
+		svc.markReady()
+	}
+
+	go func() {
+		svc.exitCode = exitCodeOf(cmd.Wait())
+		close(svc.exited)
+		cancel()
+	}()
+
+	return svc, nil
+}
+
+// readinessWriter returns a writer marking the service as ready once a line written
+// to it matches ready, and discarding what is written to it afterwards.
+func (s *service) readinessWriter(ready *regexp.Regexp) *serviceOutput {
+	return &serviceOutput{
+		service: s,
+		watcher: &lineWatcher{fn: func(line []byte) {
+			if ready.Match(line) {
+				s.markReady()
+			}
+		}},
+	}
+}
+
+// hasExited returns true if the service process exited.
+func (s *service) hasExited() bool {
+	select {
+	case <-s.exited:
+		return true
+	default:
+		return false
+	}
+}
+
+// markReady marks the service as ready.
+func (s *service) markReady() {
+	s.readyOnce.Do(func() { close(s.ready) })
+}
+
+// stop asks the service to terminate, and kills it if it didn't exit after its stop timeout.
+func (s *service) stop() {
+	if err := terminateProcess(s.cmd.Process); err != nil {
+		s.cancel()
+	}
+
+	select {
+	case <-s.exited:
+	case <-time.After(s.stopTimeout):
+		s.logger.Warnf("the %s service didn't exit %s after being stopped, killing it", s.name, s.stopTimeout)
+		s.cancel()
+	}
+}
+
+// serviceOutput is the writer an output stream of a service is written to.
+type serviceOutput struct {
+	service *service
+	watcher *lineWatcher
+	ready   atomic.Bool
+}
+
+// Write implements the io.Writer interface.
+func (o *serviceOutput) Write(p []byte) (int, error) {
+	if o.ready.Load() {
+		return len(p), nil
+	}
+
+	select {
+	case <-o.service.ready:
+		o.ready.Store(true)
+		return len(p), nil
+	default:
+		return o.watcher.Write(p)
+	}
+}
+
+// Release releases the reference the VU holds on the service, which is stopped
+// if no other VU holds a reference on it.
+func (s *Service) Release() {
+	s.mi.releaseService(s.service, false)
+}
+
+// parseServiceOptions parses the options object optionally passed to Service.
+func parseServiceOptions(rt *goja.Runtime, v goja.Value) (*serviceOptions, error) {
+	opts := &serviceOptions{
+		readyTimeout: defaultServiceReadyTimeout,
+		stopTimeout:  defaultServiceStopTimeout,
+	}
+	if common.IsNullish(v) {
+		return opts, nil
+	}
+
+	obj := v.ToObject(rt)
+	for _, key := range obj.Keys() {
+		value := obj.Get(key)
+
+		switch key {
+		case "args":
+			if err := rt.ExportTo(value, &opts.args); err != nil {
+				return nil, fmt.Errorf("invalid service args: %w", err)
+			}
+		case "env":
+			if err := rt.ExportTo(value, &opts.env); err != nil {
+				return nil, fmt.Errorf("invalid service env: %w", err)
+			}
+		case "ready":
+			ready, err := toRegexp(value)
+			if err != nil {
+				return nil, err
+			}
+			opts.ready = ready
+		case "readyTimeout", "stopTimeout":
+			d, err := types.GetDurationValue(value.Export())
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s: %w", key, err)
+			}
+			if key == "readyTimeout" {
+				opts.readyTimeout = d
+			} else {
+				opts.stopTimeout = d
+			}
+		default:
+			return nil, fmt.Errorf("unknown service option %q", key)
+		}
+	}
+
+	return opts, nil
+}
@@ -0,0 +1,121 @@
+//go:build !windows
+
+package exec
+
+import (
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"go.k6.io/k6/lib"
+)
+
+// serviceScript starts a service printing listening once ready, then never exiting on its own.
+const serviceScript = `exec.service("sh", { args: ["-c", "echo listening; exec sleep 30"], ready: /listening/ })`
+
+func TestService(t *testing.T) {
+	t.Parallel()
+
+	t.Run("shared across VUs until the test ended", func(t *testing.T) {
+		t.Parallel()
+
+		root := New()
+		vus := []*testVU{newScenarioVU(t, root, 1, nil), newScenarioVU(t, root, 2, nil)}
+		end := startScenarios(t, root, vus...)
+
+		first := vus[0].mustRun(serviceScript + `.then((s) => s.pid)`).ToInteger()
+		if second := vus[1].mustRun(serviceScript + `.then((s) => s.pid)`).ToInteger(); second != first {
+			t.Fatalf("the VUs were handed the services %d and %d, want a single one", first, second)
+		}
+		if !processRuns(int(first)) {
+			t.Fatal("the service isn't running")
+		}
+
+		end()
+		awaitProcessExit(t, int(first))
+	})
+
+	t.Run("stopped once released by all the VUs", func(t *testing.T) {
+		t.Parallel()
+
+		root := New()
+		vus := []*testVU{newScenarioVU(t, root, 1, nil), newScenarioVU(t, root, 2, nil)}
+		startScenarios(t, root, vus...)
+
+		for _, vu := range vus {
+			vu.mustRun(serviceScript + `.then((s) => { globalThis.server = s; })`)
+		}
+		pid := int(vus[0].mustRun(`server.pid`).ToInteger())
+
+		vus[0].mustRun(`server.release()`)
+		if !processRuns(pid) {
+			t.Fatal("the service was stopped while a VU still held it")
+		}
+
+		vus[1].mustRun(`server.release()`)
+		awaitProcessExit(t, pid)
+	})
+
+	tests := []struct {
+		name    string
+		script  string
+		wantErr string
+	}{
+		{
+			name:    "exited before being ready",
+			script:  `exec.service("sh", { args: ["-c", "exit 3"], ready: /listening/ })`,
+			wantErr: `the "sh" service exited before being ready (exit code 3)`,
+		},
+		{
+			name:    "not ready in time",
+			script:  `exec.service("sh", { args: ["-c", "exec sleep 30"], ready: /listening/, readyTimeout: "100ms" })`,
+			wantErr: `the "sh" service wasn't ready after 100ms`,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			root := New()
+			vu := newScenarioVU(t, root, 1, nil)
+			end := startScenarios(t, root, vu)
+			defer end()
+
+			if _, err := vu.run(tt.script); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("the script failed with %v, want an error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	t.Run("outside of a test run", func(t *testing.T) {
+		t.Parallel()
+
+		vu := newTestVU(t, nil)
+		vu.moveToVUContext(lib.Options{})
+
+		if _, err := vu.run(serviceScript); err == nil || !strings.Contains(err.Error(), errServiceOutsideTest.Error()) {
+			t.Errorf("the script failed with %v, want %q", err, errServiceOutsideTest)
+		}
+	})
+}
+
+// processRuns returns true if the process with the given ID runs.
+func processRuns(pid int) bool {
+	return syscall.Kill(pid, 0) == nil
+}
+
+// awaitProcessExit fails the test unless the process with the given ID exits within 5 seconds.
+func awaitProcessExit(t *testing.T, pid int) {
+	t.Helper()
+
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if !processRuns(pid) {
+			return
+		}
+	}
+
+	t.Errorf("the service %d is still running", pid)
+}
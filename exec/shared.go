@@ -0,0 +1,246 @@
+package exec
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/dop251/goja"
+	"go.k6.io/k6/js/common"
+)
+
+// sharedOutputs holds the parsed outputs of the commands run by SharedOutput,
+// shared by all the VUs of the test.
+type sharedOutputs struct {
+	mu   sync.Mutex
+	data map[string][]string
+}
+
+// get returns the elements of the named shared output, obtaining them from
+// produce, and storing them, if they weren't already.
+func (so *sharedOutputs) get(name string, produce func() []string) []string {
+	so.mu.Lock()
+	defer so.mu.Unlock()
+
+	if elements, ok := so.data[name]; ok {
+		return elements
+	}
+
+	if so.data == nil {
+		so.data = make(map[string][]string)
+	}
+
+	elements := produce()
+	so.data[name] = elements
+
+	return elements
+}
+
+// NewSharedOutput is the JS constructor for the SharedOutput object. It runs, once per
+// test, the command returned by the function passed as second argument, either a Cmd or
+// an array holding the name of the command followed by its arguments, and parses its
+// standard output into an array shared by all VUs, similarly to SharedArray.
+//
+// The third argument is the parser applied to the output: "lines" (the default) for
+// its non-empty lines, "json" for a JSON array, "csv" for objects keyed by the header
+// row, or a function called with the result of the command and returning an array.
+func (mi *ModuleInstance) NewSharedOutput(call goja.ConstructorCall) *goja.Object {
+	rt := mi.vu.Runtime()
+
+	if mi.vu.State() != nil {
+		common.Throw(rt, errors.New("new SharedOutput must be called in the init context"))
+	}
+
+	name := call.Argument(0).String()
+	if name == "" {
+		common.Throw(rt, errors.New("empty name provided to SharedOutput's constructor"))
+	}
+
+	spec, ok := goja.AssertFunction(call.Argument(1))
+	if !ok {
+		common.Throw(rt, errors.New("a function is expected as the second argument of SharedOutput's constructor"))
+	}
+
+	elements := mi.shared.get(name, func() []string {
+		return mi.produceSharedOutput(spec, call.Argument(2))
+	})
+
+	return rt.NewDynamicArray(&sharedOutputArray{rt: rt, elements: elements}).ToObject(rt)
+}
+
+// produceSharedOutput runs the command returned by spec, and returns the JSON
+// encoded elements its output is parsed into by parser.
+func (mi *ModuleInstance) produceSharedOutput(spec goja.Callable, parser goja.Value) []string {
+	rt := mi.vu.Runtime()
+
+	if !initExecAllowed(mi.vu) {
+		common.Throw(rt, errors.New("executing commands from the init context is disabled; set "+
+			allowInitEnvVar+"=true to enable it"))
+	}
+
+	specValue, err := spec(goja.Undefined())
+	if err != nil {
+		common.Throw(rt, err)
+	}
+
+	command := mi.sharedOutputCommand(specValue)
+
+	complete, err := command.run(&execOptions{maxPendingLines: defaultMaxPendingLines, killTree: true})
+	if err != nil {
+		command.throw(err)
+	}
+
+	result, err := complete()
+	if err != nil {
+		command.throw(err)
+	}
+
+	var elements []interface{}
+	if fn, ok := goja.AssertFunction(parser); ok {
+		parsed, err := fn(goja.Undefined(), result.toJSValue(rt))
+		if err != nil {
+			common.Throw(rt, err)
+		}
+
+		if err := rt.ExportTo(parsed, &elements); err != nil {
+			common.Throw(rt, fmt.Errorf("the SharedOutput parser must return an array: %w", err))
+		}
+	} else {
+		format := "lines"
+		if !common.IsNullish(parser) {
+			format = parser.String()
+		}
+
+		stdout, err := result.stdoutString()
+		if err != nil {
+			common.Throw(rt, err)
+		}
+
+		elements, err = parseSharedOutput(stdout, format)
+		if err != nil {
+			common.Throw(rt, err)
+		}
+	}
+
+	encoded := make([]string, len(elements))
+	for i, element := range elements {
+		b, err := json.Marshal(element)
+		if err != nil {
+			common.Throw(rt, err)
+		}
+		encoded[i] = string(b)
+	}
+
+	return encoded
+}
+
+// sharedOutputCommand returns the command described by v, either
+// a Cmd or an array holding a command name and its arguments.
+func (mi *ModuleInstance) sharedOutputCommand(v goja.Value) *Command {
+	command, ok := mi.commandFromSpec(v)
+	if !ok {
+		common.Throw(mi.vu.Runtime(),
+			errors.New("the SharedOutput command must be a Cmd, or an array holding a command and its arguments"))
+	}
+
+	return command
+}
+
+// commandFromSpec returns the command described by v, either a Cmd or an array holding
+// a command name and its arguments, and false if v describes neither.
+func (mi *ModuleInstance) commandFromSpec(v goja.Value) (*Command, bool) {
+	switch exported := v.Export().(type) {
+	case Command:
+		return &exported, true
+	case *Command:
+		return exported, true
+	}
+
+	var argv []string
+	if err := mi.vu.Runtime().ExportTo(v, &argv); err != nil || len(argv) == 0 {
+		return nil, false
+	}
+
+	command := mi.newCommand(argv[0])
+	command.args = append(command.args, argv[1:]...)
+
+	return command, true
+}
+
+// parseSharedOutput parses output according to the named format.
+func parseSharedOutput(output, format string) ([]interface{}, error) {
+	var elements []interface{}
+
+	switch format {
+	case "lines":
+		for _, line := range strings.Split(output, "\n") {
+			line = strings.TrimSuffix(line, "\r")
+			if strings.TrimSpace(line) != "" {
+				elements = append(elements, line)
+			}
+		}
+	case "json":
+		if err := json.Unmarshal([]byte(output), &elements); err != nil {
+			return nil, fmt.Errorf("unable to parse the output as a JSON array: %w", err)
+		}
+	case "csv":
+		records, err := csv.NewReader(bytes.NewReader([]byte(output))).ReadAll()
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse the output as CSV: %w", err)
+		}
+
+		for i := 1; i < len(records); i++ {
+			row := make(map[string]string, len(records[0]))
+			for j, column := range records[0] {
+				if j < len(records[i]) {
+					row[column] = records[i][j]
+				}
+			}
+			elements = append(elements, row)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported SharedOutput parser %q; expected lines, json, csv or a function", format)
+	}
+
+	return elements, nil
+}
+
+// sharedOutputArray exposes the elements of a shared output as a read-only JS array.
+type sharedOutputArray struct {
+	rt       *goja.Runtime
+	elements []string
+}
+
+// Get implements the goja.DynamicArray interface. Each access returns
+// a new copy of the element, so that VUs can't modify the shared data.
+func (a *sharedOutputArray) Get(index int) goja.Value {
+	if index < 0 || index >= len(a.elements) {
+		return goja.Undefined()
+	}
+
+	var element interface{}
+	if err := json.Unmarshal([]byte(a.elements[index]), &element); err != nil {
+		common.Throw(a.rt, err)
+	}
+
+	return a.rt.ToValue(element)
+}
+
+// Set implements the goja.DynamicArray interface.
+func (a *sharedOutputArray) Set(_ int, _ goja.Value) bool {
+	panic(a.rt.NewTypeError("SharedOutput is immutable"))
+}
+
+// Len implements the goja.DynamicArray interface.
+func (a *sharedOutputArray) Len() int {
+	return len(a.elements)
+}
+
+// SetLen implements the goja.DynamicArray interface.
+func (a *sharedOutputArray) SetLen(_ int) bool {
+	panic(a.rt.NewTypeError("SharedOutput is immutable"))
+}
@@ -0,0 +1,117 @@
+package exec
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+
+	"github.com/dop251/goja"
+	"go.k6.io/k6/js/common"
+)
+
+// shellOption is the key of the option of Sh and ShSync selecting the shell the script is run by.
+const shellOption = "shell"
+
+// defaultShell returns the shell scripts are run by unless the shell option is set:
+// /bin/sh on Unix, and cmd on Windows.
+func defaultShell() string {
+	if runtime.GOOS == "windows" {
+		return "cmd"
+	}
+
+	return "/bin/sh"
+}
+
+// shellArgs returns the arguments making the named shell run script, according to the
+// conventions of its family: cmd /C, powershell -Command, or -c for POSIX-like shells.
+func shellArgs(shell, script string) []string {
+	name := strings.ToLower(strings.TrimSuffix(filepath.Base(shell), filepath.Ext(shell)))
+
+	switch name {
+	case "cmd":
+		return []string{"/C", script}
+	case "powershell", "pwsh":
+		return []string{"-NoProfile", "-NonInteractive", "-Command", script}
+	default:
+		return []string{"-c", script}
+	}
+}
+
+// Sh runs the script through a shell, so that it can use pipes, globs and redirections, and
+// returns a promise resolved with its result. The options are the ones of Exec, along with
+// shell, the name or path of the shell run, /bin/sh on Unix and cmd on Windows by default.
+func (mi *ModuleInstance) Sh(script string, options goja.Value) *goja.Promise {
+	command, options := mi.shellCommand(script, options)
+
+	return command.Exec(options)
+}
+
+// ShSync synchronously runs the script through a shell, the same way as Sh, and returns its
+// result. It blocks the VU, and the event loop, until the command exited, so that, from the
+// init context, it requires executing commands from the init context to be allowed, as runInit does.
+func (mi *ModuleInstance) ShSync(script string, options goja.Value) goja.Value {
+	rt := mi.vu.Runtime()
+
+	command, options := mi.shellCommand(script, options)
+	if err := command.checkInitAllowed(); err != nil {
+		common.Throw(rt, err)
+	}
+
+	opts, err := parseExecOptions(rt, options)
+	if err != nil {
+		common.Throw(rt, err)
+	}
+
+	return command.runSync(opts, "shSync")
+}
+
+// shellCommand returns the command running script through the shell selected by the options,
+// and the options left once the shell option was removed from them.
+func (mi *ModuleInstance) shellCommand(script string, options goja.Value) (*Command, goja.Value) {
+	rt := mi.vu.Runtime()
+
+	shell := defaultShell()
+	if !common.IsNullish(options) {
+		obj := options.ToObject(rt)
+		rest := rt.NewObject()
+
+		for _, key := range obj.Keys() {
+			value := obj.Get(key)
+			if key != shellOption {
+				if err := rest.Set(key, value); err != nil {
+					common.Throw(rt, err)
+				}
+				continue
+			}
+
+			if shell = value.String(); common.IsNullish(value) || shell == "" {
+				common.Throw(rt, fmt.Errorf("invalid %s option; expected the name or path of a shell", shellOption))
+			}
+		}
+
+		options = rest
+	}
+
+	command := mi.newCommand(shell)
+	command.args = append(command.args, shellArgs(shell, script)...)
+	command.shell, command.script = true, script
+
+	return command, options
+}
+
+// shellAssignment matches the variable assignments which can prefix the commands of shell scripts.
+var shellAssignment = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*=`) //nolint:gochecknoglobals
+
+// scriptExecutable returns the executable the script starts with: its first word, skipping
+// the variable assignments prefixing it, if any, or an empty string if it has none.
+func scriptExecutable(script string) string {
+	for _, word := range strings.Fields(script) {
+		if !shellAssignment.MatchString(word) {
+			return strings.Trim(word, `"'`)
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,64 @@
+//go:build !windows
+
+package exec
+
+import (
+	"testing"
+
+	"go.k6.io/k6/lib"
+)
+
+func TestScriptExecutable(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		script string
+		want   string
+	}{
+		{script: "curl -s http://svc/health | jq -r .status", want: "curl"},
+		{script: "  LANG=C TZ=UTC date +%s", want: "date"},
+		{script: `"/opt/my tool/run" --fast`, want: "/opt/my"},
+		{script: "'jq' .", want: "jq"},
+		{script: "A=1", want: ""},
+		{script: "", want: ""},
+	}
+
+	for _, tt := range tests {
+		if got := scriptExecutable(tt.script); got != tt.want {
+			t.Errorf("scriptExecutable(%q) = %q, want %q", tt.script, got, tt.want)
+		}
+	}
+}
+
+func TestShTags(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		script     string
+		executable string
+		shell      string
+	}{
+		{name: "default shell", script: `exec.sh("echo hello | tr a-z A-Z")`, executable: "echo", shell: "/bin/sh"},
+		{name: "shell option", script: `exec.sh("X=1 true", { shell: "sh" })`, executable: "true", shell: "sh"},
+		{name: "empty script", script: `exec.sh("")`, executable: "/bin/sh", shell: "/bin/sh"},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			vu := newTestVU(t, nil)
+			vu.moveToVUContext(lib.Options{})
+			vu.mustRun(tt.script)
+
+			samples := vu.awaitSamplesOf("exec_command_duration")
+			tags := samples[0].Tags.Map()
+			if tags["executable"] != tt.executable || tags["shell"] != tt.shell {
+				t.Errorf("the script's metrics are tagged with executable %q and shell %q, want %q and %q",
+					tags["executable"], tags["shell"], tt.executable, tt.shell)
+			}
+		})
+	}
+}
@@ -0,0 +1,43 @@
+package exec
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// signalNames holds the portable names of the signals which can be sent to processes.
+// They are mapped to the closest equivalent on platforms lacking some of them.
+var signalNames = []string{ //nolint:gochecknoglobals
+	"SIGHUP", "SIGINT", "SIGQUIT", "SIGKILL", "SIGUSR1", "SIGUSR2", "SIGTERM", "SIGSTOP", "SIGCONT",
+}
+
+// normalizeSignal returns the portable name of the named signal, which can be
+// provided in any case, and with or without its SIG prefix.
+func normalizeSignal(name string) (string, error) {
+	normalized := strings.ToUpper(name)
+	if !strings.HasPrefix(normalized, "SIG") {
+		normalized = "SIG" + normalized
+	}
+
+	if !contains(signalNames, normalized) {
+		return "", fmt.Errorf("unsupported signal %q; expected one of %s", name, strings.Join(signalNames, ", "))
+	}
+
+	return normalized, nil
+}
+
+// suspendProcess suspends p by sending it SIGSTOP.
+func suspendProcess(p *os.Process) error {
+	return sendSignal(p, "SIGSTOP")
+}
+
+// resumeProcess resumes p by sending it SIGCONT.
+func resumeProcess(p *os.Process) error {
+	return sendSignal(p, "SIGCONT")
+}
+
+// terminateProcess asks p to terminate by sending it SIGTERM.
+func terminateProcess(p *os.Process) error {
+	return sendSignal(p, "SIGTERM")
+}
@@ -0,0 +1,30 @@
+//go:build !windows
+
+package exec
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// signals maps the portable signal names to the platform's signals.
+var signals = map[string]syscall.Signal{ //nolint:gochecknoglobals
+	"SIGHUP":  syscall.SIGHUP,
+	"SIGINT":  syscall.SIGINT,
+	"SIGQUIT": syscall.SIGQUIT,
+	"SIGKILL": syscall.SIGKILL,
+	"SIGUSR1": syscall.SIGUSR1,
+	"SIGUSR2": syscall.SIGUSR2,
+	"SIGTERM": syscall.SIGTERM,
+	"SIGSTOP": syscall.SIGSTOP,
+	"SIGCONT": syscall.SIGCONT,
+}
+
+// sendSignal sends the signal with the provided portable name to p.
+func sendSignal(p *os.Process, name string) error {
+	return p.Signal(signals[name])
+}
+
+// prepareSignals is a no-op on Unix, where signals can be sent to any process.
+func prepareSignals(_ *exec.Cmd) {}
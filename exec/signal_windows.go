@@ -0,0 +1,49 @@
+//go:build windows
+
+package exec
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/windows"
+)
+
+// windowsTerminateGracePeriod is how long processes are given to exit after
+// receiving a CTRL_BREAK_EVENT standing for SIGTERM, before being terminated.
+const windowsTerminateGracePeriod = 5 * time.Second
+
+// sendSignal delivers the signal with the provided portable name to p, as closely as
+// Windows allows: SIGINT is delivered as a CTRL_BREAK_EVENT to the process's console
+// process group, SIGTERM as a CTRL_BREAK_EVENT followed by TerminateProcess if the
+// process didn't exit within a grace period, and SIGKILL using TerminateProcess.
+func sendSignal(p *os.Process, name string) error {
+	switch name {
+	case "SIGKILL":
+		return p.Kill()
+	case "SIGINT":
+		return windows.GenerateConsoleCtrlEvent(windows.CTRL_BREAK_EVENT, uint32(p.Pid))
+	case "SIGTERM":
+		if err := windows.GenerateConsoleCtrlEvent(windows.CTRL_BREAK_EVENT, uint32(p.Pid)); err != nil {
+			return p.Kill()
+		}
+
+		time.AfterFunc(windowsTerminateGracePeriod, func() { _ = p.Kill() })
+		return nil
+	default:
+		return fmt.Errorf("sending %s is not supported on Windows", name)
+	}
+}
+
+// prepareSignals makes cmd be started in its own process group, so
+// that console control events can be delivered to it.
+func prepareSignals(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+
+	cmd.SysProcAttr.CreationFlags |= windows.CREATE_NEW_PROCESS_GROUP
+}
@@ -0,0 +1,100 @@
+package exec
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/dop251/goja"
+)
+
+// sourceEnvMarker separates the environment before and after sourcing a script
+// in the output of the shell run by SourceEnv.
+const sourceEnvMarker = "--k6-exec-source-env--"
+
+// sourceEnvScript prints the environment, sources the script passed as first
+// argument, and prints the environment again, both NUL separated.
+const sourceEnvScript = `env -0 && printf '%s\0' "` + sourceEnvMarker + `" && . "$1" >/dev/null && env -0`
+
+// sourceEnvIgnored holds the variables left out of the environment returned by
+// SourceEnv, as they are maintained by the shell itself.
+var sourceEnvIgnored = []string{"_", "SHLVL", "OLDPWD"} //nolint:gochecknoglobals
+
+// SourceEnv sources the shell script at path, and returns a promise resolved with
+// the environment variables it set or modified, as an object which can be passed
+// to the Env method of commands. Variables unset by the script are not reported.
+func (mi *ModuleInstance) SourceEnv(path string) *goja.Promise {
+	promise, resolve, reject := makeHandledPromise(mi.vu)
+
+	if reason := prohibitedReason(mi.config.resolve(mi.vu)); reason != "" {
+		reject(fmt.Errorf("sourcing %s is prohibited in this environment (%s)", path, reason))
+		return promise
+	}
+
+	// The shell is run as any other command, so that the execution policies, the sanitization
+	// of its environment and the concurrency limit apply to it.
+	command := mi.newCommand("sh")
+	command.args = append(command.args, "-c", sourceEnvScript, "sh", path)
+
+	opts, err := parseExecOptions(mi.vu.Runtime(), goja.Undefined())
+	if err != nil {
+		reject(err)
+		return promise
+	}
+
+	complete, err := command.run(opts)
+	if err != nil {
+		reject(fmt.Errorf("unable to source %s: %w", path, err))
+		return promise
+	}
+
+	go func() {
+		result, err := complete()
+		if err != nil {
+			reject(fmt.Errorf("unable to source %s: %w", path, err))
+			return
+		}
+		if result.ExitCode != 0 {
+			reject(fmt.Errorf("unable to source %s: exit status %d: %s", path, result.ExitCode, strings.TrimSpace(result.Stderr)))
+			return
+		}
+
+		before, after, ok := strings.Cut(result.Stdout, sourceEnvMarker+"\x00")
+		if !ok {
+			reject(fmt.Errorf("unable to source %s: unexpected shell output", path))
+			return
+		}
+
+		resolve(diffEnviron(parseEnviron(before), parseEnviron(after)))
+	}()
+
+	return promise
+}
+
+// parseEnviron parses a NUL separated list of KEY=value pairs, as printed by env -0.
+func parseEnviron(s string) map[string]string {
+	environ := make(map[string]string)
+	for _, kv := range strings.Split(s, "\x00") {
+		if key, value, ok := strings.Cut(kv, "="); ok && key != "" {
+			environ[key] = value
+		}
+	}
+
+	return environ
+}
+
+// diffEnviron returns the variables of after which are absent from,
+// or have a different value in, before.
+func diffEnviron(before, after map[string]string) map[string]string {
+	diff := make(map[string]string)
+	for key, value := range after {
+		if contains(sourceEnvIgnored, key) {
+			continue
+		}
+
+		if previous, ok := before[key]; !ok || previous != value {
+			diff[key] = value
+		}
+	}
+
+	return diff
+}
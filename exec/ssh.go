@@ -0,0 +1,153 @@
+package exec
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/dop251/goja"
+	"go.k6.io/k6/js/common"
+)
+
+// SSHOptions configures the remote host commands are run on, over SSH.
+type SSHOptions struct {
+	// Host is the name or address of the remote host.
+	Host string `js:"host"`
+
+	// User is the user logged in as, if not the default one of the ssh client.
+	User string `js:"user"`
+
+	// Port is the port the SSH server listens on, if not the default one of the ssh client.
+	Port int `js:"port"`
+
+	// KeyPath is the path of the private key authenticating the user, if
+	// not one of the default keys of the ssh client, or of its agent.
+	KeyPath string `js:"keyPath"`
+
+	// Options holds additional options of the ssh client, such as StrictHostKeyChecking.
+	Options map[string]string `js:"options"`
+}
+
+// sshHost is the factory of the commands run on a remote host.
+type sshHost struct {
+	mi   *ModuleInstance
+	opts *SSHOptions
+}
+
+// SSH returns a factory of commands run on the remote host, over SSH, using the ssh client,
+// rather than locally. It exposes a Cmd constructor and a run function, equivalent to the ones
+// of the module, whose commands are executed, and measured, the same way as local ones, their
+// metrics being tagged with the host as host.
+func (mi *ModuleInstance) SSH(opts SSHOptions) *goja.Object {
+	rt := mi.vu.Runtime()
+
+	if err := opts.validate("ssh"); err != nil {
+		common.Throw(rt, err)
+	}
+
+	host := &sshHost{mi: mi, opts: &opts}
+
+	factory := rt.NewObject()
+	for key, value := range map[string]interface{}{
+		"Cmd":  host.newCmd,
+		"run":  host.run,
+		"host": opts.Host,
+	} {
+		if err := factory.Set(key, value); err != nil {
+			common.Throw(rt, err)
+		}
+	}
+
+	return factory
+}
+
+// newCmd is the JS constructor for the Cmd objects run on the remote host.
+func (h *sshHost) newCmd(call goja.ConstructorCall) *goja.Object {
+	command := h.mi.constructCommand(call)
+	command.ssh = h.opts
+
+	return h.mi.vu.Runtime().ToValue(command).ToObject(h.mi.vu.Runtime())
+}
+
+// run executes the named command on the remote host, the same way as the Run function of the module.
+func (h *sshHost) run(name string, args []string, options goja.Value) *goja.Promise {
+	command := h.mi.newCommand(name)
+	command.args = append(command.args, args...)
+	command.ssh = h.opts
+
+	return command.Exec(options)
+}
+
+// validate returns an error, mentioning fn, the function the options were passed to, if they don't
+// describe a remote host.
+func (o *SSHOptions) validate(fn string) error {
+	if o.Host == "" {
+		return fmt.Errorf("%s expects a host", fn)
+	}
+	if o.Port < 0 || o.Port > 0xffff {
+		return fmt.Errorf("%s expects a valid port", fn)
+	}
+
+	return nil
+}
+
+// wrap returns the executable and arguments running the named command on the remote host,
+// along with the provided environment variables, in the directory dir, if set. When tty is set,
+// a terminal is allocated on the remote host for the command.
+func (o *SSHOptions) wrap(name string, args []string, env map[string]string, dir string, tty bool) (string, []string) {
+	script := remoteCommandLine(name, args, env)
+	if dir != "" {
+		script = "cd " + quoteArgs([]string{dir}) + " && " + script
+	}
+
+	keys := make([]string, 0, len(o.Options))
+	for key := range o.Options {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	// The ssh client uses the first value set for each option, so that the options
+	// set by scripts take precedence over the defaults, set after them.
+	wrapped := make([]string, 0, 2*len(keys)+12)
+	for _, key := range keys {
+		wrapped = append(wrapped, "-o", key+"="+o.Options[key])
+	}
+	// Prompting for passwords or passphrases would block commands forever.
+	wrapped = append(wrapped, "-o", "BatchMode=yes")
+
+	if tty {
+		wrapped = append(wrapped, "-tt")
+	} else {
+		wrapped = append(wrapped, "-T")
+	}
+	if o.Port != 0 {
+		wrapped = append(wrapped, "-p", strconv.Itoa(o.Port))
+	}
+	if o.KeyPath != "" {
+		wrapped = append(wrapped, "-i", o.KeyPath)
+	}
+	if o.User != "" {
+		wrapped = append(wrapped, "-l", o.User)
+	}
+
+	return "ssh", append(wrapped, "--", o.Host, script)
+}
+
+// remoteCommandLine returns the shell command line running the named command on a remote
+// host, along with the provided environment variables, sorted so that it is stable.
+func remoteCommandLine(name string, args []string, env map[string]string) string {
+	keys := make([]string, 0, len(env))
+	for key := range env {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var line strings.Builder
+	for _, key := range keys {
+		line.WriteString(key + "=" + quoteArgs([]string{env[key]}) + " ")
+	}
+	line.WriteString(quoteArgs(append([]string{name}, args...)))
+
+	return line.String()
+}
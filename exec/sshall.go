@@ -0,0 +1,237 @@
+package exec
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/dop251/goja"
+	"go.k6.io/k6/js/common"
+)
+
+// SSHAll runs the command described by spec, either a Cmd or an array holding the name of a command
+// followed by its arguments, on each of hosts, either names or objects holding the options of ssh,
+// and returns a promise resolved once it completed on all of them, with the result of each host,
+// and an aggregate of them. At most concurrency hosts, all of them by default, run the command at
+// once; the other options are the ones of exec, applied to the execution on each host.
+func (mi *ModuleInstance) SSHAll(hosts goja.Value, spec goja.Value, options goja.Value) *goja.Promise {
+	rt := mi.vu.Runtime()
+
+	targets, err := parseSSHHosts(rt, hosts)
+	if err != nil {
+		common.Throw(rt, err)
+	}
+
+	command, ok := mi.commandFromSpec(spec)
+	if !ok {
+		common.Throw(rt, errors.New("sshAll expects a Cmd, or an array holding a command and its arguments"))
+	}
+	if command.ssh != nil || command.azure != nil || len(command.chain) > 0 || len(command.pipeline) > 0 {
+		common.Throw(rt, errors.New("sshAll expects a command run locally, rather than on a remote host, "+
+			"and neither chained nor piped"))
+	}
+
+	promise, resolve, reject := makeHandledPromise(mi.vu)
+
+	concurrency, execOptions, err := parseSSHAllOptions(rt, options)
+	if err == nil {
+		// The options are parsed again for each host, so that no execution shares them.
+		_, err = parseExecOptions(rt, execOptions)
+	}
+	if err != nil {
+		reject(command.rejection(err))
+		return promise
+	}
+
+	if concurrency == 0 || concurrency > len(targets) {
+		concurrency = len(targets)
+	}
+
+	fanOut := &sshFanOut{
+		command: command,
+		hosts:   targets,
+		options: execOptions,
+		results: make([]sshHostResult, len(targets)),
+		resolve: resolve,
+	}
+	for i := 0; i < concurrency; i++ {
+		fanOut.launch()
+	}
+
+	return promise
+}
+
+// sshFanOut runs a command across remote hosts. It is only accessed on the event loop,
+// except for the results, each of which is written once by the execution on its host.
+type sshFanOut struct {
+	command *Command
+	hosts   []SSHOptions
+	options goja.Value
+
+	results []sshHostResult
+	// next is the index of the next host to run the command on, and settled
+	// the number of hosts the execution completed on, either way.
+	next    int
+	settled int
+
+	resolve func(interface{})
+}
+
+// sshHostResult holds the outcome of the execution of a command on a remote host:
+// either its result, or the value its promise would have been rejected with.
+type sshHostResult struct {
+	host   string
+	result *CommandResult
+	err    interface{}
+}
+
+// launch runs the command on the next host. Once it completed, the command is run on
+// the host after it, if any, or the promise is resolved if it completed on all of them.
+func (f *sshFanOut) launch() {
+	i := f.next
+	f.next++
+
+	vu := f.command.vu
+	command := f.command.Clone()
+	command.ssh = &f.hosts[i]
+	f.results[i].host = f.hosts[i].Host
+
+	opts, err := parseExecOptions(vu.Runtime(), f.options)
+	if err != nil {
+		// The options were parsed successfully before.
+		common.Throw(vu.Runtime(), err)
+	}
+
+	callback := vu.RegisterCallback()
+	// The onExit callback of the command, if any, is called for each host.
+	notifyResolve, notifyReject := command.notifyExit(func(interface{}) {}, func(interface{}) {})
+	settle := func(notify func(interface{}), value interface{}) {
+		notify(value)
+		callback(func() error {
+			f.complete()
+			return nil
+		})
+	}
+
+	command.execute(opts, func(value interface{}) {
+		f.results[i].result, _ = value.(*CommandResult)
+		settle(notifyResolve, value)
+	}, func(value interface{}) {
+		f.results[i].err = value
+		settle(notifyReject, value)
+	})
+}
+
+// complete records the completion of the execution on a host, on the event loop.
+func (f *sshFanOut) complete() {
+	f.settled++
+
+	switch {
+	case f.next < len(f.hosts):
+		f.launch()
+	case f.settled == len(f.hosts):
+		f.resolve(&sshAllResult{hosts: f.results})
+	}
+}
+
+// sshAllResult holds the outcome of the execution of a command across remote hosts.
+type sshAllResult struct {
+	hosts []sshHostResult
+}
+
+// toJSValue implements the jsValuer interface. The result holds the outcome on each host, in the
+// order the hosts were passed in, as results, the number of hosts the command succeeded, and
+// failed, on, as succeeded and failed, and the host it ran the longest on, as slowest.
+func (r *sshAllResult) toJSValue(rt *goja.Runtime) goja.Value {
+	results := make([]interface{}, 0, len(r.hosts))
+	succeeded := 0
+	var slowest *sshHostResult
+
+	for i := range r.hosts {
+		host := &r.hosts[i]
+		success := host.err == nil && host.result != nil && host.result.Success
+		if success {
+			succeeded++
+		}
+
+		result, reason := goja.Null(), goja.Null()
+		if host.result != nil {
+			result = host.result.toJSValue(rt)
+			if slowest == nil || host.result.Duration > slowest.result.Duration {
+				slowest = host
+			}
+		}
+		if host.err != nil {
+			reason = toValue(rt, host.err)
+		}
+
+		results = append(results, map[string]interface{}{
+			"host":    host.host,
+			"success": success,
+			"result":  result,
+			"error":   reason,
+		})
+	}
+
+	aggregate := map[string]interface{}{
+		"results":   results,
+		"succeeded": succeeded,
+		"failed":    len(r.hosts) - succeeded,
+		"slowest":   nil,
+	}
+	if slowest != nil {
+		aggregate["slowest"] = map[string]interface{}{"host": slowest.host, "duration": slowest.result.Duration}
+	}
+
+	return rt.ToValue(aggregate)
+}
+
+// parseSSHHosts parses the hosts passed to sshAll, either names, or objects holding the options of ssh.
+func parseSSHHosts(rt *goja.Runtime, v goja.Value) ([]SSHOptions, error) {
+	var raw []goja.Value
+	if common.IsNullish(v) || rt.ExportTo(v, &raw) != nil || len(raw) == 0 {
+		return nil, errors.New("sshAll expects a non-empty array of hosts, either names or objects holding the options of ssh")
+	}
+
+	hosts := make([]SSHOptions, len(raw))
+	for i, host := range raw {
+		if name, ok := host.Export().(string); ok {
+			hosts[i].Host = name
+		} else if err := rt.ExportTo(host, &hosts[i]); err != nil {
+			return nil, fmt.Errorf("invalid sshAll host: %w", err)
+		}
+
+		if err := hosts[i].validate("sshAll"); err != nil {
+			return nil, err
+		}
+	}
+
+	return hosts, nil
+}
+
+// parseSSHAllOptions parses the options of sshAll, and returns the number of hosts the command
+// runs on at once, zero for all of them, and the other options, which are the ones of exec.
+func parseSSHAllOptions(rt *goja.Runtime, v goja.Value) (int, goja.Value, error) {
+	if common.IsNullish(v) {
+		return 0, v, nil
+	}
+
+	concurrency := 0
+	obj := v.ToObject(rt)
+	execOptions := rt.NewObject()
+	for _, key := range obj.Keys() {
+		value := obj.Get(key)
+
+		if key == "concurrency" {
+			if concurrency = int(value.ToInteger()); concurrency <= 0 {
+				return 0, nil, fmt.Errorf("invalid concurrency %d; expected a positive number", concurrency)
+			}
+			continue
+		}
+
+		if err := execOptions.Set(key, value); err != nil {
+			return 0, nil, err
+		}
+	}
+
+	return concurrency, execOptions, nil
+}
@@ -0,0 +1,88 @@
+package exec
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"syscall"
+	"time"
+)
+
+// maxStartRetries bounds how many times starting a command failing
+// with a transient error is retried.
+const maxStartRetries = 3
+
+// startRetryDelay is how long is waited for before retrying to start a command
+// the first time, doubling on each subsequent retry.
+const startRetryDelay = 10 * time.Millisecond
+
+// transientStartErrors are the errors starting a command fails with under heavy parallel load,
+// which retrying shortly after usually overcomes: ETXTBSY when the executable was just written,
+// and is still held open for writing by a process being forked, and EAGAIN when hitting the
+// limit on the number of processes.
+var transientStartErrors = []syscall.Errno{syscall.ETXTBSY, syscall.EAGAIN} //nolint:gochecknoglobals
+
+// transientStartError returns the name of the transient error starting a command failed with,
+// if it did, or an empty string otherwise.
+func transientStartError(err error) string {
+	for _, errno := range transientStartErrors {
+		if errors.Is(err, errno) {
+			return errnoName(errno)
+		}
+	}
+
+	return ""
+}
+
+// errnoName returns the name of the transient errno.
+func errnoName(errno syscall.Errno) string {
+	if errno == syscall.ETXTBSY {
+		return "ETXTBSY"
+	}
+
+	return "EAGAIN"
+}
+
+// startWithRetries starts cmd, retrying a few times, with an exponential backoff, if it
+// failed to start with a transient error, until ctx is done. It returns the errors the
+// retried attempts failed with.
+func startWithRetries(ctx context.Context, cmd *exec.Cmd, opts *execOptions) ([]string, error) {
+	var retried []string
+	delay := startRetryDelay
+
+	for {
+		err := startCommand(cmd, opts)
+		transient := transientStartError(err)
+		if transient == "" || len(retried) == maxStartRetries {
+			return retried, err
+		}
+
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return retried, err
+		}
+
+		retried, delay = append(retried, transient), delay*2
+		resetCommand(ctx, cmd)
+	}
+}
+
+// resetCommand makes cmd, which failed to start, startable again, as an exec.Cmd can
+// only be started once. The exported fields are kept, and cmd being reset in place, the
+// functions referencing it, such as its Cancel function, keep referencing it.
+func resetCommand(ctx context.Context, cmd *exec.Cmd) {
+	fresh := exec.CommandContext(ctx, cmd.Path)
+	// The executable was already looked up, and the outcome of that lookup accepted.
+	fresh.Err = cmd.Err
+	fresh.Args = cmd.Args
+	fresh.Env = cmd.Env
+	fresh.Dir = cmd.Dir
+	fresh.Stdin, fresh.Stdout, fresh.Stderr = cmd.Stdin, cmd.Stdout, cmd.Stderr
+	fresh.ExtraFiles = cmd.ExtraFiles
+	fresh.SysProcAttr = cmd.SysProcAttr
+	fresh.Cancel = cmd.Cancel
+	fresh.WaitDelay = cmd.WaitDelay
+
+	*cmd = *fresh
+}
@@ -0,0 +1,153 @@
+package exec
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/metrics"
+)
+
+// metricsFdEnvVar is the name of the environment variable holding the number of the file
+// descriptor the command can write metrics to, in the child's environment.
+const metricsFdEnvVar = "K6_EXEC_METRICS_FD"
+
+// statsdTypes maps the statsd metric types to the k6 metric and value types they are emitted as.
+var statsdTypes = map[string]struct { //nolint:gochecknoglobals
+	metricType metrics.MetricType
+	valueType  metrics.ValueType
+}{
+	"c":  {metrics.Counter, metrics.Default},
+	"g":  {metrics.Gauge, metrics.Default},
+	"ms": {metrics.Trend, metrics.Time},
+	"h":  {metrics.Trend, metrics.Default},
+	"d":  {metrics.Trend, metrics.Default},
+}
+
+// openMetricsChannel creates the pipe cmd writes metrics to, passed to it as an extra file,
+// whose number is set in its environment. It returns the read end of the pipe, and the
+// write end, which should be closed once the command was started.
+func openMetricsChannel(cmd *exec.Cmd) (*os.File, *os.File, error) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to create the metrics channel: %w", err)
+	}
+
+	cmd.ExtraFiles = append(cmd.ExtraFiles, w)
+	cmd.Env = append(cmd.Env, metricsFdEnvVar+"="+strconv.Itoa(2+len(cmd.ExtraFiles)))
+
+	return r, w, nil
+}
+
+// readMetrics reads the statsd-style lines written to r, and emits them as samples, until
+// the command and its children closed the channel, or ctx is done. It returns a channel
+// closed once it stopped reading. Invalid lines are logged, and skipped.
+func (c *Command) readMetrics(
+	ctx context.Context, state *lib.State, logger logrus.FieldLogger, r *os.File,
+) <-chan struct{} {
+	done := make(chan struct{})
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = r.Close()
+		case <-done:
+		}
+	}()
+
+	go func() {
+		defer close(done)
+		defer func() { _ = r.Close() }()
+
+		scanner := bufio.NewScanner(r)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" {
+				continue
+			}
+
+			sample, err := c.parseStatsdLine(state, line)
+			if err != nil {
+				logger.WithError(err).Warnf("invalid metric line written by %s", c.Name)
+				continue
+			}
+
+			if state != nil {
+				metrics.PushIfNotDone(ctx, state.Samples, sample)
+			}
+		}
+	}()
+
+	return done
+}
+
+// parseStatsdLine parses a statsd-style line, such as my_metric:12|g|#tag:x, into a sample of
+// the metric it names, which is registered, or reused if it already exists. Counter values are
+// scaled by their sample rate, if any, and tags are added to the ones of the VU, if any.
+func (c *Command) parseStatsdLine(state *lib.State, line string) (metrics.Sample, error) {
+	name, rest, ok := strings.Cut(line, ":")
+	if !ok {
+		return metrics.Sample{}, fmt.Errorf("%q lacks a value", line)
+	}
+
+	fields := strings.Split(rest, "|")
+	if len(fields) < 2 {
+		return metrics.Sample{}, fmt.Errorf("%q lacks a type", line)
+	}
+
+	value, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return metrics.Sample{}, fmt.Errorf("%q isn't a number", fields[0])
+	}
+
+	kind, ok := statsdTypes[fields[1]]
+	if !ok {
+		return metrics.Sample{}, fmt.Errorf("unsupported metric type %q; expected c, g, ms, h or d", fields[1])
+	}
+
+	metric, err := c.metrics.registry.NewMetric(name, kind.metricType, kind.valueType)
+	if err != nil {
+		return metrics.Sample{}, err
+	}
+
+	tags := c.metrics.registry.RootTagSet()
+	if state != nil {
+		tags = state.Tags.GetCurrentValues().Tags
+	}
+	tags = c.withCommandTags(tags)
+
+	for _, field := range fields[2:] {
+		switch {
+		case strings.HasPrefix(field, "@"):
+			rate, err := strconv.ParseFloat(field[1:], 64)
+			if err != nil || rate <= 0 || rate > 1 {
+				return metrics.Sample{}, fmt.Errorf("invalid sample rate %q", field[1:])
+			}
+			if kind.metricType == metrics.Counter {
+				value /= rate
+			}
+		case strings.HasPrefix(field, "#"):
+			for _, tag := range strings.Split(field[1:], ",") {
+				key, tagValue, _ := strings.Cut(tag, ":")
+				if key != "" {
+					tags = tags.With(key, tagValue)
+				}
+			}
+		default:
+			return metrics.Sample{}, fmt.Errorf("unsupported field %q", field)
+		}
+	}
+
+	return metrics.Sample{
+		TimeSeries: metrics.TimeSeries{Metric: metric, Tags: tags},
+		Value:      value,
+		Time:       time.Now(),
+	}, nil
+}
@@ -0,0 +1,124 @@
+package exec
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"go.k6.io/k6/metrics"
+)
+
+func TestParseStatsdLine(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		line       string
+		metricType metrics.MetricType
+		valueType  metrics.ValueType
+		value      float64
+		tags       map[string]string
+		wantErr    string
+	}{
+		{
+			name: "counter", line: "requests:3|c",
+			metricType: metrics.Counter, value: 3,
+		},
+		{
+			name: "sampled counter", line: "requests:3|c|@0.5",
+			metricType: metrics.Counter, value: 6,
+		},
+		{
+			name: "sampled gauge", line: "queue_depth:12|g|@0.5",
+			metricType: metrics.Gauge, value: 12,
+		},
+		{
+			name: "timer", line: "latency:12.5|ms",
+			metricType: metrics.Trend, valueType: metrics.Time, value: 12.5,
+		},
+		{
+			name: "histogram", line: "size:-1e3|h",
+			metricType: metrics.Trend, value: -1000,
+		},
+		{
+			name: "distribution", line: "size:7|d",
+			metricType: metrics.Trend, value: 7,
+		},
+		{
+			name: "tags", line: "requests:1|c|#region:eu,cached,:skipped|@1",
+			metricType: metrics.Counter, value: 1,
+			tags: map[string]string{"region": "eu", "cached": ""},
+		},
+		{
+			name: "tags overriding the ones of the command", line: "requests:1|c|#executable:other",
+			metricType: metrics.Counter, value: 1,
+			tags: map[string]string{"executable": "other"},
+		},
+		{name: "no value", line: "requests", wantErr: `"requests" lacks a value`},
+		{name: "no type", line: "requests:1", wantErr: `"requests:1" lacks a type`},
+		{name: "invalid value", line: "requests:many|c", wantErr: `"many" isn't a number`},
+		{name: "unsupported type", line: "users:1|s", wantErr: `unsupported metric type "s"`},
+		{name: "zero sample rate", line: "requests:1|c|@0", wantErr: `invalid sample rate "0"`},
+		{name: "sample rate above one", line: "requests:1|c|@2", wantErr: `invalid sample rate "2"`},
+		{name: "unsupported field", line: "requests:1|c|extra", wantErr: `unsupported field "extra"`},
+		{name: "invalid name", line: "a,b:1|c", wantErr: "Invalid metric name"},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			c := &Command{Name: "tool", metrics: RegisterCustomMetrics(metrics.NewRegistry())}
+
+			sample, err := c.parseStatsdLine(nil, tt.line)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("parseStatsdLine(%q) error = %v, want it to contain %q", tt.line, err, tt.wantErr)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("parseStatsdLine(%q) error = %v", tt.line, err)
+			}
+			if sample.Metric.Type != tt.metricType || sample.Metric.Contains != tt.valueType {
+				t.Errorf("parseStatsdLine(%q) emits a %s metric of %s values, want %s of %s", tt.line,
+					sample.Metric.Type, sample.Metric.Contains, tt.metricType, tt.valueType)
+			}
+			if sample.Value != tt.value {
+				t.Errorf("parseStatsdLine(%q) value = %v, want %v", tt.line, sample.Value, tt.value)
+			}
+
+			want := map[string]string{"executable": "tool"}
+			for key, value := range tt.tags {
+				want[key] = value
+			}
+			if got := sample.Tags.Map(); !reflect.DeepEqual(got, want) {
+				t.Errorf("parseStatsdLine(%q) tags = %v, want %v", tt.line, got, want)
+			}
+		})
+	}
+}
+
+func TestParseStatsdLineReusesMetrics(t *testing.T) {
+	t.Parallel()
+
+	c := &Command{Name: "tool", metrics: RegisterCustomMetrics(metrics.NewRegistry())}
+
+	first, err := c.parseStatsdLine(nil, "requests:1|c")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := c.parseStatsdLine(nil, "requests:2|c")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.Metric != second.Metric {
+		t.Error("parseStatsdLine registered the requests metric twice")
+	}
+
+	if _, err := c.parseStatsdLine(nil, "requests:1|g"); err == nil {
+		t.Error("parseStatsdLine redefined the requests counter as a gauge")
+	}
+}
@@ -0,0 +1,331 @@
+package exec
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"syscall"
+
+	"github.com/dop251/goja"
+	"go.k6.io/k6/js/common"
+	"go.k6.io/k6/js/modules"
+)
+
+// stdinSource is the source of the chunks fed to a command's standard input: a generator
+// function, or an iterable or async iterable object, such as a generator, yielding strings,
+// ArrayBuffers or Uint8Arrays, or a file, or static data.
+type stdinSource struct {
+	value goja.Value
+
+	// data, if set, is the static data fed to the command's standard input.
+	data []byte
+
+	// file, if set, is the path of the file the command reads its standard input from,
+	// which is opened as the command's standard input rather than being read by the module.
+	file string
+}
+
+// parseStdin parses the stdin execution option.
+func parseStdin(rt *goja.Runtime, v goja.Value) (*stdinSource, error) {
+	// Strings and binary data are checked first, as typed arrays are iterable too.
+	if isStaticData(v) {
+		data, err := toBytes(rt, v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid stdin: %w", err)
+		}
+		// The data is copied, as it is written off the event loop, and its buffer might be reused.
+		return &stdinSource{data: append([]byte{}, data...)}, nil
+	}
+
+	if _, ok := goja.AssertFunction(v); ok {
+		return &stdinSource{value: v}, nil
+	}
+
+	obj, ok := v.(*goja.Object)
+	if ok && iteratorMethod(rt, obj) != nil {
+		return &stdinSource{value: v}, nil
+	}
+
+	if ok {
+		if file := obj.Get("file"); !common.IsNullish(file) && file.String() != "" {
+			return &stdinSource{file: file.String()}, nil
+		}
+	}
+
+	return nil, errors.New("invalid stdin; expected a string, an ArrayBuffer, a Uint8Array, a generator function, " +
+		"an iterable or async iterable object, or an object holding the path of a file")
+}
+
+// isStaticData returns true if v is a string, an ArrayBuffer, or a view on an ArrayBuffer such as a Uint8Array.
+func isStaticData(v goja.Value) bool {
+	switch v.Export().(type) {
+	case string, goja.ArrayBuffer:
+		return true
+	}
+
+	obj, ok := v.(*goja.Object)
+	if !ok {
+		return false
+	}
+	_, ok = arrayBufferOf(obj)
+
+	return ok
+}
+
+// iterates returns true if the chunks are pulled from a JS iterator, on the event loop.
+func (s *stdinSource) iterates() bool {
+	return s != nil && s.value != nil
+}
+
+// Stdin returns a copy of the command whose standard input is fed with stdin: a string, an
+// ArrayBuffer or a Uint8Array, a generator function, or an iterable or async iterable object,
+// yielding chunks, or an object holding the path of a file. The stdin execution option takes
+// precedence over it.
+func (c Command) Stdin(stdin goja.Value) Command {
+	source, err := parseStdin(c.vu.Runtime(), stdin)
+	if err != nil {
+		common.Throw(c.vu.Runtime(), err)
+	}
+
+	c.stdin = source
+	return c
+}
+
+// stdinSource returns the source of the command's standard input for an execution with
+// opts: the stdin option, or the one set using Stdin, or nil if there is none.
+func (c *Command) stdinSource(opts *execOptions) *stdinSource {
+	if opts.stdin != nil {
+		return opts.stdin
+	}
+
+	return c.stdin
+}
+
+// iteratorMethod returns the method of obj returning its async iterator, or its iterator, or
+// nil if it is neither iterable, nor an iterator itself, having a next method. As the runtime
+// doesn't know of Symbol.asyncIterator, it is looked up rather than being referenced directly.
+func iteratorMethod(rt *goja.Runtime, obj *goja.Object) goja.Callable {
+	if sym, ok := rt.Get("Symbol").ToObject(rt).Get("asyncIterator").(*goja.Symbol); ok {
+		if method, ok := goja.AssertFunction(obj.GetSymbol(sym)); ok {
+			return method
+		}
+	}
+
+	if method, ok := goja.AssertFunction(obj.GetSymbol(goja.SymIterator)); ok {
+		return method
+	}
+
+	if _, ok := goja.AssertFunction(obj.Get("next")); ok {
+		return func(this goja.Value, _ ...goja.Value) (goja.Value, error) { return this, nil }
+	}
+
+	return nil
+}
+
+// iterator returns the iterator the chunks are pulled from. It must be called on the event loop.
+func (s *stdinSource) iterator(vu modules.VU) (*stdinIterator, error) {
+	rt := vu.Runtime()
+
+	v := s.value
+	if fn, ok := goja.AssertFunction(v); ok {
+		var err error
+		if v, err = fn(goja.Undefined()); err != nil {
+			return nil, fmt.Errorf("unable to call the stdin generator function: %w", err)
+		}
+	}
+
+	obj, ok := v.(*goja.Object)
+	if !ok {
+		return nil, errors.New("the stdin generator function didn't return an iterator")
+	}
+
+	if method := iteratorMethod(rt, obj); method != nil {
+		var err error
+		if v, err = method(obj); err != nil {
+			return nil, fmt.Errorf("unable to get the stdin iterator: %w", err)
+		}
+		if obj, ok = v.(*goja.Object); !ok {
+			return nil, errors.New("the stdin iterable didn't return an iterator")
+		}
+	}
+
+	next, ok := goja.AssertFunction(obj.Get("next"))
+	if !ok {
+		return nil, errors.New("the stdin iterator has no next method")
+	}
+
+	return &stdinIterator{vu: vu, obj: obj, next: next}, nil
+}
+
+// stdinIterator pulls the chunks fed to a command's standard input from a JS iterator,
+// one at a time, as the command reads them.
+type stdinIterator struct {
+	vu   modules.VU
+	obj  *goja.Object
+	next goja.Callable
+}
+
+// pulledChunk is the outcome of pulling a chunk from a stdinIterator.
+type pulledChunk struct {
+	chunk []byte
+	done  bool
+	err   error
+}
+
+// feed writes the chunks pulled from the iterator to w, until the iterator is done, writing
+// fails, or exited is closed, and then closes w. The returned channel receives the error the
+// iterator failed with, if any. The command exiting before reading all its input isn't an error.
+func (it *stdinIterator) feed(w io.WriteCloser, exited <-chan struct{}) <-chan error {
+	done := make(chan error, 1)
+
+	// The pipe is closed once the command exited, as writing to it blocks
+	// forever if a child of the command holds it open without reading it.
+	go func() {
+		<-exited
+		_ = w.Close()
+	}()
+
+	go func() {
+		defer close(done)
+
+		err := it.pump(w, exited)
+		_ = w.Close()
+		done <- err
+	}()
+
+	return done
+}
+
+// pump writes the chunks pulled from the iterator to w.
+func (it *stdinIterator) pump(w io.Writer, exited <-chan struct{}) error {
+	for {
+		var pulled pulledChunk
+		select {
+		case pulled = <-it.pull():
+		case <-exited:
+			it.stop()
+			return nil
+		}
+
+		if pulled.err != nil || pulled.done {
+			return pulled.err
+		}
+
+		if _, err := w.Write(pulled.chunk); err != nil {
+			it.stop()
+			if errors.Is(err, syscall.EPIPE) || errors.Is(err, os.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+	}
+}
+
+// pull calls the iterator's next method on the event loop, and returns a channel receiving
+// the chunk it returned, or the promise it returned was resolved with.
+func (it *stdinIterator) pull() <-chan pulledChunk {
+	pulled := make(chan pulledChunk, 1)
+	callback := it.vu.RegisterCallback()
+
+	callback(func() error {
+		rt := it.vu.Runtime()
+
+		v, err := it.next(it.obj)
+		if err != nil {
+			pulled <- pulledChunk{err: err}
+			return nil
+		}
+
+		if _, isPromise := v.Export().(*goja.Promise); !isPromise {
+			pulled <- iteratorResult(rt, v)
+			return nil
+		}
+
+		then, _ := goja.AssertFunction(v.ToObject(rt).Get("then"))
+		onFulfilled := func(value goja.Value) { pulled <- iteratorResult(rt, value) }
+		onRejected := func(reason goja.Value) { pulled <- pulledChunk{err: fmt.Errorf("%v", reason)} }
+		if _, err := then(v, rt.ToValue(onFulfilled), rt.ToValue(onRejected)); err != nil {
+			pulled <- pulledChunk{err: err}
+		}
+
+		return nil
+	})
+
+	return pulled
+}
+
+// stop calls the iterator's return method, if any, on the event loop,
+// so that generators stopped early can release what they hold.
+func (it *stdinIterator) stop() {
+	callback := it.vu.RegisterCallback()
+	callback(func() error {
+		if ret, ok := goja.AssertFunction(it.obj.Get("return")); ok {
+			_, _ = ret(it.obj)
+		}
+		return nil
+	})
+}
+
+// iteratorResult returns the chunk held by the result v of an iterator's next method.
+func iteratorResult(rt *goja.Runtime, v goja.Value) pulledChunk {
+	obj, ok := v.(*goja.Object)
+	if !ok {
+		return pulledChunk{err: errors.New("the stdin iterator returned a result which isn't an object")}
+	}
+
+	if obj.Get("done").ToBoolean() {
+		return pulledChunk{done: true}
+	}
+
+	chunk, err := toBytes(rt, obj.Get("value"))
+	if err != nil {
+		return pulledChunk{err: fmt.Errorf("invalid stdin chunk; expected a string, an ArrayBuffer or a Uint8Array: %w", err)}
+	}
+
+	// The chunk is copied, as it is written off the event loop, and its buffer might be reused.
+	return pulledChunk{chunk: append([]byte(nil), chunk...)}
+}
+
+// stdinPipe returns the iterator feeding cmd's standard input, and the pipe it is fed through,
+// whose read end is cmd's standard input, and should be closed once cmd was started. When cmd
+// reads its standard input from a file, the file is returned as the read end, without an iterator
+// nor a write end, the command reading it directly, so that it isn't copied through the module.
+// Static data is written to cmd's standard input by cmd itself, nothing being returned.
+func (s *stdinSource) stdinPipe(vu modules.VU, cmd *exec.Cmd) (*stdinIterator, *os.File, io.WriteCloser, error) {
+	if cmd.Stdin != nil {
+		return nil, nil, nil, errors.New("stdin can't be set when sudo reads its password from the standard input")
+	}
+
+	if s.data != nil {
+		// The writes failing as the command exited without reading all of its input, or
+		// as it was killed once the VU context was cancelled, are ignored by exec.Cmd.
+		cmd.Stdin = bytes.NewReader(s.data)
+		return nil, nil, nil, nil
+	}
+
+	if s.file != "" {
+		f, err := os.Open(s.file)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("unable to open the stdin file: %w", err)
+		}
+		cmd.Stdin = f
+
+		return nil, f, nil, nil
+	}
+
+	it, err := s.iterator(vu)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	r, w, err := newInputPipe()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	cmd.Stdin = r
+
+	return it, r, w, nil
+}
@@ -0,0 +1,75 @@
+//go:build !windows
+
+package exec
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.k6.io/k6/lib"
+)
+
+func TestCommandStdin(t *testing.T) {
+	t.Parallel()
+
+	file := filepath.Join(t.TempDir(), "input")
+	if err := os.WriteFile(file, []byte("from a file"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		command string
+		want    string
+		wantErr string
+	}{
+		{name: "string", command: `new exec.Cmd("cat").stdin("hello").exec()`, want: "hello"},
+		{name: "option", command: `new exec.Cmd("cat").exec({ stdin: "hello" })`, want: "hello"},
+		{name: "Uint8Array", command: `new exec.Cmd("cat").exec({ stdin: new Uint8Array([104, 105]) })`, want: "hi"},
+		{name: "ArrayBuffer", command: `new exec.Cmd("cat").exec({ stdin: new Uint8Array([104, 105]).buffer })`, want: "hi"},
+		{
+			name:    "generator function",
+			command: `new exec.Cmd("cat").exec({ stdin: function* () { yield "a\n"; yield new Uint8Array([98, 10]); } })`,
+			want:    "a\nb\n",
+		},
+		{name: "iterable", command: `new exec.Cmd("cat").exec({ stdin: ["a", "b"] })`, want: "ab"},
+		{
+			name: "async iterator",
+			command: `new exec.Cmd("cat").exec({ stdin: ((chunks) => ({
+				next: () => Promise.resolve(chunks.length > 0 ? { value: chunks.shift(), done: false } : { done: true }),
+			}))(["a", "b"]) })`,
+			want: "ab",
+		},
+		{name: "file", command: fmt.Sprintf(`new exec.Cmd("cat").exec({ stdin: { file: %q } })`, file), want: "from a file"},
+		{name: "not read", command: `new exec.Cmd("true").exec({ stdin: "x".repeat(1 << 20) })`, want: ""},
+		{name: "invalid", command: `new exec.Cmd("cat").exec({ stdin: 42 })`, wantErr: "invalid stdin"},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			vu := newTestVU(t, nil)
+			vu.moveToVUContext(lib.Options{})
+
+			got, err := vu.run(`(async () => (await ` + tt.command + `).stdout)()`)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("the script failed with %v, want an error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.String() != tt.want {
+				t.Errorf("the command's output is %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,118 @@
+//go:build !windows
+
+package exec
+
+import (
+	"strings"
+	"testing"
+
+	"go.k6.io/k6/lib"
+)
+
+func TestInputStream(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		// feed is the body of the async function feeding the standard input of proc, a spawned cat.
+		feed    string
+		want    string
+		wantErr string
+	}{
+		{
+			name: "writer",
+			feed: `const writer = proc.stdin.getWriter();
+				await writer.write("a\n");
+				await writer.write(new Uint8Array([98, 10]));
+				await writer.close();`,
+			want: "a\nb\n",
+		},
+		{
+			name: "underlying sink",
+			feed: `await proc.stdin.write("a\n", {});
+				await proc.stdin.write(new Uint8Array([98, 10]).buffer, {});
+				await proc.stdin.close();`,
+			want: "a\nb\n",
+		},
+		{
+			name: "process methods",
+			feed: `await proc.write("a\n");
+				await proc.end();`,
+			want: "a\n",
+		},
+		{
+			name: "aborted",
+			feed: `await proc.stdin.abort();
+				await proc.write("a\n");`,
+			wantErr: "the stream is closed",
+		},
+		{
+			name: "locked",
+			feed: `const writer = proc.stdin.getWriter();
+				const locked = proc.stdin.locked();
+				writer.releaseLock();
+				await proc.stdin.write(locked + " " + proc.stdin.locked(), {});
+				await proc.end();`,
+			want: "true false",
+		},
+		{
+			name: "locked to another writer",
+			feed: `proc.stdin.getWriter();
+				try {
+					proc.stdin.getWriter();
+				} finally {
+					await proc.end();
+				}`,
+			wantErr: "the stream is already locked to a writer",
+		},
+		{
+			name: "written once closed",
+			feed: `await proc.end();
+				await proc.write("a\n");`,
+			wantErr: "the stream is closed",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			vu := newTestVU(t, nil)
+			vu.moveToVUContext(lib.Options{})
+
+			got, err := vu.run(`(async () => {
+				const proc = new exec.Cmd("cat").spawn();
+				const reader = proc.stdout.getReader();
+				const read = (async () => {
+					let output = "";
+					for (let r = await reader.read(); !r.done; r = await reader.read()) {
+						output += String.fromCharCode(...r.value);
+					}
+					return output;
+				})();
+
+				try {
+					` + tt.feed + `
+				} finally {
+					await proc.wait();
+				}
+
+				return read;
+			})()`)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("the script failed with %v, want an error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.String() != tt.want {
+				t.Errorf("the process's output is %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,464 @@
+package exec
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+	"syscall"
+
+	"github.com/dop251/goja"
+	"go.k6.io/k6/js/common"
+	"go.k6.io/k6/js/modules"
+)
+
+// serialQueue runs functions in the background, one after the other,
+// in the order they were queued. It must only be used from the event loop.
+type serialQueue struct {
+	// tail is closed once the last queued function returned.
+	tail chan struct{}
+}
+
+// newSerialQueue returns a new, empty, serialQueue.
+func newSerialQueue() *serialQueue {
+	q := &serialQueue{tail: make(chan struct{})}
+	close(q.tail)
+
+	return q
+}
+
+// run runs fn in the background, once all the previously queued functions returned.
+func (q *serialQueue) run(fn func()) {
+	prev, next := q.tail, make(chan struct{})
+	q.tail = next
+
+	go func() {
+		<-prev
+		defer close(next)
+
+		fn()
+	}()
+}
+
+// OutputStream exposes one of a spawned process's output streams following the web streams
+// API. It can either be read directly using a reader obtained from GetReader, or be used as
+// the underlying source of a ReadableStream, as it implements the pull and cancel methods.
+type OutputStream struct {
+	vu modules.VU
+	r  *os.File
+
+	// chunkSize is the maximum size of the chunks read from the stream.
+	chunkSize int
+
+	// locked is set when a reader is active on the stream.
+	locked bool
+
+	// consumed is set once the script started consuming the stream, using a reader,
+	// listeners, or as the underlying source of a ReadableStream.
+	consumed atomic.Bool
+
+	// reads makes sure reads are served in the order they were issued.
+	reads *serialQueue
+
+	// finished is closed once the stream reached EOF, or was cancelled.
+	finished  chan struct{}
+	closeOnce sync.Once
+
+	// read holds the amount of bytes read from the stream so far.
+	read int64
+
+	// lines counts the lines read from the stream so far.
+	lines lineCounter
+
+	// onRead, if not nil, is called each time data was read from the stream.
+	onRead func()
+}
+
+// newOutputStream returns a new OutputStream reading from r in chunks of at
+// most chunkSize bytes. The stream is cancelled once ctx is done.
+func newOutputStream(ctx context.Context, vu modules.VU, r *os.File, chunkSize int) *OutputStream {
+	s := &OutputStream{
+		vu:        vu,
+		r:         r,
+		chunkSize: chunkSize,
+		reads:     newSerialQueue(),
+		finished:  make(chan struct{}),
+	}
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			s.finish()
+		case <-s.finished:
+		}
+	}()
+
+	return s
+}
+
+// Locked returns true if a reader is active on the stream.
+func (s *OutputStream) Locked() bool {
+	return s.locked
+}
+
+// GetReader returns a reader for the stream, and locks the stream to it.
+func (s *OutputStream) GetReader() *OutputStreamReader {
+	if s.locked {
+		common.Throw(s.vu.Runtime(), errors.New("the stream is already locked to a reader"))
+	}
+
+	s.locked = true
+	s.consumed.Store(true)
+	return &OutputStreamReader{stream: s}
+}
+
+// Pull implements the pull method of the web streams underlying source API: it
+// reads the next chunk of the stream, and enqueues it to the provided controller,
+// or closes the controller when the end of the stream is reached.
+func (s *OutputStream) Pull(controller *goja.Object) *goja.Promise {
+	rt := s.vu.Runtime()
+	promise, resolve, reject := rt.NewPromise()
+	callback := s.vu.RegisterCallback()
+
+	s.consumed.Store(true)
+	s.next(func(chunk []byte, err error) {
+		callback(func() error {
+			method, value := "enqueue", goja.Value(nil)
+			switch {
+			case errors.Is(err, io.EOF):
+				method = "close"
+			case err != nil:
+				reject(err)
+				return nil
+			default:
+				value = toUint8Array(rt, chunk)
+			}
+
+			fn, ok := goja.AssertFunction(controller.Get(method))
+			if !ok {
+				reject(errors.New("the controller has no " + method + " method"))
+				return nil
+			}
+
+			var args []goja.Value
+			if value != nil {
+				args = append(args, value)
+			}
+
+			if _, err := fn(controller, args...); err != nil {
+				reject(err)
+				return nil
+			}
+
+			resolve(goja.Undefined())
+			return nil
+		})
+	})
+
+	return promise
+}
+
+// Cancel cancels the stream, discarding any data that wasn't read yet.
+func (s *OutputStream) Cancel(_ goja.Value) *goja.Promise {
+	promise, resolve, _ := s.vu.Runtime().NewPromise()
+	s.finish()
+	resolve(goja.Undefined())
+
+	return promise
+}
+
+// next reads the next chunk of the stream, once all the previously
+// issued reads completed, and passes it to done.
+//
+// The chunk is nil and the error is io.EOF when the end of the stream is reached.
+func (s *OutputStream) next(done func(chunk []byte, err error)) {
+	s.reads.run(func() {
+		done(s.readChunk())
+	})
+}
+
+// readChunk reads a single chunk from the stream.
+func (s *OutputStream) readChunk() ([]byte, error) {
+	select {
+	case <-s.finished:
+		return nil, io.EOF
+	default:
+	}
+
+	buf := make([]byte, s.chunkSize)
+	n, err := s.r.Read(buf)
+	if n > 0 {
+		atomic.AddInt64(&s.read, int64(n))
+		_, _ = s.lines.Write(buf[:n])
+		if s.onRead != nil {
+			s.onRead()
+		}
+
+		return buf[:n], nil
+	}
+
+	if err == nil {
+		return nil, nil
+	}
+
+	// Reading the master end of a pseudo-terminal fails with EIO once the process exited.
+	s.finish()
+	if errors.Is(err, io.EOF) || errors.Is(err, os.ErrClosed) || errors.Is(err, syscall.EIO) {
+		return nil, io.EOF
+	}
+
+	return nil, err
+}
+
+// finish marks the stream as finished, and closes its underlying file.
+func (s *OutputStream) finish() {
+	s.closeOnce.Do(func() {
+		close(s.finished)
+		_ = s.r.Close()
+	})
+}
+
+// bytesRead returns the amount of bytes read from the stream so far.
+func (s *OutputStream) bytesRead() int64 {
+	return atomic.LoadInt64(&s.read)
+}
+
+// totals returns the amount of bytes and lines read from the stream so far.
+func (s *OutputStream) totals() outputTotals {
+	return outputTotals{bytes: s.bytesRead(), lines: s.lines.count()}
+}
+
+// OutputStreamReader reads chunks from an OutputStream, following the web streams reader API.
+type OutputStreamReader struct {
+	stream   *OutputStream
+	released bool
+}
+
+// Read returns a promise resolving with the next chunk of the stream, as an
+// object holding the chunk as a Uint8Array in its value property, and a done
+// property set to true once the end of the stream is reached.
+func (r *OutputStreamReader) Read() *goja.Promise {
+	promise, resolve, reject := makeHandledPromise(r.stream.vu)
+	if r.released {
+		reject(errors.New("the reader's lock on the stream was released"))
+		return promise
+	}
+
+	r.stream.next(func(chunk []byte, err error) {
+		switch {
+		case errors.Is(err, io.EOF):
+			resolve(readResult{done: true})
+		case err != nil:
+			reject(err)
+		default:
+			resolve(readResult{chunk: chunk})
+		}
+	})
+
+	return promise
+}
+
+// Cancel cancels the underlying stream.
+func (r *OutputStreamReader) Cancel(reason goja.Value) *goja.Promise {
+	return r.stream.Cancel(reason)
+}
+
+// ReleaseLock releases the reader's lock on the stream.
+func (r *OutputStreamReader) ReleaseLock() {
+	if !r.released {
+		r.released = true
+		r.stream.locked = false
+	}
+}
+
+// readResult is the result of reading a chunk from an OutputStreamReader.
+type readResult struct {
+	chunk []byte
+	done  bool
+}
+
+// toJSValue implements the jsValuer interface.
+func (rr readResult) toJSValue(rt *goja.Runtime) goja.Value {
+	obj := rt.NewObject()
+	value := goja.Undefined()
+	if !rr.done {
+		value = toUint8Array(rt, rr.chunk)
+	}
+
+	if err := obj.Set("value", value); err != nil {
+		common.Throw(rt, err)
+	}
+	if err := obj.Set("done", rr.done); err != nil {
+		common.Throw(rt, err)
+	}
+
+	return obj
+}
+
+// toUint8Array returns a new JS Uint8Array holding b.
+func toUint8Array(rt *goja.Runtime, b []byte) goja.Value {
+	ctor, ok := goja.AssertConstructor(rt.Get("Uint8Array"))
+	if !ok {
+		common.Throw(rt, errors.New("the Uint8Array constructor is not available"))
+	}
+
+	arr, err := ctor(nil, rt.ToValue(rt.NewArrayBuffer(b)))
+	if err != nil {
+		common.Throw(rt, err)
+	}
+
+	return arr
+}
+
+// toBytes returns the bytes held by v, which can either be a string, an
+// ArrayBuffer, or a view on an ArrayBuffer such as a Uint8Array. The bytes of
+// ArrayBuffers aren't copied, so that they must be before being used off the event loop.
+func toBytes(rt *goja.Runtime, v goja.Value) ([]byte, error) {
+	if obj, ok := v.(*goja.Object); ok {
+		if buf, ok := arrayBufferOf(obj); ok {
+			offset := obj.Get("byteOffset").ToInteger()
+			length := obj.Get("byteLength").ToInteger()
+
+			return buf.Bytes()[offset : offset+length], nil
+		}
+	}
+
+	return common.ToBytes(v.Export())
+}
+
+// arrayBufferOf returns the ArrayBuffer obj is a view on, if it is one, such as a Uint8Array.
+func arrayBufferOf(obj *goja.Object) (goja.ArrayBuffer, bool) {
+	// Get returns nil, rather than undefined, for the properties objects don't have.
+	buffer := obj.Get("buffer")
+	if buffer == nil {
+		return goja.ArrayBuffer{}, false
+	}
+
+	buf, ok := buffer.Export().(goja.ArrayBuffer)
+	return buf, ok
+}
+
+// InputStream exposes a spawned process's standard input following the web streams
+// API. It can either be written to directly using a writer obtained from GetWriter,
+// or be used as the underlying sink of a WritableStream, as it implements the
+// write, close and abort methods.
+type InputStream struct {
+	vu modules.VU
+	w  io.WriteCloser
+
+	// locked is set when a writer is active on the stream.
+	locked bool
+
+	// closed is set once the stream was closed or aborted.
+	closed bool
+
+	// writes makes sure writes are performed in the order they were issued.
+	writes *serialQueue
+}
+
+// newInputStream returns a new InputStream writing to w.
+func newInputStream(vu modules.VU, w io.WriteCloser) *InputStream {
+	return &InputStream{vu: vu, w: w, writes: newSerialQueue()}
+}
+
+// Locked returns true if a writer is active on the stream.
+func (s *InputStream) Locked() bool {
+	return s.locked
+}
+
+// GetWriter returns a writer for the stream, and locks the stream to it.
+func (s *InputStream) GetWriter() *InputStreamWriter {
+	if s.locked {
+		common.Throw(s.vu.Runtime(), errors.New("the stream is already locked to a writer"))
+	}
+
+	s.locked = true
+	return &InputStreamWriter{stream: s}
+}
+
+// Write writes chunk, either a string, an ArrayBuffer or a Uint8Array, to the
+// stream. The returned promise is resolved once the chunk was written.
+func (s *InputStream) Write(chunk goja.Value, _ goja.Value) *goja.Promise {
+	b, err := toBytes(s.vu.Runtime(), chunk)
+	if err != nil {
+		return s.enqueue(func() error { return err })
+	}
+	// The chunk is copied, as it is written off the event loop, and its buffer might be reused.
+	b = append([]byte(nil), b...)
+
+	return s.enqueue(func() error {
+		_, err := s.w.Write(b)
+		return err
+	})
+}
+
+// Close closes the stream once all the pending writes completed,
+// signaling the end of the input to the process.
+func (s *InputStream) Close() *goja.Promise {
+	promise := s.enqueue(s.w.Close)
+	s.closed = true
+
+	return promise
+}
+
+// Abort closes the stream right away.
+func (s *InputStream) Abort(_ goja.Value) *goja.Promise {
+	promise, resolve, _ := s.vu.Runtime().NewPromise()
+	s.closed = true
+	_ = s.w.Close()
+	resolve(goja.Undefined())
+
+	return promise
+}
+
+// enqueue runs op once all the previously issued operations completed, and
+// returns a promise resolved, or rejected with its error, once it did.
+func (s *InputStream) enqueue(op func() error) *goja.Promise {
+	promise, resolve, reject := makeHandledPromise(s.vu)
+	if s.closed {
+		reject(errors.New("the stream is closed"))
+		return promise
+	}
+
+	s.writes.run(func() {
+		if err := op(); err != nil {
+			reject(err)
+			return
+		}
+
+		resolve(goja.Undefined())
+	})
+
+	return promise
+}
+
+// InputStreamWriter writes chunks to an InputStream, following the web streams writer API.
+type InputStreamWriter struct {
+	stream   *InputStream
+	released bool
+}
+
+// Write writes chunk to the underlying stream.
+func (w *InputStreamWriter) Write(chunk goja.Value) *goja.Promise {
+	return w.stream.Write(chunk, goja.Undefined())
+}
+
+// Close closes the underlying stream.
+func (w *InputStreamWriter) Close() *goja.Promise {
+	return w.stream.Close()
+}
+
+// Abort aborts the underlying stream.
+func (w *InputStreamWriter) Abort(reason goja.Value) *goja.Promise {
+	return w.stream.Abort(reason)
+}
+
+// ReleaseLock releases the writer's lock on the stream.
+func (w *InputStreamWriter) ReleaseLock() {
+	if !w.released {
+		w.released = true
+		w.stream.locked = false
+	}
+}
@@ -0,0 +1,122 @@
+//go:build !windows
+
+package exec
+
+import (
+	"strings"
+	"testing"
+
+	"go.k6.io/k6/lib"
+)
+
+func TestOutputStream(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		script  string
+		want    string
+		wantErr string
+	}{
+		{
+			name: "reader",
+			script: `(async () => {
+				const proc = new exec.Cmd("sh").args(["-c", "printf 'a\\nb\\n'"]).spawn();
+				const reader = proc.stdout.getReader();
+				let output = "";
+				for (let r = await reader.read(); !r.done; r = await reader.read()) {
+					output += String.fromCharCode(...r.value);
+				}
+				await proc.wait();
+				return output;
+			})()`,
+			want: "a\nb\n",
+		},
+		{
+			name: "pulled into a controller",
+			script: `(async () => {
+				const proc = new exec.Cmd("sh").args(["-c", "printf 'a\\nb\\n'"]).spawn();
+				let output = "", closed = false;
+				const controller = {
+					enqueue: (chunk) => { output += String.fromCharCode(...chunk); },
+					close: () => { closed = true; },
+				};
+				while (!closed) {
+					await proc.stdout.pull(controller);
+				}
+				await proc.wait();
+				return output;
+			})()`,
+			want: "a\nb\n",
+		},
+		{
+			name: "locked",
+			script: `(async () => {
+				const proc = new exec.Cmd("true").spawn();
+				const reader = proc.stdout.getReader();
+				const locked = proc.stdout.locked();
+				reader.releaseLock();
+				await proc.wait();
+				return locked + " " + proc.stdout.locked();
+			})()`,
+			want: "true false",
+		},
+		{
+			name: "locked to another reader",
+			script: `(async () => {
+				const proc = new exec.Cmd("true").spawn();
+				proc.stdout.getReader();
+				proc.stdout.getReader();
+			})()`,
+			wantErr: "the stream is already locked to a reader",
+		},
+		{
+			name: "released reader",
+			script: `(async () => {
+				const proc = new exec.Cmd("true").spawn();
+				const reader = proc.stdout.getReader();
+				reader.releaseLock();
+				await proc.wait();
+				await reader.read();
+			})()`,
+			wantErr: "the reader's lock on the stream was released",
+		},
+		{
+			name: "cancelled",
+			script: `(async () => {
+				const proc = new exec.Cmd("sh").args(["-c", "echo a"]).spawn();
+				const reader = proc.stdout.getReader();
+				await reader.cancel();
+				const r = await reader.read();
+				await proc.wait();
+				return "" + r.done;
+			})()`,
+			want: "true",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			vu := newTestVU(t, nil)
+			vu.moveToVUContext(lib.Options{})
+
+			got, err := vu.run(tt.script)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("the script failed with %v, want an error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.String() != tt.want {
+				t.Errorf("the script returned %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package exec
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"go.k6.io/k6/js/common"
+)
+
+// SudoOptions configures how a command is run with elevated privileges.
+type SudoOptions struct {
+	// Tool is the privilege escalation tool used, either sudo (the default) or doas.
+	Tool string `js:"tool"`
+
+	// User is the user the command runs as. Defaults to the tool's default, root.
+	User string `js:"user"`
+
+	// NonInteractive makes the tool fail, rather than prompt,
+	// when a password is required.
+	NonInteractive bool `js:"nonInteractive"`
+
+	// PasswordEnv is the name of the environment variable of the k6 process
+	// holding the password fed to the tool, through its standard input.
+	PasswordEnv string `js:"passwordEnv"`
+}
+
+// passwordRequiredMessages are the messages the privilege escalation tools fail
+// with when a password is required, but can't be prompted for.
+var passwordRequiredMessages = []string{ //nolint:gochecknoglobals
+	"a password is required",
+	"a terminal is required",
+	"Authentication required",
+}
+
+// Sudo returns a copy of the command run with elevated privileges, using sudo or doas.
+func (c Command) Sudo(opts SudoOptions) Command {
+	if opts.Tool == "" {
+		opts.Tool = "sudo"
+	}
+
+	switch {
+	case opts.Tool != "sudo" && opts.Tool != "doas":
+		common.Throw(c.vu.Runtime(), fmt.Errorf("unsupported privilege escalation tool %q; expected sudo or doas", opts.Tool))
+	case opts.Tool == "doas" && opts.PasswordEnv != "":
+		common.Throw(c.vu.Runtime(), errors.New("doas can't read passwords from its standard input; use sudo instead"))
+	}
+
+	c.sudo = &opts
+	return c
+}
+
+// wrap returns the executable and arguments running the named command through the tool.
+func (o *SudoOptions) wrap(name string, args []string) (string, []string) {
+	wrapped := make([]string, 0, len(args)+6)
+	if o.NonInteractive {
+		wrapped = append(wrapped, "-n")
+	}
+	if o.User != "" {
+		wrapped = append(wrapped, "-u", o.User)
+	}
+	if o.PasswordEnv != "" {
+		// The prompt is disabled, as it would be written to the standard error.
+		wrapped = append(wrapped, "-S", "-p", "")
+	}
+	wrapped = append(wrapped, "--", name)
+
+	return o.Tool, append(wrapped, args...)
+}
+
+// password returns the password fed to the tool, read from its environment variable.
+func (o *SudoOptions) password() (string, error) {
+	password, ok := os.LookupEnv(o.PasswordEnv)
+	if !ok {
+		return "", fmt.Errorf("the %s environment variable holding the %s password is not set", o.PasswordEnv, o.Tool)
+	}
+
+	return password, nil
+}
+
+// failure returns a descriptive error if the named command failed because
+// the tool required a password it couldn't obtain. It is nil-safe.
+func (o *SudoOptions) failure(name string, exitCode int, stderr capturedOutput) error {
+	if o == nil || exitCode == 0 {
+		return nil
+	}
+
+	b, err := stderr.bytes()
+	if err != nil {
+		return nil //nolint:nilerr
+	}
+
+	for _, msg := range passwordRequiredMessages {
+		if bytes.Contains(b, []byte(msg)) && bytes.HasPrefix(b, []byte(o.Tool+":")) {
+			user := o.User
+			if user == "" {
+				user = "root"
+			}
+
+			return fmt.Errorf("%s requires a password to run %q as %s; allow it to run without one, "+
+				"or provide the password using passwordEnv", o.Tool, name, user)
+		}
+	}
+
+	return nil
+}
+
+// withoutEnvVar returns environ, a list of KEY=value pairs, without the named variable.
+func withoutEnvVar(environ []string, key string) []string {
+	filtered := make([]string, 0, len(environ))
+	for _, kv := range environ {
+		if !strings.HasPrefix(kv, key+"=") {
+			filtered = append(filtered, kv)
+		}
+	}
+
+	return filtered
+}
@@ -0,0 +1,45 @@
+package exec
+
+import (
+	"time"
+
+	"go.k6.io/k6/lib"
+)
+
+// pausePollInterval is the interval at which whether the test is paused is checked.
+const pausePollInterval = 100 * time.Millisecond
+
+// suspendWhilePaused suspends the command while the test is paused, and
+// resumes it once the test is resumed, until the command exits.
+func (e *execution) suspendWhilePaused(es *lib.ExecutionState, logger func(err error, msg string)) {
+	ticker := time.NewTicker(pausePollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-e.exited:
+			return
+		}
+
+		if !es.IsPaused() {
+			continue
+		}
+
+		if err := suspendProcess(e.cmd.Process); err != nil {
+			logger(err, "unable to suspend "+e.command.Name+" while the test is paused")
+			return
+		}
+
+		select {
+		case <-es.ResumeNotify():
+		case <-e.exited:
+			return
+		}
+
+		if err := resumeProcess(e.cmd.Process); err != nil {
+			logger(err, "unable to resume "+e.command.Name+" after the test was resumed")
+			return
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package exec
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/dop251/goja"
+)
+
+// systemctlTimeout bounds the time waited for systemctl to signal or stop a unit.
+const systemctlTimeout = 5 * time.Second
+
+// systemdPropertyAliases maps the shorthand options of the systemd
+// option to the unit properties they set.
+var systemdPropertyAliases = map[string]string{ //nolint:gochecknoglobals
+	"cpuQuota":  "CPUQuota",
+	"memoryMax": "MemoryMax",
+	"tasksMax":  "TasksMax",
+	"ioWeight":  "IOWeight",
+}
+
+// systemdUnitCount counts the transient units started, to name them uniquely.
+var systemdUnitCount atomic.Uint64 //nolint:gochecknoglobals
+
+// systemdUnit describes the transient scope unit a command is run as, using systemd-run.
+//
+// systemd-run is used rather than talking to systemd over D-Bus, which keeps the
+// extension free of a D-Bus dependency, and works the same for the system and user
+// managers. As scopes run the command directly, its exit status is its own.
+type systemdUnit struct {
+	// user makes the unit be started by the user's service manager.
+	user bool
+	// slice is the slice the unit is placed in, if any.
+	slice string
+	// properties are the unit properties, as Name=value pairs.
+	properties []string
+
+	// name is the name of the unit the command was last run as.
+	name string
+}
+
+// parseSystemdUnit parses the systemd option, either true, or an object holding the user
+// and slice options, properties to set on the unit, and shorthands for common properties.
+func parseSystemdUnit(rt *goja.Runtime, v goja.Value) (*systemdUnit, error) {
+	unit := &systemdUnit{}
+	if b, ok := v.Export().(bool); ok {
+		if !b {
+			return nil, nil //nolint:nilnil
+		}
+		return unit, nil
+	}
+
+	obj := v.ToObject(rt)
+	for _, key := range obj.Keys() {
+		value := obj.Get(key)
+
+		switch key {
+		case "user":
+			unit.user = value.ToBoolean()
+		case "slice":
+			unit.slice = value.String()
+		case "properties":
+			var properties map[string]string
+			if err := rt.ExportTo(value, &properties); err != nil {
+				return nil, fmt.Errorf("systemd properties must be an object: %w", err)
+			}
+			for name, value := range properties {
+				unit.properties = append(unit.properties, name+"="+value)
+			}
+		default:
+			property, ok := systemdPropertyAliases[key]
+			if !ok {
+				return nil, fmt.Errorf("unknown systemd option %q", key)
+			}
+			unit.properties = append(unit.properties, property+"="+value.String())
+		}
+	}
+	sort.Strings(unit.properties)
+
+	return unit, nil
+}
+
+// wrap returns the executable and arguments running the named command as a new transient scope unit.
+func (u *systemdUnit) wrap(name string, args []string) (string, []string) {
+	u.name = "k6-exec-" + strconv.Itoa(os.Getpid()) + "-" + strconv.FormatUint(systemdUnitCount.Add(1), 10) + ".scope"
+
+	wrapped := make([]string, 0, len(args)+len(u.properties)*2+8)
+	wrapped = append(wrapped, "--scope", "--quiet", "--collect", "--unit="+u.name)
+	if u.user {
+		wrapped = append(wrapped, "--user")
+	}
+	if u.slice != "" {
+		wrapped = append(wrapped, "--slice="+u.slice)
+	}
+	for _, property := range u.properties {
+		wrapped = append(wrapped, "--property="+property)
+	}
+	wrapped = append(wrapped, "--", name)
+
+	return "systemd-run", append(wrapped, args...)
+}
+
+// signal sends the named signal to all the processes of the unit.
+func (u *systemdUnit) signal(signal string) error {
+	return u.systemctl("kill", "--signal="+signal)
+}
+
+// stop stops the unit, terminating the processes the command left behind, if any.
+func (u *systemdUnit) stop() error {
+	return u.systemctl("stop", "--no-block")
+}
+
+// systemctl runs the systemctl verb on the unit.
+func (u *systemdUnit) systemctl(verb string, args ...string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), systemctlTimeout)
+	defer cancel()
+
+	if u.user {
+		args = append(args, "--user")
+	}
+	args = append(append([]string{verb}, args...), u.name)
+
+	if out, err := exec.CommandContext(ctx, "systemctl", args...).CombinedOutput(); err != nil {
+		return fmt.Errorf("systemctl %s %s failed: %w: %s", verb, u.name, err, out)
+	}
+
+	return nil
+}
@@ -0,0 +1,157 @@
+package exec
+
+import (
+	"strings"
+)
+
+// parseKeyValues parses KEY=VALUE lines, such as the output of env or the content of
+// os-release files, into an object. Blank lines and lines starting with # are ignored,
+// and values surrounded by matching quotes are unquoted.
+func parseKeyValues(s string) map[string]string {
+	values := make(map[string]string)
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+
+		value = strings.TrimSpace(value)
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+
+		values[strings.TrimSpace(key)] = value
+	}
+
+	return values
+}
+
+// parseTable parses a whitespace-aligned table, such as the output of df or kubectl get, into
+// an array of objects keyed by the column names found in its first line.
+//
+// Columns are delimited by the ranges of positions which are blank on all lines, so that both
+// left and right-aligned columns are supported. A column holding no value on any row is
+// merged into the previous one, so that column names holding spaces are kept together.
+func parseTable(s string) []map[string]string {
+	lines := make([]string, 0)
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.TrimRight(line, " \t\r")
+		if line != "" {
+			lines = append(lines, strings.ReplaceAll(line, "\t", " "))
+		}
+	}
+
+	rows := make([]map[string]string, 0, len(lines))
+	if len(lines) == 0 {
+		return rows
+	}
+
+	columns := mergeEmptyColumns(lines, tableColumns(lines))
+
+	header := make([]string, len(columns))
+	for i, col := range columns {
+		header[i] = cell(lines[0], col)
+	}
+
+	for _, line := range lines[1:] {
+		row := make(map[string]string, len(columns))
+		for i, col := range columns {
+			row[header[i]] = cell(line, col)
+		}
+
+		rows = append(rows, row)
+	}
+
+	return rows
+}
+
+// tableColumn is the range of positions of a table column. The last column has no end.
+type tableColumn struct {
+	start, end int
+}
+
+// tableColumns returns the columns of the table made of lines, delimited
+// by the ranges of positions which are blank on all of them.
+func tableColumns(lines []string) []tableColumn {
+	var width int
+	for _, line := range lines {
+		if len(line) > width {
+			width = len(line)
+		}
+	}
+
+	blank := make([]bool, width)
+	for i := range blank {
+		blank[i] = true
+		for _, line := range lines {
+			if i < len(line) && line[i] != ' ' {
+				blank[i] = false
+				break
+			}
+		}
+	}
+
+	var columns []tableColumn
+	for i := 0; i < width; {
+		for i < width && blank[i] {
+			i++
+		}
+		if i == width {
+			break
+		}
+
+		start := i
+		for i < width && !blank[i] {
+			i++
+		}
+		columns = append(columns, tableColumn{start: start, end: i})
+	}
+
+	if len(columns) > 0 {
+		columns[len(columns)-1].end = -1
+	}
+
+	return columns
+}
+
+// mergeEmptyColumns merges the columns holding no value on any row into the previous one.
+func mergeEmptyColumns(lines []string, columns []tableColumn) []tableColumn {
+	merged := make([]tableColumn, 0, len(columns))
+	for _, col := range columns {
+		empty := true
+		for _, line := range lines[1:] {
+			if cell(line, col) != "" {
+				empty = false
+				break
+			}
+		}
+
+		if empty && len(merged) > 0 && len(lines) > 1 {
+			merged[len(merged)-1].end = col.end
+			continue
+		}
+
+		merged = append(merged, col)
+	}
+
+	return merged
+}
+
+// cell returns the trimmed content of line within col.
+func cell(line string, col tableColumn) string {
+	if col.start >= len(line) {
+		return ""
+	}
+
+	end := col.end
+	if end < 0 || end > len(line) {
+		end = len(line)
+	}
+
+	return strings.TrimSpace(line[col.start:end])
+}
@@ -0,0 +1,103 @@
+package exec
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseKeyValues(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		input string
+		want  map[string]string
+	}{
+		{name: "empty", input: "", want: map[string]string{}},
+		{name: "pairs", input: "A=1\nB=two\n", want: map[string]string{"A": "1", "B": "two"}},
+		{
+			name:  "os-release",
+			input: "# comment\nNAME=\"Ubuntu\"\n\nVERSION_ID='22.04'\nID=ubuntu\n",
+			want:  map[string]string{"NAME": "Ubuntu", "VERSION_ID": "22.04", "ID": "ubuntu"},
+		},
+		{name: "blanks", input: "  KEY = value  \r\n", want: map[string]string{"KEY": "value"}},
+		{name: "empty value", input: "EMPTY=\nQUOTED=\"\"", want: map[string]string{"EMPTY": "", "QUOTED": ""}},
+		{name: "equal signs in values", input: "OPTS=a=b=c", want: map[string]string{"OPTS": "a=b=c"}},
+		{name: "mismatched quotes", input: "A=\"x'\nB=\"y\nC=\"", want: map[string]string{"A": "\"x'", "B": "\"y", "C": "\""}},
+		{name: "lines without equal sign", input: "garbage\nA=1", want: map[string]string{"A": "1"}},
+		{name: "later values win", input: "A=1\nA=2", want: map[string]string{"A": "2"}},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := parseKeyValues(tt.input); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseKeyValues(%q) = %v, want %v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTable(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		input string
+		want  []map[string]string
+	}{
+		{name: "empty", input: "", want: []map[string]string{}},
+		{name: "blank lines only", input: "\n  \n\t\n", want: []map[string]string{}},
+		{name: "header only", input: "NAME   READY\n", want: []map[string]string{}},
+		{
+			name: "left-aligned",
+			input: "NAME    READY   STATUS\n" +
+				"web-1   1/1     Running\n" +
+				"db-0    0/1     Pending\n",
+			want: []map[string]string{
+				{"NAME": "web-1", "READY": "1/1", "STATUS": "Running"},
+				{"NAME": "db-0", "READY": "0/1", "STATUS": "Pending"},
+			},
+		},
+		{
+			name: "right-aligned and names holding spaces",
+			input: "Filesystem  Size  Used Mounted on\n" +
+				"/dev/sda1    50G   20G /\n" +
+				"tmpfs       1.0G     0 /dev/shm\n",
+			want: []map[string]string{
+				{"Filesystem": "/dev/sda1", "Size": "50G", "Used": "20G", "Mounted on": "/"},
+				{"Filesystem": "tmpfs", "Size": "1.0G", "Used": "0", "Mounted on": "/dev/shm"},
+			},
+		},
+		{
+			name: "missing cells",
+			input: "NAME   PORTS   AGE\n" +
+				"a      80      1d\n" +
+				"b              2d\n" +
+				"c      443\n",
+			want: []map[string]string{
+				{"NAME": "a", "PORTS": "80", "AGE": "1d"},
+				{"NAME": "b", "PORTS": "", "AGE": "2d"},
+				{"NAME": "c", "PORTS": "443", "AGE": ""},
+			},
+		},
+		{
+			name:  "tabs and carriage returns",
+			input: "A\tB\r\n1\t2\r\n",
+			want:  []map[string]string{{"A": "1", "B": "2"}},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := parseTable(tt.input); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseTable(%q) = %v, want %v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,190 @@
+package exec
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.k6.io/k6/js/modules"
+	"go.k6.io/k6/lib"
+)
+
+// testEndPollInterval is how often the execution state is checked for the end of the test,
+// when it can't be observed otherwise.
+const testEndPollInterval = 100 * time.Millisecond
+
+// testRun tracks the phases of the test run, which k6 doesn't notify extensions of, out of the
+// VUs it initializes, and runs what is bound to them, such as the hooks declared in the test options.
+//
+// The VUs running the scenarios are initialized first, with a context derived from the one of
+// the test run, done once all of them were, before setup() and the scenarios start. The VU
+// running setup(), if it is exported, is then initialized with the execution state of the test,
+// and the ones running teardown() and handleSummary(), unless they don't run, once the scenarios
+// ended. When neither of them runs, the end of the test is polled from its execution state, if
+// known, which is best-effort, as k6 may exit before it is noticed.
+type testRun struct {
+	hooks  *testHooks
+	aborts *abortHandlers
+	policy *operatorPolicy
+
+	mu sync.Mutex
+
+	// ctx is the context the first VU running the scenarios was initialized with.
+	ctx context.Context
+
+	// ended is true once the test ended, and cleanups holds what is run then.
+	ended    bool
+	cleanups []func()
+
+	endOnce   sync.Once
+	watchOnce sync.Once
+}
+
+// observe tracks the phases of the test run out of vu, a VU being initialized, whose module
+// instance is configured by config.
+func (tr *testRun) observe(vu modules.VU, config *moduleConfig) {
+	ctx := vu.Context()
+	// The options are evaluated, and archives built, with a context which can't be done.
+	if ctx == nil || ctx.Done() == nil {
+		return
+	}
+
+	es := lib.GetExecutionState(ctx)
+	switch {
+	case vuID(vu) > 0:
+		tr.mu.Lock()
+		first := tr.ctx == nil
+		if first {
+			tr.ctx = ctx
+		}
+		tr.mu.Unlock()
+
+		if first {
+			tr.hooks.schedule()
+			go tr.start(vu, ctx, config)
+		}
+	case es != nil:
+		// setup() runs before the scenarios, once the test started.
+		tr.watch(es)
+		tr.hooks.waitStarted()
+	case tr.initialized():
+		tr.end()
+	}
+}
+
+// start starts the test once the VUs running the scenarios, initialized with ctx, were, vu being
+// the first of them, configured by config. Its state is set once it was initialized, unless it
+// failed to, and neither of them is written to before the test started.
+func (tr *testRun) start(vu modules.VU, ctx context.Context, config *moduleConfig) {
+	<-ctx.Done()
+
+	tr.hooks.startEagerly(ctx, vu.State(), tr.policy, config.resolve(vu))
+}
+
+// initialized returns true once the VUs running the scenarios were initialized.
+func (tr *testRun) initialized() bool {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+
+	return tr.ctx != nil && tr.ctx.Err() != nil
+}
+
+// onEnd registers fn to be run once the test ended, and returns false if the end of the test
+// can't be observed, as it already ended, or as ctx, a VU context, isn't one of a test run.
+func (tr *testRun) onEnd(ctx context.Context, fn func()) bool {
+	var es *lib.ExecutionState
+	if ctx != nil {
+		es = lib.GetExecutionState(ctx)
+	}
+
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+
+	if !tr.observableLocked(es) {
+		return false
+	}
+	tr.cleanups = append(tr.cleanups, fn)
+	if es != nil {
+		tr.watch(es)
+	}
+
+	return true
+}
+
+// observable returns true unless the end of the test can't be observed, as onEnd reports.
+func (tr *testRun) observable(ctx context.Context) bool {
+	var es *lib.ExecutionState
+	if ctx != nil {
+		es = lib.GetExecutionState(ctx)
+	}
+
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+
+	return tr.observableLocked(es)
+}
+
+// observableLocked returns true unless the test ended, or neither the VUs running the scenarios
+// were observed, nor es, the execution state of the test, is known. The mutex must be held.
+func (tr *testRun) observableLocked(es *lib.ExecutionState) bool {
+	return !tr.ended && (tr.ctx != nil || es != nil)
+}
+
+// hasEnded returns true once the test ended.
+func (tr *testRun) hasEnded() bool {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+
+	return tr.ended
+}
+
+// end runs, once, the abort handlers, if the test run was aborted, the cleanups registered by VUs,
+// in the reverse order they were registered in, and then the end hooks. It blocks until they
+// completed, so that k6 doesn't exit before.
+func (tr *testRun) end() {
+	tr.endOnce.Do(func() {
+		tr.mu.Lock()
+		tr.ended = true
+		cleanups := tr.cleanups
+		tr.cleanups = nil
+		tr.mu.Unlock()
+
+		tr.aborts.runIfAborted()
+		for i := len(cleanups) - 1; i >= 0; i-- {
+			cleanups[i]()
+		}
+
+		tr.hooks.end()
+	})
+}
+
+// watch polls es, the execution state of the test, if known, for its end, for the end of the test
+// to be noticed when neither teardown() nor handleSummary() runs. Only the first call starts polling.
+func (tr *testRun) watch(es *lib.ExecutionState) {
+	if tr == nil || es == nil {
+		return
+	}
+
+	tr.watchOnce.Do(func() {
+		go func() {
+			ticker := time.NewTicker(testEndPollInterval)
+			defer ticker.Stop()
+
+			for !es.HasEnded() && !tr.hasEnded() {
+				<-ticker.C
+			}
+
+			tr.end()
+		}()
+	})
+}
+
+// vuID returns the ID of vu, as exposed to scripts as __VU, which is zero for the VUs running
+// setup(), teardown() and handleSummary().
+func vuID(vu modules.VU) int64 {
+	if id := vu.Runtime().Get("__VU"); id != nil {
+		return id.ToInteger()
+	}
+
+	return 0
+}
@@ -0,0 +1,191 @@
+package exec
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dop251/goja"
+	"go.k6.io/k6/js/common"
+	"go.k6.io/k6/lib/types"
+	"go.k6.io/k6/metrics"
+)
+
+// defaultThresholdTriggerTimeout is how long threshold triggers are allowed to run by default.
+const defaultThresholdTriggerTimeout = 30 * time.Second
+
+// thresholdPollInterval is how often the thresholds are checked for being crossed, which k6
+// doesn't notify extensions of: as often as k6 itself evaluates them while the test runs.
+const thresholdPollInterval = 2 * time.Second
+
+// thresholdTriggers holds the commands run once thresholds are crossed, registered by all the
+// VUs of the test. As each VU registers the same triggers, they are deduplicated.
+type thresholdTriggers struct {
+	mu       sync.Mutex
+	keys     map[string]bool
+	triggers []*thresholdTrigger
+
+	watchOnce sync.Once
+}
+
+// thresholdTrigger is a command run the first time the thresholds of a metric are crossed.
+type thresholdTrigger struct {
+	// metric is the name of the metric whose thresholds are watched, or of the
+	// sub-metric, such as http_req_duration{scenario:spike}.
+	metric  string
+	handler *detachedCommand
+	fired   bool
+}
+
+// OnThreshold registers a command, either a Cmd or an array holding the name of the command
+// followed by its arguments, run the first time the thresholds defined on the named metric, or
+// sub-metric, are crossed while the test runs, for instance to capture diagnostic state of the
+// system under test as soon as it degrades. It is run once per test, even when every VU registers
+// it, and in the background, not holding the test up.
+func (mi *ModuleInstance) OnThreshold(metric string, spec goja.Value, options goja.Value) {
+	rt := mi.vu.Runtime()
+
+	command, ok := mi.commandFromSpec(spec)
+	if !ok {
+		common.Throw(rt, errors.New("onThreshold expects a metric name, and a Cmd or an array holding a command and its arguments"))
+	}
+
+	timeout, err := parseThresholdTriggerOptions(rt, options)
+	if err != nil {
+		common.Throw(rt, err)
+	}
+
+	if reason := prohibitedReason(command.config.resolve(mi.vu)); reason != "" {
+		if _, err := command.prohibited(reason); err != nil {
+			command.throw(err)
+		}
+		return
+	}
+
+	handler, err := command.detach(timeout)
+	if err != nil {
+		common.Throw(rt, err)
+	}
+	mi.thresholds.add(metric+" "+command.key(), &thresholdTrigger{metric: metric, handler: handler})
+	mi.thresholds.watch(mi.Metrics.registry, mi.testRun)
+}
+
+// add registers trigger, unless a trigger with the same key already was.
+func (tt *thresholdTriggers) add(key string, trigger *thresholdTrigger) {
+	tt.mu.Lock()
+	defer tt.mu.Unlock()
+
+	if tt.keys[key] {
+		trigger.handler.cancel()
+		return
+	}
+
+	if tt.keys == nil {
+		tt.keys = make(map[string]bool)
+	}
+	tt.keys[key] = true
+	tt.triggers = append(tt.triggers, trigger)
+}
+
+// watch periodically checks the thresholds of the metrics of registry, and runs the triggers
+// of the ones which were crossed, until tr, the test run, ended. The thresholds are evaluated by
+// k6, which marks the metrics whose thresholds were crossed as tainted.
+func (tt *thresholdTriggers) watch(registry *metrics.Registry, tr *testRun) {
+	if registry == nil {
+		return
+	}
+
+	tt.watchOnce.Do(func() {
+		go func() {
+			ticker := time.NewTicker(thresholdPollInterval)
+			defer ticker.Stop()
+
+			for range ticker.C {
+				if tr.hasEnded() {
+					return
+				}
+				tt.check(registry)
+			}
+		}()
+	})
+}
+
+// check runs the triggers whose metric's thresholds were crossed, which didn't run yet.
+//
+// k6 marks the metrics as tainted holding the lock of its metrics engine, which isn't exposed to
+// extensions, so that they are read unsynchronized. As they are only written to when the
+// thresholds are evaluated, every two seconds, which check is rate-limited to, a stale read only
+// delays the trigger until the next check.
+func (tt *thresholdTriggers) check(registry *metrics.Registry) {
+	tt.mu.Lock()
+	var crossed []*thresholdTrigger
+	for _, trigger := range tt.triggers {
+		if trigger.fired {
+			continue
+		}
+
+		if metric := lookupMetric(registry, trigger.metric); metric != nil && metric.Tainted.Bool {
+			trigger.fired = true
+			crossed = append(crossed, trigger)
+		}
+	}
+	tt.mu.Unlock()
+
+	for _, trigger := range crossed {
+		go func(trigger *thresholdTrigger) {
+			handler := trigger.handler
+			logger := handler.logger.WithField("metric", trigger.metric)
+			logger.Infof("the thresholds of %s were crossed, running the %s trigger", trigger.metric, handler.name)
+
+			if err := handler.run(); err != nil {
+				logger.WithError(err).Warnf("the %s threshold trigger failed", handler.name)
+			}
+		}(trigger)
+	}
+}
+
+// lookupMetric returns the named metric of registry, or the named sub-metric, such as
+// http_req_duration{scenario:spike}, or nil if it doesn't exist (yet).
+func lookupMetric(registry *metrics.Registry, name string) *metrics.Metric {
+	parent, _, isSubmetric := strings.Cut(name, "{")
+
+	metric := registry.Get(parent)
+	if metric == nil || !isSubmetric {
+		return metric
+	}
+
+	for _, submetric := range metric.Submetrics {
+		if submetric.Name == name {
+			return submetric.Metric
+		}
+	}
+
+	return nil
+}
+
+// parseThresholdTriggerOptions parses the options object optionally passed to
+// OnThreshold, and returns how long the trigger is allowed to run.
+func parseThresholdTriggerOptions(rt *goja.Runtime, v goja.Value) (time.Duration, error) {
+	timeout := defaultThresholdTriggerTimeout
+	if common.IsNullish(v) {
+		return timeout, nil
+	}
+
+	obj := v.ToObject(rt)
+	for _, key := range obj.Keys() {
+		switch key {
+		case "timeout":
+			d, err := types.GetDurationValue(obj.Get(key).Export())
+			if err != nil {
+				return 0, fmt.Errorf("invalid timeout: %w", err)
+			}
+			timeout = d
+		default:
+			return 0, fmt.Errorf("unknown onThreshold option %q", key)
+		}
+	}
+
+	return timeout, nil
+}
@@ -0,0 +1,75 @@
+package exec
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/dop251/goja"
+	"go.k6.io/k6/js/common"
+	"go.k6.io/k6/lib/types"
+)
+
+// defaultTimeoutGrace is how long commands which timed out are given to exit once
+// they were sent the signal set using Timeout, before being killed.
+const defaultTimeoutGrace = 5 * time.Second
+
+// commandTimeout is how long a command is allowed to run, as set using Timeout,
+// and the sequence of signals it is terminated with once it timed out, if any.
+type commandTimeout struct {
+	duration     time.Duration
+	killSequence []killStep
+}
+
+// Timeout returns a copy of the command allowed to run for timeout before being terminated, and its
+// execution failing. It accepts an optional object whose signal key is the signal the command is first
+// sent, SIGTERM by default, and whose grace key is how long it is then given to exit before being
+// killed, 5s by default, the command being terminated the same way without it. The timeout and
+// killSequence execution options take precedence over it.
+func (c Command) Timeout(timeout goja.Value, options goja.Value) Command {
+	rt := c.vu.Runtime()
+
+	duration, err := types.GetDurationValue(timeout.Export())
+	if err != nil || duration <= 0 {
+		common.Throw(rt, errors.New("timeout expects a positive duration, such as \"30s\""))
+	}
+
+	signal, grace := "SIGTERM", defaultTimeoutGrace
+	if !common.IsNullish(options) {
+		obj := options.ToObject(rt)
+		if v := obj.Get("signal"); !common.IsNullish(v) {
+			if signal, err = normalizeSignal(v.String()); err != nil {
+				common.Throw(rt, fmt.Errorf("invalid timeout signal: %w", err))
+			}
+		}
+		if v := obj.Get("grace"); !common.IsNullish(v) {
+			if grace, err = types.GetDurationValue(v.Export()); err != nil {
+				common.Throw(rt, fmt.Errorf("invalid timeout grace: %w", err))
+			}
+		}
+	}
+
+	c.timeout = &commandTimeout{
+		duration:     duration,
+		killSequence: []killStep{{signal: signal, wait: grace}, {signal: "SIGKILL"}},
+	}
+	return c
+}
+
+// withTimeout returns opts, or a copy of opts bounding the execution
+// by the command's timeout, if it has one and opts doesn't override it.
+func (c *Command) withTimeout(opts *execOptions) *execOptions {
+	if c.timeout == nil || (opts.timeout > 0 && len(opts.killSequence) > 0) {
+		return opts
+	}
+
+	bounded := *opts
+	if bounded.timeout == 0 {
+		bounded.timeout = c.timeout.duration
+	}
+	if len(bounded.killSequence) == 0 {
+		bounded.killSequence = c.timeout.killSequence
+	}
+
+	return &bounded
+}
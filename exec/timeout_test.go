@@ -0,0 +1,60 @@
+//go:build !windows
+
+package exec
+
+import (
+	"testing"
+
+	"go.k6.io/k6/lib"
+)
+
+func TestCommandTimeout(t *testing.T) {
+	t.Parallel()
+
+	// The command exits with 7 once it was sent SIGTERM, and with 8 once it was sent SIGINT.
+	const trapping = `new exec.Cmd("sh").args(["-c", "trap 'exit 7' TERM; trap 'exit 8' INT; sleep 5 >/dev/null 2>&1 & wait"])`
+
+	tests := []struct {
+		name    string
+		command string
+		want    string
+	}{
+		{name: "graceful by default", command: trapping + `.timeout("100ms")`, want: `[true,7,false]`},
+		{name: "signal", command: trapping + `.timeout("100ms", { signal: "INT" })`, want: `[true,8,false]`},
+		{
+			name:    "killed after the grace period",
+			command: `new exec.Cmd("sh").args(["-c", "trap '' TERM; sleep 5 >/dev/null 2>&1 & wait"]).timeout("100ms", { grace: "100ms" })`,
+			want:    `[true,-1,true]`,
+		},
+		{name: "exited in time", command: `new exec.Cmd("true").timeout("5s")`, want: `[false,0,false]`},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			vu := newTestVU(t, nil)
+			vu.moveToVUContext(lib.Options{})
+
+			got := vu.mustRun(`new Promise((resolve) => {
+				` + tt.command + `.spawn().on("exit", (r) => resolve(JSON.stringify([r.timedOut, r.exitCode, r.signaled])));
+			})`).String()
+			if got != tt.want {
+				t.Errorf("the result of %s is %s, want %s", tt.command, got, tt.want)
+			}
+		})
+	}
+
+	t.Run("rejection", func(t *testing.T) {
+		t.Parallel()
+
+		vu := newTestVU(t, nil)
+		vu.moveToVUContext(lib.Options{})
+
+		got := vu.mustRun(trapping + `.timeout("100ms").exec().then(() => "resolved", (err) => JSON.stringify([err.timedOut, err.exitCode]))`).String()
+		if want := `[true,7]`; got != want {
+			t.Errorf("the error is %s, want %s", got, want)
+		}
+	})
+}
@@ -0,0 +1,180 @@
+package exec
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// transcriptCount counts the transcripts written, to name them uniquely.
+var transcriptCount atomic.Uint64 //nolint:gochecknoglobals
+
+// unsafeFileNameChars matches the characters replaced in the names of transcript files.
+var unsafeFileNameChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`) //nolint:gochecknoglobals
+
+// transcript is a self-contained record of an execution, written to a file: its command
+// line, environment, the output of its streams interleaved as it is read, and its exit status.
+type transcript struct {
+	mu    sync.Mutex
+	f     *os.File
+	start time.Time
+}
+
+// openTranscript creates the execution's transcript file in the transcript directory,
+// and writes its header. As transcripts are best-effort, failures are logged rather
+// than failing the execution.
+func (e *execution) openTranscript() {
+	if err := os.MkdirAll(e.opts.transcriptDir, 0o755); err != nil { //nolint:gosec
+		e.logger().WithError(err).Warnf("unable to create the transcript directory %s", e.opts.transcriptDir)
+		return
+	}
+
+	name := fmt.Sprintf("%s-%s-%d", e.start.Format("20060102T150405.000"),
+		unsafeFileNameChars.ReplaceAllString(filepath.Base(e.command.Name), "_"), transcriptCount.Add(1))
+	if state := e.command.vu.State(); state != nil {
+		name += "-vu" + strconv.FormatUint(state.VUID, 10)
+	}
+
+	f, err := os.Create(filepath.Join(e.opts.transcriptDir, name+".log"))
+	if err != nil {
+		e.logger().WithError(err).Warnf("unable to create the transcript of %s", e.command.Name)
+		return
+	}
+
+	e.transcript = &transcript{f: f, start: e.start}
+	// The environment of commands run on remote hosts is passed on their command line.
+	e.transcript.printf("command: %s\n", quoteArgs(e.command.redactArgs(e.cmd.Args)))
+	e.transcript.printf("path: %s\n", e.cmd.Path)
+	dir := e.cmd.Dir
+	if dir == "" {
+		dir, _ = os.Getwd()
+	}
+	e.transcript.printf("dir: %s\n", dir)
+	e.transcript.printf("pid: %d\n", e.cmd.Process.Pid)
+	e.transcript.printf("run id: %s\n", e.runID)
+	e.transcript.printf("started: %s\n", e.start.Format(time.RFC3339Nano))
+
+	set, unset := environDiff(os.Environ(), e.cmd.Env)
+	for _, kv := range set {
+		if key, _, _ := strings.Cut(kv, "="); e.command.sensitive[key] {
+			kv = key + "=" + redactedValue
+		}
+		e.transcript.printf("env: %s\n", kv)
+	}
+	for _, key := range unset {
+		e.transcript.printf("env removed: %s\n", key)
+	}
+	e.transcript.printf("\n")
+}
+
+// closeTranscript writes the footer of the execution's transcript, holding
+// its exit status, and closes it. It must be called once the output was drained.
+func (e *execution) closeTranscript() {
+	t := e.transcript
+	if t == nil {
+		return
+	}
+
+	for _, ts := range e.transcriptStreams {
+		ts.flush()
+	}
+
+	t.printf("\nended: %s\n", e.end.Format(time.RFC3339Nano))
+	t.printf("duration: %s\n", e.end.Sub(e.start))
+	t.printf("exit code: %d\n", e.exitCode)
+	if e.signal != "" {
+		t.printf("signal: %s\n", e.signal)
+	}
+	if reason := e.reason(); reason != "" {
+		t.printf("kill reason: %s\n", reason)
+	}
+
+	if err := t.f.Close(); err != nil {
+		e.logger().WithError(err).Warnf("unable to write the transcript of %s", e.command.Name)
+	}
+}
+
+// printf writes a formatted line to the transcript.
+func (t *transcript) printf(format string, args ...interface{}) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	_, _ = fmt.Fprintf(t.f, format, args...)
+}
+
+// stream returns a writer recording the lines written to it in the transcript,
+// prefixed by the time they were read at, relative to the start of the
+// execution, and the name of the stream they were written to.
+func (t *transcript) stream(name string) *transcriptStream {
+	ts := &transcriptStream{}
+	ts.lineWatcher = lineWatcher{fn: func(line []byte) {
+		t.printf("[%9.3fs %s] %s\n", time.Since(t.start).Seconds(), name, line)
+	}}
+
+	return ts
+}
+
+// transcriptStream is an io.Writer recording the lines of a stream in a transcript.
+type transcriptStream struct {
+	lineWatcher
+}
+
+// flush records the last, incomplete, line written, if any.
+func (ts *transcriptStream) flush() {
+	if len(ts.partial) > 0 {
+		ts.fn(bytes.TrimSuffix(ts.partial, []byte("\r")))
+		ts.partial = nil
+	}
+}
+
+// environDiff returns the variables of environ, a list of KEY=value pairs, which
+// were set or changed from base, and the names of the variables of base it lacks.
+func environDiff(base, environ []string) (set, unset []string) {
+	baseValues := make(map[string]string, len(base))
+	for _, kv := range base {
+		key, value, _ := strings.Cut(kv, "=")
+		baseValues[key] = value
+	}
+
+	present := make(map[string]bool, len(environ))
+	for _, kv := range environ {
+		key, value, _ := strings.Cut(kv, "=")
+		present[key] = true
+		if baseValue, ok := baseValues[key]; !ok || baseValue != value {
+			set = append(set, kv)
+		}
+	}
+
+	for key := range baseValues {
+		if !present[key] {
+			unset = append(unset, key)
+		}
+	}
+	sort.Strings(set)
+	sort.Strings(unset)
+
+	return set, unset
+}
+
+// quoteArgs returns args as a shell command line, quoting the arguments which need it.
+func quoteArgs(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		if arg != "" && !strings.ContainsAny(arg, " \t\n'\"\\$`*?[]{}()<>|&;#~") {
+			quoted[i] = arg
+			continue
+		}
+
+		quoted[i] = "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+	}
+
+	return strings.Join(quoted, " ")
+}
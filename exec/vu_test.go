@@ -0,0 +1,209 @@
+//go:build !windows
+
+package exec
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/dop251/goja"
+	"go.k6.io/k6/js/modulestest"
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/lib/testutils"
+	"go.k6.io/k6/metrics"
+)
+
+// testVU is a VU running scripts which use the module's exports, available as the exec global.
+type testVU struct {
+	t        testing.TB
+	runtime  *modulestest.Runtime
+	registry *metrics.Registry
+	samples  chan metrics.SampleContainer
+
+	// pushed holds the samples received so far.
+	pushed []metrics.Sample
+}
+
+// newTestVU returns a VU in the init context, whose environment variables are the given ones.
+func newTestVU(t testing.TB, env map[string]string) *testVU {
+	t.Helper()
+
+	return newTestVUOf(t, New(), env)
+}
+
+// newTestVUOf returns a VU of the given root module, sharing its state with the other VUs of it,
+// in the init context, whose environment variables are the given ones.
+func newTestVUOf(t testing.TB, root *RootModule, env map[string]string) *testVU {
+	t.Helper()
+
+	return newScenarioVU(t, root, 0, env)
+}
+
+// newScenarioVU returns a VU of the given root module in the init context, as newTestVUOf does,
+// as the ones k6 initializes to run the scenarios, with the given ID; an ID of zero makes it one
+// of the VUs initialized to run setup(), teardown() or handleSummary() instead.
+func newScenarioVU(t testing.TB, root *RootModule, id int64, env map[string]string) *testVU {
+	t.Helper()
+
+	runtime := modulestest.NewRuntime(t)
+	if id > 0 {
+		if err := runtime.VU.Runtime().Set("__VU", id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	runtime.VU.InitEnvField.LookupEnv = func(key string) (string, bool) {
+		value, ok := env[key]
+		return value, ok
+	}
+
+	vu := &testVU{
+		t:        t,
+		runtime:  runtime,
+		registry: runtime.VU.InitEnvField.Registry,
+		samples:  make(chan metrics.SampleContainer, 10000),
+	}
+
+	instance, ok := root.NewModuleInstance(runtime.VU).(*ModuleInstance)
+	if !ok {
+		t.Fatal("the module instance isn't a *ModuleInstance")
+	}
+	if err := runtime.VU.Runtime().Set("exec", instance.Exports().Named); err != nil {
+		t.Fatal(err)
+	}
+
+	return vu
+}
+
+// moveToVUContext moves the VU out of the init context, as when it starts iterating,
+// its state holding the given options.
+func (vu *testVU) moveToVUContext(options lib.Options) {
+	vu.runtime.MoveToVUContext(&lib.State{
+		Options: options,
+		Samples: vu.samples,
+		Tags:    lib.NewVUStateTags(vu.registry.RootTagSet()),
+		Logger:  testutils.NewLogger(vu.t),
+		VUID:    1,
+	})
+}
+
+// startScenarios starts the scenarios run by the given VUs, once they were all initialized, as k6
+// does, moving them to the VU context, and returns a function ending the test, as k6 does once the
+// scenarios ended, by initializing a VU of the root module to run teardown().
+func startScenarios(t testing.TB, root *RootModule, vus ...*testVU) (end func()) {
+	t.Helper()
+
+	return startScenariosWith(t, root, lib.Options{}, vus...)
+}
+
+// startScenariosWith starts the scenarios run by the given VUs as startScenarios does, their state
+// holding the given options.
+func startScenariosWith(t testing.TB, root *RootModule, options lib.Options, vus ...*testVU) (end func()) {
+	t.Helper()
+
+	// The VUs are initialized with a context of their own, done once they all were, their state being
+	// set beforehand.
+	initialized := make([]func(), 0, len(vus))
+	for _, vu := range vus {
+		initialized = append(initialized, vu.runtime.CancelContext)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		t.Cleanup(cancel)
+		vu.runtime.CancelContext = cancel
+		vu.runtime.VU.CtxField = ctx
+		vu.moveToVUContext(options)
+	}
+	for _, cancel := range initialized {
+		cancel()
+	}
+
+	return func() {
+		t.Helper()
+		newTestVUOf(t, root, nil)
+	}
+}
+
+// enterScenario makes the VU run the iterations of the given scenario, as k6 does by setting
+// its state in the context of the VU.
+func (vu *testVU) enterScenario(scenario *lib.ScenarioState) {
+	vu.runtime.VU.CtxField = lib.WithScenarioState(vu.runtime.VU.CtxField, scenario)
+}
+
+// run runs the script until the event loop ran all its callbacks, and returns its value,
+// or, if it is a promise, the value it was resolved with, or the error it was rejected with.
+func (vu *testVU) run(script string) (goja.Value, error) {
+	vu.t.Helper()
+
+	var value goja.Value
+	err := vu.runtime.EventLoop.Start(func() (err error) {
+		value, err = vu.runtime.VU.Runtime().RunString(script)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	promise, ok := value.Export().(*goja.Promise)
+	if !ok {
+		return value, nil
+	}
+
+	switch promise.State() {
+	case goja.PromiseStateFulfilled:
+		return promise.Result(), nil
+	case goja.PromiseStateRejected:
+		return nil, fmt.Errorf("%v", promise.Result())
+	default:
+		return nil, errors.New("the promise is still pending")
+	}
+}
+
+// mustRun runs the script like run, failing the test if it failed.
+func (vu *testVU) mustRun(script string) goja.Value {
+	vu.t.Helper()
+
+	value, err := vu.run(script)
+	if err != nil {
+		vu.t.Fatalf("running %q: %v", script, err)
+	}
+
+	return value
+}
+
+// samplesOf returns the samples of the named metric pushed so far.
+func (vu *testVU) samplesOf(name string) []metrics.Sample {
+	for received := true; received; {
+		select {
+		case container := <-vu.samples:
+			vu.pushed = append(vu.pushed, container.GetSamples()...)
+		default:
+			received = false
+		}
+	}
+
+	var samples []metrics.Sample
+	for _, sample := range vu.pushed {
+		if sample.Metric.Name == name {
+			samples = append(samples, sample)
+		}
+	}
+
+	return samples
+}
+
+// awaitSamplesOf returns the samples of the named metric, once at least one was pushed,
+// as the metrics of executions are pushed once they completed, concurrently with the script.
+func (vu *testVU) awaitSamplesOf(name string) []metrics.Sample {
+	vu.t.Helper()
+
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if samples := vu.samplesOf(name); len(samples) > 0 {
+			return samples
+		}
+	}
+
+	vu.t.Fatalf("no %s sample was pushed", name)
+	return nil
+}
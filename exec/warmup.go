@@ -0,0 +1,113 @@
+package exec
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/dop251/goja"
+	"go.k6.io/k6/js/common"
+	"go.k6.io/k6/lib"
+)
+
+// warmups tracks the warm-up commands a VU executed, so that each of them is only executed once.
+type warmups struct {
+	// done holds the outcome of the executed warm-up commands, keyed by the scenario they were
+	// configured for, the empty string standing for the one configured for all the scenarios.
+	done map[string]error
+}
+
+// parseWarmup parses the warmup configuration option: either a command, a Cmd or an array
+// holding the name of a command followed by its arguments, executed in all the scenarios,
+// or an object whose keys are scenario names, and whose values are such commands.
+func (mi *ModuleInstance) parseWarmup(v goja.Value) (*Command, map[string]*Command, error) {
+	if common.IsNullish(v) {
+		return nil, nil, nil
+	}
+
+	if command, ok := mi.commandFromSpec(v); ok {
+		return command, nil, nil
+	}
+
+	if _, isObject := v.(*goja.Object); !isObject {
+		return nil, nil, errors.New("invalid warmup; expected a Cmd, an array holding a command and its arguments, " +
+			"or an object holding such commands by scenario name")
+	}
+
+	obj := v.ToObject(mi.vu.Runtime())
+	scenarioWarmups := make(map[string]*Command, len(obj.Keys()))
+	for _, scenario := range obj.Keys() {
+		command, ok := mi.commandFromSpec(obj.Get(scenario))
+		if !ok {
+			return nil, nil, fmt.Errorf("invalid warmup of the %q scenario; expected a Cmd, "+
+				"or an array holding a command and its arguments", scenario)
+		}
+		scenarioWarmups[scenario] = command
+	}
+
+	return nil, scenarioWarmups, nil
+}
+
+// warmUp executes the warm-up commands configured for the VU, and for the scenario it runs, which
+// it didn't execute yet. It is called before the VU executes a command, so that the warm-up commands
+// are executed before the first one. The error a warm-up command failed with is returned for all
+// the commands the VU executes afterwards. Commands executed from the init context aren't warmed up.
+func (c *Command) warmUp(config *moduleConfig) error {
+	if c.warmups == nil || c.vu.State() == nil {
+		return nil
+	}
+
+	if err := c.warmups.run(c, "", config.warmup); err != nil {
+		return err
+	}
+
+	scenario := lib.GetScenarioState(c.vu.Context())
+	if scenario == nil {
+		return nil
+	}
+
+	return c.warmups.run(c, scenario.Name, config.scenarioWarmups[scenario.Name])
+}
+
+// run executes the warm-up command configured for scenario, unless it was already executed,
+// and returns the error it failed with, if any. It blocks the VU until the command completed.
+func (w *warmups) run(c *Command, scenario string, warmup *Command) error {
+	if warmup == nil {
+		return nil
+	}
+
+	if err, done := w.done[scenario]; done {
+		return err
+	}
+
+	err := warmup.runWarmup(c.vu.Context())
+	if err != nil {
+		c.logger().WithError(err).Errorf("the %s warm-up command failed", warmup.Name)
+		err = fmt.Errorf("the %s warm-up command failed: %w", warmup.Name, err)
+	}
+
+	if w.done == nil {
+		w.done = make(map[string]error)
+	}
+	w.done[scenario] = err
+
+	return err
+}
+
+// runWarmup executes the warm-up command with the default options, bound to ctx. The
+// returned error includes the end of the command's output, if it failed.
+func (c *Command) runWarmup(ctx context.Context) error {
+	opts, _ := parseExecOptions(c.vu.Runtime(), nil)
+	cmd, err := c.build(ctx, opts)
+	if err != nil {
+		return err
+	}
+	defer closeFiles(cmd.ExtraFiles...)
+
+	output, err := cmd.CombinedOutput()
+	if err != nil && len(output) > 0 {
+		return fmt.Errorf("%w\noutput:\n%s", err, stderrSnippet(output))
+	}
+
+	return err
+}
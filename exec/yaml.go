@@ -0,0 +1,68 @@
+package exec
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// parseYAML parses s as YAML. A single document is returned as is, while a
+// stream of several documents is returned as an array of them.
+func parseYAML(s string) (interface{}, error) {
+	dec := yaml.NewDecoder(strings.NewReader(s))
+
+	docs := make([]interface{}, 0, 1)
+	for {
+		var doc interface{}
+		err := dec.Decode(&doc)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse the output as YAML: %w", err)
+		}
+
+		docs = append(docs, normalizeYAML(doc))
+	}
+
+	switch len(docs) {
+	case 0:
+		return nil, nil
+	case 1:
+		return docs[0], nil
+	default:
+		return docs, nil
+	}
+}
+
+// normalizeYAML converts the values decoded from YAML into values which can be
+// exposed to JS: mappings with keys other than strings are keyed by the string
+// representation of their keys, and timestamps are formatted as RFC 3339.
+func normalizeYAML(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for key, value := range v {
+			v[key] = normalizeYAML(value)
+		}
+		return v
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			m[fmt.Sprint(key)] = normalizeYAML(value)
+		}
+		return m
+	case []interface{}:
+		for i, value := range v {
+			v[i] = normalizeYAML(value)
+		}
+		return v
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	default:
+		return v
+	}
+}
@@ -1,10 +1,13 @@
 module github.com/oleiade/xk6-exec
 
-go 1.19
+go 1.20
 
 require (
 	github.com/dop251/goja v0.0.0-20230427124612-428fc442ff5f
+	github.com/sirupsen/logrus v1.9.0
 	go.k6.io/k6 v0.44.1
+	golang.org/x/sys v0.6.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
@@ -20,9 +23,7 @@ require (
 	github.com/onsi/ginkgo v1.16.5 // indirect
 	github.com/onsi/gomega v1.27.6 // indirect
 	github.com/serenize/snaker v0.0.0-20201027110005-a7ad2135616e // indirect
-	github.com/sirupsen/logrus v1.9.0 // indirect
 	github.com/spf13/afero v1.1.2 // indirect
-	golang.org/x/sys v0.6.0 // indirect
 	golang.org/x/text v0.8.0 // indirect
 	golang.org/x/time v0.3.0 // indirect
 	gopkg.in/guregu/null.v3 v3.3.0 // indirect
@@ -7,4 +7,5 @@ import (
 
 func init() {
 	modules.Register("k6/x/cmd", new(exec.RootModule))
+	modules.Register("k6/x/exec/v2", exec.NewV2())
 }